@@ -1,55 +1,253 @@
 package main
 
 import (
+	"strings"
 	"time"
 
-	"github.com/spf13/viper"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
+	internalgrpc "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc"
+	internalhttp "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/http"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tlsconfig"
 )
 
 type Config struct {
-	Logger   LoggerConf
-	HTTP     HTTPConf
-	GRPC     GrpcConf
-	Storage  StorageConf
-	Postgres PostgresConf
+	Logger      config.LoggerConf
+	HTTP        HTTPConf
+	GRPC        GrpcConf
+	Storage     config.StorageConf
+	Postgres    config.PostgresConf
+	SQLite      config.SQLiteConf
+	InMemory    InMemoryConf
+	Cache       CacheConf
+	Idempotency IdempotencyConf
+	Quota       QuotaConf
+	RateLimit   RateLimitConf
+	Auth        config.AuthConf
+	Metrics     config.MetricsConf
+	Tracing     config.TracingConf
 }
 
-type LoggerConf struct {
-	Level string
+type HTTPConf struct {
+	Host string
+	Port string
+	// Timeout bounds reading the request, its headers and body.
+	Timeout time.Duration
+	// RequestTimeout is the deadline of the requests to the routes missing from RouteTimeouts.
+	RequestTimeout time.Duration
+	RouteTimeouts  []RouteTimeoutConf
+	TLS            TLSConf
 }
 
-type HTTPConf struct {
-	Host    string
-	Port    string
+type RouteTimeoutConf struct {
+	Route   string
 	Timeout time.Duration
 }
 
+func (c HTTPConf) Timeouts() internalhttp.Timeouts {
+	timeouts := internalhttp.Timeouts{
+		Default: c.RequestTimeout,
+		Routes:  make(map[string]time.Duration, len(c.RouteTimeouts)),
+	}
+	for _, route := range c.RouteTimeouts {
+		timeouts.Routes[route.Route] = route.Timeout
+	}
+
+	return timeouts
+}
+
 type GrpcConf struct {
 	Host string
 	Port string
+	// RequestTimeout is the deadline of the calls of the methods missing from MethodTimeouts.
+	RequestTimeout time.Duration
+	MethodTimeouts []MethodTimeoutConf
+	TLS            TLSConf
 }
 
-type StorageConf struct {
-	Type string
+type MethodTimeoutConf struct {
+	Method  string
+	Timeout time.Duration
 }
 
-type PostgresConf struct {
-	Dsn string
+func (c GrpcConf) Timeouts() internalgrpc.Timeouts {
+	timeouts := internalgrpc.Timeouts{
+		Default: c.RequestTimeout,
+		Methods: make(map[string]time.Duration, len(c.MethodTimeouts)),
+	}
+	for _, method := range c.MethodTimeouts {
+		timeouts.Methods[method.Method] = method.Timeout
+	}
+
+	return timeouts
 }
 
-func NewConfig(configFile string) (Config, error) {
-	v := viper.New()
-	v.SetConfigFile(configFile)
+// TLSConf is the TLS of a server, it is served in plain text when CertFile is empty.
+// The clients must present a certificate signed by the CA of ClientCAFile when it is set.
+type TLSConf struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	MinVersion   string
+}
 
-	var c Config
+func (c TLSConf) TLSConfig() tlsconfig.Config {
+	return tlsconfig.Config{
+		CertFile:     c.CertFile,
+		KeyFile:      c.KeyFile,
+		ClientCAFile: c.ClientCAFile,
+		MinVersion:   c.MinVersion,
+	}
+}
 
-	if err := v.ReadInConfig(); err != nil {
-		return c, err
+type CacheConf struct {
+	Enabled bool
+	Size    int
+	TTL     time.Duration
+}
+
+type IdempotencyConf struct {
+	TTL time.Duration
+}
+
+// QuotaConf caps the data stored by a user, zero leaves it unlimited.
+type QuotaConf struct {
+	MaxEventsPerUser int
+}
+
+// RateLimitConf is the token bucket of every user, or of every client address for the calls
+// without a user, per route class. The class with a zero rate is not limited. A batch takes
+// a write token for each of its operations.
+type RateLimitConf struct {
+	Enabled bool
+	Read    LimitConf
+	Write   LimitConf
+}
+
+type LimitConf struct {
+	Rate  float64
+	Burst int
+}
+
+func (c RateLimitConf) Limits() map[ratelimit.Class]ratelimit.Limit {
+	return map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassRead:  {Rate: c.Read.Rate, Burst: c.Read.Burst},
+		ratelimit.ClassWrite: {Rate: c.Write.Rate, Burst: c.Write.Burst},
 	}
+}
 
-	if err := v.Unmarshal(&c); err != nil {
+type InMemoryConf struct {
+	Dir              string
+	Fsync            string
+	SnapshotInterval time.Duration
+}
+
+// NewConfig reads the file with the environment overrides and validates the settings the API uses.
+func NewConfig(configFile string) (Config, error) {
+	var c Config
+	if err := config.Load(configFile, &c); err != nil {
 		return c, err
 	}
 
-	return c, nil
+	return c, c.Validate()
+}
+
+func (c Config) Validate() error {
+	errs := &config.Errors{}
+	c.Logger.Validate(errs)
+	c.HTTP.Validate(errs)
+	c.GRPC.Validate(errs)
+
+	c.Storage.Validate(errs, "inmemory", "postgres", "sqlite")
+	switch c.Storage.Type {
+	case "inmemory":
+		c.InMemory.Validate(errs)
+	case "postgres":
+		c.Postgres.Validate(errs)
+		c.Cache.Validate(errs)
+	case "sqlite":
+		c.SQLite.Validate(errs)
+	}
+
+	errs.Duration("idempotency.ttl", c.Idempotency.TTL, false)
+	errs.NotNegative("quota.maxEventsPerUser", float64(c.Quota.MaxEventsPerUser))
+	c.RateLimit.Validate(errs)
+	c.Auth.Validate(errs)
+	c.Metrics.Validate(errs)
+	c.Tracing.Validate(errs)
+
+	return errs.Err()
+}
+
+func (c HTTPConf) Validate(errs *config.Errors) {
+	errs.Host("http.host", c.Host)
+	errs.Port("http.port", c.Port, true)
+	errs.Duration("http.timeout", c.Timeout, true)
+	errs.Duration("http.requestTimeout", c.RequestTimeout, false)
+	for _, route := range c.RouteTimeouts {
+		if !strings.HasPrefix(route.Route, "/") {
+			errs.Add("http.routeTimeouts", "route %q must start with /", route.Route)
+		}
+		errs.Duration("http.routeTimeouts."+route.Route, route.Timeout, false)
+	}
+	c.TLS.Validate(errs, "http.tls")
+}
+
+func (c GrpcConf) Validate(errs *config.Errors) {
+	errs.Host("grpc.host", c.Host)
+	errs.Port("grpc.port", c.Port, true)
+	errs.Duration("grpc.requestTimeout", c.RequestTimeout, false)
+	for _, method := range c.MethodTimeouts {
+		if !strings.HasPrefix(method.Method, "/") {
+			errs.Add("grpc.methodTimeouts", "method %q must be a full name like /calendar.Calendar/Batch",
+				method.Method)
+		}
+		errs.Duration("grpc.methodTimeouts."+method.Method, method.Timeout, false)
+	}
+	c.TLS.Validate(errs, "grpc.tls")
+}
+
+func (c TLSConf) Validate(errs *config.Errors, key string) {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs.Add(key, "certFile and keyFile must be set together")
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		errs.Add(key+".clientCAFile", "needs certFile and keyFile")
+	}
+	errs.OneOf(key+".minVersion", c.MinVersion, "", "1.2", "1.3")
+}
+
+func (c InMemoryConf) Validate(errs *config.Errors) {
+	if c.Dir == "" {
+		return
+	}
+	errs.OneOf("inmemory.fsync", c.Fsync,
+		string(memorystorage.FsyncAlways), string(memorystorage.FsyncEverySec), string(memorystorage.FsyncNever))
+	errs.Duration("inmemory.snapshotInterval", c.SnapshotInterval, true)
+}
+
+func (c CacheConf) Validate(errs *config.Errors) {
+	if !c.Enabled {
+		return
+	}
+	if c.Size <= 0 {
+		errs.Add("cache.size", "%d must be positive", c.Size)
+	}
+	errs.Duration("cache.ttl", c.TTL, true)
+}
+
+func (c RateLimitConf) Validate(errs *config.Errors) {
+	if !c.Enabled {
+		return
+	}
+	for _, class := range []struct {
+		key   string
+		limit LimitConf
+	}{{"ratelimit.read", c.Read}, {"ratelimit.write", c.Write}} {
+		errs.NotNegative(class.key+".rate", class.limit.Rate)
+		if class.limit.Rate > 0 && class.limit.Burst < 1 {
+			errs.Add(class.key+".burst", "%d must be at least 1 when the rate is set", class.limit.Burst)
+		}
+	}
 }
@@ -2,8 +2,12 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/auth"
+	internalconfig "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
 )
 
 func TestNewConfigSuccess(t *testing.T) {
@@ -13,6 +17,39 @@ func TestNewConfigSuccess(t *testing.T) {
 	require.Equal(t, "INFO", config.Logger.Level)
 	require.Equal(t, "localhost", config.HTTP.Host)
 	require.Equal(t, "8080", config.HTTP.Port)
+	require.Equal(t, 30*time.Second, config.HTTP.Timeout)
+
+	require.True(t, config.Auth.Enabled)
+	require.Equal(t, []string{"/isready"}, config.Auth.PublicRoutes)
+	require.Equal(t, auth.Config{
+		Tokens: []auth.Token{{Token: "token-1", UserID: 1}},
+		JWT:    auth.JWTConfig{HMACSecret: "hmac-secret", Leeway: 30 * time.Second},
+	}, config.Auth.AuthConfig())
+}
+
+func TestNewConfigTimeouts(t *testing.T) {
+	config, err := NewConfig("../../configs/config.toml")
+	require.NoError(t, err)
+
+	httpTimeouts := config.HTTP.Timeouts()
+	require.Equal(t, 10*time.Second, httpTimeouts.Default)
+	require.Equal(t, map[string]time.Duration{"/v1/events/": time.Minute}, httpTimeouts.Routes)
+
+	grpcTimeouts := config.GRPC.Timeouts()
+	require.Equal(t, 10*time.Second, grpcTimeouts.Default)
+	require.Equal(t, map[string]time.Duration{"/calendar.Calendar/Batch": time.Minute}, grpcTimeouts.Methods)
+}
+
+func TestNewConfigRateLimit(t *testing.T) {
+	config, err := NewConfig("../../configs/config.toml")
+	require.NoError(t, err)
+
+	require.True(t, config.RateLimit.Enabled)
+	require.Equal(t, map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassRead:  {Rate: 100, Burst: 200},
+		ratelimit.ClassWrite: {Rate: 10, Burst: 50},
+	}, config.RateLimit.Limits())
+	require.Equal(t, 10000, config.Quota.MaxEventsPerUser)
 }
 
 func TestNewConfigError(t *testing.T) {
@@ -22,7 +59,57 @@ func TestNewConfigError(t *testing.T) {
 	})
 
 	t.Run("file invalid format", func(t *testing.T) {
-		_, err := NewConfig("./test/config_test_fail.toml")
+		_, err := NewConfig("./test/config_test_invalid.toml")
 		require.NotNil(t, err)
 	})
 }
+
+func TestNewConfigEnv(t *testing.T) {
+	t.Setenv("CALENDAR_HTTP_PORT", "8081")
+	t.Setenv("CALENDAR_STORAGE_TYPE", "sqlite")
+	t.Setenv("CALENDAR_SQLITE_PATH", "/var/lib/calendar/calendar.db")
+	t.Setenv("CALENDAR_AUTH_PUBLICROUTES", "/livez,/readyz")
+
+	config, err := NewConfig("./test/config_test_ok.toml")
+	require.NoError(t, err)
+	require.Equal(t, "8081", config.HTTP.Port)
+	require.Equal(t, "sqlite", config.Storage.Type)
+	require.Equal(t, "/var/lib/calendar/calendar.db", config.SQLite.Path)
+	require.Equal(t, []string{"/livez", "/readyz"}, config.Auth.PublicRoutes)
+}
+
+func TestNewConfigValidation(t *testing.T) {
+	t.Setenv("CALENDAR_LOGGER_LEVEL", "TRACE")
+
+	_, err := NewConfig("../../configs/config.toml")
+	require.ErrorIs(t, err, internalconfig.ErrInvalid)
+	require.Contains(t, err.Error(), `logger.level: "TRACE" is not one of "DEBUG", "INFO", "WARN", "ERROR"`)
+
+	t.Setenv("CALENDAR_LOGGER_LEVEL", "INFO")
+	config, err := NewConfig("../../configs/config.toml")
+	require.NoError(t, err)
+
+	config.HTTP.Timeout = 30
+	config.GRPC.Port = ""
+	config.GRPC.TLS.CertFile = "server.crt"
+	config.Cache = CacheConf{Enabled: true}
+	config.Auth.Enabled = true
+	config.Auth.Tokens = []internalconfig.TokenConf{{Token: "token-1"}}
+
+	err = config.Validate()
+	require.ErrorIs(t, err, internalconfig.ErrInvalid)
+	require.Equal(t, `invalid config:
+	http.timeout: 30 is read as nanoseconds, set a duration with a unit like "30s"
+	grpc.port: must be set
+	grpc.tls: certFile and keyFile must be set together
+	cache.size: 0 must be positive
+	cache.ttl: must be set to a duration like "30s"
+	auth.tokens: token 1 must have a token and a positive userId`, err.Error())
+
+	// The settings of the storages which are not used are not checked.
+	config, err = NewConfig("../../configs/config.toml")
+	require.NoError(t, err)
+	config.SQLite.Path = ""
+	config.InMemory.Fsync = "sometimes"
+	require.NoError(t, config.Validate())
+}
@@ -2,21 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/auth"
+	internalconfig "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/health"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
 	internalgrpc "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/http"
-	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
-	sqlstorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
 )
 
+// shutdownTimeout bounds the draining of the servers on exit, it is below the 30s grace period
+// of Kubernetes, which kills the pod after it.
+const shutdownTimeout = 10 * time.Second
+
 var configFile string
 
 func init() {
@@ -32,50 +44,63 @@ func main() {
 	}
 
 	config, err := NewConfig(configFile)
+	if flag.Arg(0) == "config" {
+		os.Exit(internalconfig.Command(os.Stdout, flag.Args()[1:], configFile, err))
+	}
 	if err != nil {
 		fmt.Printf("error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), config, flag.Args()[1:]); err != nil {
+			fmt.Printf("error migrating database: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
-	logg, err := logger.New(config.Logger.Level, os.Stderr)
+	logg, err := logger.NewWithConfig(config.Logger.LoggerConfig(), os.Stderr)
 	if err != nil {
 		fmt.Printf("error creating logger: %v\n", err)
 		return
 	}
 
-	logg.Debug("created logger", logg)
+	logg.Debug("created logger", "level", config.Logger.Level, "format", config.Logger.Format)
 
-	var storage app.Storage
-	switch config.Storage.Type {
-	case "inmemory":
-		storage = memorystorage.New()
-	case "postgres":
-		sqlStorage := sqlstorage.New(config.Postgres.Dsn)
-		ctx := context.Background()
-		err = sqlStorage.Connect(ctx)
-		if err != nil {
-			fmt.Printf("error connecting to database: %v\n", err)
-			return
-		}
-		defer sqlStorage.Close(ctx)
-		storage = sqlStorage
-	default:
-		fmt.Print("error creating storage: unknown storage type")
+	shutdownTracing, err := tracing.Setup(context.Background(), "calendar", config.Tracing.TracingConfig())
+	if err != nil {
+		logg.Error("error setting up tracing", "error", err)
 		return
 	}
-	logg.Debug("create storage", storage)
+	defer shutdownTracing(context.Background())
+
+	registry := metrics.NewRegistry()
 
-	calendar := app.New(logg, storage)
-	logg.Debug("create calendar app", calendar)
+	storage, closeStorage, err := newStorage(context.Background(), config, logg, registry)
+	if err != nil {
+		logg.Error("error creating storage", "error", err)
+		return
+	}
+	defer closeStorage(context.Background())
+	logg.Debug("created storage", "type", config.Storage.Type)
+
+	calendar := app.NewWithConfig(logg, storage, app.Config{
+		IdempotencyTTL:   config.Idempotency.TTL,
+		MaxEventsPerUser: config.Quota.MaxEventsPerUser,
+	})
+	logg.Debug("created calendar app")
 
 	httpServer := internalhttp.NewServer(
 		logg,
 		calendar,
 		config.HTTP.Host,
 		config.HTTP.Port,
-		time.Duration(int64(config.HTTP.Timeout)*int64(time.Millisecond)),
+		config.HTTP.Timeout,
 	)
-	logg.Debug("create http server", httpServer)
+	httpServer.SetMetrics(metrics.NewHTTPMetrics(registry))
+	httpServer.SetTimeouts(config.HTTP.Timeouts())
+	logg.Debug("created http server", "host", config.HTTP.Host, "port", config.HTTP.Port)
 
 	grpcServer := internalgrpc.NewServer(
 		logg,
@@ -83,42 +108,153 @@ func main() {
 		config.GRPC.Host,
 		config.GRPC.Port,
 	)
-	logg.Debug("create grpc server", grpcServer)
+	grpcServer.SetMetrics(metrics.NewGRPCMetrics(registry))
+	grpcServer.SetTimeouts(config.GRPC.Timeouts())
+	logg.Debug("created grpc server", "host", config.GRPC.Host, "port", config.GRPC.Port)
+
+	checker := health.New(logg)
+	checker.Add("storage", storage.Ping)
+	httpServer.SetHealth(checker)
+	grpcServer.SetHealth(checker)
+
+	if config.Auth.Enabled {
+		authenticator, err := auth.New(config.Auth.AuthConfig())
+		if err != nil {
+			logg.Error("error creating authenticator", "error", err)
+			return
+		}
+		httpServer.Use(internalhttp.AuthMiddleware(authenticator, logg, config.Auth.PublicRoutes))
+		grpcServer.Use(internalgrpc.AuthMiddleware(authenticator, logg, config.Auth.PublicMethods))
+		grpcServer.UseStream(internalgrpc.StreamAuthMiddleware(authenticator, logg, config.Auth.PublicMethods))
+		logg.Debug("enabled authentication")
+	}
+
+	// The limits are applied after the authentication to count the calls by the user.
+	if config.RateLimit.Enabled {
+		limiter := ratelimit.New(config.RateLimit.Limits())
+		httpServer.Use(internalhttp.RateLimitMiddleware(limiter))
+		grpcServer.Use(internalgrpc.RateLimitMiddleware(limiter))
+		grpcServer.UseStream(internalgrpc.StreamRateLimitMiddleware(limiter))
+		logg.Debug("enabled rate limiting")
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	var certificates []*tlsconfig.Server
+	for _, server := range []struct {
+		name   string
+		config tlsconfig.Config
+		setTLS func(tlsConfig *tls.Config)
+	}{
+		{name: "http", config: config.HTTP.TLS.TLSConfig(), setTLS: httpServer.SetTLS},
+		{name: "grpc", config: config.GRPC.TLS.TLSConfig(), setTLS: grpcServer.SetTLS},
+	} {
+		if !server.config.Enabled() {
+			continue
+		}
+
+		certificate, err := tlsconfig.NewServer(server.config)
+		if err != nil {
+			logg.Error("error loading tls certificate", "server", server.name, "error", err)
+			return
+		}
+		server.setTLS(certificate.TLSConfig())
+		certificates = append(certificates, certificate)
+		logg.Debug("enabled tls", "server", server.name, "mtls", server.config.ClientCAFile != "")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	running := config
+	internalconfig.OnReload(ctx, func() {
+		running = reloadConfig(logg, running)
+	})
+
+	for _, certificate := range certificates {
+		certificate := certificate
+		go func() {
+			if err := certificate.Watch(ctx, logg); err != nil {
+				logg.Error("error watching tls certificate", "error", err)
+			}
+		}()
+	}
+
 	go func() {
 		if err := httpServer.Start(ctx); err != nil {
-			logg.Error("failed to start http server: " + err.Error())
+			logg.Error("failed to start http server", "error", err)
 			cancel()
 			return
 		}
 
-		logg.Info("http server started...")
+		logg.Info("http server stopped")
 	}()
 
 	go func() {
 		if err := grpcServer.Start(ctx); err != nil {
-			logg.Error("failed to start grpc server: " + err.Error())
+			logg.Error("failed to start grpc server", "error", err)
 			cancel()
 			return
 		}
 
-		logg.Info("grpc server started...")
+		logg.Info("grpc server stopped")
 	}()
 
+	metricsServer := metrics.NewServer(registry, config.Metrics.Host, config.Metrics.CalendarPort)
+	if config.Metrics.CalendarPort != "" {
+		go func() {
+			if err := metricsServer.Start(ctx); err != nil {
+				logg.Error("failed to start metrics server", "error", err)
+			}
+		}()
+	}
+
 	<-ctx.Done()
 
-	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), time.Second*3)
+	// The servers drain the in-flight requests at the same time within the shared deadline.
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer timeoutCancel()
 
-	if err := httpServer.Stop(timeoutCtx); err != nil {
-		logg.Error("failed to stop http server: " + err.Error())
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := httpServer.Stop(timeoutCtx); err != nil {
+			logg.Error("failed to stop http server", "error", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := grpcServer.Stop(timeoutCtx); err != nil {
+			logg.Error("failed to stop grpc server", "error", err)
+		}
+	}()
+	wg.Wait()
+
+	if config.Metrics.CalendarPort != "" {
+		if err := metricsServer.Stop(timeoutCtx); err != nil {
+			logg.Error("failed to stop metrics server", "error", err)
+		}
+	}
+}
+
+// reloadConfig applies the log level of the file and returns the config the API runs with.
+// The other settings are kept, their changes are reported as needing a restart.
+func reloadConfig(logg *logger.Logger, current Config) Config {
+	reloaded, err := NewConfig(configFile)
+	if err != nil {
+		logg.Error("error reloading config, keeping the current one", "error", err)
+		return current
+	}
+
+	if err := logg.SetLevel(reloaded.Logger.Level); err != nil {
+		logg.Error("error setting logger level", "error", err)
+		return current
 	}
+	logg.Info("reloaded config", "level", reloaded.Logger.Level)
 
-	if err := grpcServer.Stop(timeoutCtx); err != nil {
-		logg.Error("failed to stop grpc server: " + err.Error())
+	current.Logger.Level = reloaded.Logger.Level
+	if !reflect.DeepEqual(current, reloaded) {
+		logg.Warn("config changes other than logger.level need a restart")
 	}
+
+	return current
 }
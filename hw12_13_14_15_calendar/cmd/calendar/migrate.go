@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	sqlstorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sql"
+	sqlitestorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sqlite"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/migrations"
+)
+
+var (
+	ErrUnknownMigrateCommand = errors.New(
+		"unknown migrate command, expected up, down [steps], status or baseline <version>",
+	)
+	ErrMigrateStorageType = errors.New("migrations are supported for postgres and sqlite storages only")
+)
+
+type migrator interface {
+	Connect(ctx context.Context) error
+	Close() error
+	Up(ctx context.Context) ([]migrations.Migration, error)
+	Down(ctx context.Context, steps int) ([]migrations.Migration, error)
+	Status(ctx context.Context) ([]migrations.Status, error)
+	Baseline(ctx context.Context, version int) ([]migrations.Migration, error)
+}
+
+func newMigrator(config Config) (migrator, error) {
+	switch config.Storage.Type {
+	case "postgres":
+		return sqlstorage.NewMigrator(config.Postgres.Dsn)
+	case "sqlite":
+		return sqlitestorage.NewMigrator(config.SQLite.Path)
+	default:
+		return nil, ErrMigrateStorageType
+	}
+}
+
+// runMigrate handles `calendar migrate up|down [steps]|status|baseline <version>`.
+func runMigrate(ctx context.Context, config Config, args []string) error {
+	if len(args) == 0 {
+		return ErrUnknownMigrateCommand
+	}
+
+	migrator, err := newMigrator(config)
+	if err != nil {
+		return err
+	}
+
+	err = migrator.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %s\n", migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid steps count: %s", args[1])
+			}
+		}
+
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("rolled back %s\n", migration.Name)
+		}
+		return err
+	case "baseline":
+		if len(args) != 2 {
+			return ErrUnknownMigrateCommand
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+
+		recorded, err := migrator.Baseline(ctx, version)
+		for _, migration := range recorded {
+			fmt.Printf("recorded %s as applied\n", migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return ErrUnknownMigrateCommand
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	cachestorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/cache"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+	metricsstorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/metrics"
+	sqlstorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sql"
+	sqlitestorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sqlite"
+)
+
+var errUnknownStorageType = errors.New("unknown storage type")
+
+// newStorage opens the configured storage and returns it with the function closing it.
+// The queries of the storage are reported to the registry.
+func newStorage(
+	ctx context.Context,
+	config Config,
+	logg *logger.Logger,
+	registry prometheus.Registerer,
+) (app.Storage, func(context.Context) error, error) {
+	storageMetrics := metrics.NewStorageMetrics(registry)
+
+	switch config.Storage.Type {
+	case "inmemory":
+		if config.InMemory.Dir == "" {
+			noClose := func(context.Context) error { return nil }
+			return metricsstorage.New(memorystorage.New(), storageMetrics), noClose, nil
+		}
+
+		memoryStorage := memorystorage.NewPersistent(logg, memorystorage.PersistenceConfig{
+			Dir:              config.InMemory.Dir,
+			Fsync:            memorystorage.FsyncPolicy(config.InMemory.Fsync),
+			SnapshotInterval: config.InMemory.SnapshotInterval,
+		})
+		if err := memoryStorage.Open(ctx); err != nil {
+			return nil, nil, fmt.Errorf("error opening storage: %w", err)
+		}
+
+		return metricsstorage.New(memoryStorage, storageMetrics), memoryStorage.Close, nil
+	case "postgres":
+		sqlStorage := sqlstorage.NewWithConfig(config.Postgres.StorageConfig())
+		if err := sqlStorage.Connect(ctx); err != nil {
+			return nil, nil, fmt.Errorf("error connecting to database: %w", err)
+		}
+
+		var storage app.Storage = metricsstorage.New(sqlStorage, storageMetrics)
+		if !config.Cache.Enabled {
+			return storage, sqlStorage.Close, nil
+		}
+
+		cachedStorage, err := cachestorage.New(storage, cachestorage.Config{
+			Size: config.Cache.Size,
+			TTL:  config.Cache.TTL,
+		})
+		if err != nil {
+			sqlStorage.Close(ctx)
+			return nil, nil, fmt.Errorf("error creating storage cache: %w", err)
+		}
+		metrics.RegisterCacheStats(registry, cachedStorage.Stats)
+
+		return cachedStorage, sqlStorage.Close, nil
+	case "sqlite":
+		sqliteStorage := sqlitestorage.New(config.SQLite.Path)
+		if err := sqliteStorage.Connect(ctx); err != nil {
+			return nil, nil, fmt.Errorf("error connecting to database: %w", err)
+		}
+
+		return metricsstorage.New(sqliteStorage, storageMetrics), sqliteStorage.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("error creating storage: %w", errUnknownStorageType)
+	}
+}
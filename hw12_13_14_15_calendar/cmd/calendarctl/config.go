@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tlsconfig"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// Config is where calendarctl finds the services and how it prints the results.
+type Config struct {
+	Server    ServerConf
+	Scheduler SchedulerConf
+	Output    OutputConf
+}
+
+// ServerConf is the gRPC address of the calendar API. The token is sent as the bearer token
+// of every call, it is an API token or a JWT of the user.
+type ServerConf struct {
+	Address string
+	Token   string
+	Timeout time.Duration
+	TLS     TLSConf
+}
+
+// TLSConf is the TLS of the connection to the API, it is on when any of the settings is set.
+// The server certificate is checked against the system roots when CAFile is empty.
+type TLSConf struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	MinVersion string
+}
+
+func (c TLSConf) Enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.ServerName != "" || c.MinVersion != ""
+}
+
+func (c TLSConf) ClientConfig() tlsconfig.ClientConfig {
+	return tlsconfig.ClientConfig{
+		CAFile:     c.CAFile,
+		CertFile:   c.CertFile,
+		KeyFile:    c.KeyFile,
+		ServerName: c.ServerName,
+		MinVersion: c.MinVersion,
+	}
+}
+
+// SchedulerConf is the admin address of the scheduler, the port of metrics.schedulerPort,
+// where the jobs are triggered.
+type SchedulerConf struct {
+	Address string
+}
+
+type OutputConf struct {
+	Format string
+}
+
+func defaultConfig() Config {
+	return Config{
+		Server:    ServerConf{Address: "localhost:50051", Timeout: 10 * time.Second},
+		Scheduler: SchedulerConf{Address: "localhost:9101"},
+		Output:    OutputConf{Format: outputTable},
+	}
+}
+
+// defaultConfigFile is calendarctl/config.toml in the user config directory,
+// e.g. ~/.config/calendarctl/config.toml on Linux.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "calendarctl", "config.toml")
+}
+
+// NewConfig reads the file over the defaults with the environment overrides. The missing file
+// is skipped unless it was asked for, so calendarctl runs against a local server without one.
+func NewConfig(configFile string, required bool) (Config, error) {
+	c := defaultConfig()
+
+	if !required {
+		if _, err := os.Stat(configFile); errors.Is(err, fs.ErrNotExist) {
+			configFile = ""
+		}
+	}
+
+	if err := config.Load(configFile, &c); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+func (c Config) Validate() error {
+	errs := &config.Errors{}
+	errs.Required("server.address", c.Server.Address)
+	if _, _, err := net.SplitHostPort(c.Server.Address); c.Server.Address != "" && err != nil {
+		errs.Add("server.address", "%q must be a host:port", c.Server.Address)
+	}
+	errs.Duration("server.timeout", c.Server.Timeout, true)
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs.Add("server.tls", "certFile and keyFile must be set together")
+	}
+	errs.OneOf("server.tls.minVersion", c.Server.TLS.MinVersion, "", "1.2", "1.3")
+	errs.OneOf("output.format", c.Output.Format, outputTable, outputJSON)
+
+	return errs.Err()
+}
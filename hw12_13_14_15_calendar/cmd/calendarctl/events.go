@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var ErrMissingFlag = errors.New("missing flag")
+
+// eventFlags are the fields of the event set by create and update.
+type eventFlags struct {
+	id           string
+	title        string
+	description  string
+	location     string
+	url          string
+	calendarID   string
+	start        string
+	end          string
+	notifyBefore time.Duration
+	tags         []string
+}
+
+func (f *eventFlags) register(flags *pflag.FlagSet) {
+	flags.StringVar(&f.title, "title", "", "Title of the event")
+	flags.StringVar(&f.description, "description", "", "Description of the event")
+	flags.StringVar(&f.location, "location", "", "Location of the event")
+	flags.StringVar(&f.url, "url", "", "URL of the event")
+	flags.StringVar(&f.calendarID, "calendar", "", "Calendar of the event, the default calendar when empty")
+	flags.StringVar(&f.start, "start", "", "Start of the event, e.g. 2024-06-03 15:04 in local time or RFC 3339")
+	flags.StringVar(&f.end, "end", "", "End of the event, in the format of --start")
+	flags.DurationVar(&f.notifyBefore, "notify-before", 0, "How long before the start to notify, e.g. 1h")
+	flags.StringSliceVar(&f.tags, "tag", nil, "Tag of the event, repeated or comma-separated")
+}
+
+// apply sets the fields of the flags set on the command line, the others are kept.
+func (f *eventFlags) apply(flags *pflag.FlagSet, event *storage.Event) error {
+	if flags.Changed("title") {
+		event.Title = f.title
+	}
+	if flags.Changed("description") {
+		event.Description = f.description
+	}
+	if flags.Changed("location") {
+		event.Location = f.location
+	}
+	if flags.Changed("url") {
+		event.URL = f.url
+	}
+	if flags.Changed("calendar") {
+		event.CalendarID = f.calendarID
+	}
+	if flags.Changed("start") {
+		start, err := parseTime(f.start)
+		if err != nil {
+			return fmt.Errorf("--start: %w", err)
+		}
+		event.StartDate = start
+	}
+	if flags.Changed("end") {
+		end, err := parseTime(f.end)
+		if err != nil {
+			return fmt.Errorf("--end: %w", err)
+		}
+		event.EndDate = end
+	}
+	if flags.Changed("notify-before") {
+		event.NotifyBefore = f.notifyBefore
+	}
+	if flags.Changed("tag") {
+		event.Tags = f.tags
+	}
+
+	return nil
+}
+
+func newCreateCommand(c *ctl) *cobra.Command {
+	f := &eventFlags{}
+	var idempotencyKey string
+
+	cmd := &cobra.Command{
+		Use:   "create --title TITLE --start TIME --end TIME",
+		Short: "Create an event and print its ID",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			for _, name := range []string{"title", "start", "end"} {
+				if !cmd.Flags().Changed(name) {
+					return fmt.Errorf("%w --%s", ErrMissingFlag, name)
+				}
+			}
+
+			event := storage.Event{ID: f.id}
+			if err := f.apply(cmd.Flags(), &event); err != nil {
+				return err
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := c.callContext(cmd)
+			defer cancel()
+			if idempotencyKey != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "idempotency-key", idempotencyKey)
+			}
+
+			result, err := client.Create(ctx, createRequest(event))
+			if err != nil {
+				return err
+			}
+
+			return c.printID(cmd.OutOrStdout(), result.GetId())
+		},
+	}
+
+	f.register(cmd.Flags())
+	cmd.Flags().StringVar(&f.id, "id", "", "ID of the event, a UUID generated by the server when empty")
+	cmd.Flags().StringVar(&idempotencyKey, "idempotency-key", "",
+		"Key making the retries of the create return the first event rather than a new one")
+
+	return cmd
+}
+
+// newUpdateCommand changes the fields of the flags only, the API replaces the whole event
+// so the event is read first.
+func newUpdateCommand(c *ctl) *cobra.Command {
+	f := &eventFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "update ID",
+		Short: "Change the fields of an event set by the flags",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := c.callContext(cmd)
+			defer cancel()
+
+			current, err := client.Get(ctx, &calendarpb.GetRequest{Id: args[0]})
+			if err != nil {
+				return err
+			}
+
+			event := toEvent(current)
+			if err := f.apply(cmd.Flags(), &event); err != nil {
+				return err
+			}
+
+			_, err = client.Update(ctx, &calendarpb.UpdateRequest{
+				EventId:      event.ID,
+				Title:        event.Title,
+				StartDt:      timestamppb.New(event.StartDate),
+				EndDt:        timestamppb.New(event.EndDate),
+				NotifyBefore: durationpb.New(event.NotifyBefore),
+				CalendarId:   event.CalendarID,
+				Tags:         event.Tags,
+				Description:  event.Description,
+				Location:     event.Location,
+				Url:          event.URL,
+			})
+			return err
+		},
+	}
+
+	f.register(cmd.Flags())
+
+	return cmd
+}
+
+func newDeleteCommand(c *ctl) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID...",
+		Short: "Delete events",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			for _, id := range args {
+				ctx, cancel := c.callContext(cmd)
+				_, err := client.Delete(ctx, &calendarpb.DeleteRequest{EventId: id})
+				cancel()
+				if err != nil {
+					return fmt.Errorf("delete %s: %w", id, err)
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+func newGetCommand(c *ctl) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get ID",
+		Short: "Print an event",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := c.callContext(cmd)
+			defer cancel()
+
+			result, err := client.Get(ctx, &calendarpb.GetRequest{Id: args[0]})
+			if err != nil {
+				return err
+			}
+
+			return c.printEvent(cmd.OutOrStdout(), toEvent(result))
+		},
+	}
+}
+
+func createRequest(event storage.Event) *calendarpb.CreateRequest {
+	return &calendarpb.CreateRequest{
+		Id:           event.ID,
+		Title:        event.Title,
+		StartDt:      timestamppb.New(event.StartDate),
+		EndDt:        timestamppb.New(event.EndDate),
+		NotifyBefore: durationpb.New(event.NotifyBefore),
+		CalendarId:   event.CalendarID,
+		Tags:         event.Tags,
+		Description:  event.Description,
+		Location:     event.Location,
+		Url:          event.URL,
+	}
+}
+
+func toEvent(r *calendarpb.GetResult) storage.Event {
+	event := storage.Event{
+		ID:           r.GetId(),
+		CalendarID:   r.GetCalendarId(),
+		Title:        r.GetTitle(),
+		Description:  r.GetDescription(),
+		Location:     r.GetLocation(),
+		URL:          r.GetUrl(),
+		StartDate:    r.GetStartDt().AsTime().Local(),
+		EndDate:      r.GetEndDt().AsTime().Local(),
+		CreatorID:    int(r.GetCreatorId()),
+		NotifyBefore: r.GetNotifyBefore().AsDuration(),
+		Notified:     r.GetNotified(),
+		Tags:         r.GetTags(),
+	}
+	if r.GetCreatedAt() != nil {
+		event.CreatedAt = r.GetCreatedAt().AsTime().Local()
+	}
+	if r.GetUpdatedAt() != nil {
+		event.UpdatedAt = r.GetUpdatedAt().AsTime().Local()
+	}
+
+	return event
+}
+
+func toEvents(list []*calendarpb.GetResult) []storage.Event {
+	events := make([]storage.Event, 0, len(list))
+	for _, r := range list {
+		events = append(events, toEvent(r))
+	}
+
+	return events
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var ErrJobNotTriggered = errors.New("job not triggered")
+
+// schedulerJobs are the jobs served by the scheduler on POST /jobs/{name} of its admin port.
+var schedulerJobs = []string{"notify", "clean"}
+
+func newJobsCommand(c *ctl) *cobra.Command {
+	jobs := &cobra.Command{
+		Use:   "jobs",
+		Short: "Manage the scheduler jobs",
+	}
+
+	jobs.AddCommand(&cobra.Command{
+		Use:   "run notify|clean",
+		Short: "Run a scheduler job now rather than on its schedule",
+		Long: `Run a scheduler job now rather than on its schedule: notify sends the notifications
+of the events due, clean deletes the events older than a year. The job is queued and
+runs in the background, see the scheduler logs for its outcome.
+
+The scheduler serves the jobs with scheduler.jobTriggers set and checks server.token
+against its [auth] tokens like the API does. The user of the token must be listed
+in scheduler.jobAdmins.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: schedulerJobs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := c.callContext(cmd)
+			defer cancel()
+
+			status, err := triggerJob(ctx, c.config.Scheduler.Address, c.config.Server.Token, args[0])
+			if err != nil {
+				return err
+			}
+
+			if c.config.Output.Format == outputJSON {
+				return writeJSON(cmd.OutOrStdout(), struct {
+					Job    string `json:"job"`
+					Status string `json:"status"`
+				}{Job: args[0], Status: status})
+			}
+
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "job %s %s\n", args[0], status)
+			return err
+		},
+	})
+
+	return jobs
+}
+
+// triggerJob asks the scheduler at the admin address to run the job as the user of the token
+// and returns the status it answered.
+func triggerJob(ctx context.Context, address, token, job string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("%w: scheduler.address is not set", ErrJobNotTriggered)
+	}
+	if token == "" {
+		return "", fmt.Errorf("%w: server.token is not set", ErrJobNotTriggered)
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address+"/jobs/"+job, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusForbidden:
+		return "", fmt.Errorf("%w: scheduler answered %s, the user of server.token is not in scheduler.jobAdmins",
+			ErrJobNotTriggered, resp.Status)
+	case http.StatusNotFound:
+		return "", fmt.Errorf("%w: scheduler answered %s, the job is unknown or scheduler.jobTriggers is not set",
+			ErrJobNotTriggered, resp.Status)
+	default:
+		return "", fmt.Errorf("%w: scheduler answered %s", ErrJobNotTriggered, resp.Status)
+	}
+
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error reading scheduler answer: %w", err)
+	}
+
+	return result.Status, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	periodDay   = "day"
+	periodWeek  = "week"
+	periodMonth = "month"
+)
+
+var ErrInvalidTime = errors.New("invalid time")
+
+// localLayouts are the layouts of the times read in the local time zone, RFC 3339 is read too.
+var localLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", time.DateOnly}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w %q, expected e.g. 2024-06-03, 2024-06-03 15:04 or RFC 3339",
+		ErrInvalidTime, value)
+}
+
+// periodStart returns the start of the period containing the day: the day itself,
+// the Monday of its week or the first day of its month.
+func periodStart(period string, day time.Time) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+
+	switch period {
+	case periodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case periodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+func newListCommand(c *ctl) *cobra.Command {
+	var filter storage.EventFilter
+
+	cmd := &cobra.Command{
+		Use:   "list day|week|month [DATE]",
+		Short: "List the events of a day, a week or a month",
+		Long: `List the events starting on the day, in the 7 days or in the month from the date.
+Without a date the current day, the Monday of the current week or the first day
+of the current month is taken.`,
+		Args:      cobra.RangeArgs(1, 2),
+		ValidArgs: []string{periodDay, periodWeek, periodMonth},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			period := args[0]
+			if period != periodDay && period != periodWeek && period != periodMonth {
+				return fmt.Errorf("unknown period %q, expected day, week or month", period)
+			}
+
+			start := periodStart(period, time.Now())
+			if len(args) == 2 {
+				date, err := parseTime(args[1])
+				if err != nil {
+					return err
+				}
+				start = date
+			}
+			// The server takes the calendar date of the start in UTC.
+			start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := c.callContext(cmd)
+			defer cancel()
+
+			var list []*calendarpb.GetResult
+			switch period {
+			case periodDay:
+				result, err := client.GetEventsListOnDate(ctx, &calendarpb.GetEventsListOnDateRequest{
+					DayDate:     timestamppb.New(start),
+					CalendarIds: filter.CalendarIDs,
+					Tags:        filter.Tags,
+				})
+				if err != nil {
+					return err
+				}
+				list = result.GetList()
+			case periodWeek:
+				result, err := client.GetEventsListOnWeek(ctx, &calendarpb.GetEventsListOnWeekRequest{
+					WeekStartDate: timestamppb.New(start),
+					CalendarIds:   filter.CalendarIDs,
+					Tags:          filter.Tags,
+				})
+				if err != nil {
+					return err
+				}
+				list = result.GetList()
+			case periodMonth:
+				result, err := client.GetEventsListOnMonth(ctx, &calendarpb.GetEventsListOnMonthRequest{
+					MonthStartDate: timestamppb.New(start),
+					CalendarIds:    filter.CalendarIDs,
+					Tags:           filter.Tags,
+				})
+				if err != nil {
+					return err
+				}
+				list = result.GetList()
+			}
+
+			return c.printEvents(cmd.OutOrStdout(), toEvents(list))
+		},
+	}
+
+	registerFilterFlags(cmd, &filter)
+
+	return cmd
+}
+
+func registerFilterFlags(cmd *cobra.Command, filter *storage.EventFilter) {
+	cmd.Flags().StringSliceVar(&filter.CalendarIDs, "calendar", nil,
+		"Only the events of the calendar, repeated or comma-separated")
+	cmd.Flags().StringSliceVar(&filter.Tags, "tag", nil,
+		"Only the events with any of the tags, repeated or comma-separated")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	ctl := &ctl{}
+	err := newRootCommand(ctl, os.Stdout).Execute()
+	ctl.close()
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// ctl is the state shared by the commands: the config of the run and the connection
+// to the API, which is dialed by the first command calling the API.
+type ctl struct {
+	configFile string
+	config     Config
+	conn       *grpc.ClientConn
+}
+
+func newRootCommand(c *ctl, out io.Writer) *cobra.Command {
+	root := &cobra.Command{
+		Use:   "calendarctl",
+		Short: "Manage the events of the calendar service over gRPC",
+		Long: `calendarctl manages the events of the calendar service over its gRPC API and triggers
+the scheduler jobs.
+
+The address and the credentials are read from the config file, see configs/calendarctl.toml,
+and are overridden by the CALENDAR_ environment variables, e.g. CALENDAR_SERVER_TOKEN,
+and by the flags.`,
+		SilenceUsage: true,
+	}
+	root.SetOut(out)
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&c.configFile, "config", defaultConfigFile(), "Path to configuration file, none when empty")
+	flags.String("address", "", "gRPC address of the calendar API, host:port")
+	flags.String("token", "", "API token or JWT sent as the bearer token")
+	flags.Duration("timeout", 0, "Timeout of a call to the API")
+	flags.StringP("output", "o", "", "Output format: table or json")
+	_ = root.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(
+		[]string{outputTable, outputJSON}, cobra.ShellCompDirectiveNoFileComp,
+	))
+
+	root.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		// The completion scripts are generated without a config, a broken one must not break them.
+		if cmd.Name() == cobra.ShellCompRequestCmd || cmd.HasParent() && cmd.Parent().Name() == "completion" {
+			return nil
+		}
+		return c.loadConfig(cmd)
+	}
+
+	root.AddCommand(
+		newCreateCommand(c),
+		newUpdateCommand(c),
+		newDeleteCommand(c),
+		newGetCommand(c),
+		newListCommand(c),
+		newExportCommand(c),
+		newImportCommand(c),
+		newJobsCommand(c),
+	)
+
+	return root
+}
+
+// loadConfig reads the config and applies the global flags set on the command line.
+func (c *ctl) loadConfig(cmd *cobra.Command) error {
+	config, err := NewConfig(c.configFile, cmd.Flags().Changed("config"))
+	if err != nil {
+		return fmt.Errorf("error reading config: %w", err)
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("address") {
+		config.Server.Address, _ = flags.GetString("address")
+	}
+	if flags.Changed("token") {
+		config.Server.Token, _ = flags.GetString("token")
+	}
+	if flags.Changed("timeout") {
+		config.Server.Timeout, _ = flags.GetDuration("timeout")
+	}
+	if flags.Changed("output") {
+		config.Output.Format, _ = flags.GetString("output")
+	}
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	c.config = config
+	return nil
+}
+
+// client returns the client of the API, the connection is dialed on the first call.
+func (c *ctl) client() (calendarpb.CalendarClient, error) {
+	if c.conn == nil {
+		conn, err := dial(c.config.Server)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+
+	return calendarpb.NewCalendarClient(c.conn), nil
+}
+
+// callContext bounds a call to the API by server.timeout.
+func (c *ctl) callContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(cmd.Context(), c.config.Server.Timeout)
+}
+
+func (c *ctl) close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
+
+func dial(config ServerConf) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if config.TLS.Enabled() {
+		tlsConfig, err := tlsconfig.NewClient(config.TLS.ClientConfig())
+		if err != nil {
+			return nil, fmt.Errorf("error loading tls config: %w", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if config.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(config.Token)))
+	}
+
+	return grpc.NewClient(config.Address, opts...)
+}
+
+// bearerToken sends the token in the authorization metadata, which the API authenticates.
+// It is sent without TLS too, for the servers on localhost.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
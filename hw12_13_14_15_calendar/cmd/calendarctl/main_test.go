@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeCalendar serves the calls calendarctl makes and remembers what it was sent.
+type fakeCalendar struct {
+	calendarpb.UnimplementedCalendarServer
+
+	authorization  []string
+	idempotencyKey []string
+	created        *calendarpb.CreateRequest
+	updated        *calendarpb.UpdateRequest
+	week           *calendarpb.GetEventsListOnWeekRequest
+	batch          []*calendarpb.BatchRequest
+}
+
+var fakeEvent = &calendarpb.GetResult{
+	Id:      "6f1c5bd8-3a39-4c4b-9d36-3f1e0c2b7a11",
+	Title:   "Standup",
+	StartDt: timestamppb.New(time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)),
+	EndDt:   timestamppb.New(time.Date(2024, 6, 3, 9, 15, 0, 0, time.UTC)),
+	Tags:    []string{"work"},
+}
+
+func (f *fakeCalendar) Create(ctx context.Context, r *calendarpb.CreateRequest) (*calendarpb.CreateResult, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.authorization = md.Get("authorization")
+	f.idempotencyKey = md.Get("idempotency-key")
+	f.created = r
+	return &calendarpb.CreateResult{Id: fakeEvent.Id}, nil
+}
+
+func (f *fakeCalendar) Get(context.Context, *calendarpb.GetRequest) (*calendarpb.GetResult, error) {
+	return fakeEvent, nil
+}
+
+func (f *fakeCalendar) Update(_ context.Context, r *calendarpb.UpdateRequest) (*calendarpb.UpdateResult, error) {
+	f.updated = r
+	return &calendarpb.UpdateResult{}, nil
+}
+
+func (f *fakeCalendar) GetEventsListOnWeek(
+	_ context.Context,
+	r *calendarpb.GetEventsListOnWeekRequest,
+) (*calendarpb.GetEventsListOnWeekResult, error) {
+	f.week = r
+	return &calendarpb.GetEventsListOnWeekResult{List: []*calendarpb.GetResult{fakeEvent}}, nil
+}
+
+func (f *fakeCalendar) Batch(stream calendarpb.Calendar_BatchServer) error {
+	results := []*calendarpb.BatchItemResult{}
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		f.batch = append(f.batch, r)
+		result := &calendarpb.BatchItemResult{Index: int32(len(results)), EventId: r.GetEventId(), Ok: true}
+		if r.GetTitle() == "" {
+			result.Ok = false
+			result.Error = "title: must be set"
+		}
+		results = append(results, result)
+	}
+
+	return stream.SendAndClose(&calendarpb.BatchResult{Applied: true, Results: results})
+}
+
+func startFakeCalendar(t *testing.T) (*fakeCalendar, string) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	fake := &fakeCalendar{}
+	server := grpc.NewServer()
+	calendarpb.RegisterCalendarServer(server, fake)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	return fake, lis.Addr().String()
+}
+
+// run runs calendarctl with the args against the address, without a config file.
+func run(t *testing.T, address string, args ...string) (string, error) {
+	t.Helper()
+
+	c := &ctl{}
+	defer c.close()
+
+	var out bytes.Buffer
+	root := newRootCommand(c, &out)
+	root.SetErr(io.Discard)
+	root.SetArgs(append([]string{
+		"--config", "",
+		"--address", address,
+	}, args...))
+
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestEventCommands(t *testing.T) {
+	fake, address := startFakeCalendar(t)
+
+	out, err := run(t, address, "--token", "token-1", "create",
+		"--title", "Standup", "--start", "2024-06-03T09:00:00Z", "--end", "2024-06-03 09:15",
+		"--notify-before", "10m", "--tag", "work,daily", "--idempotency-key", "key-1")
+	require.NoError(t, err)
+	require.Equal(t, fakeEvent.Id+"\n", out)
+	require.Equal(t, []string{"Bearer token-1"}, fake.authorization)
+	require.Equal(t, []string{"key-1"}, fake.idempotencyKey)
+	require.Equal(t, "Standup", fake.created.GetTitle())
+	require.Equal(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC), fake.created.GetStartDt().AsTime())
+	require.Equal(t, time.Date(2024, 6, 3, 9, 15, 0, 0, time.Local), fake.created.GetEndDt().AsTime().Local())
+	require.Equal(t, 10*time.Minute, fake.created.GetNotifyBefore().AsDuration())
+	require.Equal(t, []string{"work", "daily"}, fake.created.GetTags())
+
+	_, err = run(t, address, "create", "--title", "Standup")
+	require.ErrorIs(t, err, ErrMissingFlag)
+
+	// The fields without a flag are kept from the event.
+	_, err = run(t, address, "update", fakeEvent.Id, "--title", "Retro")
+	require.NoError(t, err)
+	require.Equal(t, "Retro", fake.updated.GetTitle())
+	require.Equal(t, fakeEvent.GetStartDt().AsTime(), fake.updated.GetStartDt().AsTime())
+	require.Equal(t, []string{"work"}, fake.updated.GetTags())
+
+	out, err = run(t, address, "-o", "json", "get", fakeEvent.Id)
+	require.NoError(t, err)
+	var event storage.Event
+	require.NoError(t, json.Unmarshal([]byte(out), &event))
+	require.Equal(t, fakeEvent.Id, event.ID)
+	require.Equal(t, "Standup", event.Title)
+}
+
+func TestListCommand(t *testing.T) {
+	fake, address := startFakeCalendar(t)
+
+	out, err := run(t, address, "list", "week", "2024-06-03", "--tag", "work")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), fake.week.GetWeekStartDate().AsTime())
+	require.Equal(t, []string{"work"}, fake.week.GetTags())
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, []string{"ID", "START", "END", "TITLE", "CALENDAR", "TAGS"}, strings.Fields(lines[0]))
+	require.Contains(t, lines[1], fakeEvent.Id)
+	require.Contains(t, lines[1], "Standup")
+
+	out, err = run(t, address, "list", "week", "-o", "json")
+	require.NoError(t, err)
+	var events []storage.Event
+	require.NoError(t, json.Unmarshal([]byte(out), &events))
+	require.Len(t, events, 1)
+
+	_, err = run(t, address, "list", "year")
+	require.Error(t, err)
+	_, err = run(t, address, "list", "day", "tomorrow")
+	require.ErrorIs(t, err, ErrInvalidTime)
+}
+
+func TestImportCommand(t *testing.T) {
+	fake, address := startFakeCalendar(t)
+
+	file := filepath.Join(t.TempDir(), "events.json")
+	require.NoError(t, os.WriteFile(file, []byte(`[
+		{"id": "6f1c5bd8-3a39-4c4b-9d36-3f1e0c2b7a11", "title": "Standup",
+		 "start_dt": "2024-06-03T09:00:00Z", "end_dt": "2024-06-03T09:15:00Z"},
+		{"id": "0b8f3f5e-43a2-4d8e-8f5c-0d1b6e8a9c22", "title": "",
+		 "start_dt": "2024-06-04T09:00:00Z", "end_dt": "2024-06-04T09:15:00Z"}
+	]`), 0o600))
+
+	out, err := run(t, address, "import", "--file", file, "--best-effort", "--new-ids")
+	require.ErrorIs(t, err, ErrImportFailed)
+	require.Len(t, fake.batch, 2)
+	require.Equal(t, calendarpb.BatchMode_BATCH_MODE_BEST_EFFORT, fake.batch[0].GetMode())
+	require.Empty(t, fake.batch[0].GetEventId())
+	require.Equal(t, "Standup", fake.batch[0].GetTitle())
+	require.Contains(t, out, "title: must be set")
+}
+
+func TestJobsCommand(t *testing.T) {
+	var path, authorization string
+	scheduler := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"job":"clean","status":"queued"}`))
+	}))
+	defer scheduler.Close()
+
+	t.Setenv("CALENDAR_SCHEDULER_ADDRESS", scheduler.URL)
+
+	_, err := run(t, "localhost:50051", "jobs", "run", "clean")
+	require.ErrorIs(t, err, ErrJobNotTriggered)
+	require.Empty(t, path)
+
+	out, err := run(t, "localhost:50051", "--token", "token-1", "jobs", "run", "clean")
+	require.NoError(t, err)
+	require.Equal(t, "POST /jobs/clean", path)
+	require.Equal(t, "Bearer token-1", authorization)
+	require.Equal(t, "job clean queued\n", out)
+}
+
+func TestConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "calendarctl.toml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+[server]
+address = "calendar.local:50051"
+token = "token-1"
+
+[output]
+format = "json"
+`), 0o600))
+
+	config, err := NewConfig(file, true)
+	require.NoError(t, err)
+	require.Equal(t, "calendar.local:50051", config.Server.Address)
+	require.Equal(t, "token-1", config.Server.Token)
+	require.Equal(t, 10*time.Second, config.Server.Timeout)
+	require.Equal(t, "localhost:9101", config.Scheduler.Address)
+	require.NoError(t, config.Validate())
+
+	config, err = NewConfig("../../configs/calendarctl.toml", true)
+	require.NoError(t, err)
+	require.Equal(t, defaultConfig(), config)
+
+	_, err = NewConfig(filepath.Join(t.TempDir(), "missing.toml"), true)
+	require.Error(t, err)
+
+	config, err = NewConfig(filepath.Join(t.TempDir(), "missing.toml"), false)
+	require.NoError(t, err)
+	require.Equal(t, defaultConfig(), config)
+
+	config.Server.Address = "calendar.local"
+	config.Output.Format = "yaml"
+	require.Error(t, config.Validate())
+}
+
+func TestPeriodStart(t *testing.T) {
+	wednesday := time.Date(2024, 6, 5, 15, 4, 0, 0, time.Local)
+	require.Equal(t, time.Date(2024, 6, 5, 0, 0, 0, 0, time.Local), periodStart(periodDay, wednesday))
+	require.Equal(t, time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local), periodStart(periodWeek, wednesday))
+	require.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local), periodStart(periodMonth, wednesday))
+
+	sunday := time.Date(2024, 6, 9, 0, 0, 0, 0, time.Local)
+	require.Equal(t, time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local), periodStart(periodWeek, sunday))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+const tableTimeFormat = "2006-01-02 15:04"
+
+func writeJSON(out io.Writer, value any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func newTable(out io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format(tableTimeFormat)
+}
+
+func (c *ctl) printID(out io.Writer, id string) error {
+	if c.config.Output.Format == outputJSON {
+		return writeJSON(out, struct {
+			ID string `json:"id"`
+		}{ID: id})
+	}
+
+	_, err := fmt.Fprintln(out, id)
+	return err
+}
+
+func (c *ctl) printEvents(out io.Writer, events []storage.Event) error {
+	if c.config.Output.Format == outputJSON {
+		return writeJSON(out, events)
+	}
+
+	w := newTable(out)
+	fmt.Fprintln(w, "ID\tSTART\tEND\tTITLE\tCALENDAR\tTAGS")
+	for _, event := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			event.ID,
+			formatTime(event.StartDate),
+			formatTime(event.EndDate),
+			event.Title,
+			event.CalendarID,
+			strings.Join(event.Tags, ","),
+		)
+	}
+
+	return w.Flush()
+}
+
+func (c *ctl) printEvent(out io.Writer, event storage.Event) error {
+	if c.config.Output.Format == outputJSON {
+		return writeJSON(out, event)
+	}
+
+	w := newTable(out)
+	fmt.Fprintf(w, "ID:\t%s\n", event.ID)
+	fmt.Fprintf(w, "Title:\t%s\n", event.Title)
+	fmt.Fprintf(w, "Start:\t%s\n", formatTime(event.StartDate))
+	fmt.Fprintf(w, "End:\t%s\n", formatTime(event.EndDate))
+	fmt.Fprintf(w, "Notify before:\t%s\n", event.NotifyBefore)
+	fmt.Fprintf(w, "Notified:\t%t\n", event.Notified)
+	fmt.Fprintf(w, "Calendar:\t%s\n", event.CalendarID)
+	fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(event.Tags, ","))
+	fmt.Fprintf(w, "Description:\t%s\n", event.Description)
+	fmt.Fprintf(w, "Location:\t%s\n", event.Location)
+	fmt.Fprintf(w, "URL:\t%s\n", event.URL)
+	fmt.Fprintf(w, "Creator:\t%d\n", event.CreatorID)
+	fmt.Fprintf(w, "Created:\t%s\n", formatTime(event.CreatedAt))
+	fmt.Fprintf(w, "Updated:\t%s\n", formatTime(event.UpdatedAt))
+
+	return w.Flush()
+}
+
+func (c *ctl) printImportResults(out io.Writer, results []importResult) error {
+	if c.config.Output.Format == outputJSON {
+		return writeJSON(out, results)
+	}
+
+	w := newTable(out)
+	fmt.Fprintln(w, "INDEX\tID\tSTATUS\tERROR")
+	for _, result := range results {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", result.Index, result.ID, result.Status, result.Error)
+	}
+
+	return w.Flush()
+}
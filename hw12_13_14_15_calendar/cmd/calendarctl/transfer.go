@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var ErrImportFailed = errors.New("import failed")
+
+// The files of export and import hold a JSON array of the events in the format of the HTTP API,
+// so an export of one server is imported into another one as is.
+
+func newExportCommand(c *ctl) *cobra.Command {
+	var (
+		from, to string
+		file     string
+		filter   storage.EventFilter
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export --from TIME --to TIME",
+		Short: "Write the events starting in the period to a JSON file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			fromTime, err := parseTime(from)
+			if err != nil {
+				return fmt.Errorf("--from: %w", err)
+			}
+			toTime, err := parseTime(to)
+			if err != nil {
+				return fmt.Errorf("--to: %w", err)
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := c.callContext(cmd)
+			defer cancel()
+
+			result, err := client.GetEventsListByDates(ctx, &calendarpb.GetEventsListByDatesRequest{
+				From:        timestamppb.New(fromTime),
+				To:          timestamppb.New(toTime),
+				CalendarIds: filter.CalendarIDs,
+				Tags:        filter.Tags,
+			})
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if file != "" && file != "-" {
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+
+			events := toEvents(result.GetList())
+			if err := writeJSON(out, events); err != nil {
+				return err
+			}
+
+			if out != cmd.OutOrStdout() {
+				fmt.Fprintf(cmd.ErrOrStderr(), "exported %d events to %s\n", len(events), file)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "Start of the period, e.g. 2024-06-01")
+	cmd.Flags().StringVar(&to, "to", "", "End of the period, in the format of --from")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "File to write, the standard output when empty or -")
+	registerFilterFlags(cmd, &filter)
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+	_ = cmd.MarkFlagFilename("file", "json")
+
+	return cmd
+}
+
+// importResult is the outcome of an event of the file, in the order of the file.
+type importResult struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newImportCommand(c *ctl) *cobra.Command {
+	var (
+		file       string
+		bestEffort bool
+		newIDs     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import --file FILE",
+		Short: "Create the events of a JSON file in one batch",
+		Long: `Create the events of a JSON file, e.g. written by export, in one batch. Either all
+the events are created or none of them unless --best-effort is set.
+The IDs of the file are kept, so the events are imported once, unless --new-ids is set.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			in := cmd.InOrStdin()
+			if file != "-" {
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+
+			var events []storage.Event
+			if err := json.NewDecoder(in).Decode(&events); err != nil {
+				return fmt.Errorf("error reading %s: %w", file, err)
+			}
+
+			client, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := c.callContext(cmd)
+			defer cancel()
+
+			stream, err := client.Batch(ctx)
+			if err != nil {
+				return err
+			}
+
+			mode := calendarpb.BatchMode_BATCH_MODE_ALL_OR_NOTHING
+			if bestEffort {
+				mode = calendarpb.BatchMode_BATCH_MODE_BEST_EFFORT
+			}
+
+			for i := range events {
+				if newIDs {
+					events[i].ID = ""
+				}
+
+				err := stream.Send(&calendarpb.BatchRequest{
+					Mode:         mode,
+					Type:         calendarpb.BatchOperationType_BATCH_OPERATION_CREATE,
+					EventId:      events[i].ID,
+					Title:        events[i].Title,
+					StartDt:      timestamppb.New(events[i].StartDate),
+					EndDt:        timestamppb.New(events[i].EndDate),
+					NotifyBefore: durationpb.New(events[i].NotifyBefore),
+					CalendarId:   events[i].CalendarID,
+					Tags:         events[i].Tags,
+					Description:  events[i].Description,
+					Location:     events[i].Location,
+					Url:          events[i].URL,
+				})
+				if err != nil {
+					// The server ended the stream, its error is returned by CloseAndRecv.
+					break
+				}
+			}
+
+			result, err := stream.CloseAndRecv()
+			if err != nil {
+				return err
+			}
+
+			results := make([]importResult, 0, len(result.GetResults()))
+			failed := !result.GetApplied()
+			for _, r := range result.GetResults() {
+				item := importResult{Index: int(r.GetIndex()), ID: r.GetEventId(), Status: "ok", Error: r.GetError()}
+				switch {
+				case r.GetAborted():
+					item.Status = "aborted"
+				case !r.GetOk():
+					item.Status = "error"
+					failed = true
+				}
+				results = append(results, item)
+			}
+
+			if err := c.printImportResults(cmd.OutOrStdout(), results); err != nil {
+				return err
+			}
+
+			if failed {
+				return ErrImportFailed
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "File to read, the standard input when -")
+	cmd.Flags().BoolVar(&bestEffort, "best-effort", false, "Create the valid events when some of them fail")
+	cmd.Flags().BoolVar(&newIDs, "new-ids", false, "Create the events with new IDs rather than the ones of the file")
+	_ = cmd.MarkFlagRequired("file")
+	_ = cmd.MarkFlagFilename("file", "json")
+
+	return cmd
+}
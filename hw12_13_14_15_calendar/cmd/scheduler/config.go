@@ -3,52 +3,63 @@ package main
 import (
 	"time"
 
-	"github.com/spf13/viper"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
 )
 
 type Config struct {
-	Logger    LoggerConf
+	Logger    config.LoggerConf
 	Scheduler SchedulerConf
-	Storage   StorageConf
-	Postgres  PostgresConf
-	Rabbit    RabbitConf
-}
-
-type LoggerConf struct {
-	Level string
+	Storage   config.StorageConf
+	Postgres  config.PostgresConf
+	SQLite    config.SQLiteConf
+	Rabbit    config.RabbitConf
+	Metrics   config.MetricsConf
+	Tracing   config.TracingConf
+	Auth      config.AuthConf
 }
 
+// SchedulerConf is how often the jobs run, both are reloaded on SIGHUP. JobTriggers serves
+// POST /jobs/{name} on the admin port to the users of JobAdmins with a token accepted by [auth].
 type SchedulerConf struct {
 	EventsNotifyCheckFrequency time.Duration
 	OldEventsCleanerFrequency  time.Duration
+	JobTriggers                bool
+	JobAdmins                  []int
 }
 
-type StorageConf struct {
-	Type string
-}
-
-type PostgresConf struct {
-	Dsn string
-}
-
-type RabbitConf struct {
-	URI      string
-	Exchange string
-}
-
+// NewConfig reads the file with the environment overrides and validates the settings the scheduler uses.
 func NewConfig(configFile string) (Config, error) {
-	v := viper.New()
-	v.SetConfigFile(configFile)
-
 	var c Config
-
-	if err := v.ReadInConfig(); err != nil {
+	if err := config.Load(configFile, &c); err != nil {
 		return c, err
 	}
 
-	if err := v.Unmarshal(&c); err != nil {
-		return c, err
+	return c, c.Validate()
+}
+
+func (c Config) Validate() error {
+	errs := &config.Errors{}
+	c.Logger.Validate(errs)
+	errs.Duration("scheduler.eventsNotifyCheckFrequency", c.Scheduler.EventsNotifyCheckFrequency, true)
+	errs.Duration("scheduler.oldEventsCleanerFrequency", c.Scheduler.OldEventsCleanerFrequency, true)
+	// The in-memory storage is not shared with the API, so the scheduler would never see its events.
+	c.Storage.Validate(errs, "postgres", "sqlite")
+	switch c.Storage.Type {
+	case "postgres":
+		c.Postgres.Validate(errs)
+	case "sqlite":
+		c.SQLite.Validate(errs)
+	}
+	c.Rabbit.Validate(errs)
+	c.Metrics.Validate(errs)
+	c.Tracing.Validate(errs)
+	c.Auth.Validate(errs)
+	if c.Scheduler.JobTriggers && !c.Auth.Enabled {
+		errs.Add("scheduler.jobTriggers", "requires auth.enabled, the triggers are never served without a token")
+	}
+	if c.Scheduler.JobTriggers && len(c.Scheduler.JobAdmins) == 0 {
+		errs.Add("scheduler.jobAdmins", "must list the users allowed to trigger the jobs with scheduler.jobTriggers")
 	}
 
-	return c, nil
+	return errs.Err()
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+)
+
+const (
+	jobsPath = "/jobs/"
+
+	jobNotify = "notify"
+	jobClean  = "clean"
+)
+
+// jobs runs the scheduler jobs out of their schedule on POST /jobs/{name} of the admin port,
+// e.g. by `calendarctl jobs run notify`. A trigger is queued for the job loop, so the request
+// returns before the run ends and the triggers sent while one is already queued are merged.
+// The triggers are served with scheduler.jobTriggers only, to scheduler.jobAdmins behind requireToken.
+type jobs map[string]chan struct{}
+
+// Authenticator resolves the token of the caller to the ID of the user.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (int, error)
+}
+
+type jobResult struct {
+	Job    string `json:"job"`
+	Status string `json:"status"`
+}
+
+func (j jobs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, jobsPath)
+	trigger, ok := j[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(jobResult{Job: name, Status: "queued"})
+}
+
+// requireToken serves the requests with a bearer token accepted by the authenticator, the tokens
+// and JWT of [auth] like the API, of the users in adminIDs. It rejects the requests without
+// a valid token with 401 and the other users with 403.
+func requireToken(authenticator Authenticator, adminIDs []int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			token = ""
+		}
+
+		userID, err := authenticator.Authenticate(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calendar-scheduler"`)
+			status := http.StatusUnauthorized
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if !isAdmin(adminIDs, userID) {
+			status := http.StatusForbidden
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(identity.WithUserID(r.Context(), userID)))
+	})
+}
+
+func isAdmin(adminIDs []int, userID int) bool {
+	for _, adminID := range adminIDs {
+		if adminID == userID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobs(t *testing.T) {
+	triggers := jobs{
+		jobNotify: make(chan struct{}, 1),
+		jobClean:  make(chan struct{}, 1),
+	}
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		triggers.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := serve(http.MethodPost, "/jobs/notify")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.JSONEq(t, `{"job":"notify","status":"queued"}`, w.Body.String())
+	require.Len(t, triggers[jobNotify], 1)
+	require.Len(t, triggers[jobClean], 0)
+
+	// The trigger already queued is not doubled and the request doesn't block.
+	require.Equal(t, http.StatusAccepted, serve(http.MethodPost, "/jobs/notify").Code)
+	require.Len(t, triggers[jobNotify], 1)
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/jobs/unknown").Code)
+
+	w = serve(http.MethodGet, "/jobs/clean")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+	require.Len(t, triggers[jobClean], 0)
+}
+
+type tokenAuthenticator map[string]int
+
+func (a tokenAuthenticator) Authenticate(_ context.Context, token string) (int, error) {
+	userID, ok := a[token]
+	if !ok {
+		return 0, errors.New("invalid token")
+	}
+
+	return userID, nil
+}
+
+func TestRequireToken(t *testing.T) {
+	triggers := jobs{jobNotify: make(chan struct{}, 1)}
+	handler := requireToken(tokenAuthenticator{"token-1": 1, "token-2": 2}, []int{1}, triggers)
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/jobs/notify", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, authorization := range []string{"", "Bearer token-3", "Basic token-1"} {
+		w := serve(authorization)
+		require.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+		require.Equal(t, `Bearer realm="calendar-scheduler"`, w.Header().Get("WWW-Authenticate"))
+	}
+	require.Len(t, triggers[jobNotify], 0)
+
+	// An authenticated user who is not a job admin.
+	require.Equal(t, http.StatusForbidden, serve("Bearer token-2").Code)
+	require.Len(t, triggers[jobNotify], 0)
+
+	require.Equal(t, http.StatusAccepted, serve("Bearer token-1").Code)
+	require.Len(t, triggers[jobNotify], 1)
+}
@@ -3,21 +3,35 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/auth"
+	internalconfig "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/health"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
 	rabbit "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	metricsstorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/metrics"
 	sqlstorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sql"
+	sqlitestorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sqlite"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel"
 )
 
 var configFile string
 
+var tracer = otel.Tracer("github.com/wursta/otus_go/hw12_13_14_15_calendar/cmd/scheduler")
+
 func init() {
 	pflag.StringVar(&configFile, "config", "/etc/calendar/scheduler_config.toml", "Path to configuration file")
 	pflag.Parse()
@@ -25,23 +39,53 @@ func init() {
 
 func main() {
 	config, err := NewConfig(configFile)
+	if pflag.Arg(0) == "config" {
+		os.Exit(internalconfig.Command(os.Stdout, pflag.Args()[1:], configFile, err))
+	}
 	if err != nil {
 		fmt.Printf("error reading config: %v\n", err)
-		return
+		os.Exit(1)
 	}
 
-	log, err := logger.New(config.Logger.Level, os.Stderr)
+	log, err := logger.NewWithConfig(config.Logger.LoggerConfig(), os.Stderr)
 	if err != nil {
 		fmt.Printf("error creating logger: %v\n", err)
 		return
 	}
 
-	log.Debug("created logger", log)
+	log.Debug("created logger", "level", config.Logger.Level, "format", config.Logger.Format)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "calendar-scheduler", config.Tracing.TracingConfig())
+	if err != nil {
+		log.Error("error setting up tracing", "error", err)
+		return
+	}
+	defer shutdownTracing(context.Background())
+
+	var authenticator *auth.Authenticator
+	if config.Scheduler.JobTriggers {
+		authenticator, err = auth.New(config.Auth.AuthConfig())
+		if err != nil {
+			log.Error("error creating authenticator", "error", err)
+			return
+		}
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
 	done := make(chan bool)
+	notifyFrequency := make(chan time.Duration, 1)
+	cleanFrequency := make(chan time.Duration, 1)
+	triggers := jobs{
+		jobNotify: make(chan struct{}, 1),
+		jobClean:  make(chan struct{}, 1),
+	}
+
+	running := config
+	internalconfig.OnReload(ctx, func() {
+		running = reloadConfig(log, running, notifyFrequency, cleanFrequency)
+	})
 
 	var storage app.Storage
 
@@ -50,35 +94,60 @@ func main() {
 		log.Error("error creating storage: inmemory storage not allowed here")
 		return
 	case "postgres":
-		sqlStorage := sqlstorage.New(config.Postgres.Dsn)
+		sqlStorage := sqlstorage.NewWithConfig(config.Postgres.StorageConfig())
 
 		ctx := context.Background()
 		err = sqlStorage.Connect(ctx)
 		if err != nil {
-			log.Error(fmt.Sprintf("error connecting to database: %v\n", err))
+			log.Error("error connecting to database", "error", err)
 			return
 		}
 		defer sqlStorage.Close(ctx)
 
 		storage = sqlStorage
 
+	case "sqlite":
+		sqliteStorage := sqlitestorage.New(config.SQLite.Path)
+
+		ctx := context.Background()
+		err = sqliteStorage.Connect(ctx)
+		if err != nil {
+			log.Error("error connecting to database", "error", err)
+			return
+		}
+		defer sqliteStorage.Close(ctx)
+
+		storage = sqliteStorage
+
 	default:
 		log.Error("error creating storage: unknown storage type")
 		return
 	}
 
-	log.Debug("create storage", storage)
+	registry := metrics.NewRegistry()
+	storage = metricsstorage.New(storage, metrics.NewStorageMetrics(registry))
+	schedulerMetrics := metrics.NewSchedulerMetrics(registry)
+
+	log.Debug("created storage", "type", config.Storage.Type)
 
 	producer := rabbit.NewProducer(config.Rabbit.URI, config.Rabbit.Exchange)
+	if config.Rabbit.TLS.Enabled() {
+		tlsConfig, err := tlsconfig.NewClient(config.Rabbit.TLS.ClientConfig())
+		if err != nil {
+			log.Error("error loading rabbitmq tls config", "error", err)
+			return
+		}
+		producer.SetTLS(tlsConfig)
+	}
 
 	err = producer.Connect()
 	if err != nil {
-		log.Error(fmt.Sprint("connect error:", err))
+		log.Error("error connecting to rabbitmq", "error", err)
 		return
 	}
 	defer producer.Disconnect()
 
-	log.Debug("create producer and connected", producer)
+	log.Debug("connected producer", "exchange", config.Rabbit.Exchange)
 
 	wg := &sync.WaitGroup{}
 
@@ -90,8 +159,11 @@ func main() {
 			log,
 			storage,
 			config.Scheduler.EventsNotifyCheckFrequency,
+			notifyFrequency,
+			triggers[jobNotify],
 			done,
 			producer,
+			schedulerMetrics,
 		)
 	}()
 
@@ -103,15 +175,89 @@ func main() {
 			log,
 			storage,
 			config.Scheduler.OldEventsCleanerFrequency,
+			cleanFrequency,
+			triggers[jobClean],
 			done,
+			schedulerMetrics,
 		)
 	}()
 
+	metricsServer := metrics.NewServer(registry, config.Metrics.Host, config.Metrics.SchedulerPort)
+	checker := health.New(log)
+	checker.Add("storage", storage.Ping)
+	checker.Add("rabbit", producer.Ping)
+	metricsServer.Handle("/livez", http.HandlerFunc(checker.ServeLiveness))
+	metricsServer.Handle("/readyz", http.HandlerFunc(checker.ServeReadiness))
+	if authenticator != nil {
+		metricsServer.Handle(jobsPath, requireToken(authenticator, config.Scheduler.JobAdmins, triggers))
+	}
+	if config.Metrics.SchedulerPort != "" {
+		go func() {
+			if err := metricsServer.Start(ctx); err != nil {
+				log.Error("failed to start metrics server", "error", err)
+			}
+		}()
+	}
+
 	<-ctx.Done()
 
 	close(done)
 
 	wg.Wait()
+
+	if config.Metrics.SchedulerPort != "" {
+		timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer timeoutCancel()
+
+		if err := metricsServer.Stop(timeoutCtx); err != nil {
+			log.Error("failed to stop metrics server", "error", err)
+		}
+	}
+}
+
+// reloadConfig applies the log level and the job frequencies of the file and returns the config
+// the scheduler runs with. The other settings are kept, their changes are reported as needing a restart.
+func reloadConfig(
+	log *logger.Logger,
+	current Config,
+	notifyFrequency chan time.Duration,
+	cleanFrequency chan time.Duration,
+) Config {
+	reloaded, err := NewConfig(configFile)
+	if err != nil {
+		log.Error("error reloading config, keeping the current one", "error", err)
+		return current
+	}
+
+	if err := log.SetLevel(reloaded.Logger.Level); err != nil {
+		log.Error("error setting logger level", "error", err)
+		return current
+	}
+	current.Logger.Level = reloaded.Logger.Level
+
+	if reloaded.Scheduler.EventsNotifyCheckFrequency != current.Scheduler.EventsNotifyCheckFrequency {
+		setFrequency(notifyFrequency, reloaded.Scheduler.EventsNotifyCheckFrequency)
+	}
+	if reloaded.Scheduler.OldEventsCleanerFrequency != current.Scheduler.OldEventsCleanerFrequency {
+		setFrequency(cleanFrequency, reloaded.Scheduler.OldEventsCleanerFrequency)
+	}
+	current.Scheduler = reloaded.Scheduler
+	log.Info("reloaded config", "level", current.Logger.Level)
+
+	if !reflect.DeepEqual(current, reloaded) {
+		log.Warn("config changes other than logger.level and [scheduler] need a restart")
+	}
+
+	return current
+}
+
+// setFrequency replaces the frequency the job has not picked yet, so the send never blocks.
+func setFrequency(ch chan time.Duration, frequency time.Duration) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- frequency
 }
 
 func runScheduler(
@@ -119,10 +265,13 @@ func runScheduler(
 	log *logger.Logger,
 	storage app.Storage,
 	frequency time.Duration,
+	frequencyCh <-chan time.Duration,
+	triggerCh <-chan struct{},
 	doneCh <-chan bool,
 	producer *rabbit.Producer,
+	schedulerMetrics *metrics.SchedulerMetrics,
 ) {
-	checkEventsForNotify(ctx, log, storage, producer)
+	checkEventsForNotify(ctx, log, storage, producer, schedulerMetrics)
 
 	ticker := time.NewTicker(frequency)
 	defer ticker.Stop()
@@ -130,10 +279,16 @@ func runScheduler(
 	for {
 		select {
 		case <-doneCh:
-			log.Info("Stopping events notify checker")
+			log.Info("stopping events notify checker")
 			return
+		case frequency := <-frequencyCh:
+			ticker.Reset(frequency)
+			log.Info("changed events notify check frequency", "frequency", frequency)
 		case <-ticker.C:
-			checkEventsForNotify(ctx, log, storage, producer)
+			checkEventsForNotify(ctx, log, storage, producer, schedulerMetrics)
+		case <-triggerCh:
+			log.Info("running events notify check on demand")
+			checkEventsForNotify(ctx, log, storage, producer, schedulerMetrics)
 		}
 	}
 }
@@ -143,9 +298,12 @@ func runOldEventsCleaner(
 	log *logger.Logger,
 	storage app.Storage,
 	frequency time.Duration,
+	frequencyCh <-chan time.Duration,
+	triggerCh <-chan struct{},
 	doneCh <-chan bool,
+	schedulerMetrics *metrics.SchedulerMetrics,
 ) {
-	removeOldEvents(ctx, log, storage)
+	removeOldEvents(ctx, log, storage, schedulerMetrics)
 
 	ticker := time.NewTicker(frequency)
 	defer ticker.Stop()
@@ -153,49 +311,95 @@ func runOldEventsCleaner(
 	for {
 		select {
 		case <-doneCh:
-			log.Info("Stopping old events cleaner")
+			log.Info("stopping old events cleaner")
 			return
+		case frequency := <-frequencyCh:
+			ticker.Reset(frequency)
+			log.Info("changed old events cleaner frequency", "frequency", frequency)
 		case <-ticker.C:
-			removeOldEvents(ctx, log, storage)
+			removeOldEvents(ctx, log, storage, schedulerMetrics)
+		case <-triggerCh:
+			log.Info("running old events cleaner on demand")
+			removeOldEvents(ctx, log, storage, schedulerMetrics)
 		}
 	}
 }
 
-func checkEventsForNotify(ctx context.Context, log *logger.Logger, storage app.Storage, producer *rabbit.Producer) {
-	events := storage.GetEventsForNotify(ctx, time.Now().Format(time.DateOnly))
+func checkEventsForNotify(
+	ctx context.Context,
+	log *logger.Logger,
+	storage app.Storage,
+	producer *rabbit.Producer,
+	schedulerMetrics *metrics.SchedulerMetrics,
+) {
+	defer func(start time.Time) {
+		schedulerMetrics.ObserveTick("notify", time.Since(start))
+	}(time.Now())
+
+	ctx, span := tracer.Start(ctx, "scheduler.notify")
+	defer span.End()
 
-	log.Info(fmt.Sprintf("Fetched events for notify: %d", len(events)))
+	events, err := storage.GetEventsForNotify(ctx, time.Now().Format(time.DateOnly))
+	if err != nil {
+		log.ErrorContext(ctx, "error fetching events for notify, skipping the check", "error", err)
+		return
+	}
+
+	log.InfoContext(ctx, "fetched events for notify", "count", len(events))
 
 	for i := range events {
 		event := events[i]
 
-		err := producer.ProduceEvent(event)
+		err := producer.ProduceEvent(ctx, event)
+		schedulerMetrics.NotificationProduced(err)
 
 		if err != nil {
-			log.Error(fmt.Sprint("error consume event:", err))
+			log.ErrorContext(ctx, "error producing event", "event_id", event.ID, "error", err)
 		} else {
 			event.Notified = true
 
 			err = storage.UpdateEvent(ctx, event.ID, event)
 			if err != nil {
-				log.Error(fmt.Sprint("error updating event:", err))
+				log.ErrorContext(ctx, "error updating event", "event_id", event.ID, "error", err)
 			}
 
-			log.Info("Consume event: " + events[i].ID)
+			log.InfoContext(ctx, "produced event", "event_id", event.ID)
 		}
 	}
 }
 
-func removeOldEvents(ctx context.Context, log *logger.Logger, storage app.Storage) {
+func removeOldEvents(
+	ctx context.Context,
+	log *logger.Logger,
+	eventStorage app.Storage,
+	schedulerMetrics *metrics.SchedulerMetrics,
+) {
+	defer func(start time.Time) {
+		schedulerMetrics.ObserveTick("clean", time.Since(start))
+	}(time.Now())
+
+	ctx, span := tracer.Start(ctx, "scheduler.clean")
+	defer span.End()
+
 	yearAgo := time.Now().AddDate(-1, 0, 0)
-	events := storage.GetEventsListByDates(ctx, nil, &yearAgo)
+	events, err := eventStorage.GetEventsListByDates(ctx, nil, &yearAgo, storage.EventFilter{})
+	if err != nil {
+		log.ErrorContext(ctx, "error fetching old events, skipping the clean", "error", err)
+		return
+	}
 
-	log.Info(fmt.Sprintf("Fetched old events for clean: %d", len(events)))
+	log.InfoContext(ctx, "fetched old events for clean", "count", len(events))
 
 	for i := range events {
-		err := storage.DeleteEvent(ctx, events[i].ID)
+		err := eventStorage.DeleteEvent(ctx, events[i].ID)
+		if err != nil {
+			log.ErrorContext(ctx, "error deleting old event", "event_id", events[i].ID, "error", err)
+			continue
+		}
+
+		err = eventStorage.DeleteEventHistory(ctx, events[i].ID)
 		if err != nil {
-			log.Error(fmt.Sprint("error while deleting old event:", err))
+			log.ErrorContext(ctx, "error deleting old event history", "event_id", events[i].ID, "error", err)
 		}
 	}
 }
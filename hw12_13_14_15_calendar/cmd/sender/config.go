@@ -1,37 +1,33 @@
 package main
 
 import (
-	"github.com/spf13/viper"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
 )
 
 type Config struct {
-	Logger LoggerConf
-	Rabbit RabbitConf
-}
-
-type LoggerConf struct {
-	Level string
-}
-
-type RabbitConf struct {
-	URI      string
-	Exchange string
-	Queue    string
+	Logger  config.LoggerConf
+	Rabbit  config.RabbitConf
+	Metrics config.MetricsConf
+	Tracing config.TracingConf
 }
 
+// NewConfig reads the file with the environment overrides and validates the settings the sender uses.
 func NewConfig(configFile string) (Config, error) {
-	v := viper.New()
-	v.SetConfigFile(configFile)
-
 	var c Config
-
-	if err := v.ReadInConfig(); err != nil {
+	if err := config.Load(configFile, &c); err != nil {
 		return c, err
 	}
 
-	if err := v.Unmarshal(&c); err != nil {
-		return c, err
-	}
+	return c, c.Validate()
+}
+
+func (c Config) Validate() error {
+	errs := &config.Errors{}
+	c.Logger.Validate(errs)
+	c.Rabbit.Validate(errs)
+	errs.Required("rabbit.queue", c.Rabbit.Queue)
+	c.Metrics.Validate(errs)
+	c.Tracing.Validate(errs)
 
-	return c, nil
+	return errs.Err()
 }
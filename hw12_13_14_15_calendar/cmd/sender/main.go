@@ -3,18 +3,33 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"syscall"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/streadway/amqp"
+	internalconfig "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/config"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/health"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
 	rabbit "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var configFile string
 
+var tracer = otel.Tracer("github.com/wursta/otus_go/hw12_13_14_15_calendar/cmd/sender")
+
 func init() {
 	pflag.StringVar(&configFile, "config", "/etc/calendar/scheduler_config.toml", "Path to configuration file")
 	pflag.Parse()
@@ -22,59 +37,154 @@ func init() {
 
 func main() {
 	config, err := NewConfig(configFile)
+	if pflag.Arg(0) == "config" {
+		os.Exit(internalconfig.Command(os.Stdout, pflag.Args()[1:], configFile, err))
+	}
 	if err != nil {
 		fmt.Printf("error reading config: %v\n", err)
-		return
+		os.Exit(1)
 	}
 
-	log, err := logger.New(config.Logger.Level, os.Stderr)
+	log, err := logger.NewWithConfig(config.Logger.LoggerConfig(), os.Stderr)
 	if err != nil {
 		fmt.Printf("error creating logger: %v\n", err)
 		return
 	}
 
-	log.Debug("created logger", log)
+	log.Debug("created logger", "level", config.Logger.Level, "format", config.Logger.Format)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "calendar-sender", config.Tracing.TracingConfig())
+	if err != nil {
+		log.Error("error setting up tracing", "error", err)
+		return
+	}
+	defer shutdownTracing(context.Background())
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	running := config
+	internalconfig.OnReload(ctx, func() {
+		running = reloadConfig(log, running)
+	})
+
 	consumer := rabbit.NewConsumer(config.Rabbit.URI, config.Rabbit.Exchange, config.Rabbit.Queue)
 	defer consumer.Disconnect()
+	if config.Rabbit.TLS.Enabled() {
+		tlsConfig, err := tlsconfig.NewClient(config.Rabbit.TLS.ClientConfig())
+		if err != nil {
+			log.Error("error loading rabbitmq tls config", "error", err)
+			return
+		}
+		consumer.SetTLS(tlsConfig)
+	}
 
-	log.Debug("create consumer", consumer)
+	log.Debug("created consumer", "exchange", config.Rabbit.Exchange, "queue", config.Rabbit.Queue)
 
 	err = consumer.Connect()
 	if err != nil {
-		log.Error(fmt.Sprint("connect error:", err))
+		log.Error("error connecting to rabbitmq", "error", err)
 		return
 	}
 
-	log.Debug("connect consumer")
+	log.Debug("connected consumer")
 
 	deliveries, err := consumer.ConsumeEvents()
 	if err != nil {
-		fmt.Printf("error consuming events: %v\n", err)
+		log.Error("error consuming events", "error", err)
 		return
 	}
 
-	go handleEvents(log, deliveries)
+	registry := metrics.NewRegistry()
+
+	go handleEvents(log, deliveries, metrics.NewSenderMetrics(registry))
+
+	metricsServer := metrics.NewServer(registry, config.Metrics.Host, config.Metrics.SenderPort)
+	checker := health.New(log)
+	checker.Add("rabbit", consumer.Ping)
+	metricsServer.Handle("/livez", http.HandlerFunc(checker.ServeLiveness))
+	metricsServer.Handle("/readyz", http.HandlerFunc(checker.ServeReadiness))
+	if config.Metrics.SenderPort != "" {
+		go func() {
+			if err := metricsServer.Start(ctx); err != nil {
+				log.Error("failed to start metrics server", "error", err)
+			}
+		}()
+	}
 
 	<-ctx.Done()
+
+	if config.Metrics.SenderPort != "" {
+		timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer timeoutCancel()
+
+		if err := metricsServer.Stop(timeoutCtx); err != nil {
+			log.Error("failed to stop metrics server", "error", err)
+		}
+	}
 }
 
-func handleEvents(log *logger.Logger, deliveries <-chan amqp.Delivery) {
+// reloadConfig applies the log level of the file and returns the config the sender runs with.
+// The other settings are kept, their changes are reported as needing a restart.
+func reloadConfig(log *logger.Logger, current Config) Config {
+	reloaded, err := NewConfig(configFile)
+	if err != nil {
+		log.Error("error reloading config, keeping the current one", "error", err)
+		return current
+	}
+
+	if err := log.SetLevel(reloaded.Logger.Level); err != nil {
+		log.Error("error setting logger level", "error", err)
+		return current
+	}
+	log.Info("reloaded config", "level", reloaded.Logger.Level)
+
+	current.Logger.Level = reloaded.Logger.Level
+	if !reflect.DeepEqual(current, reloaded) {
+		log.Warn("config changes other than logger.level need a restart")
+	}
+
+	return current
+}
+
+func handleEvents(log *logger.Logger, deliveries <-chan amqp.Delivery, senderMetrics *metrics.SenderMetrics) {
 	for d := range deliveries {
-		log.Info(
-			fmt.Sprintf(
-				"got %dB delivery: [%v] %q",
-				len(d.Body),
-				d.DeliveryTag,
-				d.Body,
-			),
-		)
-
-		d.Ack(false)
+		log.Info("got delivery", "size", len(d.Body), "delivery_tag", d.DeliveryTag, "body", string(d.Body))
+
+		err := handleDelivery(context.Background(), d)
+		senderMetrics.Delivered(err)
+		if err != nil {
+			log.Error("error handling delivery", "delivery_tag", d.DeliveryTag, "error", err)
+		}
+	}
+
+	log.Debug("deliveries channel closed")
+}
+
+// handleDelivery acks the delivery and reports whether it held an event.
+// It is traced in the span of the scheduler which produced the delivery.
+func handleDelivery(ctx context.Context, d amqp.Delivery) (err error) {
+	_, span := tracer.Start(rabbit.DeliveryContext(ctx, d), d.Exchange+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingOperationDeliver,
+			semconv.MessagingDestinationName(d.Exchange),
+		),
+	)
+	defer tracing.End(span, &err)
+
+	var event storage.Event
+	decodeErr := event.UnmarshalJSON(d.Body)
+	span.SetAttributes(attribute.String("event.id", event.ID))
+
+	if err := d.Ack(false); err != nil {
+		return fmt.Errorf("ack error: %w", err)
+	}
+
+	if decodeErr != nil {
+		return fmt.Errorf("error decoding event: %w", decodeErr)
 	}
 
-	log.Debug("handle: deliveries channel closed")
+	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -59,7 +60,7 @@ func TestCreateEventValidationFail(t *testing.T) {
 	}
 
 	status, resp = sendRequest("http://localhost:8080/event/create", http.MethodPost, formData, headers)
-	require.Equal(t, http.StatusBadRequest, status, string(resp))
+	require.Equal(t, http.StatusUnprocessableEntity, status, string(resp))
 
 	formData.Set("id", uuid.NewString())
 	formData.Set("start_dt", "invalid_date")
@@ -195,8 +196,8 @@ func TestGetEventSuccess(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		`{"id":"`+uuid+`","title":"Test","description":"","start_dt":"2024-06-13T00:00:00Z","end_dt":"2024-07-13T00:00:00Z","creator_id":0,"notify_before":86400000000000}`,
-		string(resp),
+		`{"id":"`+uuid+`","title":"Test","description":"","start_dt":"2024-06-13T00:00:00Z","end_dt":"2024-07-13T00:00:00Z","creator_id":0,"notify_before":86400000000000,"notified":false}`,
+		withoutTimestamps(resp),
 	)
 
 	formData = url.Values{}
@@ -253,8 +254,8 @@ func TestListByDates(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-01T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000}{"id":"`+uuid2+`","title":"Test2","description":"","start_dt":"2024-06-05T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000}]`,
-		string(resp),
+		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-01T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000,"notified":false}{"id":"`+uuid2+`","title":"Test2","description":"","start_dt":"2024-06-05T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000,"notified":false}]`,
+		withoutTimestamps(resp),
 	)
 
 	formData = url.Values{}
@@ -303,8 +304,8 @@ func TestListOnDate(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-01T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000}]`,
-		string(resp),
+		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-01T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000,"notified":false}]`,
+		withoutTimestamps(resp),
 	)
 
 	formData = url.Values{}
@@ -354,8 +355,8 @@ func TestListOnWeek(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-03T00:00:00Z","end_dt":"2024-06-09T00:00:00Z","creator_id":0,"notify_before":86400000000000}]`,
-		string(resp),
+		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-03T00:00:00Z","end_dt":"2024-06-09T00:00:00Z","creator_id":0,"notify_before":86400000000000,"notified":false}]`,
+		withoutTimestamps(resp),
 	)
 
 	formData = url.Values{}
@@ -405,8 +406,8 @@ func TestListOnMonth(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-03T00:00:00Z","end_dt":"2024-06-09T00:00:00Z","creator_id":0,"notify_before":86400000000000}{"id":"`+uuid2+`","title":"Test2","description":"","start_dt":"2024-06-05T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000}]`,
-		string(resp),
+		`[{"id":"`+uuid1+`","title":"Test","description":"","start_dt":"2024-06-03T00:00:00Z","end_dt":"2024-06-09T00:00:00Z","creator_id":0,"notify_before":86400000000000,"notified":false}{"id":"`+uuid2+`","title":"Test2","description":"","start_dt":"2024-06-05T00:00:00Z","end_dt":"2024-06-10T00:00:00Z","creator_id":0,"notify_before":86400000000000,"notified":false}]`,
+		withoutTimestamps(resp),
 	)
 
 	formData = url.Values{}
@@ -418,6 +419,13 @@ func TestListOnMonth(t *testing.T) {
 	sendRequest("http://localhost:8080/event/delete", http.MethodPost, formData, headers)
 }
 
+var timestampsRegexp = regexp.MustCompile(`,"created_at":"[^"]*","updated_at":"[^"]*"`)
+
+// withoutTimestamps removes the creation and update time of the events which are set by the server.
+func withoutTimestamps(resp []byte) string {
+	return timestampsRegexp.ReplaceAllString(string(resp), "")
+}
+
 func sendRequest(url string, method string, formData url.Values, headers map[string]string) (status int, body []byte) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
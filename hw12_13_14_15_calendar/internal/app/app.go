@@ -2,21 +2,45 @@ package app
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel"
+)
+
+// MaxBatchSize limits the number of operations accepted by a single ApplyBatch call.
+const MaxBatchSize = 1000
+
+var tracer = otel.Tracer("github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app")
+
+var (
+	ErrBatchEmpty    = errors.New("batch: no operations passed")
+	ErrBatchTooLarge = errors.New("batch: too many operations")
 )
 
 type App struct {
-	logger  Logger
-	storage Storage
-	userID  int
+	logger           Logger
+	storage          Storage
+	idempotency      *idempotencyStore
+	maxEventsPerUser int
+}
+
+type Config struct {
+	// IdempotencyTTL is how long the retries of a create with the same idempotency key get the original result.
+	IdempotencyTTL time.Duration
+	// MaxEventsPerUser caps the number of events a user may store, zero leaves it unlimited.
+	MaxEventsPerUser int
 }
 
 type Logger interface {
-	Debug(msg string, params ...any)
-	Info(msg string)
-	Error(msg string)
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
 }
 
 type Storage interface {
@@ -24,11 +48,36 @@ type Storage interface {
 	UpdateEvent(ctx context.Context, eventID string, event storage.Event) error
 	DeleteEvent(ctx context.Context, eventID string) error
 	GetEvent(ctx context.Context, eventID string) (storage.Event, error)
-	GetEventsListByDates(ctx context.Context, from *time.Time, to *time.Time) []storage.Event
-	GetEventsForNotify(ctx context.Context, notifyDate string) []storage.Event
-	GetEventsOnDate(ctx context.Context, date time.Time) []storage.Event
-	GetEventsOnWeek(ctx context.Context, weekStartDate time.Time) []storage.Event
-	GetEventsOnMonth(ctx context.Context, monthStartDate time.Time) []storage.Event
+	CountUserEvents(ctx context.Context, creatorID int) (int, error)
+	GetEventsListByDates(
+		ctx context.Context,
+		from *time.Time,
+		to *time.Time,
+		filter storage.EventFilter,
+	) ([]storage.Event, error)
+	GetEventsForNotify(ctx context.Context, notifyDate string) ([]storage.Event, error)
+	GetEventsOnDate(ctx context.Context, date time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventsOnWeek(ctx context.Context, weekStartDate time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventsOnMonth(ctx context.Context, monthStartDate time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventHistory(ctx context.Context, eventID string) ([]storage.EventHistoryRecord, error)
+	DeleteEventHistory(ctx context.Context, eventID string) error
+	ApplyBatch(
+		ctx context.Context,
+		mode storage.BatchMode,
+		operations []storage.BatchOperation,
+	) ([]storage.BatchResult, error)
+	CreateCalendar(ctx context.Context, calendar storage.Calendar) error
+	GetCalendar(ctx context.Context, calendarID string) (storage.Calendar, error)
+	GetUserCalendars(ctx context.Context, userID int) ([]storage.UserCalendar, error)
+	DeleteCalendar(ctx context.Context, calendarID string) error
+	SaveCalendarGrant(ctx context.Context, grant storage.CalendarGrant) error
+	DeleteCalendarGrant(ctx context.Context, calendarID string, userID int) error
+	GetCalendarGrants(ctx context.Context, calendarID string) ([]storage.CalendarGrant, error)
+	SaveTag(ctx context.Context, tag storage.Tag) error
+	DeleteTag(ctx context.Context, ownerID int, name string) error
+	GetUserTags(ctx context.Context, ownerID int) ([]storage.Tag, error)
+	// Ping reports whether the storage can serve the queries, it backs the readiness checks.
+	Ping(ctx context.Context) error
 }
 
 type Server interface {
@@ -37,76 +86,454 @@ type Server interface {
 }
 
 func New(logger Logger, storage Storage) *App {
+	return NewWithConfig(logger, storage, Config{})
+}
+
+func NewWithConfig(logger Logger, storage Storage, config Config) *App {
+	if config.IdempotencyTTL <= 0 {
+		config.IdempotencyTTL = DefaultIdempotencyTTL
+	}
+
 	return &App{
-		logger:  logger,
-		storage: storage,
+		logger:           logger,
+		storage:          storage,
+		idempotency:      newIdempotencyStore(config.IdempotencyTTL),
+		maxEventsPerUser: config.MaxEventsPerUser,
 	}
 }
 
+// CreateEvent returns the ID of the created event, which is generated when an empty id is passed.
+// The retries with the idempotency key of ctx get the ID of the event created by the first call.
 func (a *App) CreateEvent(
 	ctx context.Context,
-	id, title string,
+	id, calendarID, title, description, location, url string,
 	startDate time.Time,
 	endDate time.Time,
 	notifyBefore time.Duration,
-) error {
-	return a.storage.CreateEvent(
-		ctx,
-		storage.Event{
-			ID:           id,
-			Title:        title,
-			StartDate:    startDate,
-			EndDate:      endDate,
-			CreatorID:    a.userID,
-			NotifyBefore: notifyBefore,
-		},
-	)
+	tags []string,
+) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "App.CreateEvent")
+	defer tracing.End(span, &err)
+
+	event := storage.Event{
+		ID:           id,
+		CalendarID:   calendarID,
+		Title:        title,
+		Description:  description,
+		Location:     location,
+		URL:          url,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		CreatorID:    identity.UserID(ctx),
+		NotifyBefore: notifyBefore,
+		Tags:         tags,
+	}
+
+	return a.idempotent(ctx, createEventFingerprint(event), func() (string, error) {
+		if event.ID == "" {
+			event.ID = uuid.NewString()
+		}
+
+		err := validateEvent(event)
+		if err != nil {
+			return "", err
+		}
+
+		event.Tags, err = storage.NormalizeTags(tags)
+		if err != nil {
+			return "", err
+		}
+
+		err = a.checkCalendarWriteAccess(ctx, calendarID, event.CreatorID)
+		if err != nil {
+			return "", err
+		}
+
+		left, err := a.eventQuotaLeft(ctx)
+		if err != nil {
+			return "", err
+		}
+		if left == 0 {
+			return "", ErrEventQuotaExceeded
+		}
+
+		event.CreatedAt = time.Now().UTC()
+		event.UpdatedAt = event.CreatedAt
+
+		err = a.storage.CreateEvent(ctx, event)
+		if err != nil {
+			return "", err
+		}
+
+		return event.ID, nil
+	})
 }
 
+// UpdateEvent keeps the current calendar of the event when an empty calendarID is passed.
+// The creator of the event is kept and the notification is sent again only when its time changes.
 func (a *App) UpdateEvent(
 	ctx context.Context,
-	id, title string,
+	id, calendarID, title, description, location, url string,
 	startDate time.Time,
 	endDate time.Time,
 	notifyBefore time.Duration,
-) error {
-	return a.storage.UpdateEvent(
-		ctx,
-		id,
-		storage.Event{
-			Title:        title,
-			StartDate:    startDate,
-			EndDate:      endDate,
-			CreatorID:    a.userID,
-			NotifyBefore: notifyBefore,
-		},
-	)
-}
-
-func (a *App) DeleteEvent(ctx context.Context, id string) error {
+	tags []string,
+) (err error) {
+	ctx, span := tracer.Start(ctx, "App.UpdateEvent")
+	defer tracing.End(span, &err)
+
+	event := storage.Event{
+		ID:           id,
+		CalendarID:   calendarID,
+		Title:        title,
+		Description:  description,
+		Location:     location,
+		URL:          url,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: notifyBefore,
+	}
+
+	err = validateEvent(event)
+	if err != nil {
+		return err
+	}
+
+	event.Tags, err = storage.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	savedEvent, err := a.storage.GetEvent(ctx, id)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		return storage.ErrUpdateEventIDNotExists
+	}
+	if err != nil {
+		return err
+	}
+
+	err = a.checkCalendarWriteAccess(ctx, savedEvent.CalendarID, savedEvent.CreatorID)
+	if err != nil {
+		return err
+	}
+
+	if event.CalendarID == "" {
+		event.CalendarID = savedEvent.CalendarID
+	}
+
+	if event.CalendarID != savedEvent.CalendarID {
+		err = a.checkCalendarWriteAccess(ctx, event.CalendarID, savedEvent.CreatorID)
+		if err != nil {
+			return err
+		}
+	}
+
+	keepEventState(&event, savedEvent)
+
+	return a.storage.UpdateEvent(ctx, id, event)
+}
+
+// keepEventState copies the fields of the saved event which the update does not change.
+func keepEventState(event *storage.Event, savedEvent storage.Event) {
+	event.CreatorID = savedEvent.CreatorID
+	event.CreatedAt = savedEvent.CreatedAt
+	event.UpdatedAt = time.Now().UTC()
+	event.Notified = savedEvent.Notified &&
+		event.StartDate.Equal(savedEvent.StartDate) &&
+		event.NotifyBefore == savedEvent.NotifyBefore
+}
+
+func (a *App) DeleteEvent(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.DeleteEvent")
+	defer tracing.End(span, &err)
+
+	savedEvent, err := a.storage.GetEvent(ctx, id)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = a.checkCalendarWriteAccess(ctx, savedEvent.CalendarID, savedEvent.CreatorID)
+	if err != nil {
+		return err
+	}
+
 	return a.storage.DeleteEvent(ctx, id)
 }
 
-func (a *App) GetEvent(ctx context.Context, id string) (storage.Event, error) {
-	return a.storage.GetEvent(ctx, id)
+func (a *App) GetEvent(ctx context.Context, id string) (_ storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEvent")
+	defer tracing.End(span, &err)
+
+	event, err := a.storage.GetEvent(ctx, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	permission, err := a.calendarPermission(ctx, event.CalendarID, event.CreatorID)
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	if !permission.CanRead() {
+		return maskEvent(event), nil
+	}
+
+	return event, nil
 }
 
-func (a *App) GetEventsListByDates(ctx context.Context, from *time.Time, to *time.Time) []storage.Event {
-	return a.storage.GetEventsListByDates(ctx, from, to)
+func (a *App) GetEventsListByDates(
+	ctx context.Context,
+	from *time.Time,
+	to *time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEventsListByDates")
+	defer tracing.End(span, &err)
+
+	filter, freeBusy, err := a.eventFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEventsListByDates(ctx, from, to, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return maskFreeBusyEvents(events, freeBusy), nil
 }
 
-func (a *App) GetEventsForNotify(ctx context.Context, notifyDate string) []storage.Event {
+func (a *App) GetEventsForNotify(ctx context.Context, notifyDate string) (_ []storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEventsForNotify")
+	defer tracing.End(span, &err)
+
 	return a.storage.GetEventsForNotify(ctx, notifyDate)
 }
 
-func (a *App) GetEventsOnDate(ctx context.Context, date time.Time) []storage.Event {
-	return a.storage.GetEventsOnDate(ctx, date)
+func (a *App) GetEventsOnDate(
+	ctx context.Context,
+	date time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEventsOnDate")
+	defer tracing.End(span, &err)
+
+	filter, freeBusy, err := a.eventFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEventsOnDate(ctx, date, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return maskFreeBusyEvents(events, freeBusy), nil
 }
 
-func (a *App) GetEventsOnWeek(ctx context.Context, weekStartDate time.Time) []storage.Event {
-	return a.storage.GetEventsOnWeek(ctx, weekStartDate)
+func (a *App) GetEventsOnWeek(
+	ctx context.Context,
+	weekStartDate time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEventsOnWeek")
+	defer tracing.End(span, &err)
+
+	filter, freeBusy, err := a.eventFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEventsOnWeek(ctx, weekStartDate, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return maskFreeBusyEvents(events, freeBusy), nil
+}
+
+func (a *App) GetEventsOnMonth(
+	ctx context.Context,
+	monthStartDate time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEventsOnMonth")
+	defer tracing.End(span, &err)
+
+	filter, freeBusy, err := a.eventFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEventsOnMonth(ctx, monthStartDate, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return maskFreeBusyEvents(events, freeBusy), nil
+}
+
+func (a *App) GetEventHistory(ctx context.Context, id string) (_ []storage.EventHistoryRecord, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEventHistory")
+	defer tracing.End(span, &err)
+
+	history, err := a.storage.GetEventHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history) == 0 {
+		return history, nil
+	}
+
+	// The event may already be deleted, so the access is checked against its last known calendar.
+	last := history[len(history)-1]
+	calendarID := ""
+	creatorID := 0
+	switch {
+	case last.After != nil:
+		calendarID, creatorID = last.After.CalendarID, last.After.CreatorID
+	case last.Before != nil:
+		calendarID, creatorID = last.Before.CalendarID, last.Before.CreatorID
+	}
+
+	permission, err := a.calendarPermission(ctx, calendarID, creatorID)
+	if err != nil && !errors.Is(err, storage.ErrCalendarNotExists) {
+		return nil, err
+	}
+
+	if !permission.CanRead() {
+		return nil, storage.ErrCalendarAccessDenied
+	}
+
+	return history, nil
+}
+
+func (a *App) ApplyBatch(
+	ctx context.Context,
+	mode storage.BatchMode,
+	operations []storage.BatchOperation,
+) (_ []storage.BatchResult, err error) {
+	ctx, span := tracer.Start(ctx, "App.ApplyBatch")
+	defer tracing.End(span, &err)
+
+	if len(operations) == 0 {
+		return nil, ErrBatchEmpty
+	}
+
+	if len(operations) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	quotaLeft, err := a.eventQuotaLeft(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]storage.BatchOperation, 0, len(operations))
+	allowedIndexes := make([]int, 0, len(operations))
+	results := make([]storage.BatchResult, len(operations))
+
+	now := time.Now().UTC()
+	for i := range operations {
+		operations[i].Event.CreatorID = identity.UserID(ctx)
+
+		eventID := operations[i].EventID
+		if operations[i].Type == storage.BatchOperationCreate {
+			if operations[i].Event.ID == "" {
+				operations[i].Event.ID = uuid.NewString()
+			}
+			eventID = operations[i].Event.ID
+			operations[i].Event.CreatedAt = now
+			operations[i].Event.UpdatedAt = now
+		}
+
+		results[i] = storage.BatchResult{Index: i, EventID: eventID}
+		if operations[i].Type != storage.BatchOperationDelete {
+			event := operations[i].Event
+			event.ID = eventID
+			results[i].Err = validateEvent(event)
+			if results[i].Err != nil {
+				continue
+			}
+		}
+
+		operations[i].Event.Tags, results[i].Err = storage.NormalizeTags(operations[i].Event.Tags)
+		if results[i].Err != nil {
+			continue
+		}
+
+		results[i].Err = a.checkBatchOperationAccess(ctx, &operations[i])
+		if results[i].Err != nil {
+			continue
+		}
+
+		if operations[i].Type == storage.BatchOperationCreate && quotaLeft >= 0 {
+			if quotaLeft == 0 {
+				results[i].Err = ErrEventQuotaExceeded
+				continue
+			}
+			quotaLeft--
+		}
+
+		allowed = append(allowed, operations[i])
+		allowedIndexes = append(allowedIndexes, i)
+	}
+
+	if len(allowed) < len(operations) && mode == storage.BatchModeAllOrNothing {
+		storage.AbortBatchResults(results)
+		return results, nil
+	}
+
+	if len(allowed) == 0 {
+		return results, nil
+	}
+
+	allowedResults, err := a.storage.ApplyBatch(ctx, mode, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range allowedResults {
+		index := allowedIndexes[allowedResults[i].Index]
+		results[index].Err = allowedResults[i].Err
+	}
+
+	return results, nil
 }
 
-func (a *App) GetEventsOnMonth(ctx context.Context, monthStartDate time.Time) []storage.Event {
-	return a.storage.GetEventsOnMonth(ctx, monthStartDate)
+func (a *App) checkBatchOperationAccess(ctx context.Context, operation *storage.BatchOperation) error {
+	if operation.Type == storage.BatchOperationCreate {
+		return a.checkCalendarWriteAccess(ctx, operation.Event.CalendarID, operation.Event.CreatorID)
+	}
+
+	savedEvent, err := a.storage.GetEvent(ctx, operation.EventID)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		// the storage reports the missing event itself
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = a.checkCalendarWriteAccess(ctx, savedEvent.CalendarID, savedEvent.CreatorID)
+	if err != nil {
+		return err
+	}
+
+	if operation.Type != storage.BatchOperationUpdate {
+		return nil
+	}
+
+	keepEventState(&operation.Event, savedEvent)
+
+	if operation.Event.CalendarID == "" {
+		operation.Event.CalendarID = savedEvent.CalendarID
+		return nil
+	}
+
+	return a.checkCalendarWriteAccess(ctx, operation.Event.CalendarID, savedEvent.CreatorID)
 }
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
+)
+
+// busyEventTitle replaces the title of events shown to users with the free/busy permission only.
+const busyEventTitle = "Busy"
+
+func (a *App) CreateCalendar(ctx context.Context, name string) (_ storage.Calendar, err error) {
+	ctx, span := tracer.Start(ctx, "App.CreateCalendar")
+	defer tracing.End(span, &err)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return storage.Calendar{}, storage.ErrCalendarNameEmpty
+	}
+
+	calendar := storage.Calendar{
+		ID:      uuid.NewString(),
+		OwnerID: identity.UserID(ctx),
+		Name:    name,
+	}
+
+	err = a.storage.CreateCalendar(ctx, calendar)
+	if err != nil {
+		return storage.Calendar{}, err
+	}
+
+	return calendar, nil
+}
+
+func (a *App) GetCalendars(ctx context.Context) (_ []storage.UserCalendar, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetCalendars")
+	defer tracing.End(span, &err)
+
+	return a.storage.GetUserCalendars(ctx, identity.UserID(ctx))
+}
+
+func (a *App) DeleteCalendar(ctx context.Context, calendarID string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.DeleteCalendar")
+	defer tracing.End(span, &err)
+
+	_, err = a.ownCalendar(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+
+	return a.storage.DeleteCalendar(ctx, calendarID)
+}
+
+func (a *App) ShareCalendar(
+	ctx context.Context,
+	calendarID string,
+	userID int,
+	permission storage.CalendarPermission,
+) (err error) {
+	ctx, span := tracer.Start(ctx, "App.ShareCalendar")
+	defer tracing.End(span, &err)
+
+	if !permission.Valid() {
+		return storage.ErrUnknownCalendarPermission
+	}
+
+	calendar, err := a.ownCalendar(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+
+	if calendar.OwnerID == userID {
+		return storage.ErrCalendarShareOwner
+	}
+
+	return a.storage.SaveCalendarGrant(ctx, storage.CalendarGrant{
+		CalendarID: calendarID,
+		UserID:     userID,
+		Permission: permission,
+	})
+}
+
+func (a *App) UnshareCalendar(ctx context.Context, calendarID string, userID int) (err error) {
+	ctx, span := tracer.Start(ctx, "App.UnshareCalendar")
+	defer tracing.End(span, &err)
+
+	_, err = a.ownCalendar(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+
+	return a.storage.DeleteCalendarGrant(ctx, calendarID, userID)
+}
+
+func (a *App) GetCalendarGrants(ctx context.Context, calendarID string) (_ []storage.CalendarGrant, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetCalendarGrants")
+	defer tracing.End(span, &err)
+
+	_, err = a.ownCalendar(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.storage.GetCalendarGrants(ctx, calendarID)
+}
+
+func (a *App) ownCalendar(ctx context.Context, calendarID string) (storage.Calendar, error) {
+	calendar, err := a.storage.GetCalendar(ctx, calendarID)
+	if err != nil {
+		return storage.Calendar{}, err
+	}
+
+	if calendar.OwnerID != identity.UserID(ctx) {
+		return storage.Calendar{}, storage.ErrCalendarAccessDenied
+	}
+
+	return calendar, nil
+}
+
+// calendarPermission returns the permission of the current user on the calendar of an event
+// created by the creator. The events outside of any calendar belong to their creator only.
+func (a *App) calendarPermission(
+	ctx context.Context,
+	calendarID string,
+	creatorID int,
+) (storage.CalendarPermission, error) {
+	if calendarID == "" {
+		if creatorID != identity.UserID(ctx) {
+			return "", storage.ErrCalendarAccessDenied
+		}
+		return storage.CalendarPermissionOwner, nil
+	}
+
+	calendar, err := a.storage.GetCalendar(ctx, calendarID)
+	if err != nil {
+		return "", err
+	}
+
+	userID := identity.UserID(ctx)
+	if calendar.OwnerID == userID {
+		return storage.CalendarPermissionOwner, nil
+	}
+
+	grants, err := a.storage.GetCalendarGrants(ctx, calendarID)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range grants {
+		if grants[i].UserID == userID {
+			return grants[i].Permission, nil
+		}
+	}
+
+	return "", storage.ErrCalendarAccessDenied
+}
+
+func (a *App) checkCalendarWriteAccess(ctx context.Context, calendarID string, creatorID int) error {
+	permission, err := a.calendarPermission(ctx, calendarID, creatorID)
+	if err != nil {
+		return err
+	}
+
+	if !permission.CanWrite() {
+		return storage.ErrCalendarAccessDenied
+	}
+
+	return nil
+}
+
+// eventFilter restricts the passed filter to the calendars visible to the current user.
+// Without requested calendars the events outside of any calendar and of every visible calendar are listed,
+// the events outside of any calendar are always limited to the ones the user created.
+// The returned set contains the calendars the user may see only as free/busy.
+func (a *App) eventFilter(
+	ctx context.Context,
+	filter storage.EventFilter,
+) (storage.EventFilter, map[string]struct{}, error) {
+	userID := identity.UserID(ctx)
+	calendars, err := a.storage.GetUserCalendars(ctx, userID)
+	if err != nil {
+		return storage.EventFilter{}, nil, err
+	}
+	filter.CreatorID = &userID
+
+	permissions := make(map[string]storage.CalendarPermission, len(calendars))
+	freeBusy := make(map[string]struct{})
+	for i := range calendars {
+		permissions[calendars[i].ID] = calendars[i].Permission
+		if !calendars[i].Permission.CanRead() {
+			freeBusy[calendars[i].ID] = struct{}{}
+		}
+	}
+
+	if len(filter.CalendarIDs) == 0 {
+		filter.CalendarIDs = []string{""}
+		for i := range calendars {
+			filter.CalendarIDs = append(filter.CalendarIDs, calendars[i].ID)
+		}
+		return filter, freeBusy, nil
+	}
+
+	for _, calendarID := range filter.CalendarIDs {
+		if calendarID == "" {
+			continue
+		}
+
+		if _, ok := permissions[calendarID]; !ok {
+			return storage.EventFilter{}, nil, storage.ErrCalendarAccessDenied
+		}
+	}
+
+	return filter, freeBusy, nil
+}
+
+func maskFreeBusyEvents(events []storage.Event, freeBusy map[string]struct{}) []storage.Event {
+	for i := range events {
+		if _, ok := freeBusy[events[i].CalendarID]; ok {
+			events[i] = maskEvent(events[i])
+		}
+	}
+
+	return events
+}
+
+func maskEvent(event storage.Event) storage.Event {
+	return storage.Event{
+		ID:         event.ID,
+		CalendarID: event.CalendarID,
+		Title:      busyEventTitle,
+		StartDate:  event.StartDate,
+		EndDate:    event.EndDate,
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+)
+
+func TestEventsWithoutCalendar(t *testing.T) {
+	a := New(nil, memorystorage.New())
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	from, to := start.Add(-time.Hour), start.Add(2*time.Hour)
+
+	owner := identity.WithUserID(context.Background(), 1)
+	other := identity.WithUserID(context.Background(), 2)
+
+	id, err := a.CreateEvent(owner, "", "", "Private", "", "", "", start, end, 0, nil)
+	require.NoError(t, err)
+	calendar, err := a.CreateCalendar(other, "Work")
+	require.NoError(t, err)
+
+	_, err = a.GetEvent(other, id)
+	require.ErrorIs(t, err, storage.ErrCalendarAccessDenied)
+	_, err = a.GetEventHistory(other, id)
+	require.ErrorIs(t, err, storage.ErrCalendarAccessDenied)
+
+	err = a.UpdateEvent(other, id, "", "Changed", "", "", "", start, end, 0, nil)
+	require.ErrorIs(t, err, storage.ErrCalendarAccessDenied)
+	err = a.UpdateEvent(other, id, calendar.ID, "Moved", "", "", "", start, end, 0, nil)
+	require.ErrorIs(t, err, storage.ErrCalendarAccessDenied)
+	require.ErrorIs(t, a.DeleteEvent(other, id), storage.ErrCalendarAccessDenied)
+
+	results, err := a.ApplyBatch(other, storage.BatchModeBestEffort, []storage.BatchOperation{
+		{Type: storage.BatchOperationDelete, EventID: id},
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, storage.ErrCalendarAccessDenied)
+
+	events, err := a.GetEventsListByDates(other, &from, &to, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Empty(t, events)
+	events, err = a.GetEventsListByDates(other, &from, &to, storage.EventFilter{CalendarIDs: []string{""}})
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	// The creator keeps the full access.
+	event, err := a.GetEvent(owner, id)
+	require.NoError(t, err)
+	require.Equal(t, "Private", event.Title)
+
+	events, err = a.GetEventsListByDates(owner, &from, &to, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	require.NoError(t, a.UpdateEvent(owner, id, "", "Changed", "", "", "", start, end, 0, nil))
+	require.NoError(t, a.DeleteEvent(owner, id))
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+// DefaultIdempotencyTTL is how long the result of a create with an idempotency key is kept by default.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// MaxIdempotencyKeyLength limits the length of the keys passed by the clients.
+const MaxIdempotencyKeyLength = 255
+
+var (
+	ErrIdempotencyKeyReused  = errors.New("idempotency key: already used for another request")
+	ErrIdempotencyKeyTooLong = errors.New("idempotency key: too long")
+
+	errCreatePanicked = errors.New("create panicked")
+)
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx carrying the idempotency key passed by the client.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+type idempotencyEntry struct {
+	key         string
+	fingerprint string
+	eventID     string
+	done        chan struct{}
+	expiresAt   time.Time
+}
+
+// idempotencyStore keeps the IDs of the events created with an idempotency key.
+// Only successful creates are kept, so a retry of a failed one runs again.
+type idempotencyStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// order holds the finished entries by expiration time, the ttl is the same for all of them.
+	order []*idempotencyEntry
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// begin returns the entry of the key and whether the caller must run the request and finish the entry.
+func (s *idempotencyStore) begin(key, fingerprint string) (*idempotencyEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+
+	entry, ok := s.entries[key]
+	if ok {
+		if entry.fingerprint != fingerprint {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+
+		return entry, false, nil
+	}
+
+	entry = &idempotencyEntry{
+		key:         key,
+		fingerprint: fingerprint,
+		done:        make(chan struct{}),
+	}
+	s.entries[key] = entry
+
+	return entry, true, nil
+}
+
+func (s *idempotencyStore) finish(entry *idempotencyEntry, eventID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		delete(s.entries, entry.key)
+	} else {
+		entry.eventID = eventID
+		entry.expiresAt = time.Now().Add(s.ttl)
+		s.order = append(s.order, entry)
+	}
+
+	close(entry.done)
+}
+
+// run finishes the entry even when create panics, so the retries are not blocked.
+func (s *idempotencyStore) run(entry *idempotencyEntry, create func() (string, error)) (eventID string, err error) {
+	err = errCreatePanicked
+	defer func() {
+		s.finish(entry, eventID, err)
+	}()
+
+	return create()
+}
+
+func (s *idempotencyStore) expire(now time.Time) {
+	expired := 0
+	for expired < len(s.order) && !s.order[expired].expiresAt.After(now) {
+		if s.entries[s.order[expired].key] == s.order[expired] {
+			delete(s.entries, s.order[expired].key)
+		}
+		expired++
+	}
+
+	s.order = s.order[expired:]
+}
+
+// idempotent runs create once per key of the current user within the ttl and returns the original event ID
+// to the retries.
+func (a *App) idempotent(ctx context.Context, fingerprint string, create func() (string, error)) (string, error) {
+	key := idempotencyKeyFrom(ctx)
+	if key == "" {
+		return create()
+	}
+
+	if len(key) > MaxIdempotencyKeyLength {
+		return "", ErrIdempotencyKeyTooLong
+	}
+
+	key = strconv.Itoa(identity.UserID(ctx)) + "\x00" + key
+	for {
+		entry, owner, err := a.idempotency.begin(key, fingerprint)
+		if err != nil {
+			return "", err
+		}
+
+		if owner {
+			return a.idempotency.run(entry, create)
+		}
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		if entry.eventID != "" {
+			return entry.eventID, nil
+		}
+		// the request with the same key failed, so this one runs again
+	}
+}
+
+func createEventFingerprint(event storage.Event) string {
+	return fmt.Sprintf(
+		"%q %q %q %q %q %q %d %d %d %q",
+		event.ID,
+		event.CalendarID,
+		event.Title,
+		event.Description,
+		event.Location,
+		event.URL,
+		event.StartDate.UnixNano(),
+		event.EndDate.UnixNano(),
+		event.NotifyBefore,
+		strings.Join(event.Tags, "\x00"),
+	)
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotent(t *testing.T) {
+	a := NewWithConfig(nil, nil, Config{IdempotencyTTL: time.Hour})
+	ctx := WithIdempotencyKey(context.Background(), "key")
+
+	t.Run("concurrent retries get the first result", func(t *testing.T) {
+		var calls atomic.Int32
+		create := func() (string, error) {
+			calls.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return "event-1", nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				eventID, err := a.idempotent(ctx, "request", create)
+				require.NoError(t, err)
+				require.Equal(t, "event-1", eventID)
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("another request with the same key", func(t *testing.T) {
+		_, err := a.idempotent(ctx, "another request", func() (string, error) {
+			return "event-2", nil
+		})
+		require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	})
+
+	t.Run("failed create is not kept", func(t *testing.T) {
+		ctx := WithIdempotencyKey(context.Background(), "failing")
+		failure := errors.New("storage failure")
+
+		_, err := a.idempotent(ctx, "request", func() (string, error) {
+			return "", failure
+		})
+		require.ErrorIs(t, err, failure)
+
+		eventID, err := a.idempotent(ctx, "request", func() (string, error) {
+			return "event-3", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "event-3", eventID)
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		a.idempotency.expire(time.Now().Add(2 * time.Hour))
+
+		eventID, err := a.idempotent(ctx, "another request", func() (string, error) {
+			return "event-4", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "event-4", eventID)
+	})
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+)
+
+var ErrEventQuotaExceeded = errors.New("event quota: too many events stored by the user")
+
+// eventQuotaLeft returns how many more events the current user may create, or -1 when
+// the user is not limited: the cap is off or the call is made without a user.
+// The count is not taken atomically with the create, so the concurrent creates
+// of a user may go over the cap by a few events.
+func (a *App) eventQuotaLeft(ctx context.Context) (int, error) {
+	userID := identity.UserID(ctx)
+	if a.maxEventsPerUser <= 0 || userID == 0 {
+		return -1, nil
+	}
+
+	count, err := a.storage.CountUserEvents(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if count >= a.maxEventsPerUser {
+		return 0, nil
+	}
+
+	return a.maxEventsPerUser - count, nil
+}
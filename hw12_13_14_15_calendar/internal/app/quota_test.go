@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+)
+
+func TestEventQuota(t *testing.T) {
+	a := NewWithConfig(nil, memorystorage.New(), Config{MaxEventsPerUser: 3})
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	create := func(ctx context.Context) error {
+		_, err := a.CreateEvent(ctx, "", "", "Event", "", "", "", start, start.Add(time.Hour), 0, nil)
+		return err
+	}
+
+	first := identity.WithUserID(context.Background(), 1)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, create(first))
+	}
+
+	batch := func(ctx context.Context, mode storage.BatchMode) []storage.BatchResult {
+		operations := make([]storage.BatchOperation, 2)
+		for i := range operations {
+			operations[i] = storage.BatchOperation{
+				Type:  storage.BatchOperationCreate,
+				Event: storage.Event{Title: "Batch event", StartDate: start, EndDate: start.Add(time.Hour)},
+			}
+		}
+		results, err := a.ApplyBatch(ctx, mode, operations)
+		require.NoError(t, err)
+		return results
+	}
+
+	results := batch(first, storage.BatchModeAllOrNothing)
+	require.ErrorIs(t, results[0].Err, storage.ErrBatchAborted)
+	require.ErrorIs(t, results[1].Err, ErrEventQuotaExceeded)
+
+	results = batch(first, storage.BatchModeBestEffort)
+	require.NoError(t, results[0].Err)
+	require.ErrorIs(t, results[1].Err, ErrEventQuotaExceeded)
+
+	require.ErrorIs(t, create(first), ErrEventQuotaExceeded)
+
+	// The other users and the calls without a user are not affected.
+	require.NoError(t, create(identity.WithUserID(context.Background(), 2)))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, create(context.Background()))
+	}
+}
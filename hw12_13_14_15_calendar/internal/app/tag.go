@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
+)
+
+// SaveTag creates the tag of the current user or changes the color of the existing one.
+func (a *App) SaveTag(ctx context.Context, name, color string) (_ storage.Tag, err error) {
+	ctx, span := tracer.Start(ctx, "App.SaveTag")
+	defer tracing.End(span, &err)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return storage.Tag{}, storage.ErrTagNameEmpty
+	}
+
+	if len(name) > storage.MaxTagNameLength {
+		return storage.Tag{}, storage.ErrTagNameTooLong
+	}
+
+	err = storage.ValidateTagColor(color)
+	if err != nil {
+		return storage.Tag{}, err
+	}
+
+	tag := storage.Tag{
+		OwnerID: identity.UserID(ctx),
+		Name:    name,
+		Color:   strings.ToLower(color),
+	}
+
+	err = a.storage.SaveTag(ctx, tag)
+	if err != nil {
+		return storage.Tag{}, err
+	}
+
+	return tag, nil
+}
+
+// DeleteTag removes the tag definition only, the events keep the tag name.
+func (a *App) DeleteTag(ctx context.Context, name string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.DeleteTag")
+	defer tracing.End(span, &err)
+
+	return a.storage.DeleteTag(ctx, identity.UserID(ctx), strings.TrimSpace(name))
+}
+
+func (a *App) GetTags(ctx context.Context) (_ []storage.Tag, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetTags")
+	defer tracing.End(span, &err)
+
+	return a.storage.GetUserTags(ctx, identity.UserID(ctx))
+}
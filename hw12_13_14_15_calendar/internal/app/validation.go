@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	// MaxTitleLength is the length of the title column of the event tables.
+	MaxTitleLength = 255
+	// MaxLocationLength is the length of the location column of the event tables.
+	MaxLocationLength = 255
+)
+
+var ErrValidation = errors.New("validation failed")
+
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists every invalid field of the passed event, the field names are the ones of the APIs.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+func (e *ValidationError) add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+func validateEvent(event storage.Event) error {
+	verr := &ValidationError{}
+
+	if _, err := uuid.Parse(event.ID); err != nil {
+		verr.add("id", "must be a uuid")
+	}
+
+	switch {
+	case strings.TrimSpace(event.Title) == "":
+		verr.add("title", "must not be empty")
+	case utf8.RuneCountInString(event.Title) > MaxTitleLength:
+		verr.add("title", "must be at most 255 characters")
+	}
+
+	if utf8.RuneCountInString(event.Location) > MaxLocationLength {
+		verr.add("location", "must be at most 255 characters")
+	}
+
+	if event.URL != "" && !isWebURL(event.URL) {
+		verr.add("url", "must be an absolute http or https url")
+	}
+
+	if event.StartDate.IsZero() {
+		verr.add("start_dt", "must be set")
+	}
+
+	if !event.EndDate.After(event.StartDate) {
+		verr.add("end_dt", "must be after start_dt")
+	}
+
+	if event.NotifyBefore < 0 {
+		verr.add("notify_before", "must not be negative")
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+func isWebURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
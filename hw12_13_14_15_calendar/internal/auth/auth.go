@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken     = errors.New("auth: invalid token")
+	ErrNoAuthenticators = errors.New("auth: neither tokens nor JWT keys configured")
+	ErrInvalidTokenUser = errors.New("auth: token user ID must be positive")
+)
+
+type Config struct {
+	// Tokens are the static API tokens, every one of them authenticates a single user.
+	Tokens []Token
+	JWT    JWTConfig
+}
+
+type Token struct {
+	Token  string
+	UserID int
+}
+
+type JWTConfig struct {
+	// HMACSecret validates the HS256 tokens, they are rejected when it is empty.
+	HMACSecret string
+	// PublicKeyFile is a PEM file with the RSA public key of the RS256 tokens.
+	PublicKeyFile string
+	// JWKSFile is a JSON Web Key Set file with the RSA keys of the RS256 tokens, chosen by the kid header.
+	JWKSFile string
+	// Issuer and Audience are checked against the iss and aud claims when they are set.
+	Issuer   string
+	Audience string
+	// Leeway is the clock skew allowed when the exp and nbf claims are checked.
+	Leeway time.Duration
+}
+
+// Authenticator resolves the static API tokens and the JWTs to the IDs of the users.
+// The user of a JWT is its sub claim, which must be a positive integer.
+type Authenticator struct {
+	// tokens are the user IDs by the SHA-256 of the token, so the lookup time does not depend
+	// on how much of the token matches.
+	tokens map[[sha256.Size]byte]int
+	jwt    *jwtValidator
+}
+
+func New(config Config) (*Authenticator, error) {
+	a := &Authenticator{tokens: make(map[[sha256.Size]byte]int, len(config.Tokens))}
+
+	for _, token := range config.Tokens {
+		if token.UserID <= 0 {
+			return nil, ErrInvalidTokenUser
+		}
+		a.tokens[sha256.Sum256([]byte(token.Token))] = token.UserID
+	}
+
+	jwt, err := newJWTValidator(config.JWT)
+	if err != nil {
+		return nil, err
+	}
+	a.jwt = jwt
+
+	if len(a.tokens) == 0 && a.jwt == nil {
+		return nil, ErrNoAuthenticators
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the ID of the user the token was issued to.
+func (a *Authenticator) Authenticate(_ context.Context, token string) (int, error) {
+	if token == "" {
+		return 0, ErrInvalidToken
+	}
+
+	if userID, ok := a.tokens[sha256.Sum256([]byte(token))]; ok {
+		return userID, nil
+	}
+
+	if a.jwt != nil && strings.Count(token, ".") == 2 {
+		return a.jwt.validate(token)
+	}
+
+	return 0, ErrInvalidToken
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func signedToken(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return signed
+}
+
+func claimsOf(subject string, expiresIn time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "calendar-tests",
+		Audience:  jwt.ClaimStrings{"calendar"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	}
+}
+
+func TestAuthenticatorTokens(t *testing.T) {
+	a, err := New(Config{Tokens: []Token{{Token: "secret-1", UserID: 1}, {Token: "secret-2", UserID: 2}}})
+	require.NoError(t, err)
+
+	userID, err := a.Authenticate(context.Background(), "secret-2")
+	require.NoError(t, err)
+	require.Equal(t, 2, userID)
+
+	_, err = a.Authenticate(context.Background(), "secret-3")
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = a.Authenticate(context.Background(), "")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestAuthenticatorHS256(t *testing.T) {
+	a, err := New(Config{JWT: JWTConfig{HMACSecret: "hmac-secret", Issuer: "calendar-tests", Audience: "calendar"}})
+	require.NoError(t, err)
+
+	hs256 := func(claims jwt.RegisteredClaims) string {
+		return signedToken(t, jwt.SigningMethodHS256, []byte("hmac-secret"), "", claims)
+	}
+
+	userID, err := a.Authenticate(context.Background(), hs256(claimsOf("7", time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, 7, userID)
+
+	invalid := map[string]string{
+		"wrong secret": signedToken(t, jwt.SigningMethodHS256, []byte("other"), "", claimsOf("7", time.Minute)),
+		"expired":      hs256(claimsOf("7", -time.Minute)),
+		"not a user":   hs256(claimsOf("admin", time.Minute)),
+		"without exp": hs256(jwt.RegisteredClaims{
+			Subject: "7", Issuer: "calendar-tests", Audience: jwt.ClaimStrings{"calendar"},
+		}),
+		"other issuer": hs256(jwt.RegisteredClaims{
+			Subject: "7", Issuer: "other", Audience: jwt.ClaimStrings{"calendar"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		}),
+		"none algorithm": signedToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "",
+			claimsOf("7", time.Minute)),
+	}
+	for name, token := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := a.Authenticate(context.Background(), token)
+			require.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestAuthenticatorRS256(t *testing.T) {
+	pemKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwksKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&pemKey.PublicKey)
+	require.NoError(t, err)
+	pemFile := filepath.Join(dir, "public.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+	require.NoError(t, os.WriteFile(pemFile, pemData, 0o600))
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "EC", "kid": "ec-key", "crv": "P-256"},
+		{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(jwksKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(jwksKey.E)).Bytes()),
+		},
+	}})
+	require.NoError(t, err)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0o600))
+
+	a, err := New(Config{JWT: JWTConfig{PublicKeyFile: pemFile, JWKSFile: jwksFile}})
+	require.NoError(t, err)
+
+	userID, err := a.Authenticate(context.Background(),
+		signedToken(t, jwt.SigningMethodRS256, pemKey, "", claimsOf("3", time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, 3, userID)
+
+	userID, err = a.Authenticate(context.Background(),
+		signedToken(t, jwt.SigningMethodRS256, jwksKey, "key-1", claimsOf("4", time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, 4, userID)
+
+	_, err = a.Authenticate(context.Background(),
+		signedToken(t, jwt.SigningMethodRS256, jwksKey, "key-2", claimsOf("4", time.Minute)))
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = a.Authenticate(context.Background(),
+		signedToken(t, jwt.SigningMethodRS256, jwksKey, "", claimsOf("4", time.Minute)))
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// Without an HMAC secret the HS256 tokens are rejected.
+	_, err = a.Authenticate(context.Background(),
+		signedToken(t, jwt.SigningMethodHS256, []byte(""), "", claimsOf("4", time.Minute)))
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewFail(t *testing.T) {
+	_, err := New(Config{})
+	require.ErrorIs(t, err, ErrNoAuthenticators)
+
+	_, err = New(Config{Tokens: []Token{{Token: "secret", UserID: 0}}})
+	require.ErrorIs(t, err, ErrInvalidTokenUser)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, []byte(`{"keys": []}`), 0o600))
+	_, err = New(Config{JWT: JWTConfig{JWKSFile: jwksFile}})
+	require.ErrorIs(t, err, ErrInvalidJWKS)
+
+	_, err = New(Config{JWT: JWTConfig{PublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")}})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
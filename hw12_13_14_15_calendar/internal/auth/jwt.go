@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidJWKS = errors.New("auth: invalid JWKS")
+
+	errUnknownKey = errors.New("unknown signing key")
+)
+
+type jwtValidator struct {
+	hmacSecret []byte
+	publicKey  *rsa.PublicKey
+	// keys are the JWKS keys by their kid.
+	keys   map[string]*rsa.PublicKey
+	parser *jwt.Parser
+}
+
+// newJWTValidator returns nil when no key is configured, so the JWTs are not accepted.
+func newJWTValidator(config JWTConfig) (*jwtValidator, error) {
+	v := &jwtValidator{hmacSecret: []byte(config.HMACSecret)}
+
+	if config.PublicKeyFile != "" {
+		data, err := os.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		v.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+
+		v.keys, err = parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var methods []string
+	if len(v.hmacSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if v.publicKey != nil || len(v.keys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+
+	return v, nil
+}
+
+func (v *jwtValidator) validate(token string) (int, error) {
+	var claims jwt.RegisteredClaims
+	_, err := v.parser.ParseWithClaims(token, &claims, v.key)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID <= 0 {
+		return 0, fmt.Errorf("%w: subject is not a user ID", ErrInvalidToken)
+	}
+
+	return userID, nil
+}
+
+// key returns the key of the token, the RS256 tokens with the kid header are checked
+// with the key of the JWKS and the other ones with the key of the PEM file.
+func (v *jwtValidator) key(token *jwt.Token) (any, error) {
+	if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+		return v.hmacSecret, nil
+	}
+
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		if key, ok := v.keys[kid]; ok {
+			return key, nil
+		}
+
+		return nil, errUnknownKey
+	}
+
+	if v.publicKey == nil {
+		return nil, errUnknownKey
+	}
+
+	return v.publicKey, nil
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS returns the RSA signing keys of the set, the other keys are skipped.
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set jsonWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJWKS, err.Error())
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		if key.Kid == "" {
+			return nil, fmt.Errorf("%w: key without kid", ErrInvalidJWKS)
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %s: %s", ErrInvalidJWKS, key.Kid, err.Error())
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %s: %s", ErrInvalidJWKS, key.Kid, err.Error())
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: key %s: invalid exponent", ErrInvalidJWKS, key.Kid)
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no RSA signing keys", ErrInvalidJWKS)
+	}
+
+	return keys, nil
+}
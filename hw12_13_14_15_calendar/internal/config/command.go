@@ -0,0 +1,48 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Command runs the config subcommand of the binaries, `config check`, and returns the exit code.
+// loadErr is the error of loading the file, with the environment, and validating it.
+func Command(out io.Writer, args []string, file string, loadErr error) int {
+	if len(args) != 1 || args[0] != "check" {
+		fmt.Fprintln(out, "usage: config check")
+		return 2
+	}
+
+	if loadErr != nil {
+		fmt.Fprintf(out, "config %s: %v\n", file, loadErr)
+		return 1
+	}
+
+	fmt.Fprintf(out, "config %s is valid\n", file)
+	return 0
+}
+
+// OnReload calls reload on every SIGHUP until ctx is done, the signal is handled from the return
+// on. The binaries reload only the settings which are safe to change while they run, the other
+// ones need a restart.
+func OnReload(ctx context.Context, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reload()
+			}
+		}
+	}()
+}
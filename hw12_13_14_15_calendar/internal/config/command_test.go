@@ -0,0 +1,28 @@
+//go:build !windows
+
+package config
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloaded := make(chan struct{}, 1)
+	OnReload(ctx, func() { reloaded <- struct{}{} })
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("config was not reloaded on SIGHUP")
+	}
+}
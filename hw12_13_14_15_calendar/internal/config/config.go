@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix starts the names of the environment variables overriding the settings.
+const EnvPrefix = "CALENDAR_"
+
+var ErrInvalid = errors.New("invalid config")
+
+// Load reads the TOML file into the target, a pointer to a struct, and overrides its settings
+// by the environment variables. The variable of a setting is named by its path in the file
+// in upper case with the dots replaced by underscores after EnvPrefix, e.g. CALENDAR_HTTP_PORT
+// for port of [http] or CALENDAR_AUTH_JWT_HMACSECRET for hmacSecret of [auth.jwt]. The lists
+// of strings are set as comma-separated values, the lists of tables can't be set.
+// The settings missing from the file are set by the variables too, the empty file reads
+// the variables only.
+func Load(file string, target any) error {
+	v := viper.New()
+	if file != "" {
+		v.SetConfigFile(file)
+
+		if err := v.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range keys(reflect.TypeOf(target).Elem(), "") {
+		if err := v.BindEnv(key, EnvName(key)); err != nil {
+			return err
+		}
+	}
+
+	return v.Unmarshal(target)
+}
+
+// EnvName returns the environment variable of the setting at the dotted path.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// keys returns the paths of the settings of the struct which can be set by a variable.
+func keys(t reflect.Type, prefix string) []string {
+	var result []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := prefix + strings.ToLower(field.Name)
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			result = append(result, keys(field.Type, key+".")...)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct,
+			field.Type.Kind() == reflect.Map:
+			continue
+		default:
+			result = append(result, key)
+		}
+	}
+
+	return result
+}
+
+// Errors collects the problems of a config, so all of them are reported at once
+// rather than one per start.
+type Errors struct {
+	problems []string
+}
+
+// Add reports the problem of the setting at the dotted path.
+func (e *Errors) Add(key, format string, args ...any) {
+	e.problems = append(e.problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+// Err returns the problems as one error wrapping ErrInvalid, or nil when there are none.
+func (e *Errors) Err() error {
+	if len(e.problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w:\n\t%s", ErrInvalid, strings.Join(e.problems, "\n\t"))
+}
+
+func (e *Errors) Required(key, value string) {
+	if value == "" {
+		e.Add(key, "must be set")
+	}
+}
+
+func (e *Errors) OneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	e.Add(key, "%q is not one of %s", value, strings.Join(quote(allowed), ", "))
+}
+
+// Port checks the port number, the empty port is allowed unless it is required.
+func (e *Errors) Port(key, value string, required bool) {
+	if value == "" {
+		if required {
+			e.Add(key, "must be set")
+		}
+		return
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		e.Add(key, "%q is not a port number", value)
+	}
+}
+
+// Host checks that the host has no port, the empty host listens on all the interfaces.
+func (e *Errors) Host(key, value string) {
+	if strings.Contains(value, ":") && net.ParseIP(value) == nil {
+		e.Add(key, "%q must be a host without a port", value)
+	}
+}
+
+// Duration checks that the duration is not negative and, when it must be set, positive.
+// The durations below a millisecond are reported as set without a unit, as a plain number
+// is read as nanoseconds.
+func (e *Errors) Duration(key string, value time.Duration, required bool) {
+	switch {
+	case value < 0:
+		e.Add(key, "%v must not be negative", value)
+	case value == 0 && required:
+		e.Add(key, `must be set to a duration like "30s"`)
+	case value > 0 && value < time.Millisecond:
+		e.Add(key, `%d is read as nanoseconds, set a duration with a unit like "30s"`, int64(value))
+	}
+}
+
+func (e *Errors) NotNegative(key string, value float64) {
+	if value < 0 {
+		e.Add(key, "%v must not be negative", value)
+	}
+}
+
+func quote(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+
+	return quoted
+}
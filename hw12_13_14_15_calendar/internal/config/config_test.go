@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Logger LoggerConf
+	HTTP   struct {
+		Port    string
+		Timeout time.Duration
+		Routes  []struct{ Route string }
+	}
+	Postgres PostgresConf
+	Rabbit   RabbitConf
+}
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(file, []byte(data), 0o600))
+
+	return file
+}
+
+func TestLoadEnv(t *testing.T) {
+	file := writeConfig(t, `
+[logger]
+level = "INFO"
+
+[http]
+port = 8080
+timeout = "30s"
+
+[[http.routes]]
+route = "/v1/events/"
+`)
+
+	t.Setenv("CALENDAR_LOGGER_LEVEL", "DEBUG")
+	t.Setenv("CALENDAR_HTTP_TIMEOUT", "1m")
+	// The settings missing from the file are set too.
+	t.Setenv("CALENDAR_POSTGRES_REPLICAS", "postgres://replica-1,postgres://replica-2")
+	t.Setenv("CALENDAR_RABBIT_TLS_SERVERNAME", "rabbit.local")
+
+	var c testConfig
+	require.NoError(t, Load(file, &c))
+
+	require.Equal(t, "DEBUG", c.Logger.Level)
+	require.Equal(t, "8080", c.HTTP.Port)
+	require.Equal(t, time.Minute, c.HTTP.Timeout)
+	require.Len(t, c.HTTP.Routes, 1)
+	require.Equal(t, []string{"postgres://replica-1", "postgres://replica-2"}, c.Postgres.Replicas)
+	require.Equal(t, "rabbit.local", c.Rabbit.TLS.ServerName)
+}
+
+func TestLoadEnvOnly(t *testing.T) {
+	t.Setenv("CALENDAR_HTTP_PORT", "8080")
+
+	var c testConfig
+	require.NoError(t, Load("", &c))
+	require.Equal(t, "8080", c.HTTP.Port)
+}
+
+func TestLoadFail(t *testing.T) {
+	var c testConfig
+	require.Error(t, Load(filepath.Join(t.TempDir(), "missing.toml"), &c))
+	require.Error(t, Load(writeConfig(t, "[http\nport = 8080"), &c))
+	require.Error(t, Load(writeConfig(t, "[http]\ntimeout = \"thirty\""), &c))
+}
+
+func TestEnvName(t *testing.T) {
+	require.Equal(t, "CALENDAR_AUTH_JWT_HMACSECRET", EnvName("auth.jwt.hmacsecret"))
+	require.ElementsMatch(t, []string{
+		"logger.level", "logger.format", "http.port", "http.timeout",
+	}, keys(reflect.TypeOf(struct {
+		Logger LoggerConf
+		HTTP   struct {
+			Port    string
+			Timeout time.Duration
+			Routes  []struct{ Route string }
+		}
+		unexported string
+	}{}), ""))
+}
+
+func TestErrors(t *testing.T) {
+	errs := &Errors{}
+	require.NoError(t, errs.Err())
+
+	errs.Required("postgres.dsn", "")
+	errs.OneOf("logger.format", "xml", "text", "json")
+	errs.Port("http.port", "80000", true)
+	errs.Port("metrics.calendarPort", "", false)
+	errs.Host("http.host", "localhost:8080")
+	errs.Host("grpc.host", "::1")
+	errs.Duration("http.timeout", 30, true)
+	errs.Duration("cache.ttl", 0, true)
+	errs.Duration("idempotency.ttl", 0, false)
+	errs.NotNegative("ratelimit.read.rate", -1)
+
+	err := errs.Err()
+	require.ErrorIs(t, err, ErrInvalid)
+	require.Equal(t, `invalid config:
+	postgres.dsn: must be set
+	logger.format: "xml" is not one of "text", "json"
+	http.port: "80000" is not a port number
+	http.host: "localhost:8080" must be a host without a port
+	http.timeout: 30 is read as nanoseconds, set a duration with a unit like "30s"
+	cache.ttl: must be set to a duration like "30s"
+	ratelimit.read.rate: -1 must not be negative`, err.Error())
+}
+
+func TestCommand(t *testing.T) {
+	var out bytes.Buffer
+	require.Equal(t, 0, Command(&out, []string{"check"}, "config.toml", nil))
+	require.Equal(t, "config config.toml is valid\n", out.String())
+
+	out.Reset()
+	require.Equal(t, 1, Command(&out, []string{"check"}, "config.toml", errors.New("boom")))
+	require.Equal(t, "config config.toml: boom\n", out.String())
+
+	out.Reset()
+	require.Equal(t, 2, Command(&out, []string{"show"}, "config.toml", nil))
+	require.Equal(t, "usage: config check\n", out.String())
+}
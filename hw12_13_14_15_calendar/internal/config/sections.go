@@ -0,0 +1,232 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/auth"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	sqlstorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
+)
+
+// The sections below are shared by the binaries, they read the same file.
+
+type LoggerConf struct {
+	Level  string
+	Format string
+}
+
+func (c LoggerConf) LoggerConfig() logger.Config {
+	return logger.Config{Level: c.Level, Format: c.Format}
+}
+
+func (c LoggerConf) Validate(errs *Errors) {
+	if _, err := logger.ParseLevel(c.Level); err != nil {
+		errs.OneOf("logger.level", c.Level, "DEBUG", "INFO", "WARN", "ERROR")
+	}
+	errs.OneOf("logger.format", c.Format, "", logger.FormatText, logger.FormatJSON)
+}
+
+type StorageConf struct {
+	Type string
+}
+
+// Validate checks the type against the storages the binary supports.
+func (c StorageConf) Validate(errs *Errors, types ...string) {
+	errs.OneOf("storage.type", c.Type, types...)
+}
+
+type PostgresConf struct {
+	Dsn                 string
+	Replicas            []string
+	MaxOpenConns        int
+	MaxIdleConns        int
+	ConnMaxLifetime     time.Duration
+	ConnMaxIdleTime     time.Duration
+	MaxReplicaLag       time.Duration
+	HealthCheckInterval time.Duration
+}
+
+func (c PostgresConf) StorageConfig() sqlstorage.Config {
+	return sqlstorage.Config{
+		DSN:                 c.Dsn,
+		ReplicaDSNs:         c.Replicas,
+		MaxOpenConns:        c.MaxOpenConns,
+		MaxIdleConns:        c.MaxIdleConns,
+		ConnMaxLifetime:     c.ConnMaxLifetime,
+		ConnMaxIdleTime:     c.ConnMaxIdleTime,
+		MaxReplicaLag:       c.MaxReplicaLag,
+		HealthCheckInterval: c.HealthCheckInterval,
+	}
+}
+
+func (c PostgresConf) Validate(errs *Errors) {
+	errs.Required("postgres.dsn", c.Dsn)
+	for _, replica := range c.Replicas {
+		if replica == "" {
+			errs.Add("postgres.replicas", "must not hold an empty DSN")
+		}
+	}
+	errs.NotNegative("postgres.maxOpenConns", float64(c.MaxOpenConns))
+	errs.NotNegative("postgres.maxIdleConns", float64(c.MaxIdleConns))
+	errs.Duration("postgres.connMaxLifetime", c.ConnMaxLifetime, false)
+	errs.Duration("postgres.connMaxIdleTime", c.ConnMaxIdleTime, false)
+	errs.Duration("postgres.maxReplicaLag", c.MaxReplicaLag, false)
+	errs.Duration("postgres.healthCheckInterval", c.HealthCheckInterval, false)
+}
+
+type SQLiteConf struct {
+	Path string
+}
+
+func (c SQLiteConf) Validate(errs *Errors) {
+	errs.Required("sqlite.path", c.Path)
+}
+
+// MetricsConf is the admin address of every binary, each one serves /metrics on its port
+// and is off when the port is empty.
+type MetricsConf struct {
+	Host          string
+	CalendarPort  string
+	SchedulerPort string
+	SenderPort    string
+}
+
+func (c MetricsConf) Validate(errs *Errors) {
+	errs.Host("metrics.host", c.Host)
+	errs.Port("metrics.calendarPort", c.CalendarPort, false)
+	errs.Port("metrics.schedulerPort", c.SchedulerPort, false)
+	errs.Port("metrics.senderPort", c.SenderPort, false)
+}
+
+type TracingConf struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+func (c TracingConf) TracingConfig() tracing.Config {
+	return tracing.Config{
+		Exporter:    c.Exporter,
+		Endpoint:    c.Endpoint,
+		Insecure:    c.Insecure,
+		SampleRatio: c.SampleRatio,
+	}
+}
+
+func (c TracingConf) Validate(errs *Errors) {
+	errs.OneOf("tracing.exporter", c.Exporter,
+		"", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout)
+	if c.Exporter == tracing.ExporterOTLP {
+		errs.Required("tracing.endpoint", c.Endpoint)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs.Add("tracing.sampleRatio", "%v is not between 0 and 1", c.SampleRatio)
+	}
+}
+
+type RabbitConf struct {
+	URI      string
+	Exchange string
+	// Queue is read by the sender only.
+	Queue string
+	TLS   RabbitTLSConf
+}
+
+func (c RabbitConf) Validate(errs *Errors) {
+	errs.Required("rabbit.uri", c.URI)
+	if c.URI != "" && !strings.HasPrefix(c.URI, "amqp://") && !strings.HasPrefix(c.URI, "amqps://") {
+		errs.Add("rabbit.uri", "must start with amqp:// or amqps://")
+	}
+	errs.Required("rabbit.exchange", c.Exchange)
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs.Add("rabbit.tls", "certFile and keyFile must be set together")
+	}
+	errs.OneOf("rabbit.tls.minVersion", c.TLS.MinVersion, "", "1.2", "1.3")
+}
+
+// RabbitTLSConf is the TLS of the amqps:// connection, the server certificate is checked
+// against the system roots when CAFile is empty.
+type RabbitTLSConf struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	MinVersion string
+}
+
+func (c RabbitTLSConf) Enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.ServerName != "" || c.MinVersion != ""
+}
+
+func (c RabbitTLSConf) ClientConfig() tlsconfig.ClientConfig {
+	return tlsconfig.ClientConfig{
+		CAFile:     c.CAFile,
+		CertFile:   c.CertFile,
+		KeyFile:    c.KeyFile,
+		ServerName: c.ServerName,
+		MinVersion: c.MinVersion,
+	}
+}
+
+// AuthConf is the authentication of the HTTP and gRPC calls of the API and of the job triggers
+// of the scheduler. The public routes of the HTTP server and the public methods of the gRPC server
+// are served without a user.
+type AuthConf struct {
+	Enabled       bool
+	Tokens        []TokenConf
+	JWT           JWTConf
+	PublicRoutes  []string
+	PublicMethods []string
+}
+
+type TokenConf struct {
+	Token  string
+	UserID int
+}
+
+type JWTConf struct {
+	HMACSecret    string
+	PublicKeyFile string
+	JWKSFile      string
+	Issuer        string
+	Audience      string
+	Leeway        time.Duration
+}
+
+func (c AuthConf) AuthConfig() auth.Config {
+	config := auth.Config{
+		Tokens: make([]auth.Token, 0, len(c.Tokens)),
+		JWT: auth.JWTConfig{
+			HMACSecret:    c.JWT.HMACSecret,
+			PublicKeyFile: c.JWT.PublicKeyFile,
+			JWKSFile:      c.JWT.JWKSFile,
+			Issuer:        c.JWT.Issuer,
+			Audience:      c.JWT.Audience,
+			Leeway:        c.JWT.Leeway,
+		},
+	}
+	for _, token := range c.Tokens {
+		config.Tokens = append(config.Tokens, auth.Token{Token: token.Token, UserID: token.UserID})
+	}
+
+	return config
+}
+
+func (c AuthConf) Validate(errs *Errors) {
+	if !c.Enabled {
+		return
+	}
+	if len(c.Tokens) == 0 && c.JWT.HMACSecret == "" && c.JWT.PublicKeyFile == "" && c.JWT.JWKSFile == "" {
+		errs.Add("auth", "enabled without tokens or a JWT key")
+	}
+	for i, token := range c.Tokens {
+		if token.Token == "" || token.UserID <= 0 {
+			errs.Add("auth.tokens", "token %d must have a token and a positive userId", i+1)
+		}
+	}
+	errs.Duration("auth.jwt.leeway", c.JWT.Leeway, false)
+}
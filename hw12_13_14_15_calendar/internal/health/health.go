@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds every check, so a hanging dependency fails the probe rather than stalls it.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency of the service can be used.
+type Check func(ctx context.Context) error
+
+type Logger interface {
+	WarnContext(ctx context.Context, msg string, args ...any)
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the checks of the dependencies behind the readiness probe. The liveness probe
+// runs none of them: a process which can answer is alive, the broken dependencies only take
+// it out of the load balancing until they recover.
+type Checker struct {
+	logger Logger
+	checks []namedCheck
+}
+
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func New(logg Logger) *Checker {
+	return &Checker{logger: logg}
+}
+
+// Add adds the check of the named dependency, it must be called before the checker is used.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Check runs the checks concurrently and returns the errors of the failed ones by name.
+func (c *Checker) Check(ctx context.Context) map[string]error {
+	errs := make([]error, len(c.checks))
+
+	wg := sync.WaitGroup{}
+	for i := range c.checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			errs[i] = c.checks[i].check(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := map[string]error{}
+	for i, err := range errs {
+		if err != nil {
+			failed[c.checks[i].name] = err
+		}
+	}
+
+	return failed
+}
+
+// ServeLiveness answers 200 as long as the process serves the requests.
+func (c *Checker) ServeLiveness(w http.ResponseWriter, _ *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "ok"})
+}
+
+// ServeReadiness answers 200 when all the checks pass and 503 otherwise, the body tells
+// the state of every dependency. The errors are logged rather than returned, as the probes
+// are served without authentication.
+func (c *Checker) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	failed := c.Check(r.Context())
+
+	status := http.StatusOK
+	result := report{Status: "ok", Checks: make(map[string]string, len(c.checks))}
+	for _, check := range c.checks {
+		err, ok := failed[check.name]
+		if !ok {
+			result.Checks[check.name] = "ok"
+			continue
+		}
+
+		c.logger.WarnContext(r.Context(), "health check failed", "check", check.name, "error", err)
+		result.Checks[check.name] = "unavailable"
+		result.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	writeReport(w, status, result)
+}
+
+func writeReport(w http.ResponseWriter, status int, result report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(result)
+}
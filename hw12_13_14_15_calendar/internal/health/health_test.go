@@ -0,0 +1,63 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+)
+
+func TestChecker(t *testing.T) {
+	var output bytes.Buffer
+	logg, err := logger.New("WARN", &output)
+	require.NoError(t, err)
+
+	var storageErr error
+	checker := New(logg)
+	checker.Add("storage", func(context.Context) error { return storageErr })
+	checker.Add("queue", func(context.Context) error { return nil })
+
+	serve := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	w := serve(checker.ServeReadiness)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"ok","checks":{"storage":"ok","queue":"ok"}}`, w.Body.String())
+
+	storageErr = errors.New("connection refused")
+	w = serve(checker.ServeReadiness)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.JSONEq(t, `{"status":"unavailable","checks":{"storage":"unavailable","queue":"ok"}}`, w.Body.String())
+	require.Contains(t, output.String(), "connection refused")
+	require.NotContains(t, w.Body.String(), "connection refused")
+
+	// The liveness does not depend on the checks.
+	w = serve(checker.ServeLiveness)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	logg, err := logger.New("ERROR", &bytes.Buffer{})
+	require.NoError(t, err)
+
+	checker := New(logg)
+	checker.Add("hanging", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	failed := checker.Check(context.Background())
+	require.ErrorIs(t, failed["hanging"], context.DeadlineExceeded)
+	require.Less(t, time.Since(start), checkTimeout+time.Second)
+}
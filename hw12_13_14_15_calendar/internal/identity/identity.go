@@ -0,0 +1,20 @@
+package identity
+
+import "context"
+
+type userIDKey struct{}
+
+// WithUserID returns a copy of ctx carrying the ID of the user performing the request.
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID returns the ID of the user stored in ctx or 0 for system/anonymous calls.
+func UserID(ctx context.Context) int {
+	userID, ok := ctx.Value(userIDKey{}).(int)
+	if !ok {
+		return 0
+	}
+
+	return userID
+}
@@ -1,65 +1,163 @@
 package logger
 
 import (
+	"context"
 	"errors"
 	"io"
-	"log"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/requestid"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	ErrUnknownLoggerLevel  = errors.New("unknown logger level")
+	ErrUnknownLoggerFormat = errors.New("unknown logger format")
 )
 
-var ErrUnknownLoggerLevel = errors.New("unknown logger level")
+const (
+	DEBUG = slog.LevelDebug
+	INFO  = slog.LevelInfo
+	WARN  = slog.LevelWarn
+	ERROR = slog.LevelError
+)
 
 const (
-	DEBUG = iota
-	INFO
-	ERROR
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
+type Config struct {
+	Level string
+	// Format is text or json, text is used when it is empty.
+	Format string
+}
+
+// Logger writes leveled records with key/value fields. The *Context methods add
+// the request ID and the user ID found in the context to the record.
 type Logger struct {
-	severityLevel int
-	writer        *log.Logger
+	// level is shared by the loggers made by With, so SetLevel applies to all of them.
+	level  *slog.LevelVar
+	logger *slog.Logger
 }
 
 func New(level string, writer io.Writer) (*Logger, error) {
-	var severity int
+	return NewWithConfig(Config{Level: level}, writer)
+}
+
+func NewWithConfig(config Config, writer io.Writer) (*Logger, error) {
+	severity, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	level := &slog.LevelVar{}
+	level.Set(severity)
+	options := &slog.HandlerOptions{Level: level}
 
-	switch level {
+	var handler slog.Handler
+	switch config.Format {
+	case "", FormatText:
+		handler = slog.NewTextHandler(writer, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(writer, options)
+	default:
+		return nil, ErrUnknownLoggerFormat
+	}
+
+	return &Logger{
+		level:  level,
+		logger: slog.New(contextHandler{handler}),
+	}, nil
+}
+
+// ParseLevel returns the level of its name: DEBUG, INFO, WARN or ERROR.
+func ParseLevel(name string) (slog.Level, error) {
+	switch name {
 	case "INFO":
-		severity = INFO
+		return INFO, nil
+	case "WARN":
+		return WARN, nil
 	case "ERROR":
-		severity = ERROR
+		return ERROR, nil
 	case "DEBUG":
-		severity = DEBUG
+		return DEBUG, nil
 	default:
-		return nil, ErrUnknownLoggerLevel
+		return 0, ErrUnknownLoggerLevel
 	}
+}
 
-	logger := log.Logger{}
-	logger.SetFlags(log.Ldate | log.Ltime)
-	logger.SetOutput(writer)
+// SetLevel changes the level of the logger and of the loggers made from it while they are used.
+func (l *Logger) SetLevel(name string) error {
+	severity, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	l.level.Set(severity)
 
-	return &Logger{
-		severityLevel: severity,
-		writer:        &logger,
-	}, nil
+	return nil
 }
 
-func (l Logger) Debug(msg string, params ...any) {
-	if l.severityLevel > DEBUG {
-		return
+// With returns a logger which adds the key/value pairs to every record.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{
+		level:  l.level,
+		logger: l.logger.With(args...),
 	}
-	l.writer.Printf("DEBUG "+msg+" ", params...)
 }
 
-func (l Logger) Info(msg string) {
-	if l.severityLevel > INFO {
-		return
-	}
-	l.writer.Printf("INFO " + msg)
+func (l *Logger) Debug(msg string, args ...any) {
+	l.logger.Debug(msg, args...)
+}
+
+func (l *Logger) Info(msg string, args ...any) {
+	l.logger.Info(msg, args...)
+}
+
+func (l *Logger) Warn(msg string, args ...any) {
+	l.logger.Warn(msg, args...)
+}
+
+func (l *Logger) Error(msg string, args ...any) {
+	l.logger.Error(msg, args...)
+}
+
+func (l *Logger) DebugContext(ctx context.Context, msg string, args ...any) {
+	l.logger.DebugContext(ctx, msg, args...)
 }
 
-func (l Logger) Error(msg string) {
-	if l.severityLevel > ERROR {
-		return
+func (l *Logger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.logger.InfoContext(ctx, msg, args...)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, msg string, args ...any) {
+	l.logger.WarnContext(ctx, msg, args...)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	l.logger.ErrorContext(ctx, msg, args...)
+}
+
+// contextHandler adds the request ID and the user ID of the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := requestid.RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if userID := identity.UserID(ctx); userID != 0 {
+		record.AddAttrs(slog.Int("user_id", userID))
 	}
-	l.writer.Printf("ERROR " + msg)
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
@@ -2,18 +2,25 @@ package logger
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/requestid"
+	"golang.org/x/exp/slog"
 )
 
 func TestLogger(t *testing.T) {
 	cases := []struct {
 		name          string
 		level         string
-		expectedLevel int
+		expectedLevel slog.Level
 		regex         []string
 	}{
 		{
@@ -21,9 +28,10 @@ func TestLogger(t *testing.T) {
 			level:         "DEBUG",
 			expectedLevel: DEBUG,
 			regex: []string{
-				"\\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} DEBUG Test debug",
-				"\\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} INFO Test info",
-				"\\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} ERROR Test error",
+				"time=\\S+ level=DEBUG msg=\"Test debug\"",
+				"time=\\S+ level=INFO msg=\"Test info\"",
+				"time=\\S+ level=WARN msg=\"Test warn\"",
+				"time=\\S+ level=ERROR msg=\"Test error\"",
 			},
 		},
 		{
@@ -31,8 +39,18 @@ func TestLogger(t *testing.T) {
 			level:         "INFO",
 			expectedLevel: INFO,
 			regex: []string{
-				"\\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} INFO Test info",
-				"\\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} ERROR Test error",
+				"time=\\S+ level=INFO msg=\"Test info\"",
+				"time=\\S+ level=WARN msg=\"Test warn\"",
+				"time=\\S+ level=ERROR msg=\"Test error\"",
+			},
+		},
+		{
+			name:          "warn level",
+			level:         "WARN",
+			expectedLevel: WARN,
+			regex: []string{
+				"time=\\S+ level=WARN msg=\"Test warn\"",
+				"time=\\S+ level=ERROR msg=\"Test error\"",
 			},
 		},
 		{
@@ -40,7 +58,7 @@ func TestLogger(t *testing.T) {
 			level:         "ERROR",
 			expectedLevel: ERROR,
 			regex: []string{
-				"\\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} ERROR Test error",
+				"time=\\S+ level=ERROR msg=\"Test error\"",
 			},
 		},
 	}
@@ -50,13 +68,15 @@ func TestLogger(t *testing.T) {
 			var output bytes.Buffer
 			logger, err := New(tc.level, &output)
 			require.Nil(t, err)
-			require.Equal(t, tc.expectedLevel, logger.severityLevel)
+			require.Equal(t, tc.expectedLevel, logger.level.Level())
 
 			logger.Debug("Test debug")
 			logger.Info("Test info")
+			logger.Warn("Test warn")
 			logger.Error("Test error")
 
 			logMessages := output.String()
+			require.Len(t, strings.Split(strings.TrimSpace(logMessages), "\n"), len(tc.regex))
 			for i := range tc.regex {
 				match, _ := regexp.MatchString(tc.regex[i], logMessages)
 				require.True(t, match, "Log message not match pattern. Actual message: "+logMessages)
@@ -65,7 +85,57 @@ func TestLogger(t *testing.T) {
 	}
 }
 
+func TestLoggerSetLevel(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := New("INFO", &output)
+	require.NoError(t, err)
+	component := logger.With("component", "test")
+
+	component.Debug("hidden")
+	require.NoError(t, logger.SetLevel("DEBUG"))
+	component.Debug("shown")
+	require.NotContains(t, output.String(), "hidden")
+	require.Contains(t, output.String(), `msg=shown component=test`)
+
+	require.ErrorIs(t, logger.SetLevel("TRACE"), ErrUnknownLoggerLevel)
+	require.Equal(t, DEBUG, logger.level.Level())
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := NewWithConfig(Config{Level: "INFO", Format: FormatJSON}, &output)
+	require.NoError(t, err)
+
+	ctx := requestid.WithRequestID(identity.WithUserID(context.Background(), 42), "req-1")
+	logger.With("component", "test").ErrorContext(ctx, "request failed", "error", errors.New("boom"), "status", 500)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(output.Bytes(), &record))
+	require.Equal(t, "ERROR", record["level"])
+	require.Equal(t, "request failed", record["msg"])
+	require.Equal(t, "test", record["component"])
+	require.Equal(t, "boom", record["error"])
+	require.Equal(t, float64(500), record["status"])
+	require.Equal(t, "req-1", record["request_id"])
+	require.Equal(t, float64(42), record["user_id"])
+}
+
+func TestLoggerContextWithoutIDs(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := New("INFO", &output)
+	require.NoError(t, err)
+
+	logger.InfoContext(context.Background(), "tick", "events", 3)
+
+	require.Contains(t, output.String(), "msg=tick events=3")
+	require.NotContains(t, output.String(), "request_id")
+	require.NotContains(t, output.String(), "user_id")
+}
+
 func TestLoggerFail(t *testing.T) {
 	_, err := New("UNKNOWN_LEVEL", os.Stderr)
 	require.Equal(t, ErrUnknownLoggerLevel, err)
+
+	_, err = NewWithConfig(Config{Level: "INFO", Format: "xml"}, os.Stderr)
+	require.Equal(t, ErrUnknownLoggerFormat, err)
 }
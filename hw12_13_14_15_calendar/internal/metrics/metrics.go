@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	cachestorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/cache"
+)
+
+const namespace = "calendar"
+
+// NewRegistry returns a registry with the Go runtime and process collectors of the binary.
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return registry
+}
+
+// HTTPMetrics counts the requests of the HTTP API by the route they matched.
+// The methods of a nil *HTTPMetrics do nothing.
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTPMetrics(registerer prometheus.Registerer) *HTTPMetrics {
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+	}
+	registerer.MustRegister(m.requests, m.duration)
+
+	return m
+}
+
+func (m *HTTPMetrics) Observe(route, method string, code int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.requests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	m.duration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// GRPCMetrics counts the calls of the gRPC API by the full method name.
+// The methods of a nil *GRPCMetrics do nothing.
+type GRPCMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewGRPCMetrics(registerer prometheus.Registerer) *GRPCMetrics {
+	m := &GRPCMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "gRPC calls by method and status code.",
+		}, []string{"method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "gRPC call latency by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	registerer.MustRegister(m.requests, m.duration)
+
+	return m
+}
+
+func (m *GRPCMetrics) Observe(method, code string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.requests.WithLabelValues(method, code).Inc()
+	m.duration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// StorageMetrics measures the queries of the storage by the name of the storage method.
+// The methods of a nil *StorageMetrics do nothing.
+type StorageMetrics struct {
+	queries  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewStorageMetrics(registerer prometheus.Registerer) *StorageMetrics {
+	m := &StorageMetrics{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "queries_total",
+			Help:      "Storage queries by query and result.",
+		}, []string{"query", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "query_duration_seconds",
+			Help:      "Storage query latency by query.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"query"}),
+	}
+	registerer.MustRegister(m.queries, m.duration)
+
+	return m
+}
+
+func (m *StorageMetrics) Observe(query string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.queries.WithLabelValues(query, result(err)).Inc()
+	m.duration.WithLabelValues(query).Observe(duration.Seconds())
+}
+
+// SchedulerMetrics measures the periodic tasks of the scheduler and the notifications it produces.
+// The methods of a nil *SchedulerMetrics do nothing.
+type SchedulerMetrics struct {
+	tickDuration  *prometheus.HistogramVec
+	notifications *prometheus.CounterVec
+}
+
+func NewSchedulerMetrics(registerer prometheus.Registerer) *SchedulerMetrics {
+	m := &SchedulerMetrics{
+		tickDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "tick_duration_seconds",
+			Help:      "Duration of the scheduler task runs by task.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"task"}),
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "notifications_total",
+			Help:      "Event notifications produced to the queue by result.",
+		}, []string{"result"}),
+	}
+	registerer.MustRegister(m.tickDuration, m.notifications)
+
+	return m
+}
+
+func (m *SchedulerMetrics) ObserveTick(task string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.tickDuration.WithLabelValues(task).Observe(duration.Seconds())
+}
+
+func (m *SchedulerMetrics) NotificationProduced(err error) {
+	if m == nil {
+		return
+	}
+
+	m.notifications.WithLabelValues(result(err)).Inc()
+}
+
+// SenderMetrics counts the notifications handled by the sender.
+// The methods of a nil *SenderMetrics do nothing.
+type SenderMetrics struct {
+	deliveries *prometheus.CounterVec
+}
+
+func NewSenderMetrics(registerer prometheus.Registerer) *SenderMetrics {
+	m := &SenderMetrics{
+		deliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sender",
+			Name:      "deliveries_total",
+			Help:      "Notifications handled by the sender by result.",
+		}, []string{"result"}),
+	}
+	registerer.MustRegister(m.deliveries)
+
+	return m
+}
+
+func (m *SenderMetrics) Delivered(err error) {
+	if m == nil {
+		return
+	}
+
+	m.deliveries.WithLabelValues(result(err)).Inc()
+}
+
+// RegisterCacheStats exports the counters of the storage cache, stats is called on every scrape.
+func RegisterCacheStats(registerer prometheus.Registerer, stats func() cachestorage.Stats) {
+	counter := func(name, help string, value func(cachestorage.Stats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			return float64(value(stats()))
+		})
+	}
+
+	registerer.MustRegister(
+		counter("hits_total", "Storage cache hits.", func(s cachestorage.Stats) uint64 { return s.Hits }),
+		counter("misses_total", "Storage cache misses.", func(s cachestorage.Stats) uint64 { return s.Misses }),
+		counter(
+			"evictions_total",
+			"Storage cache evictions.",
+			func(s cachestorage.Stats) uint64 { return s.Evictions },
+		),
+		counter(
+			"invalidations_total",
+			"Storage cache entries dropped by changes of the events.",
+			func(s cachestorage.Stats) uint64 { return s.Invalidations },
+		),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "entries",
+			Help:      "Storage cache entries.",
+		}, func() float64 {
+			return float64(stats().Entries)
+		}),
+	)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
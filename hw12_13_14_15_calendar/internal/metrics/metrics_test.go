@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	cachestorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/cache"
+)
+
+func TestServer(t *testing.T) {
+	registry := NewRegistry()
+	NewSenderMetrics(registry).Delivered(nil)
+	RegisterCacheStats(registry, func() cachestorage.Stats {
+		return cachestorage.Stats{Hits: 3, Misses: 1, Entries: 2}
+	})
+
+	server := NewServer(registry, "localhost", "9100")
+
+	r := httptest.NewRequest("GET", "http://localhost:9100/metrics", nil)
+	w := httptest.NewRecorder()
+	server.handler.ServeHTTP(w, r)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `calendar_sender_deliveries_total{result="ok"} 1`)
+	require.Contains(t, string(body), "calendar_cache_hits_total 3")
+	require.Contains(t, string(body), "calendar_cache_entries 2")
+	require.Contains(t, string(body), "go_goroutines")
+
+	server.Handle("/livez", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	w = httptest.NewRecorder()
+	server.handler.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:9100/livez", nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestResultLabels(t *testing.T) {
+	registry := NewRegistry()
+	schedulerMetrics := NewSchedulerMetrics(registry)
+
+	schedulerMetrics.NotificationProduced(nil)
+	schedulerMetrics.NotificationProduced(errors.New("publish failed"))
+	schedulerMetrics.NotificationProduced(nil)
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP calendar_scheduler_notifications_total Event notifications produced to the queue by result.
+# TYPE calendar_scheduler_notifications_total counter
+calendar_scheduler_notifications_total{result="error"} 1
+calendar_scheduler_notifications_total{result="ok"} 2
+`), "calendar_scheduler_notifications_total")
+	require.NoError(t, err)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var (
+		httpMetrics      *HTTPMetrics
+		grpcMetrics      *GRPCMetrics
+		storageMetrics   *StorageMetrics
+		schedulerMetrics *SchedulerMetrics
+		senderMetrics    *SenderMetrics
+	)
+
+	require.NotPanics(t, func() {
+		httpMetrics.Observe("/event/get", http.MethodGet, http.StatusOK, time.Second)
+		grpcMetrics.Observe("/event.Calendar/Get", "OK", time.Second)
+		storageMetrics.Observe("GetEvent", nil, time.Second)
+		schedulerMetrics.ObserveTick("notify", time.Second)
+		schedulerMetrics.NotificationProduced(nil)
+		senderMetrics.Delivered(nil)
+	})
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var ErrServerNotStarted = errors.New("metrics server not started")
+
+// Server serves /metrics of the registry on the admin port, apart from the API.
+type Server struct {
+	host    string
+	port    string
+	handler *http.ServeMux
+	server  *http.Server
+}
+
+func NewServer(registry *prometheus.Registry, host, port string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry}))
+
+	return &Server{
+		host:    host,
+		port:    port,
+		handler: mux,
+	}
+}
+
+// Handle serves the handler on the admin port too, like the health probes of the workers
+// which have no API. It must be called before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.handler.Handle(pattern, handler)
+}
+
+func (s *Server) Start(_ context.Context) error {
+	s.server = &http.Server{
+		Addr:              net.JoinHostPort(s.host, s.port),
+		ReadHeaderTimeout: 5 * time.Second,
+		Handler:           s.handler,
+	}
+
+	err := s.server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("listen and serve: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	if s.server == nil {
+		return ErrServerNotStarted
+	}
+
+	return s.server.Shutdown(ctx)
+}
@@ -1,6 +1,8 @@
 package rabbit
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 
 	"github.com/streadway/amqp"
@@ -10,6 +12,7 @@ type Consumer struct {
 	uri        string
 	exchange   string
 	queue      string
+	tlsConfig  *tls.Config
 	connection *amqp.Connection
 	channel    *amqp.Channel
 }
@@ -22,8 +25,13 @@ func NewConsumer(uri, exchange, queue string) *Consumer {
 	}
 }
 
+// SetTLS sets the TLS config of the amqps:// connection, it must be called before Connect.
+func (c *Consumer) SetTLS(tlsConfig *tls.Config) {
+	c.tlsConfig = tlsConfig
+}
+
 func (c *Consumer) Connect() error {
-	connection, err := amqp.Dial(c.uri)
+	connection, err := dial(c.uri, c.tlsConfig)
 	if err != nil {
 		return fmt.Errorf("dial error: %w", err)
 	}
@@ -72,6 +80,11 @@ func (c *Consumer) Connect() error {
 	return nil
 }
 
+// Ping is the health check of the connection.
+func (c *Consumer) Ping(ctx context.Context) error {
+	return ping(ctx, c.connection)
+}
+
 func (c *Consumer) Disconnect() {
 	if c.connection != nil {
 		c.connection.Close()
@@ -0,0 +1,32 @@
+package rabbit
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+
+	"github.com/streadway/amqp"
+)
+
+var ErrNotConnected = errors.New("not connected to rabbitmq")
+
+// dial connects to the URI, the TLS config is used by the amqps:// URIs only.
+// Without the config they check the server certificate against the system roots.
+func dial(uri string, tlsConfig *tls.Config) (*amqp.Connection, error) {
+	if tlsConfig == nil {
+		return amqp.Dial(uri)
+	}
+
+	// The server name is set to the host of the URI when it is empty, so the config is not shared.
+	return amqp.DialTLS(uri, tlsConfig.Clone())
+}
+
+// ping reports whether the connection is open. The library notices a broken connection
+// by the missed heartbeats, so it takes up to the heartbeat interval to fail.
+func ping(_ context.Context, connection *amqp.Connection) error {
+	if connection == nil || connection.IsClosed() {
+		return ErrNotConnected
+	}
+
+	return nil
+}
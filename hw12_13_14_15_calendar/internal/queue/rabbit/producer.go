@@ -1,15 +1,23 @@
 package rabbit
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 
 	"github.com/streadway/amqp"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Producer struct {
 	uri        string
 	exchange   string
+	tlsConfig  *tls.Config
 	connection *amqp.Connection
 	channel    *amqp.Channel
 }
@@ -21,8 +29,13 @@ func NewProducer(uri, exchange string) *Producer {
 	}
 }
 
+// SetTLS sets the TLS config of the amqps:// connection, it must be called before Connect.
+func (p *Producer) SetTLS(tlsConfig *tls.Config) {
+	p.tlsConfig = tlsConfig
+}
+
 func (p *Producer) Connect() error {
-	connection, err := amqp.Dial(p.uri)
+	connection, err := dial(p.uri, p.tlsConfig)
 	if err != nil {
 		return fmt.Errorf("dial error: %w", err)
 	}
@@ -50,6 +63,11 @@ func (p *Producer) Connect() error {
 	return nil
 }
 
+// Ping is the health check of the connection.
+func (p *Producer) Ping(ctx context.Context) error {
+	return ping(ctx, p.connection)
+}
+
 func (p *Producer) Disconnect() {
 	if p.connection != nil {
 		p.connection.Close()
@@ -58,19 +76,33 @@ func (p *Producer) Disconnect() {
 	}
 }
 
-func (p *Producer) ProduceEvent(event storage.Event) error {
+func (p *Producer) ProduceEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, span := tracer.Start(ctx, p.exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(p.exchange),
+			attribute.String("event.id", event.ID),
+		),
+	)
+	defer tracing.End(span, &err)
+
 	eventJSON, err := event.MarshalJSON()
 	if err != nil {
 		return fmt.Errorf("error marshalling event: %w", err)
 	}
 
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headersCarrier(headers))
+
 	err = p.channel.Publish(
 		p.exchange,
 		"",
 		false,
 		false,
 		amqp.Publishing{
-			Headers:         amqp.Table{},
+			Headers:         headers,
 			ContentType:     "application/json",
 			ContentEncoding: "",
 			Body:            eventJSON,
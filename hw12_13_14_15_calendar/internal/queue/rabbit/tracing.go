@@ -0,0 +1,45 @@
+package rabbit
+
+import (
+	"context"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/queue/rabbit")
+
+// headersCarrier carries the trace context in the headers of the AMQP message.
+type headersCarrier amqp.Table
+
+func (c headersCarrier) Get(key string) string {
+	value, ok := c[key].(string)
+	if !ok {
+		return ""
+	}
+
+	return value
+}
+
+func (c headersCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// DeliveryContext returns ctx with the trace context the producer put into the headers of the delivery,
+// so the spans of the consumer join the trace which produced the message.
+func DeliveryContext(ctx context.Context, d amqp.Delivery) context.Context {
+	if d.Headers == nil {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, headersCarrier(d.Headers))
+}
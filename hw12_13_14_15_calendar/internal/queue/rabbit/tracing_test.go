@@ -0,0 +1,35 @@
+package rabbit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDeliveryContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headersCarrier(headers))
+	require.Contains(t, headers, "traceparent")
+
+	got := trace.SpanContextFromContext(DeliveryContext(context.Background(), amqp.Delivery{Headers: headers}))
+	require.Equal(t, spanContext.TraceID(), got.TraceID())
+	require.Equal(t, spanContext.SpanID(), got.SpanID())
+	require.True(t, got.IsRemote())
+
+	got = trace.SpanContextFromContext(DeliveryContext(context.Background(), amqp.Delivery{}))
+	require.False(t, got.IsValid())
+}
@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"golang.org/x/time/rate"
+)
+
+// Class groups the calls sharing a limit, e.g. all the calls which only read the data.
+type Class string
+
+const (
+	ClassRead  Class = "read"
+	ClassWrite Class = "write"
+)
+
+// sweepInterval is how often the buckets of the keys which stopped calling are dropped.
+const sweepInterval = time.Minute
+
+// Limit is a token bucket: Burst calls may be made at once and the bucket refills
+// at Rate calls per second. A zero Rate leaves the class unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limiter keeps a token bucket per class and key.
+type Limiter struct {
+	limits map[Class]Limit
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	class Class
+	key   string
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+}
+
+type callKey struct{}
+
+// call is the class and key a call was limited by, kept in its context for Charge.
+type call struct {
+	limiter *Limiter
+	class   Class
+	key     string
+}
+
+func New(limits map[Class]Limit) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		now:     time.Now,
+		buckets: make(map[bucketKey]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of the key in the class. When the bucket is empty
+// it returns false and the time after which the next call will be allowed.
+func (l *Limiter) Allow(class Class, key string) (bool, time.Duration) {
+	return l.AllowN(class, key, 1)
+}
+
+// AllowN takes n tokens from the bucket of the key in the class. A call costing more than
+// the burst is allowed when the bucket is full and leaves it in debt, so the next calls
+// wait until the rest of its tokens are refilled.
+func (l *Limiter) AllowN(class Class, key string, n int) (bool, time.Duration) {
+	limit, ok := l.limits[class]
+	if !ok || limit.Rate <= 0 || n <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b := l.bucket(bucketKey{class: class, key: key}, limit)
+
+	first := n
+	if burst := b.limiter.Burst(); first > burst {
+		first = burst
+	}
+
+	reservation := b.limiter.ReserveN(now, first)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+
+	for rest := n - first; rest > 0; rest -= first {
+		if rest < first {
+			first = rest
+		}
+		b.limiter.ReserveN(now, first)
+	}
+
+	return true, 0
+}
+
+// WithCall returns the context of a call allowed in the class for the key, so that the handler
+// may Charge it for the work it finds out about later.
+func (l *Limiter) WithCall(ctx context.Context, class Class, key string) context.Context {
+	return context.WithValue(ctx, callKey{}, call{limiter: l, class: class, key: key})
+}
+
+// Charge takes n more tokens for the call of the context, e.g. one for each operation of a batch
+// beyond the first. The calls without a limit are always allowed.
+func Charge(ctx context.Context, n int) (bool, time.Duration) {
+	c, ok := ctx.Value(callKey{}).(call)
+	if !ok {
+		return true, 0
+	}
+
+	return c.limiter.AllowN(c.class, c.key, n)
+}
+
+func (l *Limiter) bucket(key bucketKey, limit Limit) *bucket {
+	if b, ok := l.buckets[key]; ok {
+		return b
+	}
+
+	burst := limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	b := &bucket{limiter: rate.NewLimiter(rate.Limit(limit.Rate), burst)}
+	l.buckets[key] = b
+
+	return b
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	// A full bucket is the same as a new one, so the keys which stopped calling are dropped
+	// once their buckets are refilled.
+	for key, b := range l.buckets {
+		if b.limiter.TokensAt(now) >= float64(b.limiter.Burst()) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Key returns the key the call is limited by: the ID of the user when the call has one
+// or the address of the client otherwise.
+func Key(ctx context.Context, clientIP string) string {
+	if userID := identity.UserID(ctx); userID != 0 {
+		return "user:" + strconv.Itoa(userID)
+	}
+
+	return "ip:" + clientIP
+}
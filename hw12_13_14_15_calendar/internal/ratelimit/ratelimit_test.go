@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+)
+
+func newTestLimiter(limits map[Class]Limit) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := New(limits)
+	limiter.now = func() time.Time { return now }
+
+	return limiter, &now
+}
+
+func TestLimiterAllow(t *testing.T) {
+	limiter, now := newTestLimiter(map[Class]Limit{ClassWrite: {Rate: 1, Burst: 2}})
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := limiter.Allow(ClassWrite, "user:1")
+		require.True(t, allowed)
+	}
+
+	allowed, retryAfter := limiter.Allow(ClassWrite, "user:1")
+	require.False(t, allowed)
+	require.Equal(t, time.Second, retryAfter)
+
+	// The keys have their own buckets.
+	allowed, _ = limiter.Allow(ClassWrite, "user:2")
+	require.True(t, allowed)
+
+	// The rejected calls do not take tokens.
+	*now = now.Add(time.Second)
+	allowed, _ = limiter.Allow(ClassWrite, "user:1")
+	require.True(t, allowed)
+	allowed, _ = limiter.Allow(ClassWrite, "user:1")
+	require.False(t, allowed)
+}
+
+func TestLimiterAllowN(t *testing.T) {
+	limiter, now := newTestLimiter(map[Class]Limit{ClassWrite: {Rate: 1, Burst: 5}})
+
+	allowed, _ := limiter.AllowN(ClassWrite, "user:1", 3)
+	require.True(t, allowed)
+
+	allowed, retryAfter := limiter.AllowN(ClassWrite, "user:1", 3)
+	require.False(t, allowed)
+	require.Equal(t, time.Second, retryAfter)
+
+	// A call costing more than the burst waits for the full bucket and leaves it in debt.
+	*now = now.Add(3 * time.Second)
+	allowed, _ = limiter.AllowN(ClassWrite, "user:1", 12)
+	require.True(t, allowed)
+
+	allowed, retryAfter = limiter.Allow(ClassWrite, "user:1")
+	require.False(t, allowed)
+	require.Equal(t, 8*time.Second, retryAfter)
+}
+
+func TestCharge(t *testing.T) {
+	limiter, _ := newTestLimiter(map[Class]Limit{ClassWrite: {Rate: 1, Burst: 3}})
+
+	allowed, _ := Charge(context.Background(), 100)
+	require.True(t, allowed)
+
+	ctx := limiter.WithCall(context.Background(), ClassWrite, "user:1")
+	allowed, _ = Charge(ctx, 2)
+	require.True(t, allowed)
+	allowed, _ = Charge(ctx, 2)
+	require.False(t, allowed)
+	allowed, _ = limiter.Allow(ClassWrite, "user:1")
+	require.True(t, allowed)
+}
+
+func TestLimiterUnlimitedClass(t *testing.T) {
+	limiter, _ := newTestLimiter(map[Class]Limit{ClassWrite: {Rate: 1, Burst: 1}, ClassRead: {}})
+
+	for i := 0; i < 100; i++ {
+		allowed, _ := limiter.Allow(ClassRead, "user:1")
+		require.True(t, allowed)
+	}
+	require.Empty(t, limiter.buckets)
+}
+
+func TestLimiterSweep(t *testing.T) {
+	limiter, now := newTestLimiter(map[Class]Limit{ClassRead: {Rate: 0.02, Burst: 1}})
+
+	limiter.Allow(ClassRead, "user:1")
+	*now = now.Add(30 * time.Second)
+	limiter.Allow(ClassRead, "user:2")
+	require.Len(t, limiter.buckets, 2)
+
+	*now = now.Add(40 * time.Second)
+	limiter.Allow(ClassRead, "user:2")
+	require.Len(t, limiter.buckets, 1)
+	require.Contains(t, limiter.buckets, bucketKey{class: ClassRead, key: "user:2"})
+}
+
+func TestKey(t *testing.T) {
+	require.Equal(t, "ip:10.0.0.1", Key(context.Background(), "10.0.0.1"))
+	require.Equal(t, "user:7", Key(identity.WithUserID(context.Background(), 7), "10.0.0.1"))
+}
@@ -0,0 +1,46 @@
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Header is the HTTP header, and in lower case the gRPC metadata key, the request ID is passed in.
+const Header = "X-Request-ID"
+
+// MaxLength limits the request IDs accepted from the clients.
+const MaxLength = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the ID of the request stored in ctx or an empty string.
+func RequestID(ctx context.Context) string {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	if !ok {
+		return ""
+	}
+
+	return requestID
+}
+
+// FromIncoming returns the request ID passed by the client, or a new one when it passed none
+// or an ID which is too long or has characters other than printable ASCII.
+func FromIncoming(requestID string) string {
+	if requestID == "" || len(requestID) > MaxLength {
+		return uuid.NewString()
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return uuid.NewString()
+		}
+	}
+
+	return requestID
+}
@@ -0,0 +1,24 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	require.Equal(t, "", RequestID(context.Background()))
+	require.Equal(t, "req-1", RequestID(WithRequestID(context.Background(), "req-1")))
+}
+
+func TestFromIncoming(t *testing.T) {
+	require.Equal(t, "req-1", FromIncoming("req-1"))
+
+	for _, incoming := range []string{"", "req 1", "req\n1", strings.Repeat("a", MaxLength+1)} {
+		_, err := uuid.Parse(FromIncoming(incoming))
+		require.NoError(t, err, "incoming %q", incoming)
+	}
+}
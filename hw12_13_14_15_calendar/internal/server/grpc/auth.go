@@ -0,0 +1,84 @@
+package internalgrpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator resolves the credentials passed by the client to the ID of the user.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (int, error)
+}
+
+var errUnauthenticated = status.Error(codes.Unauthenticated, "invalid or missing token")
+
+// AuthMiddleware serves the calls as the user the bearer token of the authorization metadata
+// was issued to and rejects the ones without a valid token with Unauthenticated.
+// The public methods, by the full method name, are served without a user.
+func AuthMiddleware(authenticator Authenticator, logg Logger, publicMethods []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if isPublicMethod(info.FullMethod, publicMethods) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate(ctx, authenticator, logg)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func StreamAuthMiddleware(
+	authenticator Authenticator,
+	logg Logger,
+	publicMethods []string,
+) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isPublicMethod(info.FullMethod, publicMethods) {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authenticate(ss.Context(), authenticator, logg)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticate(ctx context.Context, authenticator Authenticator, logg Logger) (context.Context, error) {
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		scheme, credentials, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(credentials)
+		}
+	}
+
+	userID, err := authenticator.Authenticate(ctx, token)
+	if err != nil {
+		logg.WarnContext(ctx, "authentication failed", "error", err)
+		return ctx, errUnauthenticated
+	}
+
+	return identity.WithUserID(ctx, userID), nil
+}
+
+func isPublicMethod(fullMethod string, publicMethods []string) bool {
+	for _, method := range publicMethods {
+		if method == fullMethod {
+			return true
+		}
+	}
+
+	return false
+}
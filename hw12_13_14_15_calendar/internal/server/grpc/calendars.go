@@ -0,0 +1,113 @@
+package internalgrpc
+
+import (
+	"context"
+
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Server) CreateCalendar(
+	ctx context.Context,
+	r *calendarpb.CreateCalendarRequest,
+) (*calendarpb.CalendarResult, error) {
+	calendar, err := s.app.CreateCalendar(ctx, r.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.CalendarResult{
+		Id:         calendar.ID,
+		OwnerId:    int64(calendar.OwnerID),
+		Name:       calendar.Name,
+		Permission: string(storage.CalendarPermissionOwner),
+	}, nil
+}
+
+func (s *Server) ListCalendars(
+	ctx context.Context,
+	_ *calendarpb.ListCalendarsRequest,
+) (*calendarpb.ListCalendarsResult, error) {
+	calendars, err := s.app.GetCalendars(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resultsList := make([]*calendarpb.CalendarResult, 0, len(calendars))
+	for i := range calendars {
+		resultsList = append(resultsList, &calendarpb.CalendarResult{
+			Id:         calendars[i].ID,
+			OwnerId:    int64(calendars[i].OwnerID),
+			Name:       calendars[i].Name,
+			Permission: string(calendars[i].Permission),
+		})
+	}
+
+	return &calendarpb.ListCalendarsResult{
+		List: resultsList,
+	}, nil
+}
+
+func (s *Server) DeleteCalendar(
+	ctx context.Context,
+	r *calendarpb.DeleteCalendarRequest,
+) (*calendarpb.DeleteCalendarResult, error) {
+	err := s.app.DeleteCalendar(ctx, r.GetCalendarId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.DeleteCalendarResult{}, nil
+}
+
+func (s *Server) ShareCalendar(
+	ctx context.Context,
+	r *calendarpb.ShareCalendarRequest,
+) (*calendarpb.ShareCalendarResult, error) {
+	err := s.app.ShareCalendar(
+		ctx,
+		r.GetCalendarId(),
+		int(r.GetUserId()),
+		storage.CalendarPermission(r.GetPermission()),
+	)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.ShareCalendarResult{}, nil
+}
+
+func (s *Server) UnshareCalendar(
+	ctx context.Context,
+	r *calendarpb.UnshareCalendarRequest,
+) (*calendarpb.UnshareCalendarResult, error) {
+	err := s.app.UnshareCalendar(ctx, r.GetCalendarId(), int(r.GetUserId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.UnshareCalendarResult{}, nil
+}
+
+func (s *Server) ListCalendarGrants(
+	ctx context.Context,
+	r *calendarpb.ListCalendarGrantsRequest,
+) (*calendarpb.ListCalendarGrantsResult, error) {
+	grants, err := s.app.GetCalendarGrants(ctx, r.GetCalendarId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resultsList := make([]*calendarpb.CalendarGrant, 0, len(grants))
+	for i := range grants {
+		resultsList = append(resultsList, &calendarpb.CalendarGrant{
+			CalendarId: grants[i].CalendarID,
+			UserId:     int64(grants[i].UserID),
+			Permission: string(grants[i].Permission),
+		})
+	}
+
+	return &calendarpb.ListCalendarGrantsResult{
+		List: resultsList,
+	}, nil
+}
@@ -2,51 +2,103 @@ package internalgrpc
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
 	"net"
 	"time"
 
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/health"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
 	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Logger interface {
-	Debug(msg string, params ...any)
-	Info(msg string)
-	Error(msg string)
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
 }
 
 type Application interface {
 	CreateEvent(
 		ctx context.Context,
-		id, title string,
+		id, calendarID, title, description, location, url string,
 		startDate time.Time,
 		endDate time.Time,
 		notifyBefore time.Duration,
-	) error
+		tags []string,
+	) (string, error)
 
 	UpdateEvent(
 		ctx context.Context,
 		eventID string,
+		calendarID string,
 		title string,
+		description string,
+		location string,
+		url string,
 		startDate time.Time,
 		endDate time.Time,
 		notifyBefore time.Duration,
+		tags []string,
 	) error
 
 	DeleteEvent(ctx context.Context, eventID string) error
 
 	GetEvent(ctx context.Context, id string) (storage.Event, error)
-	GetEventsListByDates(ctx context.Context, from *time.Time, to *time.Time) []storage.Event
-	GetEventsForNotify(ctx context.Context, notifyDate string) []storage.Event
-	GetEventsOnDate(ctx context.Context, date time.Time) []storage.Event
-	GetEventsOnWeek(ctx context.Context, weekStartDate time.Time) []storage.Event
-	GetEventsOnMonth(ctx context.Context, monthStartDate time.Time) []storage.Event
+	GetEventsListByDates(
+		ctx context.Context,
+		from *time.Time,
+		to *time.Time,
+		filter storage.EventFilter,
+	) ([]storage.Event, error)
+	GetEventsForNotify(ctx context.Context, notifyDate string) ([]storage.Event, error)
+	GetEventsOnDate(ctx context.Context, date time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventsOnWeek(ctx context.Context, weekStartDate time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventsOnMonth(ctx context.Context, monthStartDate time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventHistory(ctx context.Context, id string) ([]storage.EventHistoryRecord, error)
+	ApplyBatch(
+		ctx context.Context,
+		mode storage.BatchMode,
+		operations []storage.BatchOperation,
+	) ([]storage.BatchResult, error)
+	CreateCalendar(ctx context.Context, name string) (storage.Calendar, error)
+	GetCalendars(ctx context.Context) ([]storage.UserCalendar, error)
+	DeleteCalendar(ctx context.Context, calendarID string) error
+	ShareCalendar(ctx context.Context, calendarID string, userID int, permission storage.CalendarPermission) error
+	UnshareCalendar(ctx context.Context, calendarID string, userID int) error
+	GetCalendarGrants(ctx context.Context, calendarID string) ([]storage.CalendarGrant, error)
+	SaveTag(ctx context.Context, name, color string) (storage.Tag, error)
+	DeleteTag(ctx context.Context, name string) error
+	GetTags(ctx context.Context) ([]storage.Tag, error)
 }
 
+// healthInterval is how often the checks behind the gRPC health service are run.
+const healthInterval = 5 * time.Second
+
+var ErrServerNotStarted = errors.New("server not started")
+
+// idempotencyKeyMetadata is the metadata key of the Idempotency-Key passed to Create.
+const idempotencyKeyMetadata = "idempotency-key"
+
 type Server struct {
 	logger Logger
 	app    Application
@@ -54,6 +106,16 @@ type Server struct {
 	port   string
 	server *grpc.Server
 	calendarpb.UnimplementedCalendarServer
+
+	health       *health.Checker
+	healthServer *grpchealth.Server
+	done         chan struct{}
+
+	metrics           *metrics.GRPCMetrics
+	timeouts          Timeouts
+	tlsConfig         *tls.Config
+	unaryMiddlewares  []grpc.UnaryServerInterceptor
+	streamMiddlewares []grpc.StreamServerInterceptor
 }
 
 func NewServer(logg Logger, app Application, host, port string) *Server {
@@ -62,36 +124,152 @@ func NewServer(logg Logger, app Application, host, port string) *Server {
 		app:    app,
 		host:   host,
 		port:   port,
+		health: health.New(logg),
+	}
+}
+
+// SetHealth sets the checks reported by the gRPC health service, it must be called before Start.
+// Without them the server is serving as soon as it listens.
+func (s *Server) SetHealth(checker *health.Checker) {
+	s.health = checker
+}
+
+// SetMetrics sets the metrics of the calls, it must be called before Start.
+func (s *Server) SetMetrics(grpcMetrics *metrics.GRPCMetrics) {
+	s.metrics = grpcMetrics
+}
+
+// SetTimeouts sets the deadlines of the call contexts, it must be called before Start.
+func (s *Server) SetTimeouts(timeouts Timeouts) {
+	s.timeouts = timeouts
+}
+
+// SetTLS serves the calls over TLS with the config, it must be called before Start.
+func (s *Server) SetTLS(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// Use appends the interceptors of the unary calls, they run after the request ID, the access log,
+// the panic recovery and the timeout, in the order they are passed. It must be called before Start.
+func (s *Server) Use(middlewares ...grpc.UnaryServerInterceptor) {
+	s.unaryMiddlewares = append(s.unaryMiddlewares, middlewares...)
+}
+
+// UseStream appends the interceptors of the streaming calls, like Use does for the unary ones.
+func (s *Server) UseStream(middlewares ...grpc.StreamServerInterceptor) {
+	s.streamMiddlewares = append(s.streamMiddlewares, middlewares...)
+}
+
+func (s *Server) serverOptions() []grpc.ServerOption {
+	unary := []grpc.UnaryServerInterceptor{
+		requestIDMiddleware,
+		loggingMiddleware(s.logger, s.metrics),
+		recoveryMiddleware(s.logger),
+		timeoutMiddleware(s.timeouts),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		streamRequestIDMiddleware,
+		streamLoggingMiddleware(s.logger, s.metrics),
+		streamRecoveryMiddleware(s.logger),
+		streamTimeoutMiddleware(s.timeouts),
+	}
+
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(append(unary, s.unaryMiddlewares...)...),
+		grpc.ChainStreamInterceptor(append(stream, s.streamMiddlewares...)...),
 	}
+	if s.tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+
+	return options
 }
 
 func (s *Server) Start(_ context.Context) error {
 	lsn, err := net.Listen("tcp4", net.JoinHostPort(s.host, s.port))
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.Error("grpc listen failed", "error", err)
 		return err
 	}
 
-	s.server = grpc.NewServer(
-		grpc.ChainUnaryInterceptor(
-			loggingMiddleware(),
-		),
-	)
-
-	calendarpb.RegisterCalendarServer(s.server, s)
-	reflection.Register(s.server)
+	s.setup()
 
 	if err := s.server.Serve(lsn); err != nil {
-		s.logger.Error(err.Error())
+		s.logger.Error("grpc serve failed", "error", err)
 		return err
 	}
 
 	return nil
 }
 
-func (s *Server) Stop(_ context.Context) error {
-	s.server.GracefulStop()
-	return nil
+// setup creates the server with the calendar, the health and the reflection services
+// and starts the health checks.
+func (s *Server) setup() {
+	s.server = grpc.NewServer(s.serverOptions()...)
+	s.healthServer = grpchealth.NewServer()
+	s.done = make(chan struct{})
+
+	calendarpb.RegisterCalendarServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, s.healthServer)
+	reflection.Register(s.server)
+
+	s.updateHealth()
+	go s.runHealthChecks()
+}
+
+// Stop reports NOT_SERVING to the health clients, stops accepting the calls and waits for
+// the in-flight ones to finish. The calls still running when ctx is done are cancelled.
+func (s *Server) Stop(ctx context.Context) error {
+	if s.server == nil {
+		return ErrServerNotStarted
+	}
+
+	close(s.done)
+	s.healthServer.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		<-stopped
+		return ctx.Err()
+	}
+}
+
+func (s *Server) runHealthChecks() {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.updateHealth()
+		}
+	}
+}
+
+// updateHealth sets the status of the server, the empty service name, and of the calendar service.
+func (s *Server) updateHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), healthInterval)
+	defer cancel()
+
+	servingStatus := healthpb.HealthCheckResponse_SERVING
+	for name, err := range s.health.Check(ctx) {
+		s.logger.Warn("health check failed", "check", name, "error", err)
+		servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	s.healthServer.SetServingStatus("", servingStatus)
+	s.healthServer.SetServingStatus(calendarpb.Calendar_ServiceDesc.ServiceName, servingStatus)
 }
 
 func (s *Server) Create(ctx context.Context, r *calendarpb.CreateRequest) (*calendarpb.CreateResult, error) {
@@ -101,19 +279,24 @@ func (s *Server) Create(ctx context.Context, r *calendarpb.CreateRequest) (*cale
 	endDt := r.GetEndDt().AsTime()
 	notifyBefore := r.GetNotifyBefore().AsDuration()
 
-	err := s.app.CreateEvent(
-		ctx,
+	eventID, err := s.app.CreateEvent(
+		withIdempotencyKey(ctx),
 		id,
+		r.GetCalendarId(),
 		title,
+		r.GetDescription(),
+		r.GetLocation(),
+		r.GetUrl(),
 		startDt,
 		endDt,
 		notifyBefore,
+		r.GetTags(),
 	)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
 
-	return &calendarpb.CreateResult{}, nil
+	return &calendarpb.CreateResult{Id: eventID}, nil
 }
 
 func (s *Server) Update(ctx context.Context, r *calendarpb.UpdateRequest) (*calendarpb.UpdateResult, error) {
@@ -126,13 +309,18 @@ func (s *Server) Update(ctx context.Context, r *calendarpb.UpdateRequest) (*cale
 	err := s.app.UpdateEvent(
 		ctx,
 		id,
+		r.GetCalendarId(),
 		title,
+		r.GetDescription(),
+		r.GetLocation(),
+		r.GetUrl(),
 		startDt,
 		endDt,
 		notifyBefore,
+		r.GetTags(),
 	)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
 
 	return &calendarpb.UpdateResult{}, nil
@@ -143,7 +331,7 @@ func (s *Server) Delete(ctx context.Context, r *calendarpb.DeleteRequest) (*cale
 
 	err := s.app.DeleteEvent(ctx, eventID)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
 
 	return &calendarpb.DeleteResult{}, nil
@@ -154,16 +342,10 @@ func (s *Server) Get(ctx context.Context, r *calendarpb.GetRequest) (*calendarpb
 
 	event, err := s.app.GetEvent(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
 
-	return &calendarpb.GetResult{
-		Id:           event.ID,
-		Title:        event.Title,
-		StartDt:      timestamppb.New(event.StartDate),
-		EndDt:        timestamppb.New(event.EndDate),
-		NotifyBefore: durationpb.New(event.NotifyBefore),
-	}, nil
+	return buildEventResult(event), nil
 }
 
 func (s *Server) GetEventsListByDates(
@@ -173,18 +355,18 @@ func (s *Server) GetEventsListByDates(
 	from := r.GetFrom().AsTime()
 	to := r.GetTo().AsTime()
 
-	events := s.app.GetEventsListByDates(ctx, &from, &to)
+	events, err := s.app.GetEventsListByDates(ctx, &from, &to, storage.EventFilter{
+		CalendarIDs: r.GetCalendarIds(),
+		Tags:        r.GetTags(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
 
 	resultsList := []*calendarpb.GetResult{}
 
 	for i := range events {
-		resultsList = append(resultsList, &calendarpb.GetResult{
-			Id:           events[i].ID,
-			Title:        events[i].Title,
-			StartDt:      timestamppb.New(events[i].StartDate),
-			EndDt:        timestamppb.New(events[i].EndDate),
-			NotifyBefore: durationpb.New(events[i].NotifyBefore),
-		})
+		resultsList = append(resultsList, buildEventResult(events[i]))
 	}
 
 	return &calendarpb.GetEventsListByDatesResult{
@@ -197,18 +379,15 @@ func (s *Server) GetEventsForNotify(
 	r *calendarpb.GetEventsForNotifyRequest,
 ) (*calendarpb.GetEventsForNotifyResult, error) {
 	notifyDate := r.GetNotifyDate()
-	events := s.app.GetEventsForNotify(ctx, notifyDate)
+	events, err := s.app.GetEventsForNotify(ctx, notifyDate)
+	if err != nil {
+		return nil, statusError(err)
+	}
 
 	resultsList := []*calendarpb.GetResult{}
 
 	for i := range events {
-		resultsList = append(resultsList, &calendarpb.GetResult{
-			Id:           events[i].ID,
-			Title:        events[i].Title,
-			StartDt:      timestamppb.New(events[i].StartDate),
-			EndDt:        timestamppb.New(events[i].EndDate),
-			NotifyBefore: durationpb.New(events[i].NotifyBefore),
-		})
+		resultsList = append(resultsList, buildEventResult(events[i]))
 	}
 
 	return &calendarpb.GetEventsForNotifyResult{
@@ -222,18 +401,18 @@ func (s *Server) GetEventsListOnDate(
 ) (*calendarpb.GetEventsListOnDateResult, error) {
 	dayDate := r.GetDayDate().AsTime()
 
-	events := s.app.GetEventsOnDate(ctx, dayDate)
+	events, err := s.app.GetEventsOnDate(ctx, dayDate, storage.EventFilter{
+		CalendarIDs: r.GetCalendarIds(),
+		Tags:        r.GetTags(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
 
 	resultsList := []*calendarpb.GetResult{}
 
 	for i := range events {
-		resultsList = append(resultsList, &calendarpb.GetResult{
-			Id:           events[i].ID,
-			Title:        events[i].Title,
-			StartDt:      timestamppb.New(events[i].StartDate),
-			EndDt:        timestamppb.New(events[i].EndDate),
-			NotifyBefore: durationpb.New(events[i].NotifyBefore),
-		})
+		resultsList = append(resultsList, buildEventResult(events[i]))
 	}
 
 	return &calendarpb.GetEventsListOnDateResult{
@@ -247,18 +426,18 @@ func (s *Server) GetEventsListOnWeek(
 ) (*calendarpb.GetEventsListOnWeekResult, error) {
 	weekStartDate := r.GetWeekStartDate().AsTime()
 
-	events := s.app.GetEventsOnWeek(ctx, weekStartDate)
+	events, err := s.app.GetEventsOnWeek(ctx, weekStartDate, storage.EventFilter{
+		CalendarIDs: r.GetCalendarIds(),
+		Tags:        r.GetTags(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
 
 	resultsList := []*calendarpb.GetResult{}
 
 	for i := range events {
-		resultsList = append(resultsList, &calendarpb.GetResult{
-			Id:           events[i].ID,
-			Title:        events[i].Title,
-			StartDt:      timestamppb.New(events[i].StartDate),
-			EndDt:        timestamppb.New(events[i].EndDate),
-			NotifyBefore: durationpb.New(events[i].NotifyBefore),
-		})
+		resultsList = append(resultsList, buildEventResult(events[i]))
 	}
 
 	return &calendarpb.GetEventsListOnWeekResult{
@@ -272,21 +451,255 @@ func (s *Server) GetEventsListOnMonth(
 ) (*calendarpb.GetEventsListOnMonthResult, error) {
 	weekStartDate := r.GetMonthStartDate().AsTime()
 
-	events := s.app.GetEventsOnMonth(ctx, weekStartDate)
+	events, err := s.app.GetEventsOnMonth(ctx, weekStartDate, storage.EventFilter{
+		CalendarIDs: r.GetCalendarIds(),
+		Tags:        r.GetTags(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
 
 	resultsList := []*calendarpb.GetResult{}
 
 	for i := range events {
-		resultsList = append(resultsList, &calendarpb.GetResult{
-			Id:           events[i].ID,
-			Title:        events[i].Title,
-			StartDt:      timestamppb.New(events[i].StartDate),
-			EndDt:        timestamppb.New(events[i].EndDate),
-			NotifyBefore: durationpb.New(events[i].NotifyBefore),
-		})
+		resultsList = append(resultsList, buildEventResult(events[i]))
 	}
 
 	return &calendarpb.GetEventsListOnMonthResult{
 		List: resultsList,
 	}, nil
 }
+
+func (s *Server) GetEventHistory(
+	ctx context.Context,
+	r *calendarpb.GetEventHistoryRequest,
+) (*calendarpb.GetEventHistoryResult, error) {
+	history, err := s.app.GetEventHistory(ctx, r.GetEventId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resultsList := []*calendarpb.EventHistoryRecord{}
+
+	for i := range history {
+		changes := []*calendarpb.EventChange{}
+		for _, change := range history[i].Changes {
+			changes = append(changes, &calendarpb.EventChange{
+				Field:  change.Field,
+				Before: change.Before,
+				After:  change.After,
+			})
+		}
+
+		resultsList = append(resultsList, &calendarpb.EventHistoryRecord{
+			Id:        history[i].ID,
+			EventId:   history[i].EventID,
+			Action:    string(history[i].Action),
+			UserId:    int64(history[i].UserID),
+			CreatedAt: timestamppb.New(history[i].CreatedAt),
+			Before:    buildHistoryEventResult(history[i].Before),
+			After:     buildHistoryEventResult(history[i].After),
+			Changes:   changes,
+		})
+	}
+
+	return &calendarpb.GetEventHistoryResult{
+		List: resultsList,
+	}, nil
+}
+
+func buildHistoryEventResult(event *storage.Event) *calendarpb.GetResult {
+	if event == nil {
+		return nil
+	}
+
+	return buildEventResult(*event)
+}
+
+func buildEventResult(event storage.Event) *calendarpb.GetResult {
+	return &calendarpb.GetResult{
+		Id:           event.ID,
+		CalendarId:   event.CalendarID,
+		Title:        event.Title,
+		Description:  event.Description,
+		Location:     event.Location,
+		Url:          event.URL,
+		StartDt:      timestamppb.New(event.StartDate),
+		EndDt:        timestamppb.New(event.EndDate),
+		NotifyBefore: durationpb.New(event.NotifyBefore),
+		Tags:         event.Tags,
+		CreatorId:    int64(event.CreatorID),
+		Notified:     event.Notified,
+		CreatedAt:    timestamppb.New(event.CreatedAt),
+		UpdatedAt:    timestamppb.New(event.UpdatedAt),
+	}
+}
+
+func (s *Server) Batch(stream calendarpb.Calendar_BatchServer) error {
+	mode := storage.BatchModeAllOrNothing
+	operations := []storage.BatchOperation{}
+
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// The stream is not read further than the largest batch the application accepts.
+		if len(operations) == app.MaxBatchSize {
+			return statusError(app.ErrBatchTooLarge)
+		}
+
+		if len(operations) == 0 && r.GetMode() == calendarpb.BatchMode_BATCH_MODE_BEST_EFFORT {
+			mode = storage.BatchModeBestEffort
+		}
+
+		operations = append(operations, buildBatchOperation(r))
+	}
+
+	// The rate limit middleware took a token for the stream, the other operations are charged
+	// here so that a batch costs as much as the same calls made one by one.
+	if len(operations) > 1 {
+		allowed, retryAfter := ratelimit.Charge(stream.Context(), len(operations)-1)
+		if !allowed {
+			return rateLimitedError(retryAfter)
+		}
+	}
+
+	results, err := s.app.ApplyBatch(stream.Context(), mode, operations)
+	if err != nil {
+		return statusError(err)
+	}
+
+	resultsList := make([]*calendarpb.BatchItemResult, 0, len(results))
+	for i := range results {
+		result := &calendarpb.BatchItemResult{
+			Index:   int32(results[i].Index),
+			EventId: results[i].EventID,
+			Ok:      results[i].Err == nil,
+			Aborted: errors.Is(results[i].Err, storage.ErrBatchAborted),
+		}
+		if results[i].Err != nil {
+			result.Error = results[i].Err.Error()
+		}
+
+		resultsList = append(resultsList, result)
+	}
+
+	return stream.SendAndClose(&calendarpb.BatchResult{
+		Applied: storage.BatchApplied(mode, results),
+		Results: resultsList,
+	})
+}
+
+func buildBatchOperation(r *calendarpb.BatchRequest) storage.BatchOperation {
+	operation := storage.BatchOperation{
+		EventID: r.GetEventId(),
+		Event: storage.Event{
+			ID:           r.GetEventId(),
+			CalendarID:   r.GetCalendarId(),
+			Title:        r.GetTitle(),
+			Description:  r.GetDescription(),
+			Location:     r.GetLocation(),
+			URL:          r.GetUrl(),
+			StartDate:    r.GetStartDt().AsTime(),
+			EndDate:      r.GetEndDt().AsTime(),
+			NotifyBefore: r.GetNotifyBefore().AsDuration(),
+			Tags:         r.GetTags(),
+		},
+	}
+
+	switch r.GetType() {
+	case calendarpb.BatchOperationType_BATCH_OPERATION_CREATE:
+		operation.Type = storage.BatchOperationCreate
+	case calendarpb.BatchOperationType_BATCH_OPERATION_UPDATE:
+		operation.Type = storage.BatchOperationUpdate
+	case calendarpb.BatchOperationType_BATCH_OPERATION_DELETE:
+		operation.Type = storage.BatchOperationDelete
+	}
+
+	return operation
+}
+
+// withIdempotencyKey passes the idempotency-key metadata of the request to the application.
+func withIdempotencyKey(ctx context.Context) context.Context {
+	values := metadata.ValueFromIncomingContext(ctx, idempotencyKeyMetadata)
+	if len(values) == 0 {
+		return ctx
+	}
+
+	return app.WithIdempotencyKey(ctx, values[0])
+}
+
+// statusError converts the known application errors to gRPC statuses, any other error is Internal.
+func statusError(err error) error {
+	var validationErr *app.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationStatusError(validationErr)
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrStorageUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, storage.ErrCalendarAccessDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrCreateEventIDExists),
+		errors.Is(err, storage.ErrCreateCalendarIDExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, app.ErrEventQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, app.ErrIdempotencyKeyReused):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, app.ErrIdempotencyKeyTooLong),
+		errors.Is(err, app.ErrBatchEmpty),
+		errors.Is(err, app.ErrBatchTooLarge),
+		errors.Is(err, storage.ErrBatchUnknownMode),
+		errors.Is(err, storage.ErrBatchUnknownOperation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrReadEventNotExists),
+		errors.Is(err, storage.ErrUpdateEventIDNotExists),
+		errors.Is(err, storage.ErrCalendarNotExists),
+		errors.Is(err, storage.ErrCalendarGrantNotExists),
+		errors.Is(err, storage.ErrTagNotExists):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrUnknownCalendarPermission),
+		errors.Is(err, storage.ErrCalendarNameEmpty),
+		errors.Is(err, storage.ErrCalendarShareOwner),
+		errors.Is(err, storage.ErrTagNameEmpty),
+		errors.Is(err, storage.ErrTagNameTooLong),
+		errors.Is(err, storage.ErrTagColorInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// validationStatusError returns InvalidArgument with a BadRequest detail listing the invalid fields.
+func validationStatusError(err *app.ValidationError) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(err.Fields))
+	for _, field := range err.Fields {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Message,
+		})
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(
+		&errdetails.BadRequest{FieldViolations: violations},
+	)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return st.Err()
+}
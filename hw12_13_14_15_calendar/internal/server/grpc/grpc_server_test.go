@@ -0,0 +1,144 @@
+package internalgrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/health"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestHealthService(t *testing.T) {
+	logg, err := logger.New("ERROR", &bytes.Buffer{})
+	require.NoError(t, err)
+
+	var storageErr error
+	checker := health.New(logg)
+	checker.Add("storage", func(context.Context) error { return storageErr })
+
+	server := NewServer(logg, nil, "127.0.0.1", "0")
+	server.SetHealth(checker)
+	server.setup()
+
+	lsn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.server.Serve(lsn)
+
+	conn, err := grpc.NewClient(lsn.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.GetStatus()
+	}
+
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check(""))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check("calendar.Calendar"))
+
+	storageErr = errors.New("connection refused")
+	server.updateHealth()
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(""))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check("calendar.Calendar"))
+
+	// The open watch stream keeps the graceful stop waiting until the deadline.
+	watch, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.ErrorIs(t, server.Stop(ctx), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestStopNotStarted(t *testing.T) {
+	logg, err := logger.New("ERROR", &bytes.Buffer{})
+	require.NoError(t, err)
+
+	server := NewServer(logg, nil, "127.0.0.1", "0")
+	require.ErrorIs(t, server.Stop(context.Background()), ErrServerNotStarted)
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{err: storage.ErrReadEventNotExists, code: codes.NotFound},
+		{err: fmt.Errorf("update: %w", storage.ErrUpdateEventIDNotExists), code: codes.NotFound},
+		{err: fmt.Errorf("%w: connection refused", storage.ErrStorageUnavailable), code: codes.Unavailable},
+		{err: storage.ErrCalendarAccessDenied, code: codes.PermissionDenied},
+		{err: status.Error(codes.Unauthenticated, "no token"), code: codes.Unauthenticated},
+		{err: errors.New("tx rollback failed"), code: codes.Internal},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			require.Equal(t, tc.code, status.Code(statusError(tc.err)))
+		})
+	}
+}
+
+func TestBatchTooLarge(t *testing.T) {
+	logg, err := logger.New("ERROR", &bytes.Buffer{})
+	require.NoError(t, err)
+
+	server := NewServer(logg, app.New(logg, memorystorage.New()), "127.0.0.1", "0")
+	server.setup()
+
+	lsn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.server.Serve(lsn)
+	defer server.server.Stop()
+
+	conn, err := grpc.NewClient(lsn.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := calendarpb.NewCalendarClient(conn)
+
+	batch := func(size int) error {
+		stream, err := client.Batch(context.Background())
+		require.NoError(t, err)
+		for i := 0; i < size; i++ {
+			// the server stops reading once the batch is too large, the stream reports why on close
+			err = stream.Send(&calendarpb.BatchRequest{
+				Type:    calendarpb.BatchOperationType_BATCH_OPERATION_CREATE,
+				Title:   "Test",
+				StartDt: timestamppb.New(time.Date(2024, 6, 14, 0, 0, 0, 0, time.UTC)),
+				EndDt:   timestamppb.New(time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC)),
+			})
+			if err != nil {
+				break
+			}
+		}
+		_, err = stream.CloseAndRecv()
+		return err
+	}
+
+	require.NoError(t, batch(app.MaxBatchSize))
+
+	err = batch(app.MaxBatchSize + 1)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), app.ErrBatchTooLarge.Error())
+}
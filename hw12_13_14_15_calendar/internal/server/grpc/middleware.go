@@ -3,24 +3,228 @@ package internalgrpc
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
+	"strings"
 	"time"
 
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/requestid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
-func loggingMiddleware() grpc.UnaryServerInterceptor {
+// Timeouts are the deadlines of the contexts the calls are served with.
+// A shorter deadline set by the client is kept.
+type Timeouts struct {
+	// Default applies to the methods missing from Methods, zero leaves them without a deadline.
+	Default time.Duration
+	// Methods are the timeouts by the full method name, like /calendar.Calendar/Create.
+	Methods map[string]time.Duration
+}
+
+func (t Timeouts) method(fullMethod string) time.Duration {
+	if timeout, ok := t.Methods[fullMethod]; ok {
+		return timeout
+	}
+
+	return t.Default
+}
+
+// contextStream replaces the context of the stream passed to the handler.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+var requestIDMetadata = strings.ToLower(requestid.Header)
+
+// incomingRequestID returns ctx with the request ID passed by the client in the x-request-id metadata
+// or with a new one.
+func incomingRequestID(ctx context.Context) (context.Context, string) {
+	var incoming string
+	if values := metadata.ValueFromIncomingContext(ctx, requestIDMetadata); len(values) > 0 {
+		incoming = values[0]
+	}
+	id := requestid.FromIncoming(incoming)
+
+	return requestid.WithRequestID(ctx, id), id
+}
+
+// requestIDMiddleware serves the call with the request ID and returns it in the header metadata.
+func requestIDMiddleware(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx, id := incomingRequestID(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+
+	return handler(ctx, req)
+}
+
+func streamRequestIDMiddleware(
+	srv any,
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, id := incomingRequestID(ss.Context())
+	_ = ss.SetHeader(metadata.Pairs(requestIDMetadata, id))
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+func loggingMiddleware(logg Logger, grpcMetrics *metrics.GRPCMetrics) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp any, err error) {
+		start := time.Now()
+
+		ctx, span := startServerSpan(ctx, info.FullMethod)
+		defer endServerSpan(span, &err)
+
+		resp, err = handler(ctx, req)
+		duration := time.Since(start)
+		logCall(ctx, logg, info.FullMethod, err, duration)
+		grpcMetrics.Observe(info.FullMethod, status.Code(err).String(), duration)
+
+		return resp, err
+	}
+}
+
+func streamLoggingMiddleware(logg Logger, grpcMetrics *metrics.GRPCMetrics) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		start := time.Now()
+
+		ctx, span := startServerSpan(ss.Context(), info.FullMethod)
+		defer endServerSpan(span, &err)
+
+		err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		duration := time.Since(start)
+		logCall(ctx, logg, info.FullMethod, err, duration)
+		grpcMetrics.Observe(info.FullMethod, status.Code(err).String(), duration)
+
+		return err
+	}
+}
+
+// logCall writes the access log record of the call, the failed calls are logged as warnings.
+func logCall(ctx context.Context, logg Logger, fullMethod string, err error, duration time.Duration) {
+	args := []any{
+		"method", fullMethod,
+		"code", status.Code(err).String(),
+		"duration", duration,
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		args = append(args, "peer", p.Addr.String())
+	}
+
+	if err != nil {
+		logg.WarnContext(ctx, "grpc call", append(args, "error", err)...)
+		return
+	}
+
+	logg.InfoContext(ctx, "grpc call", args...)
+}
+
+// errPanic is the status of the calls which handler panicked, the panic itself is only logged.
+var errPanic = status.Error(codes.Internal, "internal error")
+
+func recoveryMiddleware(logg Logger) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req any,
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (resp any, err error) {
-		fmt.Printf(
-			"[%s] %s\n",
-			time.Now().Format(time.RFC3339),
-			info.FullMethod,
-		)
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logPanic(ctx, logg, info.FullMethod, recovered)
+				err = errPanic
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+func streamRecoveryMiddleware(logg Logger) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logPanic(ss.Context(), logg, info.FullMethod, recovered)
+				err = errPanic
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func logPanic(ctx context.Context, logg Logger, fullMethod string, recovered any) {
+	logg.ErrorContext(ctx, "handler panicked",
+		"method", fullMethod,
+		"panic", fmt.Sprint(recovered),
+		"stack", string(debug.Stack()),
+	)
+}
+
+// timeoutMiddleware sets the deadline of the method to the context of the call.
+func timeoutMiddleware(timeouts Timeouts) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		timeout := timeouts.method(info.FullMethod)
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
 
 		return handler(ctx, req)
 	}
 }
+
+func streamTimeoutMiddleware(timeouts Timeouts) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		timeout := timeouts.method(info.FullMethod)
+		if timeout <= 0 {
+			return handler(srv, ss)
+		}
+
+		ctx, cancel := context.WithTimeout(ss.Context(), timeout)
+		defer cancel()
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
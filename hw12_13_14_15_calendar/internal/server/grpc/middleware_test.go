@@ -0,0 +1,217 @@
+package internalgrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/requestid"
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var got string
+	handler := func(ctx context.Context, _ any) (any, error) {
+		got = requestid.RequestID(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-1"))
+	_, err := requestIDMiddleware(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "req-1", got)
+
+	_, err = requestIDMiddleware(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.NotEmpty(t, got)
+	require.NotEqual(t, "req-1", got)
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	logg, err := logger.New("ERROR", &output)
+	require.NoError(t, err)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/calendar.Calendar/Get"}
+	_, err = recoveryMiddleware(logg)(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		panic("boom")
+	})
+
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Contains(t, output.String(), `msg="handler panicked" method=/calendar.Calendar/Get panic=boom`)
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	timeouts := Timeouts{
+		Default: time.Second,
+		Methods: map[string]time.Duration{"/calendar.Calendar/Batch": time.Minute, "/calendar.Calendar/Get": 0},
+	}
+
+	deadline := func(fullMethod string) (time.Duration, bool) {
+		var (
+			left time.Duration
+			ok   bool
+		)
+		handler := func(ctx context.Context, _ any) (any, error) {
+			var d time.Time
+			d, ok = ctx.Deadline()
+			left = time.Until(d)
+			return nil, nil
+		}
+		info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+		_, err := timeoutMiddleware(timeouts)(context.Background(), nil, info, handler)
+		require.NoError(t, err)
+
+		return left, ok
+	}
+
+	left, ok := deadline("/calendar.Calendar/Create")
+	require.True(t, ok)
+	require.InDelta(t, time.Second, left, float64(100*time.Millisecond))
+
+	left, ok = deadline("/calendar.Calendar/Batch")
+	require.True(t, ok)
+	require.InDelta(t, time.Minute, left, float64(time.Second))
+
+	_, ok = deadline("/calendar.Calendar/Get")
+	require.False(t, ok)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.New(map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassRead:  {Rate: 1, Burst: 1},
+		ratelimit.ClassWrite: {Rate: 0.5, Burst: 1},
+	})
+	middleware := RateLimitMiddleware(limiter)
+	handler := func(context.Context, any) (any, error) {
+		return "ok", nil
+	}
+
+	call := func(method string, userID int) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000},
+		})
+		if userID != 0 {
+			ctx = identity.WithUserID(ctx, userID)
+		}
+		_, err := middleware(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/calendar.Calendar/" + method}, handler)
+		return err
+	}
+
+	require.NoError(t, call("Create", 1))
+	err := call("Update", 1)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	retryInfo, ok := details[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	require.InDelta(t, 2*time.Second, retryInfo.RetryDelay.AsDuration(), float64(100*time.Millisecond))
+
+	require.NoError(t, call("GetEventsListOnDate", 1))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call("ListTags", 1)))
+	require.NoError(t, call("Create", 2))
+	require.NoError(t, call("Create", 0))
+	require.Equal(t, codes.ResourceExhausted, status.Code(call("Create", 0)))
+}
+
+func TestStreamRateLimitBatch(t *testing.T) {
+	logg, err := logger.New("ERROR", &bytes.Buffer{})
+	require.NoError(t, err)
+
+	server := NewServer(logg, app.New(logg, memorystorage.New()), "127.0.0.1", "0")
+	server.UseStream(StreamRateLimitMiddleware(ratelimit.New(map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassWrite: {Rate: 0.1, Burst: 3},
+	})))
+	server.setup()
+
+	lsn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.server.Serve(lsn)
+	defer server.server.Stop()
+
+	conn, err := grpc.NewClient(lsn.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := calendarpb.NewCalendarClient(conn)
+
+	batch := func(size int) error {
+		stream, err := client.Batch(context.Background())
+		require.NoError(t, err)
+		for i := 0; i < size; i++ {
+			require.NoError(t, stream.Send(&calendarpb.BatchRequest{
+				Type:    calendarpb.BatchOperationType_BATCH_OPERATION_CREATE,
+				Title:   "Test",
+				StartDt: timestamppb.New(time.Date(2024, 6, 14, 0, 0, 0, 0, time.UTC)),
+				EndDt:   timestamppb.New(time.Date(2024, 6, 19, 0, 0, 0, 0, time.UTC)),
+			}))
+		}
+		_, err = stream.CloseAndRecv()
+		return err
+	}
+
+	// Each operation of the stream takes a write token.
+	require.NoError(t, batch(2))
+	require.Equal(t, codes.ResourceExhausted, status.Code(batch(2)))
+}
+
+type tokenAuthenticator map[string]int
+
+func (a tokenAuthenticator) Authenticate(_ context.Context, token string) (int, error) {
+	userID, ok := a[token]
+	if !ok {
+		return 0, errors.New("invalid token")
+	}
+
+	return userID, nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	logg, err := logger.New("ERROR", io.Discard)
+	require.NoError(t, err)
+
+	middleware := AuthMiddleware(tokenAuthenticator{"token-1": 1}, logg, []string{"/grpc.health.v1.Health/Check"})
+	var userID int
+	handler := func(ctx context.Context, _ any) (any, error) {
+		userID = identity.UserID(ctx)
+		return nil, nil
+	}
+
+	call := func(method string, authorization string) error {
+		userID = -1
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+		_, err := middleware(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	require.NoError(t, call("/calendar.Calendar/Create", "Bearer token-1"))
+	require.Equal(t, 1, userID)
+
+	for _, authorization := range []string{"", "Bearer token-2", "token-1"} {
+		require.Equal(t, codes.Unauthenticated, status.Code(call("/calendar.Calendar/Create", authorization)))
+		require.Equal(t, -1, userID)
+	}
+
+	require.NoError(t, call("/grpc.health.v1.Health/Check", ""))
+	require.Equal(t, 0, userID)
+}
@@ -31,6 +31,17 @@ type CalendarClient interface {
 	GetEventsListOnDate(ctx context.Context, in *GetEventsListOnDateRequest, opts ...grpc.CallOption) (*GetEventsListOnDateResult, error)
 	GetEventsListOnWeek(ctx context.Context, in *GetEventsListOnWeekRequest, opts ...grpc.CallOption) (*GetEventsListOnWeekResult, error)
 	GetEventsListOnMonth(ctx context.Context, in *GetEventsListOnMonthRequest, opts ...grpc.CallOption) (*GetEventsListOnMonthResult, error)
+	GetEventHistory(ctx context.Context, in *GetEventHistoryRequest, opts ...grpc.CallOption) (*GetEventHistoryResult, error)
+	Batch(ctx context.Context, opts ...grpc.CallOption) (Calendar_BatchClient, error)
+	CreateCalendar(ctx context.Context, in *CreateCalendarRequest, opts ...grpc.CallOption) (*CalendarResult, error)
+	ListCalendars(ctx context.Context, in *ListCalendarsRequest, opts ...grpc.CallOption) (*ListCalendarsResult, error)
+	DeleteCalendar(ctx context.Context, in *DeleteCalendarRequest, opts ...grpc.CallOption) (*DeleteCalendarResult, error)
+	ShareCalendar(ctx context.Context, in *ShareCalendarRequest, opts ...grpc.CallOption) (*ShareCalendarResult, error)
+	UnshareCalendar(ctx context.Context, in *UnshareCalendarRequest, opts ...grpc.CallOption) (*UnshareCalendarResult, error)
+	ListCalendarGrants(ctx context.Context, in *ListCalendarGrantsRequest, opts ...grpc.CallOption) (*ListCalendarGrantsResult, error)
+	SaveTag(ctx context.Context, in *SaveTagRequest, opts ...grpc.CallOption) (*Tag, error)
+	ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (*ListTagsResult, error)
+	DeleteTag(ctx context.Context, in *DeleteTagRequest, opts ...grpc.CallOption) (*DeleteTagResult, error)
 }
 
 type calendarClient struct {
@@ -122,6 +133,130 @@ func (c *calendarClient) GetEventsListOnMonth(ctx context.Context, in *GetEvents
 	return out, nil
 }
 
+func (c *calendarClient) GetEventHistory(ctx context.Context, in *GetEventHistoryRequest, opts ...grpc.CallOption) (*GetEventHistoryResult, error) {
+	out := new(GetEventHistoryResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/GetEventHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) Batch(ctx context.Context, opts ...grpc.CallOption) (Calendar_BatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Calendar_ServiceDesc.Streams[0], "/calendar.Calendar/Batch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calendarBatchClient{stream}
+	return x, nil
+}
+
+type Calendar_BatchClient interface {
+	Send(*BatchRequest) error
+	CloseAndRecv() (*BatchResult, error)
+	grpc.ClientStream
+}
+
+type calendarBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *calendarBatchClient) Send(m *BatchRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *calendarBatchClient) CloseAndRecv() (*BatchResult, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(BatchResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calendarClient) CreateCalendar(ctx context.Context, in *CreateCalendarRequest, opts ...grpc.CallOption) (*CalendarResult, error) {
+	out := new(CalendarResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/CreateCalendar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ListCalendars(ctx context.Context, in *ListCalendarsRequest, opts ...grpc.CallOption) (*ListCalendarsResult, error) {
+	out := new(ListCalendarsResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/ListCalendars", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) DeleteCalendar(ctx context.Context, in *DeleteCalendarRequest, opts ...grpc.CallOption) (*DeleteCalendarResult, error) {
+	out := new(DeleteCalendarResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/DeleteCalendar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ShareCalendar(ctx context.Context, in *ShareCalendarRequest, opts ...grpc.CallOption) (*ShareCalendarResult, error) {
+	out := new(ShareCalendarResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/ShareCalendar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) UnshareCalendar(ctx context.Context, in *UnshareCalendarRequest, opts ...grpc.CallOption) (*UnshareCalendarResult, error) {
+	out := new(UnshareCalendarResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/UnshareCalendar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ListCalendarGrants(ctx context.Context, in *ListCalendarGrantsRequest, opts ...grpc.CallOption) (*ListCalendarGrantsResult, error) {
+	out := new(ListCalendarGrantsResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/ListCalendarGrants", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) SaveTag(ctx context.Context, in *SaveTagRequest, opts ...grpc.CallOption) (*Tag, error) {
+	out := new(Tag)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/SaveTag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (*ListTagsResult, error) {
+	out := new(ListTagsResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/ListTags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarClient) DeleteTag(ctx context.Context, in *DeleteTagRequest, opts ...grpc.CallOption) (*DeleteTagResult, error) {
+	out := new(DeleteTagResult)
+	err := c.cc.Invoke(ctx, "/calendar.Calendar/DeleteTag", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CalendarServer is the server API for Calendar service.
 // All implementations must embed UnimplementedCalendarServer
 // for forward compatibility
@@ -135,6 +270,17 @@ type CalendarServer interface {
 	GetEventsListOnDate(context.Context, *GetEventsListOnDateRequest) (*GetEventsListOnDateResult, error)
 	GetEventsListOnWeek(context.Context, *GetEventsListOnWeekRequest) (*GetEventsListOnWeekResult, error)
 	GetEventsListOnMonth(context.Context, *GetEventsListOnMonthRequest) (*GetEventsListOnMonthResult, error)
+	GetEventHistory(context.Context, *GetEventHistoryRequest) (*GetEventHistoryResult, error)
+	Batch(Calendar_BatchServer) error
+	CreateCalendar(context.Context, *CreateCalendarRequest) (*CalendarResult, error)
+	ListCalendars(context.Context, *ListCalendarsRequest) (*ListCalendarsResult, error)
+	DeleteCalendar(context.Context, *DeleteCalendarRequest) (*DeleteCalendarResult, error)
+	ShareCalendar(context.Context, *ShareCalendarRequest) (*ShareCalendarResult, error)
+	UnshareCalendar(context.Context, *UnshareCalendarRequest) (*UnshareCalendarResult, error)
+	ListCalendarGrants(context.Context, *ListCalendarGrantsRequest) (*ListCalendarGrantsResult, error)
+	SaveTag(context.Context, *SaveTagRequest) (*Tag, error)
+	ListTags(context.Context, *ListTagsRequest) (*ListTagsResult, error)
+	DeleteTag(context.Context, *DeleteTagRequest) (*DeleteTagResult, error)
 	mustEmbedUnimplementedCalendarServer()
 }
 
@@ -169,6 +315,39 @@ func (UnimplementedCalendarServer) GetEventsListOnWeek(context.Context, *GetEven
 func (UnimplementedCalendarServer) GetEventsListOnMonth(context.Context, *GetEventsListOnMonthRequest) (*GetEventsListOnMonthResult, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEventsListOnMonth not implemented")
 }
+func (UnimplementedCalendarServer) GetEventHistory(context.Context, *GetEventHistoryRequest) (*GetEventHistoryResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEventHistory not implemented")
+}
+func (UnimplementedCalendarServer) Batch(Calendar_BatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Batch not implemented")
+}
+func (UnimplementedCalendarServer) CreateCalendar(context.Context, *CreateCalendarRequest) (*CalendarResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCalendar not implemented")
+}
+func (UnimplementedCalendarServer) ListCalendars(context.Context, *ListCalendarsRequest) (*ListCalendarsResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCalendars not implemented")
+}
+func (UnimplementedCalendarServer) DeleteCalendar(context.Context, *DeleteCalendarRequest) (*DeleteCalendarResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCalendar not implemented")
+}
+func (UnimplementedCalendarServer) ShareCalendar(context.Context, *ShareCalendarRequest) (*ShareCalendarResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShareCalendar not implemented")
+}
+func (UnimplementedCalendarServer) UnshareCalendar(context.Context, *UnshareCalendarRequest) (*UnshareCalendarResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnshareCalendar not implemented")
+}
+func (UnimplementedCalendarServer) ListCalendarGrants(context.Context, *ListCalendarGrantsRequest) (*ListCalendarGrantsResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCalendarGrants not implemented")
+}
+func (UnimplementedCalendarServer) SaveTag(context.Context, *SaveTagRequest) (*Tag, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveTag not implemented")
+}
+func (UnimplementedCalendarServer) ListTags(context.Context, *ListTagsRequest) (*ListTagsResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTags not implemented")
+}
+func (UnimplementedCalendarServer) DeleteTag(context.Context, *DeleteTagRequest) (*DeleteTagResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTag not implemented")
+}
 func (UnimplementedCalendarServer) mustEmbedUnimplementedCalendarServer() {}
 
 // UnsafeCalendarServer may be embedded to opt out of forward compatibility for this service.
@@ -344,6 +523,212 @@ func _Calendar_GetEventsListOnMonth_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Calendar_GetEventHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEventHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).GetEventHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/GetEventHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).GetEventHistory(ctx, req.(*GetEventHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_Batch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CalendarServer).Batch(&calendarBatchServer{stream})
+}
+
+type Calendar_BatchServer interface {
+	SendAndClose(*BatchResult) error
+	Recv() (*BatchRequest, error)
+	grpc.ServerStream
+}
+
+type calendarBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *calendarBatchServer) SendAndClose(m *BatchResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *calendarBatchServer) Recv() (*BatchRequest, error) {
+	m := new(BatchRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Calendar_CreateCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).CreateCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/CreateCalendar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).CreateCalendar(ctx, req.(*CreateCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ListCalendars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCalendarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ListCalendars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/ListCalendars",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ListCalendars(ctx, req.(*ListCalendarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_DeleteCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).DeleteCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/DeleteCalendar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).DeleteCalendar(ctx, req.(*DeleteCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ShareCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ShareCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/ShareCalendar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ShareCalendar(ctx, req.(*ShareCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_UnshareCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnshareCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).UnshareCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/UnshareCalendar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).UnshareCalendar(ctx, req.(*UnshareCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ListCalendarGrants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCalendarGrantsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ListCalendarGrants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/ListCalendarGrants",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ListCalendarGrants(ctx, req.(*ListCalendarGrantsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_SaveTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveTagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).SaveTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/SaveTag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).SaveTag(ctx, req.(*SaveTagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_ListTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).ListTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/ListTags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).ListTags(ctx, req.(*ListTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Calendar_DeleteTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServer).DeleteTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calendar.Calendar/DeleteTag",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServer).DeleteTag(ctx, req.(*DeleteTagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Calendar_ServiceDesc is the grpc.ServiceDesc for Calendar service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -387,7 +772,53 @@ var Calendar_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetEventsListOnMonth",
 			Handler:    _Calendar_GetEventsListOnMonth_Handler,
 		},
+		{
+			MethodName: "GetEventHistory",
+			Handler:    _Calendar_GetEventHistory_Handler,
+		},
+		{
+			MethodName: "CreateCalendar",
+			Handler:    _Calendar_CreateCalendar_Handler,
+		},
+		{
+			MethodName: "ListCalendars",
+			Handler:    _Calendar_ListCalendars_Handler,
+		},
+		{
+			MethodName: "DeleteCalendar",
+			Handler:    _Calendar_DeleteCalendar_Handler,
+		},
+		{
+			MethodName: "ShareCalendar",
+			Handler:    _Calendar_ShareCalendar_Handler,
+		},
+		{
+			MethodName: "UnshareCalendar",
+			Handler:    _Calendar_UnshareCalendar_Handler,
+		},
+		{
+			MethodName: "ListCalendarGrants",
+			Handler:    _Calendar_ListCalendarGrants_Handler,
+		},
+		{
+			MethodName: "SaveTag",
+			Handler:    _Calendar_SaveTag_Handler,
+		},
+		{
+			MethodName: "ListTags",
+			Handler:    _Calendar_ListTags_Handler,
+		},
+		{
+			MethodName: "DeleteTag",
+			Handler:    _Calendar_DeleteTag_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Batch",
+			Handler:       _Calendar_Batch_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/server/grpc/calendar.proto",
 }
@@ -0,0 +1,97 @@
+package internalgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RateLimitMiddleware rejects the calls of the users, or of the clients without a user,
+// which ran out of the limit of the method class with ResourceExhausted. The status carries
+// a RetryInfo detail with the delay after which the call may be retried. The health service
+// is never limited.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := limit(ctx, limiter, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRateLimitMiddleware is RateLimitMiddleware for the streams, a stream takes one token when it is opened.
+// Batch charges the operations of the stream beyond the first one through the stream context.
+func StreamRateLimitMiddleware(limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := limit(ss.Context(), limiter, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func limit(ctx context.Context, limiter *ratelimit.Limiter, fullMethod string) (context.Context, error) {
+	if strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+
+	class := methodClass(fullMethod)
+	key := ratelimit.Key(ctx, peerIP(ctx))
+	allowed, retryAfter := limiter.Allow(class, key)
+	if !allowed {
+		return ctx, rateLimitedError(retryAfter)
+	}
+
+	return limiter.WithCall(ctx, class, key), nil
+}
+
+// methodClass tells the reading methods, named Get* and List*, from the modifying ones.
+func methodClass(fullMethod string) ratelimit.Class {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if strings.HasPrefix(method, "Get") || strings.HasPrefix(method, "List") {
+		return ratelimit.ClassRead
+	}
+
+	return ratelimit.ClassWrite
+}
+
+func rateLimitedError(retryAfter time.Duration) error {
+	const message = "rate limit exceeded"
+
+	st, detailsErr := status.New(codes.ResourceExhausted, message).WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+	)
+	if detailsErr != nil {
+		return status.Error(codes.ResourceExhausted, message)
+	}
+
+	return st.Err()
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
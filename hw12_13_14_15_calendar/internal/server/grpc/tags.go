@@ -0,0 +1,50 @@
+package internalgrpc
+
+import (
+	"context"
+
+	calendarpb "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/grpc/pb"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Server) SaveTag(ctx context.Context, r *calendarpb.SaveTagRequest) (*calendarpb.Tag, error) {
+	tag, err := s.app.SaveTag(ctx, r.GetName(), r.GetColor())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return buildTagResult(tag), nil
+}
+
+func (s *Server) ListTags(ctx context.Context, _ *calendarpb.ListTagsRequest) (*calendarpb.ListTagsResult, error) {
+	tags, err := s.app.GetTags(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resultsList := make([]*calendarpb.Tag, 0, len(tags))
+	for i := range tags {
+		resultsList = append(resultsList, buildTagResult(tags[i]))
+	}
+
+	return &calendarpb.ListTagsResult{
+		List: resultsList,
+	}, nil
+}
+
+func (s *Server) DeleteTag(ctx context.Context, r *calendarpb.DeleteTagRequest) (*calendarpb.DeleteTagResult, error) {
+	err := s.app.DeleteTag(ctx, r.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &calendarpb.DeleteTagResult{}, nil
+}
+
+func buildTagResult(tag storage.Tag) *calendarpb.Tag {
+	return &calendarpb.Tag{
+		OwnerId: int64(tag.OwnerID),
+		Name:    tag.Name,
+		Color:   tag.Color,
+	}
+}
@@ -0,0 +1,67 @@
+package internalhttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+)
+
+// Authenticator resolves the credentials passed by the client to the ID of the user.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (int, error)
+}
+
+// AuthMiddleware serves the requests as the user the token of the Authorization header
+// was issued to and rejects the ones without a valid token with 401. The token is passed
+// as a bearer token, or as the password of the basic credentials for the CalDAV clients
+// which cannot send bearer tokens.
+//
+// The public routes are served without a user. A route ending with a slash covers
+// the paths under it, like the patterns of http.ServeMux.
+func AuthMiddleware(authenticator Authenticator, logg Logger, publicRoutes []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPublicRoute(r.URL.Path, publicRoutes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID, err := authenticator.Authenticate(r.Context(), credentials(r))
+			if err != nil {
+				logg.WarnContext(r.Context(), "authentication failed", "error", err)
+				w.Header().Add("WWW-Authenticate", `Bearer realm="calendar"`)
+				w.Header().Add("WWW-Authenticate", `Basic realm="calendar"`)
+				status := http.StatusUnauthorized
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(identity.WithUserID(r.Context(), userID)))
+		})
+	}
+}
+
+func credentials(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func isPublicRoute(path string, publicRoutes []string) bool {
+	for _, route := range publicRoutes {
+		if path == route || (strings.HasSuffix(route, "/") && strings.HasPrefix(path, route)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,191 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	batchStatusOK      = "ok"
+	batchStatusError   = "error"
+	batchStatusAborted = "aborted"
+)
+
+const (
+	// maxBatchOperationSize is the room left for one operation of the batch body,
+	// the description is the only field without a length limit.
+	maxBatchOperationSize = 16 << 10
+	// maxBatchBodySize limits the batch body to the largest batch the application accepts.
+	maxBatchBodySize = app.MaxBatchSize * maxBatchOperationSize
+)
+
+type batchRequest struct {
+	Mode       string                `json:"mode"`
+	Operations []batchOperationInput `json:"operations"`
+}
+
+type batchOperationInput struct {
+	Op           string   `json:"op"`
+	ID           string   `json:"id"`
+	CalendarID   string   `json:"calendar_id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Location     string   `json:"location"`
+	URL          string   `json:"url"`
+	StartDt      string   `json:"start_dt"`
+	EndDt        string   `json:"end_dt"`
+	NotifyBefore string   `json:"notify_before"`
+	Tags         []string `json:"tags"`
+}
+
+type batchResponse struct {
+	Applied bool                `json:"applied"`
+	Results []batchResultOutput `json:"results"`
+}
+
+type batchResultOutput struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *Server) BatchEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req batchRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&req)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = fmt.Errorf("%w: the body is larger than %d bytes", app.ErrBatchTooLarge, maxBytesErr.Limit)
+		s.requestEntityTooLarge(w, err)
+		return
+	}
+	if err != nil {
+		s.badRequest(w, errors.New("invalid json: "+err.Error()))
+		return
+	}
+
+	mode := storage.BatchMode(req.Mode)
+	if mode == "" {
+		mode = storage.BatchModeAllOrNothing
+	}
+
+	operations := make([]storage.BatchOperation, 0, len(req.Operations))
+	for i := range req.Operations {
+		operation, err := buildBatchOperation(req.Operations[i])
+		if err != nil {
+			s.badRequest(w, fmt.Errorf("operations[%d].%w", i, err))
+			return
+		}
+		operations = append(operations, operation)
+	}
+
+	// The rate limit middleware took a token for the request, the other operations are charged
+	// here so that a batch costs as much as the same requests made one by one.
+	if len(operations) > 1 && len(operations) <= app.MaxBatchSize {
+		allowed, retryAfter := ratelimit.Charge(r.Context(), len(operations)-1)
+		if !allowed {
+			tooManyRequests(w, retryAfter)
+			return
+		}
+	}
+
+	results, err := s.app.ApplyBatch(r.Context(), mode, operations)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		s.appError(w, err)
+		return
+	}
+
+	resp := batchResponse{
+		Applied: storage.BatchApplied(mode, results),
+		Results: make([]batchResultOutput, 0, len(results)),
+	}
+
+	for i := range results {
+		result := batchResultOutput{
+			Index:  results[i].Index,
+			ID:     results[i].EventID,
+			Status: batchStatusOK,
+		}
+
+		switch {
+		case errors.Is(results[i].Err, storage.ErrBatchAborted):
+			result.Status = batchStatusAborted
+		case results[i].Err != nil:
+			result.Status = batchStatusError
+			result.Error = results[i].Err.Error()
+		}
+
+		resp.Results = append(resp.Results, result)
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		s.internalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Applied {
+		w.WriteHeader(http.StatusConflict)
+	}
+
+	_, writeErr := w.Write(body)
+	if writeErr != nil {
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
+	}
+}
+
+func buildBatchOperation(input batchOperationInput) (storage.BatchOperation, error) {
+	operation := storage.BatchOperation{
+		Type:    storage.BatchOperationType(input.Op),
+		EventID: input.ID,
+	}
+
+	if operation.Type == storage.BatchOperationDelete {
+		return operation, nil
+	}
+
+	startDt, err := time.Parse(time.DateOnly, input.StartDt)
+	if err != nil {
+		return operation, errors.New("start_dt: " + err.Error())
+	}
+
+	endDt, err := time.Parse(time.DateOnly, input.EndDt)
+	if err != nil {
+		return operation, errors.New("end_dt: " + err.Error())
+	}
+
+	notifyBefore, err := time.ParseDuration(input.NotifyBefore)
+	if err != nil {
+		return operation, errors.New("notify_before: " + err.Error())
+	}
+
+	operation.Event = storage.Event{
+		ID:           input.ID,
+		CalendarID:   input.CalendarID,
+		Title:        input.Title,
+		Description:  input.Description,
+		Location:     input.Location,
+		URL:          input.URL,
+		StartDate:    startDt,
+		EndDate:      endDt,
+		NotifyBefore: notifyBefore,
+		Tags:         input.Tags,
+	}
+
+	return operation, nil
+}
@@ -0,0 +1,735 @@
+package caldav
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // ETags do not need a cryptographic hash.
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+// DefaultCollection is the calendar collection with the events which do not belong to any calendar.
+const DefaultCollection = "default"
+
+const (
+	methodPropfind = "PROPFIND"
+	methodReport   = "REPORT"
+
+	calendarContentType = "text/calendar; charset=utf-8"
+	// maxCalendarDataSize limits the size of a PUT request body.
+	maxCalendarDataSize = 1 << 20
+)
+
+var (
+	ErrCollectionNotExists = errors.New("caldav: calendar collection not exists")
+	ErrEventUIDConflict    = errors.New("caldav: event belongs to another calendar collection")
+)
+
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
+}
+
+type Application interface {
+	CreateEvent(
+		ctx context.Context,
+		id, calendarID, title, description, location, url string,
+		startDate time.Time,
+		endDate time.Time,
+		notifyBefore time.Duration,
+		tags []string,
+	) (string, error)
+	UpdateEvent(
+		ctx context.Context,
+		eventID string,
+		calendarID string,
+		title string,
+		description string,
+		location string,
+		url string,
+		startDate time.Time,
+		endDate time.Time,
+		notifyBefore time.Duration,
+		tags []string,
+	) error
+	DeleteEvent(ctx context.Context, eventID string) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	GetEventsListByDates(
+		ctx context.Context,
+		from *time.Time,
+		to *time.Time,
+		filter storage.EventFilter,
+	) ([]storage.Event, error)
+	GetCalendars(ctx context.Context) ([]storage.UserCalendar, error)
+}
+
+// Handler serves a CalDAV (RFC 4791) subset enough for the desktop and mobile clients to sync events:
+//
+//	{prefix}/                             the principal of the current user
+//	{prefix}/calendars/                   the calendar home with a collection per visible calendar
+//	{prefix}/calendars/{calendar}/        PROPFIND, REPORT calendar-query and calendar-multiget
+//	{prefix}/calendars/{calendar}/{id}.ics GET, PUT and DELETE of a single event
+type Handler struct {
+	logger Logger
+	app    Application
+	prefix string
+}
+
+// collection is a calendar visible to the current user as a CalDAV collection.
+type collection struct {
+	Name       string
+	CalendarID string
+	Title      string
+	Permission storage.CalendarPermission
+}
+
+func NewHandler(logg Logger, app Application, prefix string) *Handler {
+	return &Handler{
+		logger: logg,
+		app:    app,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// WellKnown redirects the clients looking for /.well-known/caldav to the principal.
+func (h *Handler) WellKnown(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, h.principalHref(), http.StatusMovedPermanently)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, h.prefix), "/")
+	parts := []string{}
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	switch {
+	case len(parts) == 0:
+		h.principalHandler(w, r)
+	case parts[0] != "calendars":
+		http.NotFound(w, r)
+	case len(parts) == 1:
+		h.homeHandler(w, r)
+	case len(parts) == 2:
+		h.collectionHandler(w, r, parts[1])
+	case len(parts) == 3 && strings.HasSuffix(parts[2], ".ics") && len(parts[2]) > len(".ics"):
+		h.objectHandler(w, r, parts[1], strings.TrimSuffix(parts[2], ".ics"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) principalHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != methodPropfind {
+		methodNotAllowed(w, methodPropfind)
+		return
+	}
+
+	req, ok := h.parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	responses := []davResponse{h.principalResponse(req)}
+	if depth(r) > 0 {
+		responses = append(responses, h.homeResponse(req))
+	}
+
+	h.writeMultistatus(w, responses)
+}
+
+func (h *Handler) homeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != methodPropfind {
+		methodNotAllowed(w, methodPropfind)
+		return
+	}
+
+	req, ok := h.parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	responses := []davResponse{h.homeResponse(req)}
+
+	if depth(r) > 0 {
+		collections, err := h.collections(r.Context())
+		if err != nil {
+			h.error(w, r, err)
+			return
+		}
+
+		for i := range collections {
+			response, err := h.collectionResponse(r.Context(), collections[i], req)
+			if err != nil {
+				h.error(w, r, err)
+				return
+			}
+			responses = append(responses, response)
+		}
+	}
+
+	h.writeMultistatus(w, responses)
+}
+
+func (h *Handler) collectionHandler(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != methodPropfind && r.Method != methodReport {
+		methodNotAllowed(w, methodPropfind, methodReport)
+		return
+	}
+
+	col, err := h.findCollection(r.Context(), name)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	req, ok := h.parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var responses []davResponse
+	if r.Method == methodReport {
+		responses, err = h.report(r.Context(), col, req)
+	} else {
+		responses, err = h.propfindCollection(r.Context(), col, req, depth(r))
+	}
+
+	if errors.Is(err, errReportNotSupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	h.writeMultistatus(w, responses)
+}
+
+func (h *Handler) propfindCollection(
+	ctx context.Context,
+	col collection,
+	req davRequest,
+	depth int,
+) ([]davResponse, error) {
+	response, err := h.collectionResponse(ctx, col, req)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []davResponse{response}
+	if depth == 0 {
+		return responses, nil
+	}
+
+	events, err := h.collectionEvents(ctx, col)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range events {
+		responses = append(responses, h.eventResponse(col, events[i], req))
+	}
+
+	return responses, nil
+}
+
+var errReportNotSupported = errors.New("caldav: report not supported")
+
+func (h *Handler) report(ctx context.Context, col collection, req davRequest) ([]davResponse, error) {
+	switch req.Root {
+	case "calendar-query":
+		return h.calendarQuery(ctx, col, req)
+	case "calendar-multiget":
+		return h.calendarMultiget(ctx, col, req)
+	default:
+		return nil, errReportNotSupported
+	}
+}
+
+func (h *Handler) calendarQuery(ctx context.Context, col collection, req davRequest) ([]davResponse, error) {
+	events, err := h.collectionEvents(ctx, col)
+	if err != nil {
+		return nil, err
+	}
+
+	var rangeMin, rangeMax time.Time
+	if req.RangeMin != "" {
+		rangeMin, _, err = parseTime(req.RangeMin, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.RangeMax != "" {
+		rangeMax, _, err = parseTime(req.RangeMax, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	responses := []davResponse{}
+	for i := range events {
+		if !rangeMin.IsZero() && !events[i].EndDate.After(rangeMin) {
+			continue
+		}
+		if !rangeMax.IsZero() && !events[i].StartDate.Before(rangeMax) {
+			continue
+		}
+
+		responses = append(responses, h.eventResponse(col, events[i], req))
+	}
+
+	return responses, nil
+}
+
+func (h *Handler) calendarMultiget(ctx context.Context, col collection, req davRequest) ([]davResponse, error) {
+	collectionHref := h.collectionHref(col)
+
+	responses := make([]davResponse, 0, len(req.Hrefs))
+	for _, href := range req.Hrefs {
+		name := strings.TrimPrefix(href, collectionHref)
+		if name == href || !strings.HasSuffix(name, ".ics") || strings.Contains(name, "/") {
+			responses = append(responses, davResponse{Href: href, Status: http.StatusNotFound})
+			continue
+		}
+
+		event, err := h.app.GetEvent(ctx, strings.TrimSuffix(name, ".ics"))
+		if errors.Is(err, storage.ErrReadEventNotExists) || (err == nil && event.CalendarID != col.CalendarID) {
+			responses = append(responses, davResponse{Href: href, Status: http.StatusNotFound})
+			continue
+		}
+		if errors.Is(err, storage.ErrCalendarAccessDenied) {
+			responses = append(responses, davResponse{Href: href, Status: http.StatusForbidden})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		responses = append(responses, h.eventResponse(col, event, req))
+	}
+
+	return responses, nil
+}
+
+func (h *Handler) objectHandler(w http.ResponseWriter, r *http.Request, collectionName, eventID string) {
+	col, err := h.findCollection(r.Context(), collectionName)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	event, err := h.app.GetEvent(r.Context(), eventID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, storage.ErrReadEventNotExists) {
+		h.error(w, r, err)
+		return
+	}
+
+	if exists && event.CalendarID != col.CalendarID {
+		if r.Method != http.MethodPut {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.Error(w, ErrEventUIDConflict.Error(), http.StatusConflict)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", calendarContentType)
+		w.Header().Set("ETag", eventETag(event))
+		h.write(w, formatICalendar(event, time.Now()))
+	case methodPropfind:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+
+		req, ok := h.parseRequest(w, r)
+		if !ok {
+			return
+		}
+
+		h.writeMultistatus(w, []davResponse{h.eventResponse(col, event, req)})
+	case http.MethodPut:
+		h.putEvent(w, r, col, eventID, event, exists)
+	case http.MethodDelete:
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !checkPreconditions(w, r, event, exists) {
+			return
+		}
+
+		err = h.app.DeleteEvent(r.Context(), eventID)
+		if err != nil {
+			h.error(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, methodPropfind)
+	}
+}
+
+func (h *Handler) putEvent(
+	w http.ResponseWriter,
+	r *http.Request,
+	col collection,
+	eventID string,
+	savedEvent storage.Event,
+	exists bool,
+) {
+	if !checkPreconditions(w, r, savedEvent, exists) {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxCalendarDataSize))
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	event, err := parseICalendar(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusCreated
+	if exists {
+		status = http.StatusNoContent
+		err = h.app.UpdateEvent(
+			r.Context(),
+			eventID,
+			col.CalendarID,
+			event.Summary,
+			event.Description,
+			event.Location,
+			event.URL,
+			event.Start,
+			event.End,
+			event.NotifyBefore,
+			event.Categories,
+		)
+	} else {
+		_, err = h.app.CreateEvent(
+			r.Context(),
+			eventID,
+			col.CalendarID,
+			event.Summary,
+			event.Description,
+			event.Location,
+			event.URL,
+			event.Start,
+			event.End,
+			event.NotifyBefore,
+			event.Categories,
+		)
+	}
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	saved, err := h.app.GetEvent(r.Context(), eventID)
+	if err == nil {
+		w.Header().Set("ETag", eventETag(saved))
+	}
+
+	w.WriteHeader(status)
+}
+
+// checkPreconditions handles If-Match and If-None-Match headers and writes 412 when they fail.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, event storage.Event, exists bool) bool {
+	ifMatch := r.Header.Get("If-Match")
+	ifNoneMatch := r.Header.Get("If-None-Match")
+
+	failed := false
+	switch {
+	case ifNoneMatch == "*" && exists:
+		failed = true
+	case ifNoneMatch != "" && exists && ifNoneMatch == eventETag(event):
+		failed = true
+	case ifMatch != "" && !exists:
+		failed = true
+	case ifMatch != "" && ifMatch != "*" && ifMatch != eventETag(event):
+		failed = true
+	}
+
+	if failed {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) principalResponse(req davRequest) davResponse {
+	return buildResponse(h.principalHref(), req, []davProp{
+		{propResourceType, "<D:collection/><D:principal/>"},
+		{propDisplayName, "calendar user"},
+		{propCurrentUserPrincipal, hrefElement(h.principalHref())},
+		{propPrincipalURL, hrefElement(h.principalHref())},
+		{propCalendarHomeSet, hrefElement(h.homeHref())},
+	})
+}
+
+func (h *Handler) homeResponse(req davRequest) davResponse {
+	return buildResponse(h.homeHref(), req, []davProp{
+		{propResourceType, "<D:collection/>"},
+		{propDisplayName, "calendars"},
+		{propCurrentUserPrincipal, hrefElement(h.principalHref())},
+	})
+}
+
+func (h *Handler) collectionResponse(ctx context.Context, col collection, req davRequest) (davResponse, error) {
+	events, err := h.collectionEvents(ctx, col)
+	if err != nil {
+		return davResponse{}, err
+	}
+
+	return buildResponse(h.collectionHref(col), req, []davProp{
+		{propResourceType, "<D:collection/><C:calendar/>"},
+		{propDisplayName, escapeXML(col.Title)},
+		{propGetCTag, escapeXML(collectionCTag(events))},
+		{propSupportedComponents, `<C:comp name="VEVENT"/>`},
+		{propCurrentUserPrivileges, privilegesXML(col.Permission)},
+		{propCurrentUserPrincipal, hrefElement(h.principalHref())},
+	}), nil
+}
+
+func (h *Handler) eventResponse(col collection, event storage.Event, req davRequest) davResponse {
+	return buildResponse(h.eventHref(col, event.ID), req, []davProp{
+		{propResourceType, ""},
+		{propGetETag, escapeXML(eventETag(event))},
+		{propGetContentType, calendarContentType},
+		{propCalendarData, escapeXML(string(formatICalendar(event, time.Now())))},
+	})
+}
+
+type davProp struct {
+	Name     propName
+	InnerXML string
+}
+
+// buildResponse adds the requested properties or all of them for allprop requests.
+// The calendar data is returned only when it is requested explicitly.
+func buildResponse(href string, req davRequest, props []davProp) davResponse {
+	response := davResponse{Href: href}
+
+	if req.AllProp {
+		for _, prop := range props {
+			if prop.Name == propCalendarData {
+				continue
+			}
+			response.addProp(prop.Name, prop.InnerXML)
+		}
+		return response
+	}
+
+	for _, name := range req.Props {
+		found := false
+		for _, prop := range props {
+			if prop.Name == name {
+				response.addProp(prop.Name, prop.InnerXML)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			response.addMissingProp(name)
+		}
+	}
+
+	return response
+}
+
+func privilegesXML(permission storage.CalendarPermission) string {
+	if !permission.CanRead() {
+		return "<D:privilege><C:read-free-busy/></D:privilege>"
+	}
+
+	privileges := "<D:privilege><D:read/></D:privilege>"
+	if permission.CanWrite() {
+		privileges += "<D:privilege><D:write/></D:privilege>" +
+			"<D:privilege><D:write-content/></D:privilege>" +
+			"<D:privilege><D:bind/></D:privilege>" +
+			"<D:privilege><D:unbind/></D:privilege>"
+	}
+
+	return privileges
+}
+
+func (h *Handler) collections(ctx context.Context) ([]collection, error) {
+	calendars, err := h.app.GetCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := []collection{{
+		Name:       DefaultCollection,
+		Title:      "Events",
+		Permission: storage.CalendarPermissionOwner,
+	}}
+
+	for i := range calendars {
+		collections = append(collections, collection{
+			Name:       calendars[i].ID,
+			CalendarID: calendars[i].ID,
+			Title:      calendars[i].Name,
+			Permission: calendars[i].Permission,
+		})
+	}
+
+	return collections, nil
+}
+
+func (h *Handler) findCollection(ctx context.Context, name string) (collection, error) {
+	collections, err := h.collections(ctx)
+	if err != nil {
+		return collection{}, err
+	}
+
+	for i := range collections {
+		if collections[i].Name == name {
+			return collections[i], nil
+		}
+	}
+
+	return collection{}, ErrCollectionNotExists
+}
+
+func (h *Handler) collectionEvents(ctx context.Context, col collection) ([]storage.Event, error) {
+	events, err := h.app.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{
+		CalendarIDs: []string{col.CalendarID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+
+	return events, nil
+}
+
+func (h *Handler) principalHref() string {
+	return h.prefix + "/"
+}
+
+func (h *Handler) homeHref() string {
+	return h.prefix + "/calendars/"
+}
+
+func (h *Handler) collectionHref(col collection) string {
+	return h.homeHref() + col.Name + "/"
+}
+
+func (h *Handler) eventHref(col collection, eventID string) string {
+	return h.collectionHref(col) + eventID + ".ics"
+}
+
+func (h *Handler) parseRequest(w http.ResponseWriter, r *http.Request) (davRequest, bool) {
+	req, err := parseDAVRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return davRequest{}, false
+	}
+
+	return req, true
+}
+
+func (h *Handler) writeMultistatus(w http.ResponseWriter, responses []davResponse) {
+	err := writeMultistatus(w, responses)
+	if err != nil {
+		h.logger.Error("error writing response", "error", err)
+	}
+}
+
+func (h *Handler) write(w http.ResponseWriter, body []byte) {
+	_, err := w.Write(body)
+	if err != nil {
+		h.logger.Error("error writing response", "error", err)
+	}
+}
+
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrCalendarAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrCollectionNotExists), errors.Is(err, storage.ErrCalendarNotExists):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, storage.ErrTagNameTooLong), errors.Is(err, ErrInvalidCalendarData):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, app.ErrValidation):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, app.ErrEventQuotaExceeded):
+		// RFC 4331 reports the exceeded quota of the collection with 507.
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	default:
+		h.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// depth returns 0 for the Depth: 0 header and 1 otherwise, infinity is not supported.
+func depth(r *http.Request) int {
+	if r.Header.Get("Depth") == "0" {
+		return 0
+	}
+
+	return 1
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+func eventETag(event storage.Event) string {
+	data, _ := event.MarshalJSON()
+	sum := sha1.Sum(data) //nolint:gosec
+
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func collectionCTag(events []storage.Event) string {
+	hash := sha1.New() //nolint:gosec
+	for i := range events {
+		hash.Write([]byte(eventETag(events[i])))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
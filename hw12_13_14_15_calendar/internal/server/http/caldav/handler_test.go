@@ -0,0 +1,194 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+)
+
+const testEventICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:event-1\r\n" +
+	"DTSTART:20231001T100000Z\r\n" +
+	"DTEND:20231001T110000Z\r\n" +
+	"SUMMARY:Daily\\, sync\r\n" +
+	"DESCRIPTION:Status\\nBlockers\r\n" +
+	"LOCATION:Room 1\r\n" +
+	"URL:https://meet.example.com/daily\r\n" +
+	"CATEGORIES:work,team\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICalendar(t *testing.T) {
+	event, err := parseICalendar([]byte(testEventICS))
+	require.NoError(t, err)
+	require.Equal(t, "event-1", event.UID)
+	require.Equal(t, "Daily, sync", event.Summary)
+	require.Equal(t, "Status\nBlockers", event.Description)
+	require.Equal(t, "Room 1", event.Location)
+	require.Equal(t, "https://meet.example.com/daily", event.URL)
+	require.Equal(t, time.Date(2023, 10, 1, 10, 0, 0, 0, time.UTC), event.Start)
+	require.Equal(t, time.Date(2023, 10, 1, 11, 0, 0, 0, time.UTC), event.End)
+	require.Equal(t, []string{"work", "team"}, event.Categories)
+	require.Equal(t, 15*time.Minute, event.NotifyBefore)
+
+	_, err = parseICalendar([]byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"))
+	require.ErrorIs(t, err, ErrNoEvent)
+
+	formatted := formatICalendar(storage.Event{
+		ID:           "event-1",
+		Title:        strings.Repeat("long title ", 10),
+		Description:  event.Description,
+		Location:     event.Location,
+		URL:          event.URL,
+		StartDate:    event.Start,
+		EndDate:      event.End,
+		NotifyBefore: event.NotifyBefore,
+		Tags:         event.Categories,
+	}, time.Now())
+	for _, line := range strings.Split(string(formatted), "\r\n") {
+		require.LessOrEqual(t, len(line), icalMaxLineLength)
+	}
+
+	parsed, err := parseICalendar(formatted)
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("long title ", 10), parsed.Summary)
+	require.Equal(t, event.Start, parsed.Start)
+	require.Equal(t, event.NotifyBefore, parsed.NotifyBefore)
+	require.Equal(t, event.Categories, parsed.Categories)
+	require.Equal(t, event.Description, parsed.Description)
+	require.Equal(t, event.Location, parsed.Location)
+	require.Equal(t, event.URL, parsed.URL)
+}
+
+func TestHandler(t *testing.T) {
+	logg, err := logger.New("DEBUG", &bytes.Buffer{})
+	require.NoError(t, err)
+
+	calendar := app.New(logg, memorystorage.New())
+	handler := NewHandler(logg, calendar, "/caldav")
+	ctx := identity.WithUserID(context.Background(), 1)
+
+	do := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	objectURL := "/caldav/calendars/default/8a6e0f4c-3b2d-4c1e-9f7a-5d4c3b2a1e0f.ics"
+
+	t.Run("put and get", func(t *testing.T) {
+		w := do(http.MethodPut, objectURL, testEventICS, map[string]string{"If-None-Match": "*"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		etag := w.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		w = do(http.MethodPut, objectURL, testEventICS, map[string]string{"If-None-Match": "*"})
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = do(http.MethodGet, objectURL, "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, etag, w.Header().Get("ETag"))
+		require.Contains(t, w.Body.String(), "SUMMARY:Daily\\, sync")
+		require.Contains(t, w.Body.String(), "TRIGGER:-PT15M")
+
+		updated := strings.Replace(testEventICS, "Daily\\, sync", "Weekly", 1)
+		w = do(http.MethodPut, objectURL, updated, map[string]string{"If-Match": `"stale"`})
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = do(http.MethodPut, objectURL, updated, map[string]string{"If-Match": etag})
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.NotEqual(t, etag, w.Header().Get("ETag"))
+
+		event, err := calendar.GetEvent(ctx, "8a6e0f4c-3b2d-4c1e-9f7a-5d4c3b2a1e0f")
+		require.NoError(t, err)
+		require.Equal(t, "Weekly", event.Title)
+		require.Equal(t, []string{"work", "team"}, event.Tags)
+	})
+
+	t.Run("propfind", func(t *testing.T) {
+		w := do(methodPropfind, "/caldav/calendars/", "", map[string]string{"Depth": "1"})
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.Contains(t, w.Body.String(), "<D:href>/caldav/calendars/default/</D:href>")
+		require.Contains(t, w.Body.String(), "<C:calendar/>")
+
+		body := `<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:" xmlns:CS="http://calendarserver.org/ns/">
+  <D:prop><D:getetag/><CS:getctag/><D:unknown/></D:prop>
+</D:propfind>`
+		w = do(methodPropfind, "/caldav/calendars/default/", body, map[string]string{"Depth": "1"})
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.Contains(t, w.Body.String(), "<D:href>"+objectURL+"</D:href>")
+		require.Contains(t, w.Body.String(), "<CS:getctag>")
+		require.Contains(t, w.Body.String(), "<D:unknown></D:unknown>")
+		require.Contains(t, w.Body.String(), "HTTP/1.1 404 Not Found")
+	})
+
+	t.Run("report", func(t *testing.T) {
+		query := `<?xml version="1.0"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">
+    <C:time-range start="%s" end="%s"/>
+  </C:comp-filter></C:comp-filter></C:filter>
+</C:calendar-query>`
+		inRange := fmt.Sprintf(query, "20231001T000000Z", "20231002T000000Z")
+		w := do(methodReport, "/caldav/calendars/default/", inRange, map[string]string{"Depth": "1"})
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.Contains(t, w.Body.String(), objectURL)
+		require.Contains(t, w.Body.String(), "SUMMARY:Weekly")
+
+		outOfRange := fmt.Sprintf(query, "20231101T000000Z", "20231102T000000Z")
+		w = do(methodReport, "/caldav/calendars/default/", outOfRange, map[string]string{"Depth": "1"})
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.NotContains(t, w.Body.String(), objectURL)
+
+		multiget := `<?xml version="1.0"?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/></D:prop>
+  <D:href>` + objectURL + `</D:href>
+  <D:href>/caldav/calendars/default/missing.ics</D:href>
+</C:calendar-multiget>`
+		w = do(methodReport, "/caldav/calendars/default/", multiget, nil)
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.Contains(t, w.Body.String(), "<D:getetag>")
+		require.Contains(t, w.Body.String(), "/caldav/calendars/default/missing.ics")
+		require.Contains(t, w.Body.String(), "<D:status>HTTP/1.1 404 Not Found</D:status>")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		w := do(http.MethodDelete, objectURL, "", map[string]string{"If-Match": `"stale"`})
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = do(http.MethodDelete, objectURL, "", nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		w = do(http.MethodGet, objectURL, "", nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("unknown collection", func(t *testing.T) {
+		w := do(http.MethodPut, "/caldav/calendars/unknown/1f2e3d4c-5b6a-4789-8a9b-0c1d2e3f4a5b.ics", testEventICS, nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,458 @@
+package caldav
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	icalDateTimeFormat      = "20060102T150405Z"
+	icalLocalDateTimeFormat = "20060102T150405"
+	icalDateFormat          = "20060102"
+	icalMaxLineLength       = 75
+	icalProductID           = "-//otus_go//calendar//EN"
+)
+
+var (
+	ErrInvalidCalendarData = errors.New("caldav: invalid calendar data")
+	ErrNoEvent             = errors.New("caldav: calendar data has no VEVENT component")
+	ErrInvalidDuration     = errors.New("caldav: invalid duration")
+)
+
+// icalEvent holds the VEVENT properties supported by the calendar.
+type icalEvent struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	URL          string
+	Start        time.Time
+	End          time.Time
+	Categories   []string
+	NotifyBefore time.Duration
+}
+
+// parseICalendar reads the first VEVENT of an iCalendar (RFC 5545) object.
+func parseICalendar(data []byte) (icalEvent, error) {
+	var (
+		event                icalEvent
+		inCalendar, inEvent  bool
+		inAlarm, eventParsed bool
+		allDay               bool
+		duration             time.Duration
+		hasDuration          bool
+	)
+
+	for _, line := range unfoldLines(string(data)) {
+		if line == "" {
+			continue
+		}
+
+		name, params, value, err := parseContentLine(line)
+		if err != nil {
+			return icalEvent{}, err
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VCALENDAR":
+			inCalendar = true
+			continue
+		case name == "BEGIN" && value == "VEVENT" && inCalendar && !eventParsed:
+			inEvent = true
+			continue
+		case name == "END" && value == "VEVENT" && inEvent:
+			inEvent = false
+			eventParsed = true
+			continue
+		case name == "BEGIN" && value == "VALARM" && inEvent:
+			inAlarm = true
+			continue
+		case name == "END" && value == "VALARM":
+			inAlarm = false
+			continue
+		case !inEvent:
+			continue
+		case inAlarm:
+			if name == "TRIGGER" && event.NotifyBefore == 0 && params["VALUE"] != "DATE-TIME" && params["RELATED"] != "END" {
+				trigger, err := parseDuration(value)
+				if err != nil {
+					return icalEvent{}, err
+				}
+				if trigger < 0 {
+					event.NotifyBefore = -trigger
+				}
+			}
+			continue
+		}
+
+		switch name {
+		case "UID":
+			event.UID = value
+		case "SUMMARY":
+			event.Summary = unescapeText(value)
+		case "DESCRIPTION":
+			event.Description = unescapeText(value)
+		case "LOCATION":
+			event.Location = unescapeText(value)
+		case "URL":
+			event.URL = value
+		case "DTSTART":
+			event.Start, allDay, err = parseTime(value, params)
+		case "DTEND":
+			event.End, _, err = parseTime(value, params)
+		case "DURATION":
+			duration, err = parseDuration(value)
+			hasDuration = true
+		case "CATEGORIES":
+			event.Categories = append(event.Categories, splitText(value)...)
+		}
+		if err != nil {
+			return icalEvent{}, err
+		}
+	}
+
+	if !eventParsed {
+		return icalEvent{}, ErrNoEvent
+	}
+
+	if event.Start.IsZero() {
+		return icalEvent{}, ErrInvalidCalendarData
+	}
+
+	if event.End.IsZero() {
+		switch {
+		case hasDuration:
+			event.End = event.Start.Add(duration)
+		case allDay:
+			event.End = event.Start.AddDate(0, 0, 1)
+		default:
+			event.End = event.Start
+		}
+	}
+
+	return event, nil
+}
+
+// formatICalendar writes the event as an iCalendar object with a single VEVENT.
+func formatICalendar(event storage.Event, now time.Time) []byte {
+	b := strings.Builder{}
+
+	writeLine := func(line string) {
+		b.WriteString(foldLine(line))
+		b.WriteString("\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:" + icalProductID)
+	writeLine("BEGIN:VEVENT")
+	writeLine("UID:" + event.ID)
+	writeLine("DTSTAMP:" + now.UTC().Format(icalDateTimeFormat))
+	writeLine("DTSTART:" + event.StartDate.UTC().Format(icalDateTimeFormat))
+	writeLine("DTEND:" + event.EndDate.UTC().Format(icalDateTimeFormat))
+	writeLine("SUMMARY:" + escapeText(event.Title))
+
+	if event.Description != "" {
+		writeLine("DESCRIPTION:" + escapeText(event.Description))
+	}
+
+	if event.Location != "" {
+		writeLine("LOCATION:" + escapeText(event.Location))
+	}
+
+	if event.URL != "" {
+		writeLine("URL:" + event.URL)
+	}
+
+	if !event.CreatedAt.IsZero() {
+		writeLine("CREATED:" + event.CreatedAt.UTC().Format(icalDateTimeFormat))
+	}
+
+	if !event.UpdatedAt.IsZero() {
+		writeLine("LAST-MODIFIED:" + event.UpdatedAt.UTC().Format(icalDateTimeFormat))
+	}
+
+	if len(event.Tags) > 0 {
+		categories := make([]string, 0, len(event.Tags))
+		for _, tag := range event.Tags {
+			categories = append(categories, escapeText(tag))
+		}
+		writeLine("CATEGORIES:" + strings.Join(categories, ","))
+	}
+
+	if event.NotifyBefore > 0 {
+		writeLine("BEGIN:VALARM")
+		writeLine("ACTION:DISPLAY")
+		writeLine("DESCRIPTION:" + escapeText(event.Title))
+		writeLine("TRIGGER:" + formatDuration(-event.NotifyBefore))
+		writeLine("END:VALARM")
+	}
+
+	writeLine("END:VEVENT")
+	writeLine("END:VCALENDAR")
+
+	return []byte(b.String())
+}
+
+func unfoldLines(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	return strings.Split(data, "\n")
+}
+
+// foldLine splits lines longer than 75 octets without breaking UTF-8 sequences.
+func foldLine(line string) string {
+	if len(line) <= icalMaxLineLength {
+		return line
+	}
+
+	b := strings.Builder{}
+	limit := icalMaxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space of a continuation line counts to its length
+		limit = icalMaxLineLength - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
+
+func parseContentLine(line string) (string, map[string]string, string, error) {
+	inQuotes := false
+	colon := -1
+	for i := 0; i < len(line); i++ {
+		if line[i] == '"' {
+			inQuotes = !inQuotes
+		}
+		if line[i] == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+
+	if colon <= 0 {
+		return "", nil, "", ErrInvalidCalendarData
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return "", nil, "", ErrInvalidCalendarData
+		}
+		params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, line[colon+1:], nil
+}
+
+// parseTime returns the UTC time of a DATE or DATE-TIME value and whether the value is a DATE.
+func parseTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icalDateFormat) {
+		t, err := time.ParseInLocation(icalDateFormat, value, time.UTC)
+		if err != nil {
+			return time.Time{}, false, ErrInvalidCalendarData
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalDateTimeFormat, value)
+		if err != nil {
+			return time.Time{}, false, ErrInvalidCalendarData
+		}
+		return t, false, nil
+	}
+
+	location := time.UTC
+	if tzid, ok := params["TZID"]; ok {
+		loaded, err := time.LoadLocation(tzid)
+		if err == nil {
+			location = loaded
+		}
+	}
+
+	t, err := time.ParseInLocation(icalLocalDateTimeFormat, value, location)
+	if err != nil {
+		return time.Time{}, false, ErrInvalidCalendarData
+	}
+
+	return t.UTC(), false, nil
+}
+
+// parseDuration parses the RFC 5545 duration value like -PT15M or P1DT2H.
+func parseDuration(value string) (time.Duration, error) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign = -1
+		value = value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+
+	if !strings.HasPrefix(value, "P") || len(value) < 3 {
+		return 0, ErrInvalidDuration
+	}
+	value = value[1:]
+
+	var duration time.Duration
+	inTime := false
+	number := ""
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			number += string(r)
+			continue
+		case r == 'T':
+			if inTime || number != "" {
+				return 0, ErrInvalidDuration
+			}
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, ErrInvalidDuration
+		}
+		number = ""
+
+		unit, ok := durationUnit(r, inTime)
+		if !ok {
+			return 0, ErrInvalidDuration
+		}
+		duration += time.Duration(n) * unit
+	}
+
+	if number != "" {
+		return 0, ErrInvalidDuration
+	}
+
+	return sign * duration, nil
+}
+
+func durationUnit(r rune, inTime bool) (time.Duration, bool) {
+	switch {
+	case r == 'W' && !inTime:
+		return 7 * 24 * time.Hour, true
+	case r == 'D' && !inTime:
+		return 24 * time.Hour, true
+	case r == 'H' && inTime:
+		return time.Hour, true
+	case r == 'M' && inTime:
+		return time.Minute, true
+	case r == 'S' && inTime:
+		return time.Second, true
+	default:
+		return 0, false
+	}
+}
+
+func formatDuration(duration time.Duration) string {
+	b := strings.Builder{}
+	if duration < 0 {
+		b.WriteString("-")
+		duration = -duration
+	}
+	b.WriteString("P")
+
+	days := duration / (24 * time.Hour)
+	duration -= days * 24 * time.Hour
+	if days > 0 {
+		b.WriteString(strconv.Itoa(int(days)) + "D")
+	}
+
+	if duration == 0 {
+		if days == 0 {
+			b.WriteString("T0S")
+		}
+		return b.String()
+	}
+
+	b.WriteString("T")
+	hours := duration / time.Hour
+	duration -= hours * time.Hour
+	minutes := duration / time.Minute
+	duration -= minutes * time.Minute
+	seconds := duration / time.Second
+
+	if hours > 0 {
+		b.WriteString(strconv.Itoa(int(hours)) + "H")
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.Itoa(int(minutes)) + "M")
+	}
+	if seconds > 0 {
+		b.WriteString(strconv.Itoa(int(seconds)) + "S")
+	}
+
+	return b.String()
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+func unescapeText(value string) string {
+	b := strings.Builder{}
+	escaped := false
+	for _, r := range value {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+
+		if escaped && (r == 'n' || r == 'N') {
+			b.WriteRune('\n')
+		} else {
+			b.WriteRune(r)
+		}
+		escaped = false
+	}
+
+	return b.String()
+}
+
+// splitText splits a multi-valued TEXT property on the unescaped commas.
+func splitText(value string) []string {
+	values := []string{}
+	current := strings.Builder{}
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			values = append(values, unescapeText(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	values = append(values, unescapeText(current.String()))
+
+	return values
+}
@@ -0,0 +1,216 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	nsDAV            = "DAV:"
+	nsCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	nsCalendarServer = "http://calendarserver.org/ns/"
+)
+
+var ErrInvalidXML = errors.New("caldav: invalid xml body")
+
+// propName is a property requested by PROPFIND or REPORT.
+type propName struct {
+	Space string
+	Local string
+}
+
+var (
+	propResourceType          = propName{nsDAV, "resourcetype"}
+	propDisplayName           = propName{nsDAV, "displayname"}
+	propGetETag               = propName{nsDAV, "getetag"}
+	propGetContentType        = propName{nsDAV, "getcontenttype"}
+	propCurrentUserPrincipal  = propName{nsDAV, "current-user-principal"}
+	propPrincipalURL          = propName{nsDAV, "principal-URL"}
+	propCurrentUserPrivileges = propName{nsDAV, "current-user-privilege-set"}
+	propCalendarHomeSet       = propName{nsCalDAV, "calendar-home-set"}
+	propSupportedComponents   = propName{nsCalDAV, "supported-calendar-component-set"}
+	propCalendarData          = propName{nsCalDAV, "calendar-data"}
+	propGetCTag               = propName{nsCalendarServer, "getctag"}
+)
+
+// davRequest is the parsed body of a PROPFIND or REPORT request.
+type davRequest struct {
+	// Root is the local name of the root element: propfind, calendar-query or calendar-multiget.
+	Root     string
+	AllProp  bool
+	Props    []propName
+	Hrefs    []string
+	RangeMin string
+	RangeMax string
+}
+
+// parseDAVRequest reads the request properties, the multiget hrefs and the calendar-query time range.
+// An empty body is treated as allprop.
+func parseDAVRequest(body io.Reader) (davRequest, error) {
+	req := davRequest{}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return req, err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		req.AllProp = true
+		return req, nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	path := []string{}
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return req, ErrInvalidXML
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(path) == 0 {
+				req.Root = t.Name.Local
+			}
+
+			parent := ""
+			if len(path) > 0 {
+				parent = path[len(path)-1]
+			}
+
+			switch {
+			case parent == "prop" && len(path) == 2:
+				req.Props = append(req.Props, propName{t.Name.Space, t.Name.Local})
+			case t.Name.Local == "allprop":
+				req.AllProp = true
+			case t.Name.Local == "href" && t.Name.Space == nsDAV:
+				var href string
+				err = decoder.DecodeElement(&href, &t)
+				if err != nil {
+					return req, ErrInvalidXML
+				}
+				req.Hrefs = append(req.Hrefs, strings.TrimSpace(href))
+				continue
+			case t.Name.Local == "time-range" && t.Name.Space == nsCalDAV:
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "start":
+						req.RangeMin = attr.Value
+					case "end":
+						req.RangeMax = attr.Value
+					}
+				}
+			}
+
+			path = append(path, t.Name.Local)
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+
+	if req.Root == "" {
+		return req, ErrInvalidXML
+	}
+
+	if len(req.Props) == 0 {
+		req.AllProp = true
+	}
+
+	return req, nil
+}
+
+// davResponse collects the found and missing properties of a single resource.
+type davResponse struct {
+	Href     string
+	Status   int
+	found    []string
+	notFound []string
+}
+
+func (r *davResponse) addProp(name propName, innerXML string) {
+	r.found = append(r.found, propElement(name, innerXML))
+}
+
+func (r *davResponse) addMissingProp(name propName) {
+	r.notFound = append(r.notFound, propElement(name, ""))
+}
+
+func propElement(name propName, innerXML string) string {
+	prefix := "D"
+	switch name.Space {
+	case nsCalDAV:
+		prefix = "C"
+	case nsCalendarServer:
+		prefix = "CS"
+	case nsDAV:
+	default:
+		return "<" + name.Local + ` xmlns="` + escapeXML(name.Space) + `">` + innerXML + "</" + name.Local + ">"
+	}
+
+	return "<" + prefix + ":" + name.Local + ">" + innerXML + "</" + prefix + ":" + name.Local + ">"
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []davResponse) error {
+	b := strings.Builder{}
+	b.WriteString(xml.Header)
+	b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"` +
+		` xmlns:CS="http://calendarserver.org/ns/">`)
+
+	for i := range responses {
+		b.WriteString("<D:response><D:href>" + escapeXML(responses[i].Href) + "</D:href>")
+
+		if responses[i].Status != 0 {
+			b.WriteString("<D:status>" + statusLine(responses[i].Status) + "</D:status>")
+		}
+
+		writePropstat(&b, responses[i].found, http.StatusOK)
+		writePropstat(&b, responses[i].notFound, http.StatusNotFound)
+
+		b.WriteString("</D:response>")
+	}
+
+	b.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, err := w.Write([]byte(b.String()))
+
+	return err
+}
+
+func writePropstat(b *strings.Builder, props []string, status int) {
+	if len(props) == 0 {
+		return
+	}
+
+	b.WriteString("<D:propstat><D:prop>")
+	for _, prop := range props {
+		b.WriteString(prop)
+	}
+	b.WriteString("</D:prop><D:status>" + statusLine(status) + "</D:status></D:propstat>")
+}
+
+func statusLine(status int) string {
+	return "HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status)
+}
+
+func hrefElement(href string) string {
+	return "<D:href>" + escapeXML(href) + "</D:href>"
+}
+
+func escapeXML(value string) string {
+	b := bytes.Buffer{}
+	_ = xml.EscapeText(&b, []byte(value))
+
+	return b.String()
+}
@@ -0,0 +1,183 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+type createCalendarRequest struct {
+	Name string `json:"name"`
+}
+
+type shareCalendarRequest struct {
+	Permission string `json:"permission"`
+}
+
+// CalendarsV1Handler dispatches the /v1/calendars routes:
+//
+//	GET    /v1/calendars
+//	POST   /v1/calendars
+//	DELETE /v1/calendars/{id}
+//	GET    /v1/calendars/{id}/grants
+//	PUT    /v1/calendars/{id}/grants/{userId}
+//	DELETE /v1/calendars/{id}/grants/{userId}
+func (s *Server) CalendarsV1Handler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/calendars"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "":
+		s.CalendarsHandler(w, r)
+	case len(parts) == 1:
+		s.CalendarHandler(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "grants":
+		s.CalendarGrantsHandler(w, r, parts[0])
+	case len(parts) == 3 && parts[1] == "grants":
+		userID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			s.badRequest(w, errors.New("userId: "+err.Error()))
+			return
+		}
+		s.CalendarGrantHandler(w, r, parts[0], userID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) CalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		calendars, err := s.app.GetCalendars(r.Context())
+		if err != nil {
+			s.calendarError(w, r, err)
+			return
+		}
+
+		body, err := storage.UserCalendars(calendars).MarshalJSON()
+		if err != nil {
+			s.calendarError(w, r, err)
+			return
+		}
+
+		s.writeJSON(w, http.StatusOK, body)
+	case http.MethodPost:
+		var req createCalendarRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.badRequest(w, errors.New("invalid json: "+err.Error()))
+			return
+		}
+
+		calendar, err := s.app.CreateCalendar(r.Context(), req.Name)
+		if err != nil {
+			s.calendarError(w, r, err)
+			return
+		}
+
+		body, err := calendar.MarshalJSON()
+		if err != nil {
+			s.calendarError(w, r, err)
+			return
+		}
+
+		s.writeJSON(w, http.StatusCreated, body)
+	default:
+		s.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s *Server) CalendarHandler(w http.ResponseWriter, r *http.Request, calendarID string) {
+	if r.Method != http.MethodDelete {
+		s.methodNotAllowed(w, http.MethodDelete)
+		return
+	}
+
+	err := s.app.DeleteCalendar(r.Context(), calendarID)
+	if err != nil {
+		s.calendarError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) CalendarGrantsHandler(w http.ResponseWriter, r *http.Request, calendarID string) {
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	grants, err := s.app.GetCalendarGrants(r.Context(), calendarID)
+	if err != nil {
+		s.calendarError(w, r, err)
+		return
+	}
+
+	body, err := storage.CalendarGrants(grants).MarshalJSON()
+	if err != nil {
+		s.calendarError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) CalendarGrantHandler(w http.ResponseWriter, r *http.Request, calendarID string, userID int) {
+	switch r.Method {
+	case http.MethodPut:
+		var req shareCalendarRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.badRequest(w, errors.New("invalid json: "+err.Error()))
+			return
+		}
+
+		err = s.app.ShareCalendar(r.Context(), calendarID, userID, storage.CalendarPermission(req.Permission))
+		if err != nil {
+			s.calendarError(w, r, err)
+			return
+		}
+	case http.MethodDelete:
+		err := s.app.UnshareCalendar(r.Context(), calendarID, userID)
+		if err != nil {
+			s.calendarError(w, r, err)
+			return
+		}
+	default:
+		s.methodNotAllowed(w, http.MethodPut, http.MethodDelete)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) calendarError(w http.ResponseWriter, r *http.Request, err error) {
+	s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+
+	switch {
+	case errors.Is(err, storage.ErrCalendarAccessDenied):
+		s.forbidden(w, err)
+	case errors.Is(err, storage.ErrCalendarNotExists), errors.Is(err, storage.ErrCalendarGrantNotExists):
+		s.notFound(w, err)
+	case errors.Is(err, storage.ErrUnknownCalendarPermission),
+		errors.Is(err, storage.ErrCalendarNameEmpty),
+		errors.Is(err, storage.ErrCalendarShareOwner):
+		s.badRequest(w, err)
+	default:
+		s.internalError(w, err)
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, writeErr := w.Write(body)
+	if writeErr != nil {
+		s.logger.Error("error writing response", "error", writeErr)
+	}
+}
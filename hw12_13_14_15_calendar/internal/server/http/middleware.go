@@ -1,24 +1,183 @@
 package internalhttp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"runtime/debug"
 	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler {
+var tracer = otel.Tracer("github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/http")
+
+// unmatchedRoute is the route label of the requests which no route of the mux matched.
+const unmatchedRoute = "unmatched"
+
+// Middleware wraps the handler of the next step of the chain.
+type Middleware func(next http.Handler) http.Handler
+
+// chain wraps handler into the middlewares, the first one is the outermost.
+func chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// Timeouts are the deadlines of the contexts the requests are served with.
+type Timeouts struct {
+	// Default applies to the routes missing from Routes, zero leaves them without a deadline.
+	Default time.Duration
+	// Routes are the timeouts by the pattern of the mux the request matched.
+	Routes map[string]time.Duration
+}
+
+func (t Timeouts) route(route string) time.Duration {
+	if timeout, ok := t.Routes[route]; ok {
+		return timeout
+	}
+
+	return t.Default
+}
+
+func routeOf(mux *http.ServeMux, r *http.Request) string {
+	_, route := mux.Handler(r)
+	if route == "" {
+		return unmatchedRoute
+	}
+
+	return route
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(body []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(body)
+}
+
+// requestIDMiddleware serves the request with the ID passed by the client in the X-Request-ID header
+// or with a new one, and returns the ID in the same header of the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s := fmt.Sprintf(
-			"%s [%s] %s %s %s \"%s\"",
-			r.RemoteAddr,
-			time.Now().Format(time.RFC3339),
-			r.Method,
-			r.URL.Path,
-			r.Proto,
-			r.Header.Get("User-Agent"),
-		)
-		fmt.Println(s)
-
-		next.ServeHTTP(w, r)
+		id := requestid.FromIncoming(r.Header.Get(requestid.Header))
+		w.Header().Set(requestid.Header, id)
+
+		next.ServeHTTP(w, r.WithContext(requestid.WithRequestID(r.Context(), id)))
 	})
 }
+
+// loggingMiddleware writes the access log of the requests and reports them to the metrics by the matched
+// route, so the paths with IDs do not make new series. Every request is served in a span which continues
+// the trace of the caller passed in the traceparent header.
+func loggingMiddleware(logg Logger, mux *http.ServeMux, httpMetrics *metrics.HTTPMetrics) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			route := routeOf(mux, r)
+
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+			if recorder.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.status))
+			}
+
+			duration := time.Since(start)
+			logg.InfoContext(ctx, "http request",
+				"remote_addr", r.RemoteAddr,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", route,
+				"proto", r.Proto,
+				"status", recorder.status,
+				"duration", duration,
+				"user_agent", r.Header.Get("User-Agent"),
+			)
+			httpMetrics.Observe(route, r.Method, recorder.status, duration)
+		})
+	}
+}
+
+// recoveryMiddleware logs the panics of the handlers and responds with 500 if the handler
+// wrote nothing yet. http.ErrAbortHandler is passed on, as the server expects.
+func recoveryMiddleware(logg Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(recovered)
+				}
+
+				logg.ErrorContext(r.Context(), "handler panicked",
+					"panic", fmt.Sprint(recovered),
+					"stack", string(debug.Stack()),
+				)
+				if !recorder.wroteHeader {
+					status := http.StatusInternalServerError
+					http.Error(recorder, http.StatusText(status), status)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// timeoutMiddleware sets the deadline of the route to the context of the request.
+func timeoutMiddleware(mux *http.ServeMux, timeouts Timeouts) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := timeouts.route(routeOf(mux, r))
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
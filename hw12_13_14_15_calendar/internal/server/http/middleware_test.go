@@ -0,0 +1,330 @@
+package internalhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/requestid"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoggingMiddlewareMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/events/", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/isready", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	var output bytes.Buffer
+	logg, err := logger.New("INFO", &output)
+	require.NoError(t, err)
+
+	handler := loggingMiddleware(logg, mux, metrics.NewHTTPMetrics(registry))(mux)
+	for _, path := range []string{"/v1/events/1", "/v1/events/2", "/isready", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080"+path, nil))
+	}
+
+	require.Contains(t, output.String(), `msg="http request"`)
+	require.Contains(t, output.String(), "method=GET path=/v1/events/2 route=/v1/events/ proto=HTTP/1.1 status=404")
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP calendar_http_requests_total HTTP requests by route, method and status code.
+# TYPE calendar_http_requests_total counter
+calendar_http_requests_total{code="200",method="GET",route="/isready"} 1
+calendar_http_requests_total{code="404",method="GET",route="/v1/events/"} 2
+calendar_http_requests_total{code="404",method="GET",route="unmatched"} 1
+`), "calendar_http_requests_total")
+	require.NoError(t, err)
+	require.Equal(t, 3, testutil.CollectAndCount(registry, "calendar_http_request_duration_seconds"))
+}
+
+func TestLoggingMiddlewareTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var handlerSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/events/", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	r := httptest.NewRequest("GET", "http://localhost:8080/v1/events/1", nil)
+	otel.GetTextMapPropagator().Inject(
+		trace.ContextWithSpanContext(context.Background(), parent),
+		propagation.HeaderCarrier(r.Header),
+	)
+
+	logg, err := logger.New("ERROR", io.Discard)
+	require.NoError(t, err)
+
+	loggingMiddleware(logg, mux, nil)(mux).ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET /v1/events/", spans[0].Name())
+	require.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	require.Equal(t, parent.TraceID(), spans[0].SpanContext().TraceID())
+	require.Equal(t, parent.SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, spans[0].SpanContext().SpanID(), handlerSpan.SpanID())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var got string
+	handler := requestIDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = requestid.RequestID(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "http://localhost:8080/isready", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, "req-1", got)
+	require.Equal(t, "req-1", w.Header().Get("X-Request-ID"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/isready", nil))
+	require.NotEmpty(t, got)
+	require.NotEqual(t, "req-1", got)
+	require.Equal(t, got, w.Header().Get("X-Request-ID"))
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	logg, err := logger.New("ERROR", &output)
+	require.NoError(t, err)
+
+	handler := recoveryMiddleware(logg)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/isready", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Contains(t, output.String(), `msg="handler panicked" panic=boom`)
+
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		recoveryMiddleware(logg)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	})
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	deadlines := map[string]time.Duration{}
+	mux := http.NewServeMux()
+	record := func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		if ok {
+			deadlines[r.URL.Path] = time.Until(deadline)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc("/v1/events/", record)
+	mux.HandleFunc("/isready", record)
+
+	handler := timeoutMiddleware(mux, Timeouts{
+		Default: time.Second,
+		Routes:  map[string]time.Duration{"/v1/events/": time.Minute, "/isready": 0},
+	})(mux)
+	for _, path := range []string{"/v1/events/1", "/isready"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080"+path, nil))
+	}
+
+	require.Len(t, deadlines, 1)
+	require.InDelta(t, time.Minute, deadlines["/v1/events/1"], float64(time.Second))
+}
+
+func TestServerMiddlewareChain(t *testing.T) {
+	var output bytes.Buffer
+	logg, err := logger.New("INFO", &output)
+	require.NoError(t, err)
+
+	server := NewServer(logg, app.New(logg, memorystorage.New()), "localhost", "8080", 30*time.Second)
+	server.SetTimeouts(Timeouts{Default: time.Second})
+	server.AddRoute("/panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	var order []string
+	for _, name := range []string{"first", "second"} {
+		name := name
+		server.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, hasDeadline := r.Context().Deadline()
+				require.True(t, hasDeadline)
+				require.NotEmpty(t, requestid.RequestID(r.Context()))
+
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/panic", nil))
+
+	require.Equal(t, []string{"first", "second"}, order)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	requestID := w.Header().Get("X-Request-ID")
+	require.NotEmpty(t, requestID)
+	require.Contains(t, output.String(), "route=/panic proto=HTTP/1.1 status=500")
+	require.Contains(t, output.String(), "request_id="+requestID)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.New(map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassRead:  {Rate: 1, Burst: 2},
+		ratelimit.ClassWrite: {Rate: 0.5, Burst: 1},
+	})
+	handler := RateLimitMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method, path, remoteAddr string, userID int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://localhost:8080"+path, nil)
+		r.RemoteAddr = remoteAddr
+		if userID != 0 {
+			r = r.WithContext(identity.WithUserID(r.Context(), userID))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, serve("POST", "/event/create", "10.0.0.1:1000", 1).Code)
+	w := serve("POST", "/event/create", "10.0.0.1:1000", 1)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// The reads have their own bucket and the other users are not affected.
+	require.Equal(t, http.StatusOK, serve("GET", "/event/get", "10.0.0.1:1000", 1).Code)
+	require.Equal(t, http.StatusOK, serve("POST", "/v1/events/", "10.0.0.1:1000", 2).Code)
+
+	// The calls without a user are limited by the client address.
+	require.Equal(t, http.StatusOK, serve("DELETE", "/v1/tags/work", "10.0.0.2:1000", 0).Code)
+	require.Equal(t, http.StatusTooManyRequests, serve("PUT", "/v1/tags/work", "10.0.0.2:2000", 0).Code)
+	require.Equal(t, http.StatusOK, serve("PUT", "/v1/tags/work", "10.0.0.3:1000", 0).Code)
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, serve("GET", "/isready", "10.0.0.2:1000", 0).Code)
+	}
+}
+
+func TestRateLimitBatch(t *testing.T) {
+	logg, err := logger.New("ERROR", &bytes.Buffer{})
+	require.NoError(t, err)
+
+	server := NewServer(logg, app.New(logg, memorystorage.New()), "localhost", "8080", 30*time.Second)
+	limiter := ratelimit.New(map[ratelimit.Class]ratelimit.Limit{ratelimit.ClassWrite: {Rate: 1, Burst: 3}})
+	handler := RateLimitMiddleware(limiter)(http.HandlerFunc(server.EventsV1Handler))
+
+	batch := func(size int) *httptest.ResponseRecorder {
+		operations := make([]string, 0, size)
+		for i := 0; i < size; i++ {
+			operations = append(operations, `{"op":"create","title":"Test",`+
+				`"start_dt":"2024-06-14","end_dt":"2024-06-19","notify_before":"48h"}`)
+		}
+		body := `{"operations":[` + strings.Join(operations, ",") + `]}`
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost:8080/v1/events/batch", strings.NewReader(body)))
+		return w
+	}
+
+	// Each operation of the batch takes a write token.
+	require.Equal(t, http.StatusOK, batch(2).Code)
+	w := batch(2)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+type tokenAuthenticator map[string]int
+
+func (a tokenAuthenticator) Authenticate(_ context.Context, token string) (int, error) {
+	userID, ok := a[token]
+	if !ok {
+		return 0, errors.New("invalid token")
+	}
+
+	return userID, nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	logg, err := logger.New("ERROR", io.Discard)
+	require.NoError(t, err)
+
+	var userID int
+	handler := AuthMiddleware(tokenAuthenticator{"token-1": 1}, logg, []string{"/isready", "/.well-known/"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID = identity.UserID(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	serve := func(path string, setCredentials func(r *http.Request)) *httptest.ResponseRecorder {
+		userID = -1
+		r := httptest.NewRequest("GET", "http://localhost:8080"+path, nil)
+		setCredentials(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("/v1/events/", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token-1") })
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, userID)
+
+	w = serve("/caldav/", func(r *http.Request) { r.SetBasicAuth("user", "token-1") })
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, userID)
+
+	for _, setCredentials := range []func(r *http.Request){
+		func(*http.Request) {},
+		func(r *http.Request) { r.Header.Set("Authorization", "Bearer token-2") },
+		func(r *http.Request) { r.Header.Set("Authorization", "Token token-1") },
+	} {
+		w = serve("/v1/events/", setCredentials)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Equal(t, -1, userID)
+		require.Contains(t, w.Header().Values("WWW-Authenticate"), `Bearer realm="calendar"`)
+	}
+
+	for _, path := range []string{"/isready", "/.well-known/caldav"} {
+		w = serve(path, func(*http.Request) {})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, 0, userID)
+	}
+}
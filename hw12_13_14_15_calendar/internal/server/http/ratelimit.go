@@ -0,0 +1,76 @@
+package internalhttp
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/ratelimit"
+)
+
+// RateLimitMiddleware rejects the requests of the users, or of the clients without a user,
+// which ran out of the limit of the route class with 429 and the Retry-After header.
+// The health probes are never limited. It relies on the user being set by the
+// middlewares before it. The batch handler charges the operations of the batch beyond the
+// first one through the request context.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isProbe(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := ratelimit.Key(r.Context(), clientIP(r))
+			class := routeClass(r)
+			allowed, retryAfter := limiter.Allow(class, key)
+			if !allowed {
+				tooManyRequests(w, retryAfter)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(limiter.WithCall(r.Context(), class, key)))
+		})
+	}
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	status := http.StatusTooManyRequests
+	http.Error(w, http.StatusText(status), status)
+}
+
+func isProbe(path string) bool {
+	return path == "/livez" || path == "/readyz" || path == "/isready"
+}
+
+// routeClass tells the modifying requests from the reading ones. The legacy /event/ routes
+// are told by the path, as they do not follow the methods, the other ones by the method.
+func routeClass(r *http.Request) ratelimit.Class {
+	switch r.URL.Path {
+	case "/event/create", "/event/update", "/event/delete":
+		return ratelimit.ClassWrite
+	}
+	if strings.HasPrefix(r.URL.Path, "/event/") {
+		return ratelimit.ClassRead
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "REPORT":
+		return ratelimit.ClassRead
+	default:
+		return ratelimit.ClassWrite
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -2,61 +2,106 @@ package internalhttp
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/health"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/server/http/caldav"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
 )
 
+// calDAVPrefix is the path the CalDAV clients are served under.
+const calDAVPrefix = "/caldav"
+
 var (
 	ErrServerStarted    = errors.New("server already started")
 	ErrServerNotStarted = errors.New("server not started")
 )
 
 type Server struct {
-	host    string
-	port    string
-	timeout time.Duration
-	server  *http.Server
-	mux     *http.ServeMux
-	app     Application
-	logger  Logger
+	host        string
+	port        string
+	timeout     time.Duration
+	timeouts    Timeouts
+	tlsConfig   *tls.Config
+	server      *http.Server
+	mux         *http.ServeMux
+	middlewares []Middleware
+	app         Application
+	logger      Logger
+	metrics     *metrics.HTTPMetrics
+	health      *health.Checker
 }
 
 type Logger interface {
-	Debug(msg string, params ...any)
-	Info(msg string)
-	Error(msg string)
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
 }
 
 type Application interface {
 	CreateEvent(
 		ctx context.Context,
-		id, title string,
+		id, calendarID, title, description, location, url string,
 		startDate time.Time,
 		endDate time.Time,
 		notifyBefore time.Duration,
-	) error
+		tags []string,
+	) (string, error)
 
 	UpdateEvent(
 		ctx context.Context,
 		eventID string,
+		calendarID string,
 		title string,
+		description string,
+		location string,
+		url string,
 		startDate time.Time,
 		endDate time.Time,
 		notifyBefore time.Duration,
+		tags []string,
 	) error
 	DeleteEvent(ctx context.Context, eventID string) error
 	GetEvent(ctx context.Context, id string) (storage.Event, error)
-	GetEventsListByDates(ctx context.Context, from *time.Time, to *time.Time) []storage.Event
-	GetEventsForNotify(ctx context.Context, notifyDate string) []storage.Event
-	GetEventsOnDate(ctx context.Context, date time.Time) []storage.Event
-	GetEventsOnWeek(ctx context.Context, weekStartDate time.Time) []storage.Event
-	GetEventsOnMonth(ctx context.Context, monthStartDate time.Time) []storage.Event
+	GetEventsListByDates(
+		ctx context.Context,
+		from *time.Time,
+		to *time.Time,
+		filter storage.EventFilter,
+	) ([]storage.Event, error)
+	GetEventsForNotify(ctx context.Context, notifyDate string) ([]storage.Event, error)
+	GetEventsOnDate(ctx context.Context, date time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventsOnWeek(ctx context.Context, weekStartDate time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventsOnMonth(ctx context.Context, monthStartDate time.Time, filter storage.EventFilter) ([]storage.Event, error)
+	GetEventHistory(ctx context.Context, id string) ([]storage.EventHistoryRecord, error)
+	ApplyBatch(
+		ctx context.Context,
+		mode storage.BatchMode,
+		operations []storage.BatchOperation,
+	) ([]storage.BatchResult, error)
+	CreateCalendar(ctx context.Context, name string) (storage.Calendar, error)
+	GetCalendars(ctx context.Context) ([]storage.UserCalendar, error)
+	DeleteCalendar(ctx context.Context, calendarID string) error
+	ShareCalendar(ctx context.Context, calendarID string, userID int, permission storage.CalendarPermission) error
+	UnshareCalendar(ctx context.Context, calendarID string, userID int) error
+	GetCalendarGrants(ctx context.Context, calendarID string) ([]storage.CalendarGrant, error)
+	SaveTag(ctx context.Context, name, color string) (storage.Tag, error)
+	DeleteTag(ctx context.Context, name string) error
+	GetTags(ctx context.Context) ([]storage.Tag, error)
 }
 
 func NewServer(logg Logger, app Application, host string, port string, timeout time.Duration) *Server {
@@ -67,9 +112,12 @@ func NewServer(logg Logger, app Application, host string, port string, timeout t
 		mux:     http.NewServeMux(),
 		app:     app,
 		logger:  logg,
+		health:  health.New(logg),
 	}
 
-	server.AddRoute("/isready", server.IsReady)
+	server.AddRoute("/livez", server.Livez)
+	server.AddRoute("/readyz", server.Readyz)
+	server.AddRoute("/isready", server.Readyz)
 	server.AddRoute("/event/create", server.CreateEventHandler)
 	server.AddRoute("/event/update", server.UpdateEventHandler)
 	server.AddRoute("/event/delete", server.DeleteEventHandler)
@@ -79,10 +127,57 @@ func NewServer(logg Logger, app Application, host string, port string, timeout t
 	server.AddRoute("/event/listOnDate", server.GetListOnDateHandler)
 	server.AddRoute("/event/listOnWeek", server.GetListOnWeekHandler)
 	server.AddRoute("/event/listOnMonth", server.GetListOnMonthHandler)
+	server.AddRoute("/v1/events/", server.EventsV1Handler)
+	server.AddRoute("/v1/calendars", server.CalendarsV1Handler)
+	server.AddRoute("/v1/calendars/", server.CalendarsV1Handler)
+	server.AddRoute("/v1/tags", server.TagsV1Handler)
+	server.AddRoute("/v1/tags/", server.TagsV1Handler)
+
+	calDAV := caldav.NewHandler(logg, app, calDAVPrefix)
+	server.AddRoute(calDAVPrefix+"/", calDAV.ServeHTTP)
+	server.AddRoute("/.well-known/caldav", calDAV.WellKnown)
 
 	return server
 }
 
+// SetMetrics sets the metrics of the requests, it must be called before Start.
+func (s *Server) SetMetrics(httpMetrics *metrics.HTTPMetrics) {
+	s.metrics = httpMetrics
+}
+
+// SetHealth sets the checks of the readiness probe, it must be called before Start.
+// Without them the server is ready as soon as it listens.
+func (s *Server) SetHealth(checker *health.Checker) {
+	s.health = checker
+}
+
+// SetTimeouts sets the deadlines of the request contexts, it must be called before Start.
+func (s *Server) SetTimeouts(timeouts Timeouts) {
+	s.timeouts = timeouts
+}
+
+// SetTLS serves the requests over TLS with the config, it must be called before Start.
+func (s *Server) SetTLS(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// Use appends the middlewares to the chain, they run after the request ID, the access log,
+// the panic recovery and the timeout, in the order they are passed. It must be called before Start.
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+func (s *Server) handler() http.Handler {
+	middlewares := []Middleware{
+		requestIDMiddleware,
+		loggingMiddleware(s.logger, s.mux, s.metrics),
+		recoveryMiddleware(s.logger),
+		timeoutMiddleware(s.mux, s.timeouts),
+	}
+
+	return chain(s.mux, append(middlewares, s.middlewares...)...)
+}
+
 func (s *Server) Start(_ context.Context) error {
 	if s.server != nil {
 		return ErrServerStarted
@@ -92,100 +187,136 @@ func (s *Server) Start(_ context.Context) error {
 		Addr:              address,
 		ReadHeaderTimeout: s.timeout,
 		ReadTimeout:       s.timeout,
-		Handler:           loggingMiddleware(s.mux),
+		Handler:           s.handler(),
+		TLSConfig:         s.tlsConfig,
 	}
 
-	err := s.server.ListenAndServe()
-	if err != nil {
+	var err error
+	if s.tlsConfig != nil {
+		// The certificate is taken from the config, so the files are not passed.
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("listen and serve: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Server) Stop(_ context.Context) error {
+// Stop stops accepting the connections and waits for the in-flight requests to finish.
+// The connections still busy when ctx is done are closed.
+func (s *Server) Stop(ctx context.Context) error {
 	if s.server == nil {
 		return ErrServerNotStarted
 	}
 
-	return s.server.Close()
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		return errors.Join(err, s.server.Close())
+	}
+
+	return nil
 }
 
 func (s *Server) AddRoute(route string, handlerFunc http.HandlerFunc) {
 	s.mux.HandleFunc(route, handlerFunc)
 }
 
-func (s *Server) IsReady(w http.ResponseWriter, _ *http.Request) {
-	_, err := w.Write([]byte("ok"))
-	if err != nil {
-		s.logger.Error(err.Error())
-		s.internalError(w, err)
-		return
-	}
+// Livez is the liveness probe, it does not check the dependencies.
+func (s *Server) Livez(w http.ResponseWriter, r *http.Request) {
+	s.health.ServeLiveness(w, r)
+}
+
+// Readyz is the readiness probe, it fails with 503 while a check set by SetHealth fails.
+// /isready is served by it too for the deployments which still probe the old route.
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	s.health.ServeReadiness(w, r)
 }
 
 func (s *Server) CreateEventHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.FormValue("id")
+	calendarID := r.FormValue("calendar_id")
 	title := r.FormValue("title")
+	description := r.FormValue("description")
+	location := r.FormValue("location")
+	eventURL := r.FormValue("url")
 
 	startDt, err := time.Parse(time.DateOnly, r.FormValue("start_dt"))
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.WarnContext(r.Context(), "invalid request", "error", err)
 		s.badRequest(w, errors.New("start_dt: "+err.Error()))
 		return
 	}
 
 	endDt, err := time.Parse(time.DateOnly, r.FormValue("end_dt"))
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.WarnContext(r.Context(), "invalid request", "error", err)
 		s.badRequest(w, errors.New("end_dt: "+err.Error()))
 		return
 	}
 
 	notifyBefore, err := time.ParseDuration(r.FormValue("notify_before"))
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.WarnContext(r.Context(), "invalid request", "error", err)
 		s.badRequest(w, errors.New("notify_before: "+err.Error()))
 		return
 	}
 
-	err = s.app.CreateEvent(
-		r.Context(),
+	ctx := r.Context()
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		ctx = app.WithIdempotencyKey(ctx, key)
+	}
+
+	eventID, err := s.app.CreateEvent(
+		ctx,
 		id,
+		calendarID,
 		title,
+		description,
+		location,
+		eventURL,
 		startDt,
 		endDt,
 		notifyBefore,
+		r.Form["tag"],
 	)
 
 	if err != nil {
-		s.logger.Error(err.Error())
-		s.badRequest(w, err)
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		s.appError(w, err)
+		return
 	}
 
+	w.Header().Set("Location", "/event/get?id="+url.QueryEscape(eventID))
 	w.WriteHeader(http.StatusCreated)
 }
 
 func (s *Server) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.PostFormValue("id")
+	calendarID := r.PostFormValue("calendar_id")
 	title := r.PostFormValue("title")
+	description := r.PostFormValue("description")
+	location := r.PostFormValue("location")
+	eventURL := r.PostFormValue("url")
 
 	startDt, err := time.Parse(time.DateOnly, r.PostFormValue("start_dt"))
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.WarnContext(r.Context(), "invalid request", "error", err)
 		s.badRequest(w, errors.New("start_dt: "+err.Error()))
 	}
 
 	endDt, err := time.Parse(time.DateOnly, r.PostFormValue("end_dt"))
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.WarnContext(r.Context(), "invalid request", "error", err)
 		s.badRequest(w, errors.New("end_dt: "+err.Error()))
 		return
 	}
 
 	notifyBefore, err := time.ParseDuration(r.PostFormValue("notify_before"))
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.WarnContext(r.Context(), "invalid request", "error", err)
 		s.badRequest(w, errors.New("notify_before: "+err.Error()))
 		return
 	}
@@ -193,15 +324,20 @@ func (s *Server) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 	err = s.app.UpdateEvent(
 		r.Context(),
 		id,
+		calendarID,
 		title,
+		description,
+		location,
+		eventURL,
 		startDt,
 		endDt,
 		notifyBefore,
+		r.PostForm["tag"],
 	)
 
 	if err != nil {
-		s.logger.Error(err.Error())
-		s.badRequest(w, err)
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		s.appError(w, err)
 	}
 }
 
@@ -210,8 +346,8 @@ func (s *Server) DeleteEventHandler(w http.ResponseWriter, r *http.Request) {
 
 	err := s.app.DeleteEvent(r.Context(), id)
 	if err != nil {
-		s.logger.Error(err.Error())
-		s.badRequest(w, err)
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		s.appError(w, err)
 	}
 }
 
@@ -228,19 +364,20 @@ func (s *Server) GetEventHandler(w http.ResponseWriter, r *http.Request) {
 		id,
 	)
 	if err != nil {
-		s.logger.Error(err.Error())
-		s.internalError(w, err)
+		s.readError(w, r, err)
+		return
 	}
 
 	json, err := event.MarshalJSON()
 	if err != nil {
-		s.logger.Error(err.Error())
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
 		s.internalError(w, err)
+		return
 	}
 
 	_, writeErr := w.Write(json)
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
 	}
 }
 
@@ -265,11 +402,16 @@ func (s *Server) GetListByDatesHandler(w http.ResponseWriter, r *http.Request) {
 		s.badRequest(w, errors.New("to invalid format"))
 	}
 
-	events := s.app.GetEventsListByDates(
+	events, err := s.app.GetEventsListByDates(
 		r.Context(),
 		&fromDt,
 		&toDt,
+		buildEventFilter(r),
 	)
+	if err != nil {
+		s.readError(w, r, err)
+		return
+	}
 
 	b := strings.Builder{}
 	_, err = b.WriteString("[")
@@ -296,7 +438,7 @@ func (s *Server) GetListByDatesHandler(w http.ResponseWriter, r *http.Request) {
 
 	_, writeErr := w.Write([]byte(b.String()))
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
 	}
 }
 
@@ -306,10 +448,14 @@ func (s *Server) GetListByNotifyDateHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	events := s.app.GetEventsForNotify(r.Context(), r.URL.Query().Get("notify_date"))
+	events, err := s.app.GetEventsForNotify(r.Context(), r.URL.Query().Get("notify_date"))
+	if err != nil {
+		s.readError(w, r, err)
+		return
+	}
 
 	b := strings.Builder{}
-	_, err := b.WriteString("[")
+	_, err = b.WriteString("[")
 	if err != nil {
 		s.internalError(w, err)
 	}
@@ -333,7 +479,7 @@ func (s *Server) GetListByNotifyDateHandler(w http.ResponseWriter, r *http.Reque
 
 	_, writeErr := w.Write([]byte(b.String()))
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
 	}
 }
 
@@ -348,7 +494,11 @@ func (s *Server) GetListOnDateHandler(w http.ResponseWriter, r *http.Request) {
 		s.badRequest(w, errors.New("date invalid format"))
 	}
 
-	events := s.app.GetEventsOnDate(r.Context(), date)
+	events, err := s.app.GetEventsOnDate(r.Context(), date, buildEventFilter(r))
+	if err != nil {
+		s.readError(w, r, err)
+		return
+	}
 
 	jsonStr, err := buildEventsJSON(events)
 	if err != nil {
@@ -358,7 +508,7 @@ func (s *Server) GetListOnDateHandler(w http.ResponseWriter, r *http.Request) {
 
 	_, writeErr := w.Write([]byte(jsonStr))
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
 	}
 }
 
@@ -373,7 +523,11 @@ func (s *Server) GetListOnWeekHandler(w http.ResponseWriter, r *http.Request) {
 		s.badRequest(w, errors.New("weekStartDate invalid format"))
 	}
 
-	events := s.app.GetEventsOnWeek(r.Context(), weekStartDate)
+	events, err := s.app.GetEventsOnWeek(r.Context(), weekStartDate, buildEventFilter(r))
+	if err != nil {
+		s.readError(w, r, err)
+		return
+	}
 
 	jsonStr, err := buildEventsJSON(events)
 	if err != nil {
@@ -383,7 +537,7 @@ func (s *Server) GetListOnWeekHandler(w http.ResponseWriter, r *http.Request) {
 
 	_, writeErr := w.Write([]byte(jsonStr))
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
 	}
 }
 
@@ -398,7 +552,11 @@ func (s *Server) GetListOnMonthHandler(w http.ResponseWriter, r *http.Request) {
 		s.badRequest(w, errors.New("monthStartDate invalid format"))
 	}
 
-	events := s.app.GetEventsOnMonth(r.Context(), monthStartDate)
+	events, err := s.app.GetEventsOnMonth(r.Context(), monthStartDate, buildEventFilter(r))
+	if err != nil {
+		s.readError(w, r, err)
+		return
+	}
 
 	jsonStr, err := buildEventsJSON(events)
 	if err != nil {
@@ -408,7 +566,55 @@ func (s *Server) GetListOnMonthHandler(w http.ResponseWriter, r *http.Request) {
 
 	_, writeErr := w.Write([]byte(jsonStr))
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
+	}
+}
+
+func (s *Server) EventsV1Handler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/events/"), "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "batch":
+		s.BatchEventsHandler(w, r)
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "history":
+		s.GetEventHistoryHandler(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) GetEventHistoryHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	history, err := s.app.GetEventHistory(r.Context(), id)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		s.appError(w, err)
+		return
+	}
+
+	json, err := storage.EventHistory(history).MarshalJSON()
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+		s.internalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, writeErr := w.Write(json)
+	if writeErr != nil {
+		s.logger.ErrorContext(r.Context(), "error writing response", "error", writeErr)
+	}
+}
+
+// buildEventFilter reads the repeated calendar_id and tag query parameters.
+func buildEventFilter(r *http.Request) storage.EventFilter {
+	return storage.EventFilter{
+		CalendarIDs: r.URL.Query()["calendar_id"],
+		Tags:        r.URL.Query()["tag"],
 	}
 }
 
@@ -443,7 +649,126 @@ func (s *Server) internalError(w http.ResponseWriter, err error) {
 	w.WriteHeader(http.StatusInternalServerError)
 	_, writeErr := w.Write([]byte(err.Error()))
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.Error("error writing response", "error", writeErr)
+	}
+}
+
+func (s *Server) serviceUnavailable(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, writeErr := w.Write([]byte(err.Error()))
+	if writeErr != nil {
+		s.logger.Error("error writing response", "error", writeErr)
+	}
+}
+
+type validationErrorResponse struct {
+	Error  string               `json:"error"`
+	Fields []fieldErrorResponse `json:"fields"`
+}
+
+type fieldErrorResponse struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (s *Server) unprocessableEntity(w http.ResponseWriter, err *app.ValidationError) {
+	resp := validationErrorResponse{
+		Error:  app.ErrValidation.Error(),
+		Fields: make([]fieldErrorResponse, 0, len(err.Fields)),
+	}
+	for _, field := range err.Fields {
+		resp.Fields = append(resp.Fields, fieldErrorResponse{Field: field.Field, Message: field.Message})
+	}
+
+	body, marshalErr := json.Marshal(resp)
+	if marshalErr != nil {
+		s.internalError(w, marshalErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_, writeErr := w.Write(body)
+	if writeErr != nil {
+		s.logger.Error("error writing response", "error", writeErr)
+	}
+}
+
+func (s *Server) methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// appError writes the response for an error of a modifying application call.
+func (s *Server) appError(w http.ResponseWriter, err error) {
+	var validationErr *app.ValidationError
+	if errors.As(err, &validationErr) {
+		s.unprocessableEntity(w, validationErr)
+		return
+	}
+
+	switch {
+	case errors.Is(err, storage.ErrCalendarAccessDenied), errors.Is(err, app.ErrEventQuotaExceeded):
+		s.forbidden(w, err)
+	case errors.Is(err, app.ErrIdempotencyKeyReused):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, writeErr := w.Write([]byte(err.Error()))
+		if writeErr != nil {
+			s.logger.Error("error writing response", "error", writeErr)
+		}
+	case errors.Is(err, storage.ErrReadEventNotExists),
+		errors.Is(err, storage.ErrCreateEventIDExists),
+		errors.Is(err, storage.ErrUpdateEventIDNotExists),
+		errors.Is(err, storage.ErrCalendarNotExists),
+		errors.Is(err, storage.ErrTagNotExists),
+		errors.Is(err, storage.ErrBatchUnknownMode),
+		errors.Is(err, storage.ErrBatchUnknownOperation),
+		errors.Is(err, app.ErrBatchEmpty),
+		errors.Is(err, app.ErrBatchTooLarge),
+		errors.Is(err, app.ErrIdempotencyKeyTooLong):
+		s.badRequest(w, err)
+	case errors.Is(err, storage.ErrStorageUnavailable):
+		s.serviceUnavailable(w, err)
+	default:
+		s.internalError(w, err)
+	}
+}
+
+// readError answers the failed reads of the storage: 404 for a missing event, 403 for a calendar
+// of another user, 503 when the storage is unavailable and 500 for any other failure.
+func (s *Server) readError(w http.ResponseWriter, r *http.Request, err error) {
+	s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		s.notFound(w, err)
+		return
+	}
+
+	if errors.Is(err, storage.ErrCalendarAccessDenied) {
+		s.forbidden(w, err)
+		return
+	}
+
+	if errors.Is(err, storage.ErrStorageUnavailable) {
+		s.serviceUnavailable(w, err)
+		return
+	}
+
+	s.internalError(w, err)
+}
+
+func (s *Server) notFound(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusNotFound)
+	_, writeErr := w.Write([]byte(err.Error()))
+	if writeErr != nil {
+		s.logger.Error("error writing response", "error", writeErr)
+	}
+}
+
+func (s *Server) forbidden(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusForbidden)
+	_, writeErr := w.Write([]byte(err.Error()))
+	if writeErr != nil {
+		s.logger.Error("error writing response", "error", writeErr)
 	}
 }
 
@@ -451,6 +776,14 @@ func (s *Server) badRequest(w http.ResponseWriter, err error) {
 	w.WriteHeader(http.StatusBadRequest)
 	_, writeErr := w.Write([]byte(err.Error()))
 	if writeErr != nil {
-		s.logger.Error(writeErr.Error())
+		s.logger.Error("error writing response", "error", writeErr)
+	}
+}
+
+func (s *Server) requestEntityTooLarge(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_, writeErr := w.Write([]byte(err.Error()))
+	if writeErr != nil {
+		s.logger.Error("error writing response", "error", writeErr)
 	}
 }
@@ -3,6 +3,9 @@ package internalhttp
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +16,8 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/health"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/logger"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
 	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
@@ -46,7 +51,7 @@ func TestCreateHandler(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 
 	data := url.Values{}
-	data.Set("id", "111")
+	data.Set("id", "5d0b7c3e-2f4a-4b6c-9d8e-1a2b3c4d5e6f")
 	data.Set("title", "Test")
 	data.Set("start_dt", "2025-06-01")
 	data.Set("end_dt", "2025-07-01")
@@ -66,8 +71,180 @@ func TestCreateHandler(t *testing.T) {
 	resp.Body.Close()
 
 	require.Equal(t, http.StatusCreated, resp.StatusCode)
-	_, err = storage.GetEvent(context.Background(), "111")
+	_, err = storage.GetEvent(context.Background(), "5d0b7c3e-2f4a-4b6c-9d8e-1a2b3c4d5e6f")
+	require.Nil(t, err)
+}
+
+func TestCreateHandlerIdempotency(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memorystorage.New()
+	server := NewServer(logger, app.New(logger, store), "localhost", "8080", 30*time.Second)
+
+	data := url.Values{}
+	data.Set("title", "Test")
+	data.Set("start_dt", "2025-06-01")
+	data.Set("end_dt", "2025-07-01")
+	data.Set("notify_before", "48h")
+
+	create := func(data url.Values) *http.Response {
+		r := httptest.NewRequest(
+			"POST",
+			"http://localhost:8080/event/create",
+			strings.NewReader(data.Encode()),
+		)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Idempotency-Key", "create-1")
+
+		w := httptest.NewRecorder()
+		server.CreateEventHandler(w, r)
+
+		resp := w.Result()
+		resp.Body.Close()
+
+		return resp
+	}
+
+	resp := create(data)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	location := resp.Header.Get("Location")
+	require.True(t, strings.HasPrefix(location, "/event/get?id="))
+
+	eventID := strings.TrimPrefix(location, "/event/get?id=")
+	_, err = store.GetEvent(context.Background(), eventID)
+	require.Nil(t, err)
+
+	resp = create(data)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, location, resp.Header.Get("Location"))
+
+	events, err := store.GetEventsListByDates(context.Background(), nil, nil, storage.EventFilter{})
 	require.Nil(t, err)
+	require.Len(t, events, 1)
+
+	data.Set("title", "Another")
+	resp = create(data)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestEventDetailsHandlers(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(logger, app.New(logger, memorystorage.New()), "localhost", "8080", 30*time.Second)
+
+	send := func(handler http.HandlerFunc, data url.Values) *http.Response {
+		r := httptest.NewRequest("POST", "http://localhost:8080/event", strings.NewReader(data.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		resp := w.Result()
+		resp.Body.Close()
+
+		return resp
+	}
+
+	get := func(eventID string) storage.Event {
+		r := httptest.NewRequest("GET", "http://localhost:8080/event/get?id="+eventID, nil)
+		w := httptest.NewRecorder()
+		server.GetEventHandler(w, r)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var event storage.Event
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&event))
+
+		return event
+	}
+
+	data := url.Values{}
+	data.Set("id", "3c9a4d1e-7b2f-4e6a-8d5c-0f1e2d3c4b5a")
+	data.Set("title", "Test")
+	data.Set("description", "Quarterly planning")
+	data.Set("location", "Room 1")
+	data.Set("url", "https://meet.example.com/planning")
+	data.Set("start_dt", "2025-06-01")
+	data.Set("end_dt", "2025-07-01")
+	data.Set("notify_before", "48h")
+
+	resp := send(server.CreateEventHandler, data)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	created := get(data.Get("id"))
+	require.Equal(t, "Quarterly planning", created.Description)
+	require.Equal(t, "Room 1", created.Location)
+	require.Equal(t, "https://meet.example.com/planning", created.URL)
+	require.False(t, created.Notified)
+	require.False(t, created.CreatedAt.IsZero())
+	require.Equal(t, created.CreatedAt, created.UpdatedAt)
+
+	data.Set("location", "Room 2")
+	data.Del("url")
+	resp = send(server.UpdateEventHandler, data)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	updated := get(data.Get("id"))
+	require.Equal(t, "Room 2", updated.Location)
+	require.Empty(t, updated.URL)
+	require.Equal(t, created.CreatedAt, updated.CreatedAt)
+	require.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+
+	data.Set("url", "meet.example.com/planning")
+	resp = send(server.UpdateEventHandler, data)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestCreateHandlerValidation(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(logger, app.New(logger, memorystorage.New()), "localhost", "8080", 30*time.Second)
+
+	data := url.Values{}
+	data.Set("id", "111")
+	data.Set("title", " ")
+	data.Set("start_dt", "2025-07-01")
+	data.Set("end_dt", "2025-06-01")
+	data.Set("notify_before", "-1h")
+
+	r := httptest.NewRequest(
+		"POST",
+		"http://localhost:8080/event/create",
+		strings.NewReader(data.Encode()),
+	)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	server.CreateEventHandler(w, r)
+
+	resp := w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(
+		t,
+		`{"error":"validation failed","fields":[`+
+			`{"field":"id","message":"must be a uuid"},`+
+			`{"field":"title","message":"must not be empty"},`+
+			`{"field":"end_dt","message":"must be after start_dt"},`+
+			`{"field":"notify_before","message":"must not be negative"}]}`,
+		w.Body.String(),
+	)
 }
 
 func TestUpdateHandler(t *testing.T) {
@@ -111,7 +288,7 @@ func TestUpdateHandler(t *testing.T) {
 	}
 
 	store.CreateEvent(context.Background(), storage.Event{
-		ID:           "111",
+		ID:           "5d0b7c3e-2f4a-4b6c-9d8e-1a2b3c4d5e6f",
 		Title:        "Test",
 		StartDate:    startDt,
 		EndDate:      endDt,
@@ -119,7 +296,7 @@ func TestUpdateHandler(t *testing.T) {
 	})
 
 	data := url.Values{}
-	data.Set("id", "111")
+	data.Set("id", "5d0b7c3e-2f4a-4b6c-9d8e-1a2b3c4d5e6f")
 	data.Set("title", "Test Test")
 	data.Set("start_dt", "2025-06-01")
 	data.Set("end_dt", "2025-07-01")
@@ -139,7 +316,7 @@ func TestUpdateHandler(t *testing.T) {
 	resp.Body.Close()
 
 	require.Equal(t, http.StatusOK, resp.StatusCode)
-	event, err := store.GetEvent(context.Background(), "111")
+	event, err := store.GetEvent(context.Background(), "5d0b7c3e-2f4a-4b6c-9d8e-1a2b3c4d5e6f")
 	require.Nil(t, err)
 	require.Equal(t, "Test Test", event.Title)
 }
@@ -220,7 +397,7 @@ func TestGetHandler(t *testing.T) {
 	}
 
 	server := NewServer(logger, app, "localhost", "8080", timeout)
-	r := httptest.NewRequest("POST", "http://localhost:8080/event/get", nil)
+	r := creatorRequest("POST", "http://localhost:8080/event/get", nil)
 
 	w := httptest.NewRecorder()
 	server.GetEventHandler(w, r)
@@ -230,7 +407,7 @@ func TestGetHandler(t *testing.T) {
 
 	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 
-	r = httptest.NewRequest("POST", "http://localhost:8080/event/get?id=unknown", nil)
+	r = creatorRequest("POST", "http://localhost:8080/event/get?id=unknown", nil)
 
 	w = httptest.NewRecorder()
 	server.GetEventHandler(w, r)
@@ -238,7 +415,8 @@ func TestGetHandler(t *testing.T) {
 	resp = w.Result()
 	resp.Body.Close()
 
-	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, storage.ErrReadEventNotExists.Error(), w.Body.String())
 
 	startDt, err := time.Parse(time.DateOnly, "2025-06-01")
 	if err != nil {
@@ -259,7 +437,7 @@ func TestGetHandler(t *testing.T) {
 		NotifyBefore: time.Hour * 48,
 	})
 
-	r = httptest.NewRequest(
+	r = creatorRequest(
 		"POST",
 		"http://localhost:8080/event/get?id=111",
 		nil,
@@ -280,7 +458,7 @@ func TestGetHandler(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		"{\"id\":\"111\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2025-06-01T00:00:00Z\",\"end_dt\":\"2025-07-01T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000}",
+		"{\"id\":\"111\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2025-06-01T00:00:00Z\",\"end_dt\":\"2025-07-01T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000,\"notified\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}",
 		buf.String(),
 	)
 }
@@ -342,7 +520,7 @@ func TestGetListByDatesHandler(t *testing.T) {
 		NotifyBefore: time.Hour * 48,
 	})
 
-	r := httptest.NewRequest(
+	r := creatorRequest(
 		"POST",
 		"http://localhost:8080/event/listByDates?from=2025-06-01&to=2025-06-10",
 		nil,
@@ -363,7 +541,7 @@ func TestGetListByDatesHandler(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2025-06-01T00:00:00Z\",\"end_dt\":\"2025-06-10T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000}]",
+		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2025-06-01T00:00:00Z\",\"end_dt\":\"2025-06-10T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000,\"notified\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}]",
 		buf.String(),
 	)
 }
@@ -446,7 +624,7 @@ func TestGetListByNotifyDateHandler(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		"[{\"id\":\"2\",\"title\":\"Test 2\",\"description\":\"\",\"start_dt\":\"2025-06-12T00:00:00Z\",\"end_dt\":\"2025-06-15T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000}]",
+		"[{\"id\":\"2\",\"title\":\"Test 2\",\"description\":\"\",\"start_dt\":\"2025-06-12T00:00:00Z\",\"end_dt\":\"2025-06-15T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000,\"notified\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}]",
 		buf.String(),
 	)
 }
@@ -508,7 +686,7 @@ func TestGetListOnDateHandler(t *testing.T) {
 		NotifyBefore: time.Hour * 48,
 	})
 
-	r := httptest.NewRequest(
+	r := creatorRequest(
 		"POST",
 		"http://localhost:8080/event/listOnDate?date=2024-06-01",
 		nil,
@@ -529,7 +707,7 @@ func TestGetListOnDateHandler(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2024-06-01T00:00:00Z\",\"end_dt\":\"2024-06-10T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000}]",
+		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2024-06-01T00:00:00Z\",\"end_dt\":\"2024-06-10T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000,\"notified\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}]",
 		buf.String(),
 	)
 }
@@ -591,7 +769,7 @@ func TestGetListOnWeekHandler(t *testing.T) {
 		NotifyBefore: time.Hour * 48,
 	})
 
-	r := httptest.NewRequest(
+	r := creatorRequest(
 		"POST",
 		"http://localhost:8080/event/listOnWeek?weekStartDate=2024-06-03",
 		nil,
@@ -612,11 +790,140 @@ func TestGetListOnWeekHandler(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2024-06-04T00:00:00Z\",\"end_dt\":\"2024-06-06T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000}]",
+		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2024-06-04T00:00:00Z\",\"end_dt\":\"2024-06-06T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000,\"notified\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}]",
 		buf.String(),
 	)
 }
 
+type unavailableStorage struct {
+	*memorystorage.InMemoryStorage
+}
+
+func (s unavailableStorage) GetEventsOnWeek(
+	_ context.Context,
+	_ time.Time,
+	_ storage.EventFilter,
+) ([]storage.Event, error) {
+	return nil, fmt.Errorf("%w: connection refused", storage.ErrStorageUnavailable)
+}
+
+func (s unavailableStorage) GetEvent(_ context.Context, _ string) (storage.Event, error) {
+	return storage.Event{}, fmt.Errorf("%w: connection refused", storage.ErrStorageUnavailable)
+}
+
+func (s unavailableStorage) GetEventHistory(_ context.Context, _ string) ([]storage.EventHistoryRecord, error) {
+	return nil, fmt.Errorf("%w: connection refused", storage.ErrStorageUnavailable)
+}
+
+func (s unavailableStorage) ApplyBatch(
+	_ context.Context,
+	_ storage.BatchMode,
+	_ []storage.BatchOperation,
+) ([]storage.BatchResult, error) {
+	return nil, fmt.Errorf("%w: connection refused", storage.ErrStorageUnavailable)
+}
+
+func TestGetListStorageUnavailable(t *testing.T) {
+	var output bytes.Buffer
+
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := app.New(logger, unavailableStorage{memorystorage.New()})
+	server := NewServer(logger, app, "localhost", "8080", 30*time.Second)
+
+	r := httptest.NewRequest(
+		"POST",
+		"http://localhost:8080/event/listOnWeek?weekStartDate=2024-06-03",
+		nil,
+	)
+
+	w := httptest.NewRecorder()
+	server.GetListOnWeekHandler(w, r)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Contains(t, output.String(), "connection refused")
+
+	w = httptest.NewRecorder()
+	server.GetEventHandler(w, httptest.NewRequest("GET", "http://localhost:8080/event/get?id=1", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "storage unavailable: connection refused", w.Body.String())
+
+	w = httptest.NewRecorder()
+	server.GetEventHistoryHandler(w, httptest.NewRequest("GET", "http://localhost:8080/v1/events/1/history", nil), "1")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "storage unavailable: connection refused", w.Body.String())
+}
+
+func TestBatchStorageUnavailable(t *testing.T) {
+	logger, err := logger.New("ERROR", &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := app.New(logger, unavailableStorage{memorystorage.New()})
+	server := NewServer(logger, app, "localhost", "8080", 30*time.Second)
+
+	body := `{"operations":[{"op":"create","title":"Test",
+		"start_dt":"2024-06-14","end_dt":"2024-06-19","notify_before":"48h"}]}`
+
+	w := httptest.NewRecorder()
+	server.EventsV1Handler(w, httptest.NewRequest("POST", "http://localhost:8080/v1/events/batch",
+		strings.NewReader(body)))
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "storage unavailable: connection refused", w.Body.String())
+
+	w = httptest.NewRecorder()
+	server.EventsV1Handler(w, httptest.NewRequest("POST", "http://localhost:8080/v1/events/batch",
+		strings.NewReader(`{"operations":[]}`)))
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHealthHandlers(t *testing.T) {
+	logger, err := logger.New("ERROR", &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := memorystorage.New()
+	server := NewServer(logger, app.New(logger, storage), "localhost", "8080", 30*time.Second)
+
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		server.handler().ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080"+path, nil))
+		return w.Code
+	}
+
+	// Without the checks the server is ready.
+	require.Equal(t, http.StatusOK, serve("/readyz"))
+
+	var storageErr error
+	checker := health.New(logger)
+	checker.Add("storage", func(ctx context.Context) error {
+		if storageErr != nil {
+			return storageErr
+		}
+		return storage.Ping(ctx)
+	})
+	server.SetHealth(checker)
+
+	for _, path := range []string{"/livez", "/readyz", "/isready"} {
+		require.Equal(t, http.StatusOK, serve(path), path)
+	}
+
+	storageErr = errors.New("connection refused")
+	require.Equal(t, http.StatusOK, serve("/livez"))
+	require.Equal(t, http.StatusServiceUnavailable, serve("/readyz"))
+	require.Equal(t, http.StatusServiceUnavailable, serve("/isready"))
+}
+
 func TestGetListOnMonthHandler(t *testing.T) {
 	var output bytes.Buffer
 
@@ -674,7 +981,7 @@ func TestGetListOnMonthHandler(t *testing.T) {
 		NotifyBefore: time.Hour * 48,
 	})
 
-	r := httptest.NewRequest(
+	r := creatorRequest(
 		"POST",
 		"http://localhost:8080/event/listOnMonth?monthStartDate=2024-06-01",
 		nil,
@@ -695,7 +1002,525 @@ func TestGetListOnMonthHandler(t *testing.T) {
 	require.Equal(
 		t,
 		//nolint: all
-		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2024-06-14T00:00:00Z\",\"end_dt\":\"2024-06-19T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000}]",
+		"[{\"id\":\"1\",\"title\":\"Test\",\"description\":\"\",\"start_dt\":\"2024-06-14T00:00:00Z\",\"end_dt\":\"2024-06-19T00:00:00Z\",\"creator_id\":1,\"notify_before\":172800000000000,\"notified\":false,\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}]",
 		buf.String(),
 	)
 }
+
+func TestGetEventHistoryHandler(t *testing.T) {
+	var output bytes.Buffer
+
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memStorage := memorystorage.New()
+
+	app := app.New(logger, memStorage)
+
+	timeout, err := time.ParseDuration("30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(logger, app, "localhost", "8080", timeout)
+
+	startDt, err := time.Parse(time.DateOnly, "2024-06-14")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endDt, err := time.Parse(time.DateOnly, "2024-06-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memStorage.CreateEvent(context.Background(), storage.Event{
+		ID:           "1",
+		Title:        "Test",
+		StartDate:    startDt,
+		EndDate:      endDt,
+		CreatorID:    1,
+		NotifyBefore: time.Hour * 48,
+	})
+
+	r := creatorRequest("POST", "http://localhost:8080/v1/events/1/history", nil)
+
+	w := httptest.NewRecorder()
+	server.EventsV1Handler(w, r)
+
+	resp := w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	r = creatorRequest("GET", "http://localhost:8080/v1/events/1/history", nil)
+
+	w = httptest.NewRecorder()
+	server.EventsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	history := storage.EventHistory{}
+	err = history.UnmarshalJSON(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(history))
+	require.Equal(t, storage.EventActionCreate, history[0].Action)
+	require.Equal(t, "1", history[0].After.ID)
+
+	r = creatorRequest("GET", "http://localhost:8080/v1/events/1/unknown", nil)
+
+	w = httptest.NewRecorder()
+	server.EventsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestBatchEventsHandler(t *testing.T) {
+	var output bytes.Buffer
+
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memStorage := memorystorage.New()
+
+	app := app.New(logger, memStorage)
+
+	timeout, err := time.ParseDuration("30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(logger, app, "localhost", "8080", timeout)
+
+	r := httptest.NewRequest(
+		"POST",
+		"http://localhost:8080/v1/events/batch",
+		strings.NewReader(`{"mode":"best_effort","operations":[{"op":"create","id":"1","start_dt":"invalid"}]}`),
+	)
+
+	w := httptest.NewRecorder()
+	server.EventsV1Handler(w, r)
+
+	resp := w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	body := `{"mode":"all_or_nothing","operations":[
+		{"op":"create","id":"6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60","title":"Test",
+			"start_dt":"2024-06-14","end_dt":"2024-06-19","notify_before":"48h"},
+		{"op":"create","id":"6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60","title":"Test",
+			"start_dt":"2024-06-14","end_dt":"2024-06-19","notify_before":"48h"}
+	]}`
+
+	r = httptest.NewRequest("POST", "http://localhost:8080/v1/events/batch", strings.NewReader(body))
+
+	w = httptest.NewRecorder()
+	server.EventsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	require.Equal(
+		t,
+		//nolint: all
+		`{"applied":false,"results":[{"index":0,"id":"6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60","status":"aborted"},{"index":1,"id":"6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60","status":"error","error":"create event: passed ID exists"}]}`,
+		w.Body.String(),
+	)
+
+	_, err = memStorage.GetEvent(context.Background(), "6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60")
+	require.NotNil(t, err)
+
+	body = strings.Replace(body, "all_or_nothing", "best_effort", 1)
+	r = httptest.NewRequest("POST", "http://localhost:8080/v1/events/batch", strings.NewReader(body))
+
+	w = httptest.NewRecorder()
+	server.EventsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = memStorage.GetEvent(context.Background(), "6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60")
+	require.Nil(t, err)
+
+	body = `{"operations":[{"op":"create","description":"` + strings.Repeat("a", maxBatchBodySize) + `"}]}`
+	r = httptest.NewRequest("POST", "http://localhost:8080/v1/events/batch", strings.NewReader(body))
+
+	w = httptest.NewRecorder()
+	server.EventsV1Handler(w, r)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), "batch: too many operations")
+}
+
+func TestCalendarsHandler(t *testing.T) {
+	var output bytes.Buffer
+
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memStorage := memorystorage.New()
+
+	app := app.New(logger, memStorage)
+
+	timeout, err := time.ParseDuration("30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(logger, app, "localhost", "8080", timeout)
+
+	ownerCtx := identity.WithUserID(context.Background(), 1)
+	guestCtx := identity.WithUserID(context.Background(), 2)
+
+	r := httptest.NewRequest(
+		"POST",
+		"http://localhost:8080/v1/calendars",
+		strings.NewReader(`{"name":"Work"}`),
+	).WithContext(ownerCtx)
+
+	w := httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp := w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	calendar := storage.Calendar{}
+	err = calendar.UnmarshalJSON(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, 1, calendar.OwnerID)
+	require.Equal(t, "Work", calendar.Name)
+
+	r = httptest.NewRequest(
+		"PUT",
+		"http://localhost:8080/v1/calendars/"+calendar.ID+"/grants/2",
+		strings.NewReader(`{"permission":"free_busy"}`),
+	).WithContext(guestCtx)
+
+	w = httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	r = httptest.NewRequest(
+		"PUT",
+		"http://localhost:8080/v1/calendars/"+calendar.ID+"/grants/2",
+		strings.NewReader(`{"permission":"free_busy"}`),
+	).WithContext(ownerCtx)
+
+	w = httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	r = httptest.NewRequest("GET", "http://localhost:8080/v1/calendars", nil).WithContext(guestCtx)
+
+	w = httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	calendars := storage.UserCalendars{}
+	err = calendars.UnmarshalJSON(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(calendars))
+	require.Equal(t, storage.CalendarPermissionFreeBusy, calendars[0].Permission)
+
+	form := url.Values{}
+	form.Add("id", "6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60")
+	form.Add("calendar_id", calendar.ID)
+	form.Add("title", "Secret meeting")
+	form.Add("start_dt", "2024-06-14")
+	form.Add("end_dt", "2024-06-19")
+	form.Add("notify_before", "1h")
+
+	r = httptest.NewRequest(
+		"POST",
+		"http://localhost:8080/event/create",
+		strings.NewReader(form.Encode()),
+	).WithContext(guestCtx)
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+	server.CreateEventHandler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	r = httptest.NewRequest(
+		"POST",
+		"http://localhost:8080/event/create",
+		strings.NewReader(form.Encode()),
+	).WithContext(ownerCtx)
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+	server.CreateEventHandler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	r = httptest.NewRequest("GET", "http://localhost:8080/event/get?id=6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60", nil).
+		WithContext(guestCtx)
+
+	w = httptest.NewRecorder()
+	server.GetEventHandler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	event := storage.Event{}
+	err = event.UnmarshalJSON(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, "Busy", event.Title)
+
+	r = httptest.NewRequest(
+		"GET",
+		"http://localhost:8080/event/listOnDate?date=2024-06-14&calendar_id=unknown",
+		nil,
+	).WithContext(guestCtx)
+
+	w = httptest.NewRecorder()
+	server.GetListOnDateHandler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	r = httptest.NewRequest("GET", "http://localhost:8080/v1/calendars/"+calendar.ID+"/grants", nil).
+		WithContext(ownerCtx)
+
+	w = httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	grants := storage.CalendarGrants{}
+	err = grants.UnmarshalJSON(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, storage.CalendarGrants{
+		{CalendarID: calendar.ID, UserID: 2, Permission: storage.CalendarPermissionFreeBusy},
+	}, grants)
+
+	r = httptest.NewRequest("DELETE", "http://localhost:8080/v1/calendars/"+calendar.ID+"/grants/2", nil).
+		WithContext(ownerCtx)
+
+	w = httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	r = httptest.NewRequest("GET", "http://localhost:8080/event/get?id=6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60", nil).
+		WithContext(guestCtx)
+
+	w = httptest.NewRecorder()
+	server.GetEventHandler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	r = httptest.NewRequest("DELETE", "http://localhost:8080/v1/calendars/"+calendar.ID, nil).WithContext(ownerCtx)
+
+	w = httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	r = httptest.NewRequest("DELETE", "http://localhost:8080/v1/calendars/"+calendar.ID, nil).WithContext(ownerCtx)
+
+	w = httptest.NewRecorder()
+	server.CalendarsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestTagsHandler(t *testing.T) {
+	var output bytes.Buffer
+
+	logger, err := logger.New("DEBUG", &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memStorage := memorystorage.New()
+
+	app := app.New(logger, memStorage)
+
+	timeout, err := time.ParseDuration("30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(logger, app, "localhost", "8080", timeout)
+
+	ctx := identity.WithUserID(context.Background(), 1)
+
+	r := httptest.NewRequest(
+		"PUT",
+		"http://localhost:8080/v1/tags/oncall",
+		strings.NewReader(`{"color":"red"}`),
+	).WithContext(ctx)
+
+	w := httptest.NewRecorder()
+	server.TagsV1Handler(w, r)
+
+	resp := w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	r = httptest.NewRequest(
+		"PUT",
+		"http://localhost:8080/v1/tags/oncall",
+		strings.NewReader(`{"color":"#FF0000"}`),
+	).WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	server.TagsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	r = httptest.NewRequest("GET", "http://localhost:8080/v1/tags", nil).WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	server.TagsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	tags := storage.Tags{}
+	err = tags.UnmarshalJSON(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, storage.Tags{{OwnerID: 1, Name: "oncall", Color: "#ff0000"}}, tags)
+
+	for _, event := range []struct {
+		id   string
+		tags []string
+	}{
+		{id: "6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60", tags: []string{"oncall", " oncall ", "work"}},
+		{id: "0b9e2d4a-1c3f-4e5d-8a7b-9c8d7e6f5a41", tags: []string{"home"}},
+	} {
+		form := url.Values{}
+		form.Add("id", event.id)
+		form.Add("title", "Test")
+		form.Add("start_dt", "2024-06-14")
+		form.Add("end_dt", "2024-06-15")
+		form.Add("notify_before", "1h")
+		for _, tag := range event.tags {
+			form.Add("tag", tag)
+		}
+
+		r = httptest.NewRequest(
+			"POST",
+			"http://localhost:8080/event/create",
+			strings.NewReader(form.Encode()),
+		).WithContext(ctx)
+		r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		w = httptest.NewRecorder()
+		server.CreateEventHandler(w, r)
+
+		resp = w.Result()
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	r = httptest.NewRequest(
+		"GET",
+		"http://localhost:8080/event/listOnDate?date=2024-06-14&tag=oncall",
+		nil,
+	).WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	server.GetListOnDateHandler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	event := storage.Event{}
+	err = event.UnmarshalJSON(bytes.Trim(w.Body.Bytes(), "[]"))
+	require.Nil(t, err)
+	require.Equal(t, "6f1c3f52-8d0b-4a57-9a31-2b7d3c4e5f60", event.ID)
+	require.Equal(t, []string{"oncall", "work"}, event.Tags)
+
+	r = httptest.NewRequest("DELETE", "http://localhost:8080/v1/tags/oncall", nil).WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	server.TagsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	r = httptest.NewRequest("DELETE", "http://localhost:8080/v1/tags/oncall", nil).WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	server.TagsV1Handler(w, r)
+
+	resp = w.Result()
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// creatorRequest is a request of the user who created the events stored by the tests.
+func creatorRequest(method, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	return r.WithContext(identity.WithUserID(r.Context(), 1))
+}
@@ -0,0 +1,104 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+type saveTagRequest struct {
+	Color string `json:"color"`
+}
+
+// TagsV1Handler dispatches the /v1/tags routes:
+//
+//	GET    /v1/tags
+//	PUT    /v1/tags/{name}
+//	DELETE /v1/tags/{name}
+func (s *Server) TagsV1Handler(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/tags"), "/")
+
+	switch {
+	case name == "":
+		s.TagsHandler(w, r)
+	case !strings.Contains(name, "/"):
+		s.TagHandler(w, r, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) TagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	tags, err := s.app.GetTags(r.Context())
+	if err != nil {
+		s.tagError(w, r, err)
+		return
+	}
+
+	body, err := storage.Tags(tags).MarshalJSON()
+	if err != nil {
+		s.tagError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) TagHandler(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodPut:
+		var req saveTagRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.badRequest(w, errors.New("invalid json: "+err.Error()))
+			return
+		}
+
+		tag, err := s.app.SaveTag(r.Context(), name, req.Color)
+		if err != nil {
+			s.tagError(w, r, err)
+			return
+		}
+
+		body, err := tag.MarshalJSON()
+		if err != nil {
+			s.tagError(w, r, err)
+			return
+		}
+
+		s.writeJSON(w, http.StatusOK, body)
+	case http.MethodDelete:
+		err := s.app.DeleteTag(r.Context(), name)
+		if err != nil {
+			s.tagError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.methodNotAllowed(w, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (s *Server) tagError(w http.ResponseWriter, r *http.Request, err error) {
+	s.logger.ErrorContext(r.Context(), "request failed", "error", err)
+
+	switch {
+	case errors.Is(err, storage.ErrTagNotExists):
+		s.notFound(w, err)
+	case errors.Is(err, storage.ErrTagNameEmpty),
+		errors.Is(err, storage.ErrTagNameTooLong),
+		errors.Is(err, storage.ErrTagColorInvalid):
+		s.badRequest(w, err)
+	default:
+		s.internalError(w, err)
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import "errors"
+
+var (
+	ErrBatchUnknownOperation = errors.New("batch: unknown operation type")
+	ErrBatchUnknownMode      = errors.New("batch: unknown mode")
+	ErrBatchAborted          = errors.New("batch: aborted because another operation failed")
+)
+
+type BatchOperationType string
+
+const (
+	BatchOperationCreate BatchOperationType = "create"
+	BatchOperationUpdate BatchOperationType = "update"
+	BatchOperationDelete BatchOperationType = "delete"
+)
+
+type BatchMode string
+
+const (
+	// BatchModeAllOrNothing applies every operation or none of them.
+	BatchModeAllOrNothing BatchMode = "all_or_nothing"
+	// BatchModeBestEffort applies every operation that succeeds and reports the failed ones.
+	BatchModeBestEffort BatchMode = "best_effort"
+)
+
+type BatchOperation struct {
+	Type    BatchOperationType
+	EventID string
+	Event   Event
+}
+
+type BatchResult struct {
+	Index   int
+	EventID string
+	Err     error
+}
+
+// BatchApplied reports whether the changes of a batch with the passed results were persisted.
+func BatchApplied(mode BatchMode, results []BatchResult) bool {
+	if mode == BatchModeBestEffort {
+		return true
+	}
+
+	for i := range results {
+		if results[i].Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AbortBatchResults marks every successful result as aborted after an all-or-nothing batch failure.
+func AbortBatchResults(results []BatchResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = ErrBatchAborted
+		}
+	}
+}
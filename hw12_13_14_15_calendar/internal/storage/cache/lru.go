@@ -0,0 +1,151 @@
+package cachestorage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+type lruEntry struct {
+	key       string
+	value     any
+	expiresAt time.Time
+	// affects reports whether a change of the event can change the cached value.
+	affects func(event storage.Event) bool
+}
+
+// lru is a size-limited cache evicting the least recently used entries, every entry expires after ttl.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+	now   func() time.Time
+	// generation changes on every invalidation, a value loaded before it must not be cached.
+	generation uint64
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{
+		size:  size,
+		ttl:   ttl,
+		items: map[string]*list.Element{},
+		order: list.New(),
+		now:   time.Now,
+	}
+}
+
+func (c *lru) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if c.now().After(entry.expiresAt) {
+		c.removeElement(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+
+	return entry.value, true
+}
+
+func (c *lru) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// add caches the value loaded at the generation and returns the number of evicted entries.
+func (c *lru) add(generation uint64, key string, value any, affects func(event storage.Event) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return 0
+	}
+
+	entry := &lruEntry{
+		key:       key,
+		value:     value,
+		expiresAt: c.now().Add(c.ttl),
+		affects:   affects,
+	}
+
+	if element, ok := c.items[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return 0
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+
+	evicted := 0
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		evicted++
+	}
+
+	return evicted
+}
+
+// invalidate removes the entries affected by the changed events and returns their number.
+func (c *lru) invalidate(events ...storage.Event) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	removed := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+
+		entry := element.Value.(*lruEntry)
+		for _, event := range events {
+			if entry.affects(event) {
+				c.removeElement(element)
+				removed++
+				break
+			}
+		}
+
+		element = next
+	}
+
+	return removed
+}
+
+// purge removes all entries and returns their number.
+func (c *lru) purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	removed := c.order.Len()
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+
+	return removed
+}
+
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *lru) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,73 @@
+package cachestorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+func TestLRU(t *testing.T) {
+	never := func(storage.Event) bool { return false }
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		c := newLRU(2, time.Minute)
+
+		require.Zero(t, c.add(c.currentGeneration(), "a", 1, never))
+		require.Zero(t, c.add(c.currentGeneration(), "b", 2, never))
+
+		_, ok := c.get("a")
+		require.True(t, ok)
+
+		require.Equal(t, 1, c.add(c.currentGeneration(), "c", 3, never))
+
+		_, ok = c.get("b")
+		require.False(t, ok)
+		value, ok := c.get("a")
+		require.True(t, ok)
+		require.Equal(t, 1, value)
+	})
+
+	t.Run("expires after ttl", func(t *testing.T) {
+		now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		c := newLRU(2, time.Minute)
+		c.now = func() time.Time { return now }
+
+		c.add(c.currentGeneration(), "a", 1, never)
+
+		now = now.Add(time.Minute)
+		_, ok := c.get("a")
+		require.True(t, ok)
+
+		now = now.Add(time.Second)
+		_, ok = c.get("a")
+		require.False(t, ok)
+		require.Zero(t, c.len())
+	})
+
+	t.Run("skips values loaded before invalidation", func(t *testing.T) {
+		c := newLRU(2, time.Minute)
+
+		generation := c.currentGeneration()
+		c.invalidate(storage.Event{ID: "1"})
+		c.add(generation, "a", 1, never)
+
+		_, ok := c.get("a")
+		require.False(t, ok)
+	})
+
+	t.Run("invalidates affected entries", func(t *testing.T) {
+		c := newLRU(3, time.Minute)
+
+		c.add(c.currentGeneration(), "a", 1, func(event storage.Event) bool { return event.ID == "1" })
+		c.add(c.currentGeneration(), "b", 2, func(event storage.Event) bool { return event.ID == "2" })
+
+		require.Equal(t, 1, c.invalidate(storage.Event{ID: "2"}, storage.Event{ID: "3"}))
+
+		_, ok := c.get("a")
+		require.True(t, ok)
+		_, ok = c.get("b")
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,325 @@
+package cachestorage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+var ErrInvalidConfig = errors.New("cache size and ttl must be positive")
+
+type Config struct {
+	Size int
+	TTL  time.Duration
+}
+
+// Stats are the cache counters since the start.
+type Stats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+}
+
+// CachedStorage caches the event lists and events of the wrapped storage.
+// A change of an event drops only the cached results whose dates and filter the event matches
+// before or after the change. Changes made by other processes are seen after the ttl.
+type CachedStorage struct {
+	app.Storage
+
+	cache         *lru
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+func New(next app.Storage, config Config) (*CachedStorage, error) {
+	if config.Size <= 0 || config.TTL <= 0 {
+		return nil, ErrInvalidConfig
+	}
+
+	return &CachedStorage{
+		Storage: next,
+		cache:   newLRU(config.Size, config.TTL),
+	}, nil
+}
+
+func (s *CachedStorage) Stats() Stats {
+	return Stats{
+		Hits:          s.hits.Load(),
+		Misses:        s.misses.Load(),
+		Evictions:     s.evictions.Load(),
+		Invalidations: s.invalidations.Load(),
+		Entries:       s.cache.len(),
+	}
+}
+
+func (s *CachedStorage) CreateEvent(ctx context.Context, event storage.Event) error {
+	err := s.Storage.CreateEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(event)
+
+	return nil
+}
+
+func (s *CachedStorage) UpdateEvent(ctx context.Context, eventID string, event storage.Event) error {
+	before, beforeErr := s.Storage.GetEvent(ctx, eventID)
+
+	err := s.Storage.UpdateEvent(ctx, eventID, event)
+	if err != nil {
+		return err
+	}
+
+	if beforeErr != nil {
+		s.purge()
+		return nil
+	}
+
+	event.ID = eventID
+	s.invalidate(before, event)
+
+	return nil
+}
+
+func (s *CachedStorage) DeleteEvent(ctx context.Context, eventID string) error {
+	before, beforeErr := s.Storage.GetEvent(ctx, eventID)
+
+	err := s.Storage.DeleteEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case beforeErr == nil:
+		s.invalidate(before)
+	case !errors.Is(beforeErr, storage.ErrReadEventNotExists):
+		s.purge()
+	}
+
+	return nil
+}
+
+func (s *CachedStorage) ApplyBatch(
+	ctx context.Context,
+	mode storage.BatchMode,
+	operations []storage.BatchOperation,
+) ([]storage.BatchResult, error) {
+	results, err := s.Storage.ApplyBatch(ctx, mode, operations)
+	s.purge()
+
+	return results, err
+}
+
+func (s *CachedStorage) DeleteCalendar(ctx context.Context, calendarID string) error {
+	err := s.Storage.DeleteCalendar(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+
+	// the dates of the deleted events are unknown here
+	s.purge()
+
+	return nil
+}
+
+func (s *CachedStorage) GetEvent(ctx context.Context, eventID string) (storage.Event, error) {
+	key := "event\x00" + eventID
+	if value, ok := s.get(key); ok {
+		return value.(storage.Event), nil
+	}
+
+	generation := s.cache.currentGeneration()
+	event, err := s.Storage.GetEvent(ctx, eventID)
+	if err != nil {
+		return event, err
+	}
+
+	s.add(generation, key, event, func(changed storage.Event) bool {
+		return changed.ID == eventID
+	})
+
+	return event, nil
+}
+
+func (s *CachedStorage) GetEventsListByDates(
+	ctx context.Context,
+	from *time.Time,
+	to *time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	key := listKey("dates", filter, formatBound(from), formatBound(to))
+
+	return s.list(key, dateRange{from: from, to: to}, filter, func() ([]storage.Event, error) {
+		return s.Storage.GetEventsListByDates(ctx, from, to, filter)
+	})
+}
+
+func (s *CachedStorage) GetEventsOnDate(
+	ctx context.Context,
+	date time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	key := listKey("date", filter, formatBound(&date))
+
+	return s.list(key, newDateRange(date, date.AddDate(0, 0, 1)), filter, func() ([]storage.Event, error) {
+		return s.Storage.GetEventsOnDate(ctx, date, filter)
+	})
+}
+
+func (s *CachedStorage) GetEventsOnWeek(
+	ctx context.Context,
+	weekStartDate time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	key := listKey("week", filter, formatBound(&weekStartDate))
+
+	dates := newDateRange(weekStartDate, weekStartDate.AddDate(0, 0, 7))
+
+	return s.list(key, dates, filter, func() ([]storage.Event, error) {
+		return s.Storage.GetEventsOnWeek(ctx, weekStartDate, filter)
+	})
+}
+
+func (s *CachedStorage) GetEventsOnMonth(
+	ctx context.Context,
+	monthStartDate time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	key := listKey("month", filter, formatBound(&monthStartDate))
+
+	dates := newDateRange(monthStartDate, monthStartDate.AddDate(0, 1, 0))
+
+	return s.list(key, dates, filter, func() ([]storage.Event, error) {
+		return s.Storage.GetEventsOnMonth(ctx, monthStartDate, filter)
+	})
+}
+
+func (s *CachedStorage) list(
+	key string,
+	dates dateRange,
+	filter storage.EventFilter,
+	load func() ([]storage.Event, error),
+) ([]storage.Event, error) {
+	if value, ok := s.get(key); ok {
+		return copyEvents(value.([]storage.Event)), nil
+	}
+
+	generation := s.cache.currentGeneration()
+	events, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	s.add(generation, key, copyEvents(events), func(changed storage.Event) bool {
+		return dates.overlaps(changed) &&
+			filter.MatchCalendar(changed.CalendarID, changed.CreatorID) &&
+			filter.MatchTags(changed.Tags)
+	})
+
+	return events, nil
+}
+
+func (s *CachedStorage) get(key string) (any, bool) {
+	value, ok := s.cache.get(key)
+	if ok {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+
+	return value, ok
+}
+
+func (s *CachedStorage) add(generation uint64, key string, value any, affects func(event storage.Event) bool) {
+	evicted := s.cache.add(generation, key, value, affects)
+	s.evictions.Add(uint64(evicted))
+}
+
+func (s *CachedStorage) invalidate(events ...storage.Event) {
+	removed := s.cache.invalidate(events...)
+	s.invalidations.Add(uint64(removed))
+}
+
+func (s *CachedStorage) purge() {
+	removed := s.cache.purge()
+	s.invalidations.Add(uint64(removed))
+}
+
+// dateRange is the period a list query can return events from, nil bounds are open.
+// The backends differ in whether an event must be within the period or only overlap it,
+// so every overlapping event is treated as affecting the query.
+type dateRange struct {
+	from *time.Time
+	to   *time.Time
+}
+
+func newDateRange(from time.Time, to time.Time) dateRange {
+	return dateRange{from: &from, to: &to}
+}
+
+func (r dateRange) overlaps(event storage.Event) bool {
+	if r.from != nil && event.EndDate.Before(*r.from) {
+		return false
+	}
+
+	return r.to == nil || !event.StartDate.After(*r.to)
+}
+
+func listKey(query string, filter storage.EventFilter, params ...string) string {
+	var b strings.Builder
+
+	b.WriteString(query)
+	for _, param := range params {
+		b.WriteByte(0)
+		b.WriteString(param)
+	}
+
+	// nil and empty calendar lists mean different things
+	b.WriteString("\x00calendars")
+	if filter.CalendarIDs == nil {
+		b.WriteString("\x00*")
+	}
+	for _, calendarID := range filter.CalendarIDs {
+		b.WriteString("\x00=")
+		b.WriteString(calendarID)
+	}
+	if filter.CreatorID != nil {
+		b.WriteString("\x00creator=")
+		b.WriteString(strconv.Itoa(*filter.CreatorID))
+	}
+
+	b.WriteString("\x00tags")
+	for _, tag := range filter.Tags {
+		b.WriteByte(0)
+		b.WriteString(tag)
+	}
+
+	return b.String()
+}
+
+func formatBound(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
+
+func copyEvents(events []storage.Event) []storage.Event {
+	if events == nil {
+		return nil
+	}
+
+	return append([]storage.Event{}, events...)
+}
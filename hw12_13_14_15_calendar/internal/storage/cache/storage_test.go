@@ -0,0 +1,150 @@
+package cachestorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+)
+
+func newTestStorage(t *testing.T) (*CachedStorage, *memorystorage.InMemoryStorage) {
+	t.Helper()
+
+	backend := memorystorage.New()
+	cached, err := New(backend, Config{Size: 100, TTL: time.Minute})
+	require.NoError(t, err)
+
+	return cached, backend
+}
+
+func listed(t *testing.T) func(events []storage.Event, err error) []storage.Event {
+	t.Helper()
+
+	return func(events []storage.Event, err error) []storage.Event {
+		t.Helper()
+		require.NoError(t, err)
+
+		return events
+	}
+}
+
+func TestCachedStorageWeek(t *testing.T) {
+	ctx := context.Background()
+	store, backend := newTestStorage(t)
+
+	week := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	nextWeek := week.AddDate(0, 0, 7)
+
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{
+		ID:        "1",
+		Title:     "Planning",
+		StartDate: week.Add(10 * time.Hour),
+		EndDate:   week.Add(11 * time.Hour),
+	}))
+
+	events := listed(t)(store.GetEventsOnWeek(ctx, week, storage.EventFilter{}))
+	require.Len(t, events, 1)
+	require.Len(t, listed(t)(store.GetEventsOnWeek(ctx, nextWeek, storage.EventFilter{})), 0)
+
+	events = listed(t)(store.GetEventsOnWeek(ctx, week, storage.EventFilter{}))
+	require.Len(t, events, 1)
+	require.Equal(t, Stats{Hits: 1, Misses: 2, Entries: 2}, store.Stats())
+
+	// a change made around the cache is not seen until the entry is dropped
+	require.NoError(t, backend.DeleteEvent(ctx, "1"))
+	require.Len(t, listed(t)(store.GetEventsOnWeek(ctx, week, storage.EventFilter{})), 1)
+
+	t.Run("create drops only the affected week", func(t *testing.T) {
+		require.NoError(t, store.CreateEvent(ctx, storage.Event{
+			ID:        "2",
+			Title:     "Next week",
+			StartDate: nextWeek.Add(10 * time.Hour),
+			EndDate:   nextWeek.Add(11 * time.Hour),
+		}))
+
+		require.Len(t, listed(t)(store.GetEventsOnWeek(ctx, week, storage.EventFilter{})), 1)
+		require.Len(t, listed(t)(store.GetEventsOnWeek(ctx, nextWeek, storage.EventFilter{})), 1)
+	})
+
+	t.Run("update drops the weeks before and after the change", func(t *testing.T) {
+		require.NoError(t, store.UpdateEvent(ctx, "2", storage.Event{
+			ID:        "2",
+			Title:     "Moved",
+			StartDate: week.Add(12 * time.Hour),
+			EndDate:   week.Add(13 * time.Hour),
+		}))
+
+		events := listed(t)(store.GetEventsOnWeek(ctx, week, storage.EventFilter{}))
+		require.Len(t, events, 1)
+		require.Equal(t, "Moved", events[0].Title)
+		require.Len(t, listed(t)(store.GetEventsOnWeek(ctx, nextWeek, storage.EventFilter{})), 0)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.DeleteEvent(ctx, "2"))
+
+		require.Len(t, listed(t)(store.GetEventsOnWeek(ctx, week, storage.EventFilter{})), 0)
+	})
+}
+
+func TestCachedStorageFilter(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStorage(t)
+
+	date := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	from := date.Add(-time.Hour)
+	to := date.Add(time.Hour)
+	work := storage.EventFilter{CalendarIDs: []string{"work"}}
+	home := storage.EventFilter{CalendarIDs: []string{"home"}}
+
+	require.Len(t, listed(t)(store.GetEventsListByDates(ctx, &from, &to, work)), 0)
+	require.Len(t, listed(t)(store.GetEventsListByDates(ctx, &from, &to, home)), 0)
+
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{
+		ID:         "1",
+		CalendarID: "work",
+		StartDate:  date,
+		EndDate:    date.Add(30 * time.Minute),
+	}))
+	require.Equal(t, uint64(1), store.Stats().Invalidations)
+
+	require.Len(t, listed(t)(store.GetEventsListByDates(ctx, &from, &to, work)), 1)
+	require.Len(t, listed(t)(store.GetEventsListByDates(ctx, &from, &to, home)), 0)
+	require.Equal(t, uint64(1), store.Stats().Hits)
+}
+
+func TestCachedStorageGetEvent(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestStorage(t)
+
+	_, err := store.GetEvent(ctx, "1")
+	require.ErrorIs(t, err, storage.ErrReadEventNotExists)
+
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "1", Title: "Created"}))
+
+	event, err := store.GetEvent(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "Created", event.Title)
+
+	require.NoError(t, store.UpdateEvent(ctx, "1", storage.Event{ID: "1", Title: "Updated"}))
+
+	event, err = store.GetEvent(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "Updated", event.Title)
+
+	_, err = store.ApplyBatch(ctx, storage.BatchModeBestEffort, []storage.BatchOperation{
+		{Type: storage.BatchOperationDelete, EventID: "1"},
+	})
+	require.NoError(t, err)
+
+	_, err = store.GetEvent(ctx, "1")
+	require.ErrorIs(t, err, storage.ErrReadEventNotExists)
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	_, err := New(memorystorage.New(), Config{Size: 0, TTL: time.Minute})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
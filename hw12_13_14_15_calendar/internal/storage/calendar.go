@@ -0,0 +1,64 @@
+package storage
+
+import "errors"
+
+var (
+	ErrCalendarNotExists         = errors.New("calendar: passed ID not exists")
+	ErrCreateCalendarIDExists    = errors.New("create calendar: passed ID exists")
+	ErrCalendarGrantNotExists    = errors.New("calendar grant: grant for passed user not exists")
+	ErrUnknownCalendarPermission = errors.New("calendar grant: unknown permission")
+	ErrCalendarAccessDenied      = errors.New("calendar: access denied")
+	ErrCalendarNameEmpty         = errors.New("calendar: name is empty")
+	ErrCalendarShareOwner        = errors.New("calendar grant: can not share calendar with its owner")
+)
+
+type CalendarPermission string
+
+const (
+	CalendarPermissionOwner CalendarPermission = "owner"
+	CalendarPermissionWrite CalendarPermission = "write"
+	CalendarPermissionRead  CalendarPermission = "read"
+	// CalendarPermissionFreeBusy allows to see only when the calendar owner is busy without event details.
+	CalendarPermissionFreeBusy CalendarPermission = "free_busy"
+)
+
+// CanRead reports whether the permission allows to see event details.
+func (p CalendarPermission) CanRead() bool {
+	return p == CalendarPermissionOwner || p == CalendarPermissionWrite || p == CalendarPermissionRead
+}
+
+func (p CalendarPermission) CanWrite() bool {
+	return p == CalendarPermissionOwner || p == CalendarPermissionWrite
+}
+
+func (p CalendarPermission) Valid() bool {
+	return p == CalendarPermissionWrite || p == CalendarPermissionRead || p == CalendarPermissionFreeBusy
+}
+
+//easyjson:json
+type Calendar struct {
+	ID      string `json:"id"`
+	OwnerID int    `json:"owner_id"`
+	Name    string `json:"name"`
+}
+
+//easyjson:json
+type CalendarGrant struct {
+	CalendarID string             `json:"calendar_id"`
+	UserID     int                `json:"user_id"`
+	Permission CalendarPermission `json:"permission"`
+}
+
+//easyjson:json
+type CalendarGrants []CalendarGrant
+
+// UserCalendar is a calendar visible to a user together with the permission the user has on it.
+//
+//easyjson:json
+type UserCalendar struct {
+	Calendar
+	Permission CalendarPermission `json:"permission"`
+}
+
+//easyjson:json
+type UserCalendars []UserCalendar
@@ -9,15 +9,24 @@ var (
 	ErrReadEventNotExists     = errors.New("read event: passed ID not exists")
 	ErrCreateEventIDExists    = errors.New("create event: passed ID exists")
 	ErrUpdateEventIDNotExists = errors.New("update event: event with passed ID not exists")
+	// ErrStorageUnavailable wraps the errors of a storage which can not be reached at the moment.
+	ErrStorageUnavailable = errors.New("storage unavailable")
 )
 
 type Event struct {
 	ID           string        `json:"id"`
+	CalendarID   string        `json:"calendar_id,omitempty"`
 	Title        string        `json:"title"`
 	Description  string        `json:"description"`
-	StartDate    time.Time     `json:"startDt"`
-	EndDate      time.Time     `json:"endDt"`
-	CreatorID    int           `json:"creatorId"`
-	NotifyBefore time.Duration `json:"notifyBefore"`
-	Notified     bool
+	Location     string        `json:"location,omitempty"`
+	URL          string        `json:"url,omitempty"`
+	StartDate    time.Time     `json:"start_dt"`
+	EndDate      time.Time     `json:"end_dt"`
+	CreatorID    int           `json:"creator_id"`
+	NotifyBefore time.Duration `json:"notify_before"`
+	Notified     bool          `json:"notified"`
+	Tags         []string      `json:"tags,omitempty"`
+	// CreatedAt and UpdatedAt are set by the application, the storages keep CreatedAt on update.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
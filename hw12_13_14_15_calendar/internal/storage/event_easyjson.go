@@ -39,10 +39,16 @@ func easyjsonF642ad3eDecodeGithubComWurstaOtusGoHw12131415CalendarInternalStorag
 		switch key {
 		case "id":
 			out.ID = string(in.String())
+		case "calendar_id":
+			out.CalendarID = string(in.String())
 		case "title":
 			out.Title = string(in.String())
 		case "description":
 			out.Description = string(in.String())
+		case "location":
+			out.Location = string(in.String())
+		case "url":
+			out.URL = string(in.String())
 		case "start_dt":
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.StartDate).UnmarshalJSON(data))
@@ -55,6 +61,39 @@ func easyjsonF642ad3eDecodeGithubComWurstaOtusGoHw12131415CalendarInternalStorag
 			out.CreatorID = int(in.Int())
 		case "notify_before":
 			out.NotifyBefore = time.Duration(in.Int64())
+		case "notified":
+			out.Notified = bool(in.Bool())
+		case "tags":
+			if in.IsNull() {
+				in.Skip()
+				out.Tags = nil
+			} else {
+				in.Delim('[')
+				if out.Tags == nil {
+					if !in.IsDelim(']') {
+						out.Tags = make([]string, 0, 4)
+					} else {
+						out.Tags = []string{}
+					}
+				} else {
+					out.Tags = (out.Tags)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 string
+					v1 = string(in.String())
+					out.Tags = append(out.Tags, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "created_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.CreatedAt).UnmarshalJSON(data))
+			}
+		case "updated_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.UpdatedAt).UnmarshalJSON(data))
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -74,6 +113,11 @@ func easyjsonF642ad3eEncodeGithubComWurstaOtusGoHw12131415CalendarInternalStorag
 		out.RawString(prefix[1:])
 		out.String(string(in.ID))
 	}
+	if in.CalendarID != "" {
+		const prefix string = ",\"calendar_id\":"
+		out.RawString(prefix)
+		out.String(string(in.CalendarID))
+	}
 	{
 		const prefix string = ",\"title\":"
 		out.RawString(prefix)
@@ -84,6 +128,16 @@ func easyjsonF642ad3eEncodeGithubComWurstaOtusGoHw12131415CalendarInternalStorag
 		out.RawString(prefix)
 		out.String(string(in.Description))
 	}
+	if in.Location != "" {
+		const prefix string = ",\"location\":"
+		out.RawString(prefix)
+		out.String(string(in.Location))
+	}
+	if in.URL != "" {
+		const prefix string = ",\"url\":"
+		out.RawString(prefix)
+		out.String(string(in.URL))
+	}
 	{
 		const prefix string = ",\"start_dt\":"
 		out.RawString(prefix)
@@ -104,6 +158,35 @@ func easyjsonF642ad3eEncodeGithubComWurstaOtusGoHw12131415CalendarInternalStorag
 		out.RawString(prefix)
 		out.Int64(int64(in.NotifyBefore))
 	}
+	{
+		const prefix string = ",\"notified\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Notified))
+	}
+	if len(in.Tags) != 0 {
+		const prefix string = ",\"tags\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v2, v3 := range in.Tags {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v3))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"created_at\":"
+		out.RawString(prefix)
+		out.Raw((in.CreatedAt).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"updated_at\":"
+		out.RawString(prefix)
+		out.Raw((in.UpdatedAt).MarshalJSON())
+	}
 	out.RawByte('}')
 }
 
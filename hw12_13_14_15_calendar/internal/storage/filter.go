@@ -0,0 +1,42 @@
+package storage
+
+// EventFilter restricts list queries. Nil fields do not restrict anything.
+type EventFilter struct {
+	// CalendarIDs lists the calendars to return events from.
+	// An empty ID matches the events which do not belong to any calendar.
+	CalendarIDs []string
+	// CreatorID limits the events matched by the empty calendar ID to the ones created by the user.
+	CreatorID *int
+	// Tags matches the events with at least one of the passed tags.
+	Tags []string
+}
+
+func (f EventFilter) MatchCalendar(calendarID string, creatorID int) bool {
+	if f.CalendarIDs == nil {
+		return true
+	}
+
+	for i := range f.CalendarIDs {
+		if f.CalendarIDs[i] == calendarID {
+			return calendarID != "" || f.CreatorID == nil || *f.CreatorID == creatorID
+		}
+	}
+
+	return false
+}
+
+func (f EventFilter) MatchTags(tags []string) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+
+	for i := range f.Tags {
+		for j := range tags {
+			if f.Tags[i] == tags[j] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type EventAction string
+
+const (
+	EventActionCreate EventAction = "create"
+	EventActionUpdate EventAction = "update"
+	EventActionDelete EventAction = "delete"
+)
+
+//easyjson:json
+type EventChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+//easyjson:json
+type EventHistoryRecord struct {
+	ID        int64         `json:"id"`
+	EventID   string        `json:"event_id"`
+	Action    EventAction   `json:"action"`
+	UserID    int           `json:"user_id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Before    *Event        `json:"before"`
+	After     *Event        `json:"after"`
+	Changes   []EventChange `json:"changes"`
+}
+
+//easyjson:json
+type EventHistory []EventHistoryRecord
+
+// NewEventHistoryRecord builds a history record with the field level diff between before and after.
+// Before is nil for created events and after is nil for deleted ones.
+func NewEventHistoryRecord(
+	eventID string,
+	action EventAction,
+	userID int,
+	createdAt time.Time,
+	before *Event,
+	after *Event,
+) EventHistoryRecord {
+	return EventHistoryRecord{
+		EventID:   eventID,
+		Action:    action,
+		UserID:    userID,
+		CreatedAt: createdAt,
+		Before:    before,
+		After:     after,
+		Changes:   DiffEvents(before, after),
+	}
+}
+
+func DiffEvents(before *Event, after *Event) []EventChange {
+	var beforeFields, afterFields map[string]string
+	if before != nil {
+		beforeFields = eventFields(*before)
+	}
+	if after != nil {
+		afterFields = eventFields(*after)
+	}
+
+	changes := []EventChange{}
+	for _, field := range eventFieldNames {
+		if beforeFields[field] == afterFields[field] {
+			continue
+		}
+
+		changes = append(changes, EventChange{
+			Field:  field,
+			Before: beforeFields[field],
+			After:  afterFields[field],
+		})
+	}
+
+	return changes
+}
+
+var eventFieldNames = []string{
+	"calendar_id",
+	"title",
+	"description",
+	"location",
+	"url",
+	"start_dt",
+	"end_dt",
+	"creator_id",
+	"notify_before",
+	"notified",
+	"tags",
+}
+
+func eventFields(event Event) map[string]string {
+	return map[string]string{
+		"calendar_id":   event.CalendarID,
+		"title":         event.Title,
+		"description":   event.Description,
+		"location":      event.Location,
+		"url":           event.URL,
+		"start_dt":      event.StartDate.Format(time.RFC3339),
+		"end_dt":        event.EndDate.Format(time.RFC3339),
+		"creator_id":    strconv.Itoa(event.CreatorID),
+		"notify_before": event.NotifyBefore.String(),
+		"notified":      strconv.FormatBool(event.Notified),
+		"tags":          strings.Join(event.Tags, ","),
+	}
+}
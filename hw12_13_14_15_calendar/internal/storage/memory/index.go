@@ -0,0 +1,173 @@
+package memorystorage
+
+import (
+	"math/rand"
+	"time"
+)
+
+// eventIndex is an interval tree over the event dates: a treap ordered by the start date and the event ID,
+// where every node also keeps the latest end date of its subtree.
+// Range queries visit only the subtrees which can contain matching events, O(log n + k).
+type eventIndex struct {
+	root *indexNode
+	rnd  *rand.Rand
+}
+
+type indexNode struct {
+	id       string
+	start    time.Time
+	end      time.Time
+	maxEnd   time.Time
+	priority int64
+	left     *indexNode
+	right    *indexNode
+}
+
+func newEventIndex() *eventIndex {
+	return &eventIndex{
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}
+}
+
+func (idx *eventIndex) insert(event inMemoryEvent) {
+	node := &indexNode{
+		id:       event.ID,
+		start:    event.StartDate,
+		end:      event.EndDate,
+		maxEnd:   event.EndDate,
+		priority: idx.rnd.Int63(),
+	}
+
+	left, right := split(idx.root, event.StartDate, event.ID)
+	idx.root = merge(merge(left, node), right)
+}
+
+func (idx *eventIndex) remove(event inMemoryEvent) {
+	idx.root = remove(idx.root, event.StartDate, event.ID)
+}
+
+// overlapping calls fn in the start date order for every event which ends not before from and starts not after to.
+// A nil bound is open.
+func (idx *eventIndex) overlapping(from *time.Time, to *time.Time, fn func(id string)) {
+	overlapping(idx.root, from, to, fn)
+}
+
+// startingBetween calls fn in the start date order for every event which starts within [from, to].
+func (idx *eventIndex) startingBetween(from time.Time, to time.Time, fn func(id string)) {
+	startingBetween(idx.root, from, to, fn)
+}
+
+func overlapping(node *indexNode, from *time.Time, to *time.Time, fn func(id string)) {
+	if node == nil || (from != nil && node.maxEnd.Before(*from)) {
+		return
+	}
+
+	overlapping(node.left, from, to, fn)
+
+	if to != nil && node.start.After(*to) {
+		// every event on the right starts later
+		return
+	}
+
+	if from == nil || !node.end.Before(*from) {
+		fn(node.id)
+	}
+
+	overlapping(node.right, from, to, fn)
+}
+
+func startingBetween(node *indexNode, from time.Time, to time.Time, fn func(id string)) {
+	if node == nil {
+		return
+	}
+
+	if !node.start.Before(from) {
+		startingBetween(node.left, from, to, fn)
+	}
+
+	if node.start.After(to) {
+		return
+	}
+
+	if !node.start.Before(from) {
+		fn(node.id)
+	}
+
+	startingBetween(node.right, from, to, fn)
+}
+
+// split divides the tree into the nodes ordered before the key and the rest.
+func split(node *indexNode, start time.Time, id string) (*indexNode, *indexNode) {
+	if node == nil {
+		return nil, nil
+	}
+
+	if node.less(start, id) {
+		left, right := split(node.right, start, id)
+		node.right = left
+		node.update()
+		return node, right
+	}
+
+	left, right := split(node.left, start, id)
+	node.left = right
+	node.update()
+	return left, node
+}
+
+// merge joins two trees where every node of left is ordered before every node of right.
+func merge(left *indexNode, right *indexNode) *indexNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+
+	if left.priority > right.priority {
+		left.right = merge(left.right, right)
+		left.update()
+		return left
+	}
+
+	right.left = merge(left, right.left)
+	right.update()
+	return right
+}
+
+func remove(node *indexNode, start time.Time, id string) *indexNode {
+	if node == nil {
+		return nil
+	}
+
+	switch {
+	case node.id == id && node.start.Equal(start):
+		return merge(node.left, node.right)
+	case node.less(start, id):
+		node.right = remove(node.right, start, id)
+	default:
+		node.left = remove(node.left, start, id)
+	}
+
+	node.update()
+	return node
+}
+
+// less reports whether the node is ordered before the key.
+func (n *indexNode) less(start time.Time, id string) bool {
+	if n.start.Equal(start) {
+		return n.id < id
+	}
+
+	return n.start.Before(start)
+}
+
+func (n *indexNode) update() {
+	n.maxEnd = n.end
+	if n.left != nil && n.left.maxEnd.After(n.maxEnd) {
+		n.maxEnd = n.left.maxEnd
+	}
+	if n.right != nil && n.right.maxEnd.After(n.maxEnd) {
+		n.maxEnd = n.right.maxEnd
+	}
+}
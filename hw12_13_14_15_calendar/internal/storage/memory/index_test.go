@@ -0,0 +1,102 @@
+package memorystorage
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventIndex(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	baseDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	randomEvent := func(id string) inMemoryEvent {
+		startDate := baseDate.Add(time.Duration(rnd.Intn(60*24)) * time.Hour)
+		return inMemoryEvent{
+			ID:        id,
+			StartDate: startDate,
+			EndDate:   startDate.Add(time.Duration(rnd.Intn(20*24)) * time.Hour),
+		}
+	}
+
+	index := newEventIndex()
+	events := map[string]inMemoryEvent{}
+	for i := 0; i < 2000; i++ {
+		id := strconv.Itoa(rnd.Intn(500))
+		if saved, ok := events[id]; ok {
+			index.remove(saved)
+			delete(events, id)
+			if rnd.Intn(2) == 0 {
+				continue
+			}
+		}
+
+		event := randomEvent(id)
+		events[id] = event
+		index.insert(event)
+	}
+
+	sorted := make([]inMemoryEvent, 0, len(events))
+	for _, event := range events {
+		sorted = append(sorted, event)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].StartDate.Equal(sorted[j].StartDate) {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return sorted[i].StartDate.Before(sorted[j].StartDate)
+	})
+
+	collect := func(query func(fn func(id string))) []string {
+		ids := []string{}
+		query(func(id string) {
+			ids = append(ids, id)
+		})
+		return ids
+	}
+
+	t.Run("overlapping", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			from := baseDate.Add(time.Duration(rnd.Intn(80*24)) * time.Hour)
+			to := from.Add(time.Duration(rnd.Intn(10*24)) * time.Hour)
+
+			expected := []string{}
+			for _, event := range sorted {
+				if !event.EndDate.Before(from) && !event.StartDate.After(to) {
+					expected = append(expected, event.ID)
+				}
+			}
+
+			require.Equal(t, expected, collect(func(fn func(id string)) {
+				index.overlapping(&from, &to, fn)
+			}))
+		}
+
+		all := collect(func(fn func(id string)) {
+			index.overlapping(nil, nil, fn)
+		})
+		require.Len(t, all, len(sorted))
+	})
+
+	t.Run("starting between", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			from := baseDate.Add(time.Duration(rnd.Intn(80*24)) * time.Hour)
+			to := from.Add(time.Duration(rnd.Intn(10*24)) * time.Hour)
+
+			expected := []string{}
+			for _, event := range sorted {
+				if !event.StartDate.Before(from) && !event.StartDate.After(to) {
+					expected = append(expected, event.ID)
+				}
+			}
+
+			require.Equal(t, expected, collect(func(fn func(id string)) {
+				index.startingBetween(from, to, fn)
+			}))
+		}
+	})
+}
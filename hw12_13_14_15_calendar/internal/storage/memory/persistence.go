@@ -0,0 +1,632 @@
+package memorystorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs the write-ahead log after every operation.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncEverySec syncs the write-ahead log once a second, a crash loses at most the last second.
+	FsyncEverySec FsyncPolicy = "everysec"
+	// FsyncNever leaves syncing to the operating system.
+	FsyncNever FsyncPolicy = "never"
+)
+
+const (
+	snapshotFileName = "snapshot.json"
+	walFileName      = "wal.log"
+)
+
+var (
+	ErrUnknownFsyncPolicy = errors.New("unknown fsync policy")
+	ErrPersistenceDir     = errors.New("persistence directory is not set")
+	ErrCorruptedWAL       = errors.New("write-ahead log is corrupted")
+)
+
+type Logger interface {
+	Error(msg string, args ...any)
+}
+
+type PersistenceConfig struct {
+	Dir              string
+	Fsync            FsyncPolicy
+	SnapshotInterval time.Duration
+}
+
+type walRecordType string
+
+const (
+	recordPutEvent       walRecordType = "put_event"
+	recordDeleteEvent    walRecordType = "delete_event"
+	recordAddHistory     walRecordType = "add_history"
+	recordDeleteHistory  walRecordType = "delete_history"
+	recordPutCalendar    walRecordType = "put_calendar"
+	recordDeleteCalendar walRecordType = "delete_calendar"
+	recordPutGrant       walRecordType = "put_grant"
+	recordDeleteGrant    walRecordType = "delete_grant"
+	recordPutTag         walRecordType = "put_tag"
+	recordDeleteTag      walRecordType = "delete_tag"
+)
+
+// walRecord is a single state change. Records hold the resulting state rather than the operation,
+// so the replay does not depend on the clock or the request context.
+type walRecord struct {
+	Type     walRecordType               `json:"type"`
+	ID       string                      `json:"id,omitempty"`
+	Event    *inMemoryEvent              `json:"event,omitempty"`
+	History  *storage.EventHistoryRecord `json:"history,omitempty"`
+	Calendar *storage.Calendar           `json:"calendar,omitempty"`
+	Grant    *storage.CalendarGrant      `json:"grant,omitempty"`
+	Tag      *storage.Tag                `json:"tag,omitempty"`
+}
+
+// walEntry groups the records of one storage call, they are replayed all or none.
+type walEntry struct {
+	Seq     uint64      `json:"seq"`
+	Records []walRecord `json:"records"`
+}
+
+type snapshot struct {
+	Seq           uint64                       `json:"seq"`
+	LastHistoryID int64                        `json:"lastHistoryId"`
+	Events        []inMemoryEvent              `json:"events"`
+	History       []storage.EventHistoryRecord `json:"history"`
+	Calendars     []storage.Calendar           `json:"calendars"`
+	Grants        []storage.CalendarGrant      `json:"grants"`
+	Tags          []storage.Tag                `json:"tags"`
+}
+
+// walFile is the part of *os.File used for the write-ahead log.
+type walFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+type persistence struct {
+	config  PersistenceConfig
+	logger  Logger
+	wal     walFile
+	seq     uint64
+	pending []walRecord
+	undo    []func()
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewPersistent creates the storage which keeps its state in the config directory:
+// an append-only write-ahead log of every change and a periodically compacted snapshot.
+// Open must be called before use.
+func NewPersistent(logger Logger, config PersistenceConfig) *InMemoryStorage {
+	s := New()
+	s.persistence = &persistence{
+		config: config,
+		logger: logger,
+	}
+
+	return s
+}
+
+// Open restores the state from the snapshot and the write-ahead log and starts the background
+// snapshots and syncs. A torn entry at the end of the log, left by a crash, is dropped.
+func (s *InMemoryStorage) Open(_ context.Context) error {
+	p := s.persistence
+	if p == nil {
+		return nil
+	}
+
+	switch p.config.Fsync {
+	case FsyncAlways, FsyncEverySec, FsyncNever:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFsyncPolicy, p.config.Fsync)
+	}
+
+	if p.config.Dir == "" {
+		return ErrPersistenceDir
+	}
+
+	err := os.MkdirAll(p.config.Dir, 0o750)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.loadSnapshot()
+	if err != nil {
+		return err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(p.config.Dir, walFileName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	p.wal = wal
+
+	err = s.replayWAL()
+	if err != nil {
+		p.wal.Close()
+		return err
+	}
+
+	p.done = make(chan struct{})
+	p.wg.Add(1)
+	go s.runBackground()
+
+	return nil
+}
+
+// Close stops the background jobs, writes the final snapshot and closes the log.
+func (s *InMemoryStorage) Close(_ context.Context) error {
+	p := s.persistence
+	if p == nil || p.wal == nil {
+		return nil
+	}
+
+	close(p.done)
+	p.wg.Wait()
+
+	err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = p.wal.Close()
+	p.wal = nil
+
+	return err
+}
+
+// Snapshot writes the whole state to the snapshot file and truncates the write-ahead log.
+func (s *InMemoryStorage) Snapshot() error {
+	p := s.persistence
+	if p == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p.wal == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(s.buildSnapshot())
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(filepath.Join(p.config.Dir, snapshotFileName), data)
+	if err != nil {
+		return err
+	}
+
+	// entries up to the snapshot seq are skipped on replay,
+	// so a crash before the truncate does not apply them twice
+	err = p.wal.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	return p.wal.Sync()
+}
+
+func (s *InMemoryStorage) runBackground() {
+	p := s.persistence
+	defer p.wg.Done()
+
+	var snapshotC, syncC <-chan time.Time
+	if p.config.SnapshotInterval > 0 {
+		ticker := time.NewTicker(p.config.SnapshotInterval)
+		defer ticker.Stop()
+		snapshotC = ticker.C
+	}
+	if p.config.Fsync == FsyncEverySec {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		syncC = ticker.C
+	}
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-snapshotC:
+			err := s.Snapshot()
+			if err != nil {
+				p.logger.Error("memory storage snapshot failed", "error", err)
+			}
+		case <-syncC:
+			s.mu.RLock()
+			err := p.wal.Sync()
+			s.mu.RUnlock()
+			if err != nil {
+				p.logger.Error("memory storage wal sync failed", "error", err)
+			}
+		}
+	}
+}
+
+// apply changes the state and collects the record for the write-ahead log.
+// Must be called with the write lock held.
+func (s *InMemoryStorage) apply(record walRecord) {
+	if s.persistence != nil {
+		s.persistence.undo = append(s.persistence.undo, s.undoRecord(record))
+		s.persistence.pending = append(s.persistence.pending, record)
+	}
+
+	s.applyRecord(record)
+}
+
+// commit writes the records collected by the current call to the write-ahead log as one entry.
+// When the call or the write failed, the records are dropped and the state they changed is restored,
+// a partially written entry is cut off the log. Must be called with the write lock held.
+func (s *InMemoryStorage) commit(err error) error {
+	p := s.persistence
+	if p == nil {
+		return err
+	}
+
+	pending, undo := p.pending, p.undo
+	p.pending, p.undo = nil, nil
+
+	if err != nil {
+		s.rollback(undo)
+		return err
+	}
+	if len(pending) == 0 || p.wal == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(walEntry{Seq: p.seq + 1, Records: pending})
+	if err != nil {
+		s.rollback(undo)
+		return err
+	}
+
+	offset, err := p.wal.Seek(0, io.SeekEnd)
+	if err != nil {
+		s.rollback(undo)
+		return fmt.Errorf("write-ahead log: %w", err)
+	}
+
+	_, err = p.wal.Write(append(data, '\n'))
+	if err == nil && p.config.Fsync == FsyncAlways {
+		err = p.wal.Sync()
+	}
+	if err != nil {
+		s.rollback(undo)
+		truncateErr := p.wal.Truncate(offset)
+		if truncateErr != nil {
+			return fmt.Errorf("write-ahead log: %w: %w", err, truncateErr)
+		}
+		return fmt.Errorf("write-ahead log: %w", err)
+	}
+	p.seq++
+
+	return nil
+}
+
+// discard drops the records collected by the current call, the state is restored by the caller.
+func (s *InMemoryStorage) discard() {
+	if s.persistence != nil {
+		s.persistence.pending = nil
+		s.persistence.undo = nil
+	}
+}
+
+// rollback restores the state changed by the records of the current call, the latest change first.
+func (s *InMemoryStorage) rollback(undo []func()) {
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+}
+
+// undoRecord captures the state the record is about to change and returns the function restoring it.
+func (s *InMemoryStorage) undoRecord(record walRecord) func() {
+	switch record.Type {
+	case recordPutEvent, recordDeleteEvent:
+		eventID := record.ID
+		if record.Event != nil {
+			eventID = record.Event.ID
+		}
+		event, ok := s.data[eventID]
+
+		return func() {
+			if ok {
+				s.applyRecord(walRecord{Type: recordPutEvent, Event: &event})
+				return
+			}
+			s.applyRecord(walRecord{Type: recordDeleteEvent, ID: eventID})
+		}
+	case recordAddHistory, recordDeleteHistory:
+		eventID := record.ID
+		if record.History != nil {
+			eventID = record.History.EventID
+		}
+		history, ok := s.history[eventID]
+		lastHistoryID := s.lastHistoryID
+
+		return func() {
+			s.lastHistoryID = lastHistoryID
+			if ok {
+				s.history[eventID] = history
+				return
+			}
+			delete(s.history, eventID)
+		}
+	case recordPutCalendar, recordDeleteCalendar:
+		calendarID := record.ID
+		if record.Calendar != nil {
+			calendarID = record.Calendar.ID
+		}
+		calendar, ok := s.calendars[calendarID]
+		grants, hasGrants := s.grants[calendarID]
+
+		return func() {
+			if ok {
+				s.calendars[calendarID] = calendar
+			} else {
+				delete(s.calendars, calendarID)
+			}
+			if hasGrants {
+				s.grants[calendarID] = grants
+			} else {
+				delete(s.grants, calendarID)
+			}
+		}
+	case recordPutGrant, recordDeleteGrant:
+		grant := *record.Grant
+		permission, ok := s.grants[grant.CalendarID][grant.UserID]
+
+		return func() {
+			if ok {
+				s.applyRecord(walRecord{Type: recordPutGrant, Grant: &storage.CalendarGrant{
+					CalendarID: grant.CalendarID,
+					UserID:     grant.UserID,
+					Permission: permission,
+				}})
+				return
+			}
+			delete(s.grants[grant.CalendarID], grant.UserID)
+		}
+	case recordPutTag, recordDeleteTag:
+		tag, ok := s.tags[record.Tag.OwnerID][record.Tag.Name]
+		ownerID, name := record.Tag.OwnerID, record.Tag.Name
+
+		return func() {
+			if ok {
+				s.applyRecord(walRecord{Type: recordPutTag, Tag: &tag})
+				return
+			}
+			delete(s.tags[ownerID], name)
+		}
+	}
+
+	return func() {}
+}
+
+func (s *InMemoryStorage) applyRecord(record walRecord) {
+	switch record.Type {
+	case recordPutEvent:
+		if savedEvent, ok := s.data[record.Event.ID]; ok {
+			s.index.remove(savedEvent)
+		}
+		s.data[record.Event.ID] = *record.Event
+		s.index.insert(*record.Event)
+	case recordDeleteEvent:
+		if savedEvent, ok := s.data[record.ID]; ok {
+			s.index.remove(savedEvent)
+			delete(s.data, record.ID)
+		}
+	case recordAddHistory:
+		s.history[record.History.EventID] = append(s.history[record.History.EventID], *record.History)
+		if record.History.ID > s.lastHistoryID {
+			s.lastHistoryID = record.History.ID
+		}
+	case recordDeleteHistory:
+		delete(s.history, record.ID)
+	case recordPutCalendar:
+		s.calendars[record.Calendar.ID] = *record.Calendar
+	case recordDeleteCalendar:
+		delete(s.grants, record.ID)
+		delete(s.calendars, record.ID)
+	case recordPutGrant:
+		if s.grants[record.Grant.CalendarID] == nil {
+			s.grants[record.Grant.CalendarID] = map[int]storage.CalendarPermission{}
+		}
+		s.grants[record.Grant.CalendarID][record.Grant.UserID] = record.Grant.Permission
+	case recordDeleteGrant:
+		delete(s.grants[record.Grant.CalendarID], record.Grant.UserID)
+	case recordPutTag:
+		if s.tags[record.Tag.OwnerID] == nil {
+			s.tags[record.Tag.OwnerID] = map[string]storage.Tag{}
+		}
+		s.tags[record.Tag.OwnerID][record.Tag.Name] = *record.Tag
+	case recordDeleteTag:
+		delete(s.tags[record.Tag.OwnerID], record.Tag.Name)
+	}
+}
+
+func (s *InMemoryStorage) buildSnapshot() snapshot {
+	snap := snapshot{
+		Seq:           s.persistence.seq,
+		LastHistoryID: s.lastHistoryID,
+		Events:        make([]inMemoryEvent, 0, len(s.data)),
+		History:       []storage.EventHistoryRecord{},
+		Calendars:     make([]storage.Calendar, 0, len(s.calendars)),
+		Grants:        []storage.CalendarGrant{},
+		Tags:          []storage.Tag{},
+	}
+
+	for _, event := range s.data {
+		snap.Events = append(snap.Events, event)
+	}
+	for _, records := range s.history {
+		snap.History = append(snap.History, records...)
+	}
+	for _, calendar := range s.calendars {
+		snap.Calendars = append(snap.Calendars, calendar)
+	}
+	for calendarID, grants := range s.grants {
+		for userID, permission := range grants {
+			snap.Grants = append(snap.Grants, storage.CalendarGrant{
+				CalendarID: calendarID,
+				UserID:     userID,
+				Permission: permission,
+			})
+		}
+	}
+	for _, tags := range s.tags {
+		for _, tag := range tags {
+			snap.Tags = append(snap.Tags, tag)
+		}
+	}
+
+	return snap
+}
+
+func (s *InMemoryStorage) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.persistence.config.Dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	err = json.Unmarshal(data, &snap)
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+
+	s.persistence.seq = snap.Seq
+	s.lastHistoryID = snap.LastHistoryID
+
+	for i := range snap.Events {
+		s.applyRecord(walRecord{Type: recordPutEvent, Event: &snap.Events[i]})
+	}
+	// history records of an event keep their order, sort by id to be independent of the map order
+	sortHistory(snap.History)
+	for i := range snap.History {
+		s.applyRecord(walRecord{Type: recordAddHistory, History: &snap.History[i]})
+	}
+	for i := range snap.Calendars {
+		s.applyRecord(walRecord{Type: recordPutCalendar, Calendar: &snap.Calendars[i]})
+	}
+	for i := range snap.Grants {
+		s.applyRecord(walRecord{Type: recordPutGrant, Grant: &snap.Grants[i]})
+	}
+	for i := range snap.Tags {
+		s.applyRecord(walRecord{Type: recordPutTag, Tag: &snap.Tags[i]})
+	}
+
+	return nil
+}
+
+func (s *InMemoryStorage) replayWAL() error {
+	p := s.persistence
+
+	_, err := p.wal.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(p.wal)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if errors.Is(readErr, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				// the last entry was not written completely
+				return p.wal.Truncate(offset)
+			}
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+
+		var entry walEntry
+		err = json.Unmarshal(line, &entry)
+		if err != nil {
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				return p.wal.Truncate(offset)
+			}
+			return fmt.Errorf("%w: offset %d: %s", ErrCorruptedWAL, offset, err.Error())
+		}
+		offset += int64(len(line))
+
+		if entry.Seq <= p.seq {
+			continue
+		}
+
+		for _, record := range entry.Records {
+			s.applyRecord(record)
+		}
+		p.seq = entry.Seq
+	}
+}
+
+func sortHistory(records []storage.EventHistoryRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
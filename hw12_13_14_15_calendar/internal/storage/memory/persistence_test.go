@@ -0,0 +1,280 @@
+package memorystorage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+type testLogger struct{}
+
+func (testLogger) Error(_ string, _ ...any) {}
+
+func openPersistent(t *testing.T, dir string) *InMemoryStorage {
+	t.Helper()
+
+	store := NewPersistent(testLogger{}, PersistenceConfig{Dir: dir, Fsync: FsyncAlways})
+	require.NoError(t, store.Open(context.Background()))
+
+	return store
+}
+
+// crash stops the storage without the final snapshot.
+func crash(t *testing.T, store *InMemoryStorage) {
+	t.Helper()
+
+	close(store.persistence.done)
+	store.persistence.wg.Wait()
+	require.NoError(t, store.persistence.wal.Close())
+	store.persistence.wal = nil
+}
+
+// failingWAL writes the first half of the data and fails.
+type failingWAL struct {
+	walFile
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (f failingWAL) Write(p []byte) (int, error) {
+	n, err := f.walFile.Write(p[:len(p)/2])
+	if err != nil {
+		return n, err
+	}
+	return n, errDiskFull
+}
+
+func TestPersistentStorageReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := identity.WithUserID(context.Background(), 7)
+	startDate := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	store := openPersistent(t, dir)
+
+	require.NoError(t, store.CreateCalendar(ctx, storage.Calendar{ID: "work", OwnerID: 7, Name: "Work"}))
+	require.NoError(t, store.SaveCalendarGrant(ctx, storage.CalendarGrant{
+		CalendarID: "work",
+		UserID:     8,
+		Permission: storage.CalendarPermissionRead,
+	}))
+	require.NoError(t, store.SaveTag(ctx, storage.Tag{OwnerID: 7, Name: "urgent", Color: "#ff0000"}))
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{
+		ID:           "1",
+		CalendarID:   "work",
+		Title:        "Planning",
+		Location:     "Room 1",
+		URL:          "https://meet.example.com/planning",
+		StartDate:    startDate,
+		EndDate:      startDate.Add(time.Hour),
+		CreatorID:    7,
+		NotifyBefore: time.Hour,
+		Tags:         []string{"urgent"},
+	}))
+	require.NoError(t, store.UpdateEvent(ctx, "1", storage.Event{
+		ID:         "1",
+		CalendarID: "work",
+		Title:      "Sprint planning",
+		Location:   "Room 2",
+		StartDate:  startDate,
+		EndDate:    startDate.Add(2 * time.Hour),
+		CreatorID:  7,
+		CreatedAt:  startDate.Add(-time.Hour),
+		UpdatedAt:  startDate,
+	}))
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "2", Title: "Deleted"}))
+	require.NoError(t, store.DeleteEvent(ctx, "2"))
+
+	event, err := store.GetEvent(ctx, "1")
+	require.NoError(t, err)
+	history, err := store.GetEventHistory(ctx, "1")
+	require.NoError(t, err)
+	calendars, err := store.GetUserCalendars(ctx, 8)
+	require.NoError(t, err)
+	tags, err := store.GetUserTags(ctx, 7)
+	require.NoError(t, err)
+
+	// reopen from the log only, the way it is after a crash
+	crash(t, store)
+
+	restored := openPersistent(t, dir)
+
+	restoredEvent, err := restored.GetEvent(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, event, restoredEvent)
+
+	_, err = restored.GetEvent(ctx, "2")
+	require.ErrorIs(t, err, storage.ErrReadEventNotExists)
+
+	restoredHistory, err := restored.GetEventHistory(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, history, restoredHistory)
+
+	restoredCalendars, err := restored.GetUserCalendars(ctx, 8)
+	require.NoError(t, err)
+	require.Equal(t, calendars, restoredCalendars)
+
+	restoredTags, err := restored.GetUserTags(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, tags, restoredTags)
+
+	// history ids continue after the restored ones
+	require.NoError(t, restored.DeleteEvent(ctx, "1"))
+	restoredHistory, err = restored.GetEventHistory(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, restoredHistory, 3)
+	require.Greater(t, restoredHistory[2].ID, restoredHistory[1].ID)
+
+	require.NoError(t, restored.Close(ctx))
+}
+
+func TestPersistentStorageSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := openPersistent(t, dir)
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "1", Title: "Before snapshot"}))
+	require.NoError(t, store.Snapshot())
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "2", Title: "After snapshot"}))
+	require.NoError(t, store.Close(ctx))
+
+	restored := openPersistent(t, dir)
+	defer restored.Close(ctx)
+
+	events, err := restored.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	history, err := restored.GetEventHistory(ctx, "2")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+}
+
+func TestPersistentStorageTornWAL(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := openPersistent(t, dir)
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "1", Title: "Saved"}))
+	crash(t, store)
+
+	walPath := filepath.Join(dir, walFileName)
+	f, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":2,"records":[{"type":"put_ev`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restored := openPersistent(t, dir)
+
+	_, err = restored.GetEvent(ctx, "1")
+	require.NoError(t, err)
+
+	require.NoError(t, restored.CreateEvent(ctx, storage.Event{ID: "2", Title: "After crash"}))
+	require.NoError(t, restored.Close(ctx))
+
+	restored = openPersistent(t, dir)
+	defer restored.Close(ctx)
+
+	_, err = restored.GetEvent(ctx, "2")
+	require.NoError(t, err)
+}
+
+func TestPersistentStorageCorruptedWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(
+		filepath.Join(dir, walFileName),
+		[]byte("not json\n"+`{"seq":1,"records":[]}`+"\n"),
+		0o600,
+	)
+	require.NoError(t, err)
+
+	store := NewPersistent(testLogger{}, PersistenceConfig{Dir: dir, Fsync: FsyncNever})
+	err = store.Open(context.Background())
+	require.ErrorIs(t, err, ErrCorruptedWAL)
+}
+
+func TestPersistentStorageAbortedBatch(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := openPersistent(t, dir)
+	results, err := store.ApplyBatch(ctx, storage.BatchModeAllOrNothing, []storage.BatchOperation{
+		{Type: storage.BatchOperationCreate, Event: storage.Event{ID: "1", Title: "Rolled back"}},
+		{Type: storage.BatchOperationUpdate, EventID: "unknown", Event: storage.Event{Title: "Fails"}},
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, storage.ErrBatchAborted)
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	require.NoError(t, store.Close(ctx))
+}
+
+func TestPersistentStorageFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := openPersistent(t, dir)
+	require.NoError(t, store.CreateCalendar(ctx, storage.Calendar{ID: "work", OwnerID: 1, Name: "Work"}))
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "1", CalendarID: "work", Title: "Saved"}))
+
+	wal := store.persistence.wal
+	store.persistence.wal = failingWAL{wal}
+
+	err := store.CreateEvent(ctx, storage.Event{ID: "2", Title: "Lost"})
+	require.ErrorIs(t, err, errDiskFull)
+	err = store.UpdateEvent(ctx, "1", storage.Event{ID: "1", CalendarID: "work", Title: "Lost"})
+	require.ErrorIs(t, err, errDiskFull)
+	err = store.DeleteCalendar(ctx, "work")
+	require.ErrorIs(t, err, errDiskFull)
+
+	// the failed calls leave neither the state nor the log changed
+	_, err = store.GetEvent(ctx, "2")
+	require.ErrorIs(t, err, storage.ErrReadEventNotExists)
+	event, err := store.GetEvent(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "Saved", event.Title)
+	history, err := store.GetEventHistory(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	_, err = store.GetCalendar(ctx, "work")
+	require.NoError(t, err)
+
+	store.persistence.wal = wal
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "3", Title: "After failure"}))
+	crash(t, store)
+
+	restored := openPersistent(t, dir)
+	defer restored.Close(ctx)
+
+	events, err := restored.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	history, err = restored.GetEventHistory(ctx, "3")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Greater(t, history[0].ID, int64(1))
+}
+
+func TestPersistentStorageUnknownFsync(t *testing.T) {
+	store := NewPersistent(testLogger{}, PersistenceConfig{Dir: t.TempDir(), Fsync: "sometimes"})
+
+	err := store.Open(context.Background())
+	require.ErrorIs(t, err, ErrUnknownFsyncPolicy)
+}
@@ -2,74 +2,160 @@ package memorystorage
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
 )
 
 type inMemoryEvent struct {
-	ID           string
-	Title        string
-	Description  string
-	StartDate    time.Time
-	EndDate      time.Time
-	CreatorID    int
-	NotifyBefore time.Duration
+	ID           string        `json:"id"`
+	CalendarID   string        `json:"calendarId"`
+	Title        string        `json:"title"`
+	Description  string        `json:"description"`
+	Location     string        `json:"location"`
+	URL          string        `json:"url"`
+	StartDate    time.Time     `json:"startDate"`
+	EndDate      time.Time     `json:"endDate"`
+	CreatorID    int           `json:"creatorId"`
+	NotifyBefore time.Duration `json:"notifyBefore"`
+	Notified     bool          `json:"notified"`
+	Tags         []string      `json:"tags"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
 }
 
 type InMemoryStorage struct {
-	mu   sync.RWMutex
-	data map[string]inMemoryEvent
+	mu            sync.RWMutex
+	data          map[string]inMemoryEvent
+	index         *eventIndex
+	history       map[string][]storage.EventHistoryRecord
+	lastHistoryID int64
+	calendars     map[string]storage.Calendar
+	grants        map[string]map[int]storage.CalendarPermission
+	tags          map[int]map[string]storage.Tag
+	persistence   *persistence
 }
 
 func New() *InMemoryStorage {
 	return &InMemoryStorage{
-		data: map[string]inMemoryEvent{},
+		data:      map[string]inMemoryEvent{},
+		index:     newEventIndex(),
+		history:   map[string][]storage.EventHistoryRecord{},
+		calendars: map[string]storage.Calendar{},
+		grants:    map[string]map[int]storage.CalendarPermission{},
+		tags:      map[int]map[string]storage.Tag{},
 	}
 }
 
-func (s *InMemoryStorage) CreateEvent(_ context.Context, event storage.Event) error {
+// Ping always succeeds, the state is in the memory of the process.
+func (s *InMemoryStorage) Ping(_ context.Context) error {
+	return nil
+}
+
+func (s *InMemoryStorage) CreateEvent(ctx context.Context, event storage.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, ok := s.data[event.ID]
-	if ok {
-		return storage.ErrCreateEventIDExists
-	}
+	return s.commit(s.createEvent(ctx, event))
+}
 
-	s.data[event.ID] = buildInMemoryEvent(event)
+func (s *InMemoryStorage) UpdateEvent(ctx context.Context, eventID string, event storage.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return nil
+	return s.commit(s.updateEvent(ctx, eventID, event))
 }
 
-func (s *InMemoryStorage) UpdateEvent(_ context.Context, eventID string, event storage.Event) error {
+func (s *InMemoryStorage) DeleteEvent(ctx context.Context, eventID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	savedEvent, ok := s.data[eventID]
-	if !ok {
-		return storage.ErrUpdateEventIDNotExists
+	return s.commit(s.deleteEvent(ctx, eventID))
+}
+
+func (s *InMemoryStorage) ApplyBatch(
+	ctx context.Context,
+	mode storage.BatchMode,
+	operations []storage.BatchOperation,
+) ([]storage.BatchResult, error) {
+	if mode != storage.BatchModeAllOrNothing && mode != storage.BatchModeBestEffort {
+		return nil, storage.ErrBatchUnknownMode
 	}
 
-	savedEvent = patchEventData(savedEvent, event)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	s.data[eventID] = savedEvent
-	return nil
+	results := make([]storage.BatchResult, 0, len(operations))
+	undo := make([]batchUndoEntry, 0, len(operations))
+	failed := false
+
+	for i := range operations {
+		eventID := operations[i].EventID
+		if operations[i].Type == storage.BatchOperationCreate {
+			eventID = operations[i].Event.ID
+		}
+
+		if mode == storage.BatchModeAllOrNothing && failed {
+			results = append(results, storage.BatchResult{Index: i, EventID: eventID, Err: storage.ErrBatchAborted})
+			continue
+		}
+
+		undo = append(undo, s.newBatchUndoEntry(eventID))
+
+		var err error
+		switch operations[i].Type {
+		case storage.BatchOperationCreate:
+			err = s.createEvent(ctx, operations[i].Event)
+		case storage.BatchOperationUpdate:
+			err = s.updateEvent(ctx, eventID, operations[i].Event)
+		case storage.BatchOperationDelete:
+			err = s.deleteEvent(ctx, eventID)
+		default:
+			err = storage.ErrBatchUnknownOperation
+		}
+
+		results = append(results, storage.BatchResult{Index: i, EventID: eventID, Err: err})
+		failed = failed || err != nil
+	}
+
+	if mode == storage.BatchModeAllOrNothing && failed {
+		for i := len(undo) - 1; i >= 0; i-- {
+			s.restoreBatchUndoEntry(undo[i])
+		}
+		storage.AbortBatchResults(results)
+		s.discard()
+
+		return results, nil
+	}
+
+	err := s.commit(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
 }
 
-func (s *InMemoryStorage) DeleteEvent(_ context.Context, eventID string) error {
+func (s *InMemoryStorage) GetEventHistory(_ context.Context, eventID string) ([]storage.EventHistoryRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]storage.EventHistoryRecord, len(s.history[eventID]))
+	copy(records, s.history[eventID])
+
+	return records, nil
+}
+
+func (s *InMemoryStorage) DeleteEventHistory(_ context.Context, eventID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, ok := s.data[eventID]
-	if !ok {
-		return nil
-	}
-
-	delete(s.data, eventID)
+	s.apply(walRecord{Type: recordDeleteHistory, ID: eventID})
 
-	return nil
+	return s.commit(nil)
 }
 
 func (s *InMemoryStorage) GetEvent(_ context.Context, eventID string) (storage.Event, error) {
@@ -84,34 +170,52 @@ func (s *InMemoryStorage) GetEvent(_ context.Context, eventID string) (storage.E
 	return buildStorageEvent(savedEvent), nil
 }
 
-func (s *InMemoryStorage) GetEventsListByDates(_ context.Context, from *time.Time, to *time.Time) []storage.Event {
+func (s *InMemoryStorage) CountUserEvents(_ context.Context, creatorID int) (int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	events := []storage.Event{}
-
+	count := 0
 	for _, event := range s.data {
-		if from != nil && event.EndDate.Before(*from) {
-			continue
+		if event.CreatorID == creatorID {
+			count++
 		}
+	}
 
-		if to != nil && event.StartDate.After(*to) {
-			continue
-		}
+	return count, nil
+}
 
-		events = append(events, buildStorageEvent(event))
-	}
+func (s *InMemoryStorage) GetEventsListByDates(
+	_ context.Context,
+	from *time.Time,
+	to *time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []storage.Event{}
 
-	return events
+	s.index.overlapping(from, to, func(eventID string) {
+		event := s.data[eventID]
+		if filter.MatchCalendar(event.CalendarID, event.CreatorID) && filter.MatchTags(event.Tags) {
+			events = append(events, buildStorageEvent(event))
+		}
+	})
+
+	return events, nil
 }
 
-func (s *InMemoryStorage) GetEventsForNotify(_ context.Context, notifyDate string) []storage.Event {
+func (s *InMemoryStorage) GetEventsForNotify(_ context.Context, notifyDate string) ([]storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	events := []storage.Event{}
 
 	for _, event := range s.data {
+		if event.Notified {
+			continue
+		}
+
 		dateForNotify := event.EndDate.Add(-event.NotifyBefore)
 		dateForNotifyStr := dateForNotify.Format("2006-01-02")
 		if dateForNotifyStr != notifyDate {
@@ -121,27 +225,34 @@ func (s *InMemoryStorage) GetEventsForNotify(_ context.Context, notifyDate strin
 		events = append(events, buildStorageEvent(event))
 	}
 
-	return events
+	return events, nil
 }
 
-func (s *InMemoryStorage) GetEventsOnDate(_ context.Context, date time.Time) []storage.Event {
+func (s *InMemoryStorage) GetEventsOnDate(
+	_ context.Context,
+	date time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	events := []storage.Event{}
 
-	for _, event := range s.data {
-		if !event.StartDate.Equal(date) {
-			continue
+	s.index.startingBetween(date, date, func(eventID string) {
+		event := s.data[eventID]
+		if filter.MatchCalendar(event.CalendarID, event.CreatorID) && filter.MatchTags(event.Tags) {
+			events = append(events, buildStorageEvent(event))
 		}
+	})
 
-		events = append(events, buildStorageEvent(event))
-	}
-
-	return events
+	return events, nil
 }
 
-func (s *InMemoryStorage) GetEventsOnWeek(_ context.Context, weekStartDate time.Time) []storage.Event {
+func (s *InMemoryStorage) GetEventsOnWeek(
+	_ context.Context,
+	weekStartDate time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -149,17 +260,25 @@ func (s *InMemoryStorage) GetEventsOnWeek(_ context.Context, weekStartDate time.
 
 	events := []storage.Event{}
 
-	for _, event := range s.data {
-		if (event.StartDate.Equal(weekStartDate) || event.StartDate.After(weekStartDate)) &&
-			(event.EndDate.Equal(weekEndDate) || event.EndDate.Before(weekEndDate)) {
+	s.index.startingBetween(weekStartDate, weekEndDate, func(eventID string) {
+		event := s.data[eventID]
+		if !filter.MatchCalendar(event.CalendarID, event.CreatorID) || !filter.MatchTags(event.Tags) {
+			return
+		}
+
+		if event.EndDate.Equal(weekEndDate) || event.EndDate.Before(weekEndDate) {
 			events = append(events, buildStorageEvent(event))
 		}
-	}
+	})
 
-	return events
+	return events, nil
 }
 
-func (s *InMemoryStorage) GetEventsOnMonth(_ context.Context, monthStartDate time.Time) []storage.Event {
+func (s *InMemoryStorage) GetEventsOnMonth(
+	_ context.Context,
+	monthStartDate time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -167,47 +286,341 @@ func (s *InMemoryStorage) GetEventsOnMonth(_ context.Context, monthStartDate tim
 
 	events := []storage.Event{}
 
-	for _, event := range s.data {
-		if (event.StartDate.Equal(monthStartDate) || event.StartDate.After(monthStartDate)) &&
-			(event.EndDate.Equal(monthEndDate) || event.EndDate.Before(monthEndDate)) {
+	s.index.startingBetween(monthStartDate, monthEndDate, func(eventID string) {
+		event := s.data[eventID]
+		if !filter.MatchCalendar(event.CalendarID, event.CreatorID) || !filter.MatchTags(event.Tags) {
+			return
+		}
+
+		if event.EndDate.Equal(monthEndDate) || event.EndDate.Before(monthEndDate) {
 			events = append(events, buildStorageEvent(event))
 		}
+	})
+
+	return events, nil
+}
+
+func (s *InMemoryStorage) CreateCalendar(_ context.Context, calendar storage.Calendar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.calendars[calendar.ID]
+	if ok {
+		return storage.ErrCreateCalendarIDExists
+	}
+
+	s.apply(walRecord{Type: recordPutCalendar, Calendar: &calendar})
+
+	return s.commit(nil)
+}
+
+func (s *InMemoryStorage) GetCalendar(_ context.Context, calendarID string) (storage.Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendar, ok := s.calendars[calendarID]
+	if !ok {
+		return storage.Calendar{}, storage.ErrCalendarNotExists
+	}
+
+	return calendar, nil
+}
+
+func (s *InMemoryStorage) GetUserCalendars(_ context.Context, userID int) ([]storage.UserCalendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendars := []storage.UserCalendar{}
+	for _, calendar := range s.calendars {
+		if calendar.OwnerID == userID {
+			calendars = append(calendars, storage.UserCalendar{
+				Calendar:   calendar,
+				Permission: storage.CalendarPermissionOwner,
+			})
+			continue
+		}
+
+		permission, ok := s.grants[calendar.ID][userID]
+		if ok {
+			calendars = append(calendars, storage.UserCalendar{
+				Calendar:   calendar,
+				Permission: permission,
+			})
+		}
 	}
 
-	return events
+	sort.Slice(calendars, func(i, j int) bool {
+		return calendars[i].Name < calendars[j].Name
+	})
+
+	return calendars, nil
+}
+
+func (s *InMemoryStorage) DeleteCalendar(ctx context.Context, calendarID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.calendars[calendarID]
+	if !ok {
+		return nil
+	}
+
+	for eventID, event := range s.data {
+		if event.CalendarID != calendarID {
+			continue
+		}
+
+		err := s.deleteEvent(ctx, eventID)
+		if err != nil {
+			return s.commit(err)
+		}
+	}
+
+	s.apply(walRecord{Type: recordDeleteCalendar, ID: calendarID})
+
+	return s.commit(nil)
+}
+
+func (s *InMemoryStorage) SaveCalendarGrant(_ context.Context, grant storage.CalendarGrant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.calendars[grant.CalendarID]
+	if !ok {
+		return storage.ErrCalendarNotExists
+	}
+
+	s.apply(walRecord{Type: recordPutGrant, Grant: &grant})
+
+	return s.commit(nil)
+}
+
+func (s *InMemoryStorage) DeleteCalendarGrant(_ context.Context, calendarID string, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.grants[calendarID][userID]
+	if !ok {
+		return storage.ErrCalendarGrantNotExists
+	}
+
+	s.apply(walRecord{Type: recordDeleteGrant, Grant: &storage.CalendarGrant{CalendarID: calendarID, UserID: userID}})
+
+	return s.commit(nil)
+}
+
+func (s *InMemoryStorage) GetCalendarGrants(_ context.Context, calendarID string) ([]storage.CalendarGrant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	grants := []storage.CalendarGrant{}
+	for userID, permission := range s.grants[calendarID] {
+		grants = append(grants, storage.CalendarGrant{
+			CalendarID: calendarID,
+			UserID:     userID,
+			Permission: permission,
+		})
+	}
+
+	sort.Slice(grants, func(i, j int) bool {
+		return grants[i].UserID < grants[j].UserID
+	})
+
+	return grants, nil
+}
+
+func (s *InMemoryStorage) SaveTag(_ context.Context, tag storage.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(walRecord{Type: recordPutTag, Tag: &tag})
+
+	return s.commit(nil)
+}
+
+func (s *InMemoryStorage) DeleteTag(_ context.Context, ownerID int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.tags[ownerID][name]
+	if !ok {
+		return storage.ErrTagNotExists
+	}
+
+	s.apply(walRecord{Type: recordDeleteTag, Tag: &storage.Tag{OwnerID: ownerID, Name: name}})
+
+	return s.commit(nil)
+}
+
+func (s *InMemoryStorage) GetUserTags(_ context.Context, ownerID int) ([]storage.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := []storage.Tag{}
+	for _, tag := range s.tags[ownerID] {
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+// createEvent, updateEvent, deleteEvent and addHistoryRecord must be called with the write lock held.
+func (s *InMemoryStorage) createEvent(ctx context.Context, event storage.Event) error {
+	_, ok := s.data[event.ID]
+	if ok {
+		return storage.ErrCreateEventIDExists
+	}
+
+	savedEvent := buildInMemoryEvent(event)
+	s.apply(walRecord{Type: recordPutEvent, Event: &savedEvent})
+
+	after := buildStorageEvent(savedEvent)
+	s.addHistoryRecord(ctx, event.ID, storage.EventActionCreate, nil, &after)
+
+	return nil
+}
+
+func (s *InMemoryStorage) updateEvent(ctx context.Context, eventID string, event storage.Event) error {
+	savedEvent, ok := s.data[eventID]
+	if !ok {
+		return storage.ErrUpdateEventIDNotExists
+	}
+
+	before := buildStorageEvent(savedEvent)
+
+	savedEvent = patchEventData(savedEvent, event)
+
+	s.apply(walRecord{Type: recordPutEvent, Event: &savedEvent})
+
+	after := buildStorageEvent(savedEvent)
+	s.addHistoryRecord(ctx, eventID, storage.EventActionUpdate, &before, &after)
+
+	return nil
+}
+
+func (s *InMemoryStorage) deleteEvent(ctx context.Context, eventID string) error {
+	savedEvent, ok := s.data[eventID]
+	if !ok {
+		return nil
+	}
+
+	s.apply(walRecord{Type: recordDeleteEvent, ID: eventID})
+
+	before := buildStorageEvent(savedEvent)
+	s.addHistoryRecord(ctx, eventID, storage.EventActionDelete, &before, nil)
+
+	return nil
+}
+
+func (s *InMemoryStorage) addHistoryRecord(
+	ctx context.Context,
+	eventID string,
+	action storage.EventAction,
+	before *storage.Event,
+	after *storage.Event,
+) {
+	record := storage.NewEventHistoryRecord(eventID, action, identity.UserID(ctx), time.Now().UTC(), before, after)
+
+	record.ID = s.lastHistoryID + 1
+
+	s.apply(walRecord{Type: recordAddHistory, History: &record})
+}
+
+type batchUndoEntry struct {
+	eventID    string
+	event      inMemoryEvent
+	existed    bool
+	historyLen int
+}
+
+func (s *InMemoryStorage) newBatchUndoEntry(eventID string) batchUndoEntry {
+	event, existed := s.data[eventID]
+
+	return batchUndoEntry{
+		eventID:    eventID,
+		event:      event,
+		existed:    existed,
+		historyLen: len(s.history[eventID]),
+	}
+}
+
+func (s *InMemoryStorage) restoreBatchUndoEntry(entry batchUndoEntry) {
+	if entry.existed {
+		s.applyRecord(walRecord{Type: recordPutEvent, Event: &entry.event})
+	} else {
+		s.applyRecord(walRecord{Type: recordDeleteEvent, ID: entry.eventID})
+	}
+
+	if entry.historyLen == 0 {
+		delete(s.history, entry.eventID)
+		return
+	}
+	s.history[entry.eventID] = s.history[entry.eventID][:entry.historyLen]
 }
 
 func buildStorageEvent(event inMemoryEvent) storage.Event {
 	return storage.Event{
 		ID:           event.ID,
+		CalendarID:   event.CalendarID,
 		Title:        event.Title,
 		Description:  event.Description,
+		Location:     event.Location,
+		URL:          event.URL,
 		StartDate:    event.StartDate,
 		EndDate:      event.EndDate,
 		CreatorID:    event.CreatorID,
 		NotifyBefore: event.NotifyBefore,
+		Notified:     event.Notified,
+		Tags:         copyTags(event.Tags),
+		CreatedAt:    event.CreatedAt,
+		UpdatedAt:    event.UpdatedAt,
 	}
 }
 
 func buildInMemoryEvent(event storage.Event) inMemoryEvent {
 	return inMemoryEvent{
 		ID:           event.ID,
+		CalendarID:   event.CalendarID,
 		Title:        event.Title,
 		Description:  event.Description,
+		Location:     event.Location,
+		URL:          event.URL,
 		StartDate:    event.StartDate,
 		EndDate:      event.EndDate,
 		CreatorID:    event.CreatorID,
 		NotifyBefore: event.NotifyBefore,
+		Notified:     event.Notified,
+		Tags:         copyTags(event.Tags),
+		CreatedAt:    event.CreatedAt,
+		UpdatedAt:    event.UpdatedAt,
 	}
 }
 
 func patchEventData(savedEvent inMemoryEvent, event storage.Event) inMemoryEvent {
+	savedEvent.CalendarID = event.CalendarID
 	savedEvent.Title = event.Title
 	savedEvent.Description = event.Description
+	savedEvent.Location = event.Location
+	savedEvent.URL = event.URL
 	savedEvent.StartDate = event.StartDate
 	savedEvent.EndDate = event.EndDate
 	savedEvent.CreatorID = event.CreatorID
 	savedEvent.NotifyBefore = event.NotifyBefore
+	savedEvent.Notified = event.Notified
+	savedEvent.Tags = copyTags(event.Tags)
+	savedEvent.UpdatedAt = event.UpdatedAt
 
 	return savedEvent
 }
+
+func copyTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return append([]string(nil), tags...)
+}
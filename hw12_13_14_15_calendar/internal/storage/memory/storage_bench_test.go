@@ -0,0 +1,135 @@
+package memorystorage
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+const benchEventsCount = 100_000
+
+var benchBaseDate = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// go test -run=^$ -bench=. ./internal/storage/memory
+func newBenchStorage(b *testing.B) *InMemoryStorage {
+	b.Helper()
+
+	rnd := rand.New(rand.NewSource(1))
+	store := New()
+	ctx := context.Background()
+
+	for i := 0; i < benchEventsCount; i++ {
+		startDate := benchBaseDate.Add(time.Duration(rnd.Intn(5*365*24)) * time.Hour)
+		err := store.CreateEvent(ctx, storage.Event{
+			ID:        strconv.Itoa(i),
+			Title:     "Bench",
+			StartDate: startDate,
+			EndDate:   startDate.Add(time.Duration(1+rnd.Intn(72)) * time.Hour),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return store
+}
+
+// scanEventsListByDates is the full scan the index replaced, kept to compare with.
+func scanEventsListByDates(s *InMemoryStorage, from time.Time, to time.Time) []storage.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []storage.Event{}
+	for _, event := range s.data {
+		if event.EndDate.Before(from) || event.StartDate.After(to) {
+			continue
+		}
+
+		events = append(events, buildStorageEvent(event))
+	}
+
+	return events
+}
+
+func scanEventsOnMonth(s *InMemoryStorage, monthStartDate time.Time) []storage.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	monthEndDate := monthStartDate.AddDate(0, 1, -1)
+
+	events := []storage.Event{}
+	for _, event := range s.data {
+		if (event.StartDate.Equal(monthStartDate) || event.StartDate.After(monthStartDate)) &&
+			(event.EndDate.Equal(monthEndDate) || event.EndDate.Before(monthEndDate)) {
+			events = append(events, buildStorageEvent(event))
+		}
+	}
+
+	return events
+}
+
+func BenchmarkGetEventsListByDates(b *testing.B) {
+	store := newBenchStorage(b)
+	ctx := context.Background()
+	from := benchBaseDate.AddDate(2, 3, 0)
+	to := from.AddDate(0, 0, 7)
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := store.GetEventsListByDates(ctx, &from, &to, storage.EventFilter{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanEventsListByDates(store, from, to)
+		}
+	})
+}
+
+func BenchmarkGetEventsOnMonth(b *testing.B) {
+	store := newBenchStorage(b)
+	ctx := context.Background()
+	monthStartDate := benchBaseDate.AddDate(2, 3, 0)
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := store.GetEventsOnMonth(ctx, monthStartDate, storage.EventFilter{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanEventsOnMonth(store, monthStartDate)
+		}
+	})
+}
+
+func BenchmarkCreateEvent(b *testing.B) {
+	store := newBenchStorage(b)
+	ctx := context.Background()
+	rnd := rand.New(rand.NewSource(2))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		startDate := benchBaseDate.Add(time.Duration(rnd.Intn(5*365*24)) * time.Hour)
+		err := store.CreateEvent(ctx, storage.Event{
+			ID:        "new-" + strconv.Itoa(i),
+			StartDate: startDate,
+			EndDate:   startDate.Add(time.Hour),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
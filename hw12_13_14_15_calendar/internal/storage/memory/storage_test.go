@@ -2,10 +2,12 @@ package memorystorage
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
 )
 
@@ -13,8 +15,13 @@ func TestStorageCreate(t *testing.T) {
 	store := New()
 
 	newEvent := storage.Event{
-		ID:    "1",
-		Title: "Test",
+		ID:        "1",
+		Title:     "Test",
+		Location:  "Room 1",
+		URL:       "https://meet.example.com/test",
+		Notified:  true,
+		CreatedAt: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC),
 	}
 	ctx := context.Background()
 
@@ -137,6 +144,22 @@ func TestStorageGet(t *testing.T) {
 	require.Equal(t, storage.ErrReadEventNotExists, err)
 }
 
+func TestStorageCountUserEvents(t *testing.T) {
+	store := New()
+
+	ctx := context.Background()
+	for i, creatorID := range []int{1, 1, 2} {
+		err := store.CreateEvent(ctx, storage.Event{ID: strconv.Itoa(i), CreatorID: creatorID, Title: "Test"})
+		require.NoError(t, err)
+	}
+
+	for creatorID, expected := range map[int]int{1: 2, 2: 1, 3: 0} {
+		count, err := store.CountUserEvents(ctx, creatorID)
+		require.NoError(t, err)
+		require.Equal(t, expected, count)
+	}
+}
+
 func TestStorageGetEventsListByDates(t *testing.T) {
 	store := New()
 
@@ -186,13 +209,15 @@ func TestStorageGetEventsListByDates(t *testing.T) {
 
 	fromDate, _ := time.Parse("2006-01-02", "2024-05-20")
 	toDate, _ := time.Parse("2006-01-02", "2024-05-28")
-	events := store.GetEventsListByDates(ctx, &fromDate, &toDate)
+	events, err := store.GetEventsListByDates(ctx, &fromDate, &toDate, storage.EventFilter{})
+	require.NoError(t, err)
 
 	require.Equal(t, 2, len(events))
-	require.Equal(t, "1", events[0].ID)
-	require.Equal(t, "3", events[1].ID)
+	require.Equal(t, "3", events[0].ID)
+	require.Equal(t, "1", events[1].ID)
 
-	events = store.GetEventsListByDates(ctx, nil, nil)
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{})
+	require.NoError(t, err)
 	require.Equal(t, 3, len(events))
 }
 
@@ -243,15 +268,18 @@ func TestStorageGetEventsForNotify(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	events := store.GetEventsForNotify(ctx, "2024-06-25")
+	events, err := store.GetEventsForNotify(ctx, "2024-06-25")
+	require.NoError(t, err)
 	require.Equal(t, 1, len(events))
 	require.Equal(t, "1", events[0].ID)
 
-	events = store.GetEventsForNotify(ctx, "2024-05-16")
+	events, err = store.GetEventsForNotify(ctx, "2024-05-16")
+	require.NoError(t, err)
 	require.Equal(t, 1, len(events))
 	require.Equal(t, "2", events[0].ID)
 
-	events = store.GetEventsForNotify(ctx, "2024-07-19")
+	events, err = store.GetEventsForNotify(ctx, "2024-07-19")
+	require.NoError(t, err)
 	require.Equal(t, 1, len(events))
 	require.Equal(t, "3", events[0].ID)
 }
@@ -275,7 +303,8 @@ func TestGetEventsOnDate(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	events := store.GetEventsOnWeek(ctx, startDate)
+	events, err := store.GetEventsOnWeek(ctx, startDate, storage.EventFilter{})
+	require.NoError(t, err)
 	require.Equal(t, 1, len(events))
 	require.Equal(t, "1", events[0].ID)
 }
@@ -315,7 +344,8 @@ func TestGetEventsOnWeek(t *testing.T) {
 
 	weekStartDate, _ := time.Parse(time.DateOnly, "2024-06-03")
 
-	events := store.GetEventsOnWeek(ctx, weekStartDate)
+	events, err := store.GetEventsOnWeek(ctx, weekStartDate, storage.EventFilter{})
+	require.NoError(t, err)
 	require.Equal(t, 1, len(events))
 	require.Equal(t, "1", events[0].ID)
 }
@@ -369,6 +399,300 @@ func TestGetEventsOnMonth(t *testing.T) {
 
 	weekStartDate, _ := time.Parse(time.DateOnly, "2024-06-01")
 
-	events := store.GetEventsOnMonth(ctx, weekStartDate)
+	events, err := store.GetEventsOnMonth(ctx, weekStartDate, storage.EventFilter{})
+	require.NoError(t, err)
 	require.Equal(t, 2, len(events))
 }
+
+func TestStorageEventHistory(t *testing.T) {
+	store := New()
+
+	ctx := identity.WithUserID(context.Background(), 42)
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+	event := storage.Event{
+		ID:           "1",
+		Title:        "Test",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	}
+
+	err := store.CreateEvent(ctx, event)
+	require.Nil(t, err)
+
+	event.StartDate = startDate.AddDate(0, 0, 1)
+	err = store.UpdateEvent(ctx, event.ID, event)
+	require.Nil(t, err)
+
+	err = store.DeleteEvent(ctx, event.ID)
+	require.Nil(t, err)
+
+	history, err := store.GetEventHistory(ctx, event.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(history))
+
+	require.Equal(t, storage.EventActionCreate, history[0].Action)
+	require.Nil(t, history[0].Before)
+	require.Equal(t, "Test", history[0].After.Title)
+
+	require.Equal(t, storage.EventActionUpdate, history[1].Action)
+	require.Equal(t, 42, history[1].UserID)
+	require.Equal(t, []storage.EventChange{
+		{
+			Field:  "start_dt",
+			Before: "2024-06-03T00:00:00Z",
+			After:  "2024-06-04T00:00:00Z",
+		},
+	}, history[1].Changes)
+
+	require.Equal(t, storage.EventActionDelete, history[2].Action)
+	require.Nil(t, history[2].After)
+
+	err = store.DeleteEventHistory(ctx, event.ID)
+	require.Nil(t, err)
+
+	history, err = store.GetEventHistory(ctx, event.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(history))
+}
+
+func TestStorageApplyBatch(t *testing.T) {
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+
+	newOperations := func() []storage.BatchOperation {
+		return []storage.BatchOperation{
+			{
+				Type:  storage.BatchOperationCreate,
+				Event: storage.Event{ID: "2", Title: "Test 2", StartDate: startDate, EndDate: endDate},
+			},
+			{
+				Type:    storage.BatchOperationUpdate,
+				EventID: "1",
+				Event:   storage.Event{Title: "Test Test", StartDate: startDate, EndDate: endDate},
+			},
+			{
+				Type:  storage.BatchOperationCreate,
+				Event: storage.Event{ID: "1", Title: "Duplicate", StartDate: startDate, EndDate: endDate},
+			},
+			{
+				Type:    storage.BatchOperationDelete,
+				EventID: "1",
+			},
+		}
+	}
+
+	t.Run("all or nothing", func(t *testing.T) {
+		store := New()
+		ctx := context.Background()
+
+		err := store.CreateEvent(ctx, storage.Event{ID: "1", Title: "Test", StartDate: startDate, EndDate: endDate})
+		require.Nil(t, err)
+
+		results, err := store.ApplyBatch(ctx, storage.BatchModeAllOrNothing, newOperations())
+		require.Nil(t, err)
+		require.Equal(t, 4, len(results))
+		require.False(t, storage.BatchApplied(storage.BatchModeAllOrNothing, results))
+		require.Equal(t, storage.ErrBatchAborted, results[0].Err)
+		require.Equal(t, storage.ErrBatchAborted, results[1].Err)
+		require.Equal(t, storage.ErrCreateEventIDExists, results[2].Err)
+		require.Equal(t, storage.ErrBatchAborted, results[3].Err)
+
+		_, err = store.GetEvent(ctx, "2")
+		require.Equal(t, storage.ErrReadEventNotExists, err)
+
+		event, err := store.GetEvent(ctx, "1")
+		require.Nil(t, err)
+		require.Equal(t, "Test", event.Title)
+
+		history, err := store.GetEventHistory(ctx, "1")
+		require.Nil(t, err)
+		require.Equal(t, 1, len(history))
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		store := New()
+		ctx := context.Background()
+
+		err := store.CreateEvent(ctx, storage.Event{ID: "1", Title: "Test", StartDate: startDate, EndDate: endDate})
+		require.Nil(t, err)
+
+		results, err := store.ApplyBatch(ctx, storage.BatchModeBestEffort, newOperations())
+		require.Nil(t, err)
+		require.Equal(t, 4, len(results))
+		require.Nil(t, results[0].Err)
+		require.Nil(t, results[1].Err)
+		require.Equal(t, storage.ErrCreateEventIDExists, results[2].Err)
+		require.Nil(t, results[3].Err)
+
+		_, err = store.GetEvent(ctx, "2")
+		require.Nil(t, err)
+
+		_, err = store.GetEvent(ctx, "1")
+		require.Equal(t, storage.ErrReadEventNotExists, err)
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		store := New()
+
+		_, err := store.ApplyBatch(context.Background(), "unknown", newOperations())
+		require.Equal(t, storage.ErrBatchUnknownMode, err)
+	})
+}
+
+func TestStorageCalendars(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+
+	err := store.CreateCalendar(ctx, storage.Calendar{ID: "c1", OwnerID: 1, Name: "Work"})
+	require.Nil(t, err)
+
+	err = store.CreateCalendar(ctx, storage.Calendar{ID: "c1", OwnerID: 1, Name: "Work"})
+	require.Equal(t, storage.ErrCreateCalendarIDExists, err)
+
+	err = store.CreateCalendar(ctx, storage.Calendar{ID: "c2", OwnerID: 2, Name: "Home"})
+	require.Nil(t, err)
+
+	err = store.SaveCalendarGrant(ctx, storage.CalendarGrant{
+		CalendarID: "c2",
+		UserID:     1,
+		Permission: storage.CalendarPermissionFreeBusy,
+	})
+	require.Nil(t, err)
+
+	err = store.SaveCalendarGrant(ctx, storage.CalendarGrant{
+		CalendarID: "unknown",
+		UserID:     1,
+		Permission: storage.CalendarPermissionRead,
+	})
+	require.Equal(t, storage.ErrCalendarNotExists, err)
+
+	calendars, err := store.GetUserCalendars(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, []storage.UserCalendar{
+		{Calendar: storage.Calendar{ID: "c2", OwnerID: 2, Name: "Home"}, Permission: storage.CalendarPermissionFreeBusy},
+		{Calendar: storage.Calendar{ID: "c1", OwnerID: 1, Name: "Work"}, Permission: storage.CalendarPermissionOwner},
+	}, calendars)
+
+	err = store.CreateEvent(ctx, storage.Event{ID: "1", CalendarID: "c1", StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+	err = store.CreateEvent(ctx, storage.Event{ID: "2", CalendarID: "c2", StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+	err = store.CreateEvent(ctx, storage.Event{ID: "3", StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+
+	events, err := store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{CalendarIDs: []string{"c1"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, "1", events[0].ID)
+
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{CalendarIDs: []string{""}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, "3", events[0].ID)
+
+	// The events outside of any calendar are limited to the ones of the creator.
+	creatorID := 1
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{
+		CalendarIDs: []string{"", "c1"},
+		CreatorID:   &creatorID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, "1", events[0].ID)
+
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(events))
+
+	err = store.DeleteCalendarGrant(ctx, "c2", 1)
+	require.Nil(t, err)
+
+	err = store.DeleteCalendarGrant(ctx, "c2", 1)
+	require.Equal(t, storage.ErrCalendarGrantNotExists, err)
+
+	grants, err := store.GetCalendarGrants(ctx, "c2")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(grants))
+
+	err = store.DeleteCalendar(ctx, "c1")
+	require.Nil(t, err)
+
+	_, err = store.GetCalendar(ctx, "c1")
+	require.Equal(t, storage.ErrCalendarNotExists, err)
+
+	_, err = store.GetEvent(ctx, "1")
+	require.Equal(t, storage.ErrReadEventNotExists, err)
+}
+
+func TestStorageTags(t *testing.T) {
+	store := New()
+	ctx := context.Background()
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+
+	err := store.CreateEvent(ctx, storage.Event{
+		ID:        "1",
+		StartDate: startDate,
+		EndDate:   endDate,
+		Tags:      []string{"oncall", "work"},
+	})
+	require.Nil(t, err)
+
+	err = store.CreateEvent(ctx, storage.Event{ID: "2", StartDate: startDate, EndDate: endDate, Tags: []string{"home"}})
+	require.Nil(t, err)
+
+	err = store.CreateEvent(ctx, storage.Event{ID: "3", StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+
+	events, err := store.GetEventsOnDate(ctx, startDate, storage.EventFilter{Tags: []string{"oncall"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, "1", events[0].ID)
+	require.Equal(t, []string{"oncall", "work"}, events[0].Tags)
+
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{Tags: []string{"oncall", "home"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(events))
+
+	err = store.UpdateEvent(ctx, "1", storage.Event{StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+
+	events, err = store.GetEventsOnWeek(ctx, startDate, storage.EventFilter{Tags: []string{"oncall"}})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(events))
+
+	history, err := store.GetEventHistory(ctx, "1")
+	require.Nil(t, err)
+	require.Contains(t, history[1].Changes, storage.EventChange{Field: "tags", Before: "oncall,work", After: ""})
+
+	err = store.SaveTag(ctx, storage.Tag{OwnerID: 1, Name: "oncall", Color: "#ff0000"})
+	require.Nil(t, err)
+	err = store.SaveTag(ctx, storage.Tag{OwnerID: 1, Name: "home", Color: "#00ff00"})
+	require.Nil(t, err)
+	err = store.SaveTag(ctx, storage.Tag{OwnerID: 1, Name: "oncall", Color: "#0000ff"})
+	require.Nil(t, err)
+
+	tags, err := store.GetUserTags(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, []storage.Tag{
+		{OwnerID: 1, Name: "home", Color: "#00ff00"},
+		{OwnerID: 1, Name: "oncall", Color: "#0000ff"},
+	}, tags)
+
+	tags, err = store.GetUserTags(ctx, 2)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(tags))
+
+	err = store.DeleteTag(ctx, 1, "home")
+	require.Nil(t, err)
+
+	err = store.DeleteTag(ctx, 1, "home")
+	require.Equal(t, storage.ErrTagNotExists, err)
+}
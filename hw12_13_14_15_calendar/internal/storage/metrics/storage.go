@@ -0,0 +1,193 @@
+package metricsstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/app"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MeasuredStorage reports the latency and the result of every query of the wrapped storage.
+type MeasuredStorage struct {
+	app.Storage
+
+	metrics *metrics.StorageMetrics
+}
+
+func New(next app.Storage, storageMetrics *metrics.StorageMetrics) *MeasuredStorage {
+	return &MeasuredStorage{
+		Storage: next,
+		metrics: storageMetrics,
+	}
+}
+
+func (s *MeasuredStorage) observe(query string, start time.Time, err *error) {
+	s.metrics.Observe(query, *err, time.Since(start))
+}
+
+func (s *MeasuredStorage) CreateEvent(ctx context.Context, event storage.Event) (err error) {
+	defer s.observe("CreateEvent", time.Now(), &err)
+
+	return s.Storage.CreateEvent(ctx, event)
+}
+
+func (s *MeasuredStorage) UpdateEvent(ctx context.Context, eventID string, event storage.Event) (err error) {
+	defer s.observe("UpdateEvent", time.Now(), &err)
+
+	return s.Storage.UpdateEvent(ctx, eventID, event)
+}
+
+func (s *MeasuredStorage) DeleteEvent(ctx context.Context, eventID string) (err error) {
+	defer s.observe("DeleteEvent", time.Now(), &err)
+
+	return s.Storage.DeleteEvent(ctx, eventID)
+}
+
+func (s *MeasuredStorage) GetEvent(ctx context.Context, eventID string) (_ storage.Event, err error) {
+	defer s.observe("GetEvent", time.Now(), &err)
+
+	return s.Storage.GetEvent(ctx, eventID)
+}
+
+func (s *MeasuredStorage) CountUserEvents(ctx context.Context, creatorID int) (_ int, err error) {
+	defer s.observe("CountUserEvents", time.Now(), &err)
+
+	return s.Storage.CountUserEvents(ctx, creatorID)
+}
+
+func (s *MeasuredStorage) GetEventsListByDates(
+	ctx context.Context,
+	from *time.Time,
+	to *time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	defer s.observe("GetEventsListByDates", time.Now(), &err)
+
+	return s.Storage.GetEventsListByDates(ctx, from, to, filter)
+}
+
+func (s *MeasuredStorage) GetEventsForNotify(ctx context.Context, notifyDate string) (_ []storage.Event, err error) {
+	defer s.observe("GetEventsForNotify", time.Now(), &err)
+
+	return s.Storage.GetEventsForNotify(ctx, notifyDate)
+}
+
+func (s *MeasuredStorage) GetEventsOnDate(
+	ctx context.Context,
+	date time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	defer s.observe("GetEventsOnDate", time.Now(), &err)
+
+	return s.Storage.GetEventsOnDate(ctx, date, filter)
+}
+
+func (s *MeasuredStorage) GetEventsOnWeek(
+	ctx context.Context,
+	weekStartDate time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	defer s.observe("GetEventsOnWeek", time.Now(), &err)
+
+	return s.Storage.GetEventsOnWeek(ctx, weekStartDate, filter)
+}
+
+func (s *MeasuredStorage) GetEventsOnMonth(
+	ctx context.Context,
+	monthStartDate time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	defer s.observe("GetEventsOnMonth", time.Now(), &err)
+
+	return s.Storage.GetEventsOnMonth(ctx, monthStartDate, filter)
+}
+
+func (s *MeasuredStorage) GetEventHistory(
+	ctx context.Context,
+	eventID string,
+) (_ []storage.EventHistoryRecord, err error) {
+	defer s.observe("GetEventHistory", time.Now(), &err)
+
+	return s.Storage.GetEventHistory(ctx, eventID)
+}
+
+func (s *MeasuredStorage) DeleteEventHistory(ctx context.Context, eventID string) (err error) {
+	defer s.observe("DeleteEventHistory", time.Now(), &err)
+
+	return s.Storage.DeleteEventHistory(ctx, eventID)
+}
+
+func (s *MeasuredStorage) ApplyBatch(
+	ctx context.Context,
+	mode storage.BatchMode,
+	operations []storage.BatchOperation,
+) (_ []storage.BatchResult, err error) {
+	defer s.observe("ApplyBatch", time.Now(), &err)
+
+	return s.Storage.ApplyBatch(ctx, mode, operations)
+}
+
+func (s *MeasuredStorage) CreateCalendar(ctx context.Context, calendar storage.Calendar) (err error) {
+	defer s.observe("CreateCalendar", time.Now(), &err)
+
+	return s.Storage.CreateCalendar(ctx, calendar)
+}
+
+func (s *MeasuredStorage) GetCalendar(ctx context.Context, calendarID string) (_ storage.Calendar, err error) {
+	defer s.observe("GetCalendar", time.Now(), &err)
+
+	return s.Storage.GetCalendar(ctx, calendarID)
+}
+
+func (s *MeasuredStorage) GetUserCalendars(ctx context.Context, userID int) (_ []storage.UserCalendar, err error) {
+	defer s.observe("GetUserCalendars", time.Now(), &err)
+
+	return s.Storage.GetUserCalendars(ctx, userID)
+}
+
+func (s *MeasuredStorage) DeleteCalendar(ctx context.Context, calendarID string) (err error) {
+	defer s.observe("DeleteCalendar", time.Now(), &err)
+
+	return s.Storage.DeleteCalendar(ctx, calendarID)
+}
+
+func (s *MeasuredStorage) SaveCalendarGrant(ctx context.Context, grant storage.CalendarGrant) (err error) {
+	defer s.observe("SaveCalendarGrant", time.Now(), &err)
+
+	return s.Storage.SaveCalendarGrant(ctx, grant)
+}
+
+func (s *MeasuredStorage) DeleteCalendarGrant(ctx context.Context, calendarID string, userID int) (err error) {
+	defer s.observe("DeleteCalendarGrant", time.Now(), &err)
+
+	return s.Storage.DeleteCalendarGrant(ctx, calendarID, userID)
+}
+
+func (s *MeasuredStorage) GetCalendarGrants(
+	ctx context.Context,
+	calendarID string,
+) (_ []storage.CalendarGrant, err error) {
+	defer s.observe("GetCalendarGrants", time.Now(), &err)
+
+	return s.Storage.GetCalendarGrants(ctx, calendarID)
+}
+
+func (s *MeasuredStorage) SaveTag(ctx context.Context, tag storage.Tag) (err error) {
+	defer s.observe("SaveTag", time.Now(), &err)
+
+	return s.Storage.SaveTag(ctx, tag)
+}
+
+func (s *MeasuredStorage) DeleteTag(ctx context.Context, ownerID int, name string) (err error) {
+	defer s.observe("DeleteTag", time.Now(), &err)
+
+	return s.Storage.DeleteTag(ctx, ownerID, name)
+}
+
+func (s *MeasuredStorage) GetUserTags(ctx context.Context, ownerID int) (_ []storage.Tag, err error) {
+	defer s.observe("GetUserTags", time.Now(), &err)
+
+	return s.Storage.GetUserTags(ctx, ownerID)
+}
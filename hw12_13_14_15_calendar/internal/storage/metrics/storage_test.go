@@ -0,0 +1,38 @@
+package metricsstorage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage/memory"
+)
+
+func TestMeasuredStorage(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	store := New(memorystorage.New(), metrics.NewStorageMetrics(registry))
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateEvent(ctx, storage.Event{ID: "1", Title: "Test"}))
+
+	event, err := store.GetEvent(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "Test", event.Title)
+
+	_, err = store.GetEvent(ctx, "2")
+	require.ErrorIs(t, err, storage.ErrReadEventNotExists)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP calendar_storage_queries_total Storage queries by query and result.
+# TYPE calendar_storage_queries_total counter
+calendar_storage_queries_total{query="CreateEvent",result="ok"} 1
+calendar_storage_queries_total{query="GetEvent",result="error"} 1
+calendar_storage_queries_total{query="GetEvent",result="ok"} 1
+`), "calendar_storage_queries_total")
+	require.NoError(t, err)
+}
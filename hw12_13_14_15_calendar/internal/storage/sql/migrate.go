@@ -0,0 +1,331 @@
+package sqlstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/migrations"
+)
+
+const (
+	schemaTable = "schema_migrations"
+	// migrationsLockID is the pg_advisory_lock key held while migrations run.
+	migrationsLockID = 20240501
+	// legacyTable is created by the first migration, it was created by the postgres image
+	// before the migrations were recorded.
+	legacyTable = "public.events"
+)
+
+type StorageMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator applies the migrations embedded into the binary.
+type Migrator struct {
+	dsn        string
+	db         *sqlx.DB
+	migrations []migrations.Migration
+}
+
+func NewMigrator(dsn string) (*Migrator, error) {
+	list, err := migrations.Postgres()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		dsn:        dsn,
+		migrations: list,
+	}, nil
+}
+
+func (m *Migrator) Connect(ctx context.Context) error {
+	db, err := sqlx.ConnectContext(ctx, "pgx", m.dsn)
+	if err != nil {
+		return err
+	}
+
+	m.db = db
+	return nil
+}
+
+func (m *Migrator) Close() error {
+	if m.db == nil {
+		return nil
+	}
+
+	err := m.db.Close()
+	m.db = nil
+
+	return err
+}
+
+// Up applies all pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]migrations.Migration, error) {
+	applied := []migrations.Migration{}
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(versions) == 0 {
+			legacy, err := tableExists(ctx, conn, legacyTable)
+			if err != nil {
+				return err
+			}
+			if legacy {
+				return migrations.ErrSchemaNotBaselined
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			err = runMigration(ctx, conn, migration.Up, func(tx *sqlx.Tx) error {
+				return recordMigration(ctx, tx, migration)
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Baseline records the migrations up to the version as applied without running them and returns
+// the ones recorded. It upgrades the databases whose tables were created before the migrations
+// were recorded, the version is the last migration the tables hold.
+func (m *Migrator) Baseline(ctx context.Context, version int) ([]migrations.Migration, error) {
+	list, err := migrations.UpTo(m.migrations, version)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := []migrations.Migration{}
+
+	err = m.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		return runMigration(ctx, conn, "", func(tx *sqlx.Tx) error {
+			for _, migration := range list {
+				if _, ok := versions[migration.Version]; ok {
+					continue
+				}
+
+				if err := recordMigration(ctx, tx, migration); err != nil {
+					return err
+				}
+				recorded = append(recorded, migration)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]migrations.Migration, error) {
+	rolledBack := []migrations.Migration{}
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %s", migrations.ErrMigrationWithoutDown, migration.Name)
+			}
+
+			err = runMigration(ctx, conn, migration.Down, func(tx *sqlx.Tx) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM "+schemaTable+" WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration.Name, err)
+			}
+
+			rolledBack = append(rolledBack, migration)
+		}
+
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status returns every known migration with its applied state.
+func (m *Migrator) Status(ctx context.Context) ([]migrations.Status, error) {
+	var statuses []migrations.Status
+
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		versions, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]migrations.Status, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			applied, ok := versions[migration.Version]
+			statuses = append(statuses, migrations.Status{
+				Migration: migration,
+				Applied:   ok,
+				AppliedAt: applied.AppliedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migrations advisory lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	if m.db == nil {
+		return ErrDBNotConnected
+	}
+
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+schemaTable+`(
+		version int PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func runMigration(ctx context.Context, conn *sqlx.Conn, query string, record func(tx *sqlx.Tx) error) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if query != "" {
+		_, err = tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func recordMigration(ctx context.Context, tx *sqlx.Tx, migration migrations.Migration) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO "+schemaTable+" (version, name, applied_at) VALUES ($1, $2, $3)",
+		migration.Version,
+		migration.Name,
+		time.Now().UTC(),
+	)
+	return err
+}
+
+func tableExists(ctx context.Context, db sqlx.QueryerContext, table string) (bool, error) {
+	var exists bool
+	err := sqlx.GetContext(ctx, db, &exists, "SELECT to_regclass($1) IS NOT NULL", table)
+	return exists, err
+}
+
+func appliedMigrations(ctx context.Context, conn *sqlx.Conn) (map[int]StorageMigration, error) {
+	rows, err := conn.QueryxContext(ctx, "SELECT version, name, applied_at FROM "+schemaTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]StorageMigration{}
+	for rows.Next() {
+		var migration StorageMigration
+		err = rows.StructScan(&migration)
+		if err != nil {
+			return nil, err
+		}
+		versions[migration.Version] = migration
+	}
+
+	return versions, rows.Err()
+}
+
+// checkSchema fails with migrations.ErrSchemaOutdated when any embedded migration is not applied
+// and with migrations.ErrSchemaNotBaselined when the tables were created without migrations.
+func checkSchema(ctx context.Context, db *sqlx.DB) error {
+	list, err := migrations.Postgres()
+	if err != nil {
+		return err
+	}
+
+	exists, err := tableExists(ctx, db, "public."+schemaTable)
+	if err != nil {
+		return err
+	}
+
+	var versions []int
+	if exists {
+		err = db.SelectContext(ctx, &versions, "SELECT version FROM "+schemaTable)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(versions) == 0 {
+		legacy, err := tableExists(ctx, db, legacyTable)
+		if err != nil {
+			return err
+		}
+		if legacy {
+			return migrations.ErrSchemaNotBaselined
+		}
+	}
+
+	return migrations.CheckApplied(list, versions)
+}
@@ -0,0 +1,96 @@
+package sqlstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/migrations"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	migrator, err := NewMigrator(testDSN)
+	require.NoError(t, err)
+
+	err = migrator.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer migrator.Close()
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		require.True(t, status.Applied, status.Name)
+	}
+
+	rolledBack, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, rolledBack, 1)
+	require.Equal(t, statuses[len(statuses)-1].Name, rolledBack[0].Name)
+
+	store := New(testDSN)
+	err = store.Connect(ctx)
+	require.ErrorIs(t, err, migrations.ErrSchemaOutdated)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, rolledBack, applied)
+
+	err = store.Connect(ctx)
+	require.NoError(t, err)
+	store.Close(ctx)
+}
+
+func TestMigratorBaseline(t *testing.T) {
+	ctx := context.Background()
+
+	migrator, err := NewMigrator(testDSN)
+	require.NoError(t, err)
+
+	err = migrator.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer migrator.Close()
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	_, err = migrator.Down(ctx, len(migrator.migrations))
+	require.NoError(t, err)
+
+	// The tables of the old postgres image, created without a record of the migrations.
+	for _, migration := range migrator.migrations[:2] {
+		_, err = migrator.db.ExecContext(ctx, migration.Up)
+		require.NoError(t, err)
+	}
+
+	store := New(testDSN)
+	err = store.Connect(ctx)
+	require.ErrorIs(t, err, migrations.ErrSchemaNotBaselined)
+
+	_, err = migrator.Up(ctx)
+	require.ErrorIs(t, err, migrations.ErrSchemaNotBaselined)
+
+	_, err = migrator.Baseline(ctx, 42)
+	require.ErrorIs(t, err, migrations.ErrUnknownVersion)
+
+	recorded, err := migrator.Baseline(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, migrator.migrations[:2], recorded)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, migrator.migrations[2:], applied)
+
+	err = store.Connect(ctx)
+	require.NoError(t, err)
+	store.Close(ctx)
+}
@@ -0,0 +1,185 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultHealthCheckInterval = 10 * time.Second
+
+// replicaLagQuery returns zero on the primary and on a replica which replayed everything it received.
+const replicaLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+type Config struct {
+	DSN string
+	// ReplicaDSNs are the read replicas used for the event reads.
+	// Calendars and grants are always read from the primary, so access checks never see revoked grants.
+	ReplicaDSNs     []string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// MaxReplicaLag excludes the replicas which lag behind the primary for longer, zero disables the check.
+	MaxReplicaLag       time.Duration
+	HealthCheckInterval time.Duration
+}
+
+type NodeHealth struct {
+	Name      string
+	Primary   bool
+	Healthy   bool
+	Lag       time.Duration
+	Err       error
+	CheckedAt time.Time
+}
+
+type node struct {
+	db     *sqlx.DB
+	mu     sync.RWMutex
+	health NodeHealth
+}
+
+func (n *node) state() NodeHealth {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.health
+}
+
+func (n *node) setState(lag time.Duration, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.health.Healthy = err == nil
+	n.health.Lag = lag
+	n.health.Err = err
+	n.health.CheckedAt = time.Now()
+}
+
+func (n *node) check(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lagSeconds float64
+	err := n.db.GetContext(ctx, &lagSeconds, replicaLagQuery)
+
+	n.setState(time.Duration(lagSeconds*float64(time.Second)), err)
+}
+
+// Health returns the state of the primary and every replica as of the last check.
+func (s *SQLStorage) Health() []NodeHealth {
+	s.mu.Lock()
+	nodes := append([]*node{s.primary}, s.replicas...)
+	s.mu.Unlock()
+
+	health := make([]NodeHealth, 0, len(nodes))
+	for _, n := range nodes {
+		if n != nil {
+			health = append(health, n.state())
+		}
+	}
+
+	return health
+}
+
+// read runs fn on a healthy replica and falls back to the primary when there is none or the replica failed.
+// A replica which failed with anything but a database error is unhealthy until the next successful check.
+func (s *SQLStorage) read(ctx context.Context, fn func(db *sqlx.DB) error) error {
+	replica := s.pickReplica()
+	if replica == nil {
+		return fn(s.db)
+	}
+
+	err := fn(replica.db)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) && !errors.Is(err, storage.ErrReadEventNotExists) {
+		replica.setState(0, err)
+	}
+
+	// the row may be missing on the replica only because it lags behind
+	trace.SpanFromContext(ctx).AddEvent("replica read failed, reading from primary",
+		trace.WithAttributes(attribute.String("error", err.Error())))
+
+	return fn(s.db)
+}
+
+func (s *SQLStorage) pickReplica() *node {
+	s.mu.Lock()
+	replicas := s.replicas
+	start := s.nextReplica
+	s.nextReplica++
+	s.mu.Unlock()
+
+	for i := range replicas {
+		replica := replicas[(start+i)%len(replicas)]
+		state := replica.state()
+		if !state.Healthy {
+			continue
+		}
+		if s.config.MaxReplicaLag > 0 && state.Lag > s.config.MaxReplicaLag {
+			continue
+		}
+
+		return replica
+	}
+
+	return nil
+}
+
+func (s *SQLStorage) runHealthChecks(nodes []*node, interval time.Duration, done <-chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			checkNodes(context.Background(), nodes, interval)
+		}
+	}
+}
+
+func checkNodes(ctx context.Context, nodes []*node, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			n.check(ctx, timeout)
+		}(n)
+	}
+	wg.Wait()
+}
+
+func (s *SQLStorage) configurePool(db *sqlx.DB) {
+	if s.config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(s.config.MaxOpenConns)
+	}
+	if s.config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(s.config.MaxIdleConns)
+	}
+	if s.config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(s.config.ConnMaxLifetime)
+	}
+	if s.config.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(s.config.ConnMaxIdleTime)
+	}
+}
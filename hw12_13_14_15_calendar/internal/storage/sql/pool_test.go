@@ -0,0 +1,54 @@
+package sqlstorage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickReplica(t *testing.T) {
+	newReplica := func(name string, healthy bool, lag time.Duration) *node {
+		return &node{health: NodeHealth{Name: name, Healthy: healthy, Lag: lag}}
+	}
+
+	t.Run("round robin over healthy replicas", func(t *testing.T) {
+		s := NewWithConfig(Config{MaxReplicaLag: time.Second})
+		s.replicas = []*node{
+			newReplica("replica-1", true, 0),
+			newReplica("replica-2", false, 0),
+			newReplica("replica-3", true, 500*time.Millisecond),
+			newReplica("replica-4", true, 2*time.Second),
+		}
+
+		picked := map[string]int{}
+		for i := 0; i < 8; i++ {
+			picked[s.pickReplica().state().Name]++
+		}
+
+		require.Equal(t, map[string]int{"replica-1": 4, "replica-3": 4}, picked)
+	})
+
+	t.Run("lag is not checked when not limited", func(t *testing.T) {
+		s := NewWithConfig(Config{})
+		s.replicas = []*node{newReplica("replica-1", true, time.Hour)}
+
+		require.NotNil(t, s.pickReplica())
+	})
+
+	t.Run("primary fallback", func(t *testing.T) {
+		s := NewWithConfig(Config{MaxReplicaLag: time.Second})
+		require.Nil(t, s.pickReplica())
+
+		replica := newReplica("replica-1", true, 0)
+		s.replicas = []*node{replica}
+		require.Equal(t, replica, s.pickReplica())
+
+		replica.setState(0, errors.New("connection refused"))
+		require.Nil(t, s.pickReplica())
+
+		replica.setState(2*time.Second, nil)
+		require.Nil(t, s.pickReplica())
+	})
+}
@@ -2,41 +2,107 @@ package sqlstorage
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	_ "github.com/jackc/pgx/v5/stdlib" // postgres driver
 	"github.com/jmoiron/sqlx"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
 	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/tracing"
 )
 
+// eventColumns are the columns StorageEvent is scanned from.
+const eventColumns = `id, calendar_id, creator_id, title, description, location, url, start_dt, end_dt,
+			  notify_before, notified, tags, created_at, updated_at`
+
 var ErrDBNotConnected = errors.New("not connected to database")
 
 type SQLStorage struct {
-	dsn string
-	mu  sync.Mutex
-	db  *sqlx.DB
+	config      Config
+	mu          sync.Mutex
+	db          *sqlx.DB
+	primary     *node
+	replicas    []*node
+	nextReplica int
+	done        chan struct{}
+	wg          sync.WaitGroup
 }
 
 type StorageEvent struct {
-	ID           string        `db:"id"`
-	Title        string        `db:"title"`
-	Description  string        `db:"description"`
-	StartDate    time.Time     `db:"start_dt"`
-	EndDate      time.Time     `db:"end_dt"`
-	CreatorID    int           `db:"creator_id"`
-	NotifyBefore time.Duration `db:"notify_before"`
+	ID           string         `db:"id"`
+	CalendarID   sql.NullString `db:"calendar_id"`
+	Title        string         `db:"title"`
+	Description  string         `db:"description"`
+	Location     string         `db:"location"`
+	URL          string         `db:"url"`
+	StartDate    time.Time      `db:"start_dt"`
+	EndDate      time.Time      `db:"end_dt"`
+	CreatorID    int            `db:"creator_id"`
+	NotifyBefore time.Duration  `db:"notify_before"`
+	Notified     bool           `db:"notified"`
+	Tags         StorageTags    `db:"tags"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
+}
+
+// StorageTags scans the events tags text array.
+type StorageTags []string
+
+func (t *StorageTags) Scan(src any) error {
+	tags := []string{}
+	err := pgtype.NewMap().SQLScanner(&tags).Scan(src)
+	if err != nil {
+		return err
+	}
+
+	*t = tags
+	return nil
+}
+
+type StorageTag struct {
+	OwnerID int    `db:"owner_id"`
+	Name    string `db:"name"`
+	Color   string `db:"color"`
+}
+
+type StorageCalendar struct {
+	ID         string         `db:"id"`
+	OwnerID    int            `db:"owner_id"`
+	Name       string         `db:"name"`
+	Permission sql.NullString `db:"permission"`
+}
+
+type StorageEventHistoryRecord struct {
+	ID         int64          `db:"id"`
+	EventID    string         `db:"event_id"`
+	Action     string         `db:"action"`
+	UserID     int            `db:"user_id"`
+	CreatedAt  time.Time      `db:"created_at"`
+	BeforeData sql.NullString `db:"before_data"`
+	AfterData  sql.NullString `db:"after_data"`
+	Changes    string         `db:"changes"`
 }
 
 func New(dsn string) *SQLStorage {
+	return NewWithConfig(Config{DSN: dsn})
+}
+
+func NewWithConfig(config Config) *SQLStorage {
 	return &SQLStorage{
-		dsn: dsn,
+		config: config,
 	}
 }
 
+// Connect fails when the primary is not available. Unavailable replicas are skipped
+// until the health check finds them alive.
 func (s *SQLStorage) Connect(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -46,48 +112,265 @@ func (s *SQLStorage) Connect(ctx context.Context) error {
 		return nil
 	}
 
-	db, err := sqlx.ConnectContext(ctx, "pgx", s.dsn)
+	db, err := sqlx.ConnectContext(ctx, "pgx", s.config.DSN)
 	if err != nil {
 		return err
 	}
+	s.configurePool(db)
 
-	err = db.PingContext(ctx)
+	err = checkSchema(ctx, db)
 	if err != nil {
+		db.Close()
 		return err
 	}
 
+	replicas := make([]*node, 0, len(s.config.ReplicaDSNs))
+	for i, dsn := range s.config.ReplicaDSNs {
+		replicaDB, err := sqlx.Open("pgx", dsn)
+		if err != nil {
+			db.Close()
+			for _, replica := range replicas {
+				replica.db.Close()
+			}
+			return err
+		}
+		s.configurePool(replicaDB)
+
+		replicas = append(replicas, &node{
+			db:     replicaDB,
+			health: NodeHealth{Name: fmt.Sprintf("replica-%d", i+1)},
+		})
+	}
+
 	s.db = db
+	s.primary = &node{
+		db:     db,
+		health: NodeHealth{Name: "primary", Primary: true, Healthy: true, CheckedAt: time.Now()},
+	}
+	s.replicas = replicas
+
+	interval := s.config.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	nodes := append([]*node{s.primary}, replicas...)
+	checkNodes(ctx, nodes, interval)
+
+	s.done = make(chan struct{})
+	s.wg.Add(1)
+	go s.runHealthChecks(nodes, interval, s.done)
+
 	return nil
 }
 
 func (s *SQLStorage) Close(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+
+	close(s.done)
+	s.wg.Wait()
+
+	for _, replica := range s.replicas {
+		replica.db.Close()
+	}
+
 	err := s.db.Close()
+	s.db = nil
+	s.primary = nil
+	s.replicas = nil
+
+	return err
+}
+
+// Ping checks the connection to the primary, the replicas are left out as the reads fall back to it.
+func (s *SQLStorage) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	db := s.db
+	s.mu.Unlock()
+
+	if db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	return db.PingContext(ctx)
+}
+
+func (s *SQLStorage) CreateEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, span := startSpan(ctx, "CreateEvent")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer tx.Rollback()
 
-	s.db = nil
-	return nil
+	err = createEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *SQLStorage) CreateEvent(ctx context.Context, event storage.Event) error {
+func (s *SQLStorage) UpdateEvent(ctx context.Context, eventID string, event storage.Event) (err error) {
+	ctx, span := startSpan(ctx, "UpdateEvent")
+	defer tracing.End(span, &err)
+
 	if s.db == nil {
-		return ErrDBNotConnected
+		return unavailable(ErrDBNotConnected)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateEvent(ctx, tx, eventID, event)
+	if err != nil {
+		return err
 	}
 
-	query := `INSERT INTO public.events (id, creator_id, title, description, start_dt, end_dt, notify_before)
-			   VALUES (:id, :creator_id, :title, :description, :start_dt, :end_dt, :notify_before)`
+	return tx.Commit()
+}
+
+func (s *SQLStorage) DeleteEvent(ctx context.Context, eventID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteEvent")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
 
-	_, err := s.db.NamedExecContext(ctx, query, map[string]interface{}{
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = deleteEvent(ctx, tx, eventID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStorage) ApplyBatch(
+	ctx context.Context,
+	mode storage.BatchMode,
+	operations []storage.BatchOperation,
+) (_ []storage.BatchResult, err error) {
+	ctx, span := startSpan(ctx, "ApplyBatch")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	if mode != storage.BatchModeAllOrNothing && mode != storage.BatchModeBestEffort {
+		return nil, storage.ErrBatchUnknownMode
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]storage.BatchResult, 0, len(operations))
+	failed := false
+
+	for i := range operations {
+		eventID := operations[i].EventID
+		if operations[i].Type == storage.BatchOperationCreate {
+			eventID = operations[i].Event.ID
+		}
+
+		if mode == storage.BatchModeAllOrNothing && failed {
+			results = append(results, storage.BatchResult{Index: i, EventID: eventID, Err: storage.ErrBatchAborted})
+			continue
+		}
+
+		// A failed statement aborts the whole postgres transaction,
+		// so every operation runs in its own savepoint to be able to continue.
+		_, err = tx.ExecContext(ctx, "SAVEPOINT batch_operation")
+		if err != nil {
+			return nil, err
+		}
+
+		opErr := applyBatchOperation(ctx, tx, eventID, operations[i])
+		if opErr != nil {
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_operation")
+		} else {
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, storage.BatchResult{Index: i, EventID: eventID, Err: opErr})
+		failed = failed || opErr != nil
+	}
+
+	if mode == storage.BatchModeAllOrNothing && failed {
+		storage.AbortBatchResults(results)
+		return results, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func applyBatchOperation(ctx context.Context, tx *sqlx.Tx, eventID string, operation storage.BatchOperation) error {
+	switch operation.Type {
+	case storage.BatchOperationCreate:
+		return createEvent(ctx, tx, operation.Event)
+	case storage.BatchOperationUpdate:
+		return updateEvent(ctx, tx, eventID, operation.Event)
+	case storage.BatchOperationDelete:
+		return deleteEvent(ctx, tx, eventID)
+	default:
+		return storage.ErrBatchUnknownOperation
+	}
+}
+
+func createEvent(ctx context.Context, tx *sqlx.Tx, event storage.Event) error {
+	query := `INSERT INTO public.events
+			   (id, calendar_id, creator_id, title, description, location, url, start_dt, end_dt, notify_before,
+			    notified, tags, created_at, updated_at)
+			   VALUES (:id, :calendar_id, :creator_id, :title, :description, :location, :url, :start_dt, :end_dt,
+			    :notify_before, :notified, :tags, :created_at, :updated_at)`
+
+	_, err := tx.NamedExecContext(ctx, query, map[string]interface{}{
 		"id":            event.ID,
+		"calendar_id":   nullString(event.CalendarID),
 		"creator_id":    event.CreatorID,
 		"title":         event.Title,
 		"description":   event.Description,
+		"location":      event.Location,
+		"url":           event.URL,
 		"start_dt":      event.StartDate,
 		"end_dt":        event.EndDate,
 		"notify_before": event.NotifyBefore,
+		"notified":      event.Notified,
+		"tags":          tagsParam(event.Tags),
+		"created_at":    event.CreatedAt,
+		"updated_at":    event.UpdatedAt,
 	})
 
 	var e *pgconn.PgError
@@ -99,299 +382,882 @@ func (s *SQLStorage) CreateEvent(ctx context.Context, event storage.Event) error
 		return err
 	}
 
-	return nil
+	return addEventHistoryRecord(ctx, tx, event.ID, storage.EventActionCreate, nil, &event)
 }
 
-func (s *SQLStorage) UpdateEvent(ctx context.Context, eventID string, event storage.Event) error {
-	if s.db == nil {
-		return ErrDBNotConnected
+func updateEvent(ctx context.Context, tx *sqlx.Tx, eventID string, event storage.Event) error {
+	before, err := getEventForUpdate(ctx, tx, eventID)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		return storage.ErrUpdateEventIDNotExists
+	}
+	if err != nil {
+		return err
 	}
 
 	query := `UPDATE public.events SET
+			   calendar_id = :calendar_id,
 			   creator_id = :creator_id, 
 			   title = :title, 
 			   description = :description, 
+			   location = :location,
+			   url = :url,
 			   start_dt = :start_dt, 
 			   end_dt = :end_dt, 
 			   notify_before = :notify_before,
-			   notified = :notified
+			   notified = :notified,
+			   tags = :tags,
+			   updated_at = :updated_at
 			WHERE id = :event_id`
 
-	_, err := s.db.NamedExecContext(ctx, query, map[string]interface{}{
+	_, err = tx.NamedExecContext(ctx, query, map[string]interface{}{
+		"calendar_id":   nullString(event.CalendarID),
 		"creator_id":    event.CreatorID,
 		"title":         event.Title,
 		"description":   event.Description,
+		"location":      event.Location,
+		"url":           event.URL,
 		"start_dt":      event.StartDate,
 		"end_dt":        event.EndDate,
 		"notify_before": event.NotifyBefore,
 		"notified":      event.Notified,
+		"tags":          tagsParam(event.Tags),
+		"updated_at":    event.UpdatedAt,
 		"event_id":      eventID,
 	})
 	if err != nil {
 		return err
 	}
 
-	return nil
+	event.ID = eventID
+	event.CreatedAt = before.CreatedAt
+	return addEventHistoryRecord(ctx, tx, eventID, storage.EventActionUpdate, &before, &event)
 }
 
-func (s *SQLStorage) DeleteEvent(ctx context.Context, eventID string) error {
-	if s.db == nil {
-		return ErrDBNotConnected
+func deleteEvent(ctx context.Context, tx *sqlx.Tx, eventID string) error {
+	before, err := getEventForUpdate(ctx, tx, eventID)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
 	query := "DELETE FROM public.events WHERE id = :event_id"
 
-	_, err := s.db.NamedExecContext(ctx, query, map[string]interface{}{
+	_, err = tx.NamedExecContext(ctx, query, map[string]interface{}{
 		"event_id": eventID,
 	})
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return addEventHistoryRecord(ctx, tx, eventID, storage.EventActionDelete, &before, nil)
 }
 
-func (s *SQLStorage) GetEvent(ctx context.Context, eventID string) (storage.Event, error) {
+func (s *SQLStorage) GetEvent(ctx context.Context, eventID string) (_ storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEvent")
+	defer tracing.End(span, &err)
+
 	if s.db == nil {
-		return storage.Event{}, ErrDBNotConnected
+		return storage.Event{}, unavailable(ErrDBNotConnected)
 	}
 
-	query := "SELECT id, creator_id, title, description, start_dt, end_dt, notify_before FROM public.events WHERE id = :id"
-	rows, err := s.db.NamedQueryContext(ctx, query, map[string]interface{}{
-		"id": eventID,
+	query := "SELECT " + eventColumns + `
+			  FROM public.events WHERE id = :id`
+
+	var event StorageEvent
+	err = s.read(ctx, func(db *sqlx.DB) error {
+		rows, err := db.NamedQueryContext(ctx, query, map[string]interface{}{
+			"id": eventID,
+		})
+		if err != nil {
+			return readError(err)
+		}
+		defer rows.Close()
+
+		hasRows := rows.Next()
+		if !hasRows {
+			if err := rows.Err(); err != nil {
+				return readError(err)
+			}
+			return storage.ErrReadEventNotExists
+		}
+
+		return rows.StructScan(&event)
 	})
 	if err != nil {
 		return storage.Event{}, err
 	}
 
-	var event StorageEvent
-	hasRows := rows.Next()
-	if !hasRows {
-		return storage.Event{}, storage.ErrReadEventNotExists
+	return buildStorageEvent(event), nil
+}
+
+func (s *SQLStorage) CountUserEvents(ctx context.Context, creatorID int) (_ int, err error) {
+	ctx, span := startSpan(ctx, "CountUserEvents")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return 0, unavailable(ErrDBNotConnected)
 	}
 
-	err = rows.StructScan(&event)
+	var count int
+	err = s.read(ctx, func(db *sqlx.DB) error {
+		return readError(db.GetContext(ctx, &count, "SELECT count(*) FROM public.events WHERE creator_id = $1", creatorID))
+	})
 	if err != nil {
-		return storage.Event{}, err
+		return 0, err
 	}
 
+	return count, nil
+}
+
+func (s *SQLStorage) GetEventsListByDates(
+	ctx context.Context,
+	from *time.Time,
+	to *time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEventsListByDates")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	params := map[string]interface{}{}
+
+	query := "SELECT " + eventColumns + `
+			  FROM public.events
+			  WHERE 1=1`
+
+	if from != nil {
+		query += " AND start_dt >= :from"
+		params["from"] = from
+	}
+	if to != nil {
+		query += " AND end_dt <= :to"
+		params["to"] = to
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLStorage) GetEventsForNotify(ctx context.Context, notifyDate string) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEventsForNotify")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM public.events
+			  WHERE cast((start_dt - cast(CONCAT(notify_before/1000000, ' milliseconds') as interval)) as date) = :notify_date
+			  AND notified IS FALSE`
+
+	return s.selectEvents(ctx, query, map[string]interface{}{
+		"notify_date": notifyDate,
+	})
+}
+
+func (s *SQLStorage) GetEventsOnDate(
+	ctx context.Context,
+	date time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEventsOnDate")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM public.events
+			  where cast(start_dt as date) = :start_dt`
+
+	params := map[string]interface{}{
+		"start_dt": date.Format(time.DateOnly),
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLStorage) GetEventsOnWeek(
+	ctx context.Context,
+	weekStartDate time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEventsOnWeek")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM public.events
+			  where start_dt >= :week_start_dt and end_dt <= :week_end_dt`
+
+	weekEndDate := weekStartDate.Add(time.Hour * 24 * 6)
+
+	params := map[string]interface{}{
+		"week_start_dt": weekStartDate,
+		"week_end_dt":   weekEndDate,
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLStorage) GetEventsOnMonth(
+	ctx context.Context,
+	monthStartDate time.Time,
+	filter storage.EventFilter,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEventsOnMonth")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM public.events
+			  where start_dt >= :month_start_dt and end_dt <= :month_end_dt`
+
+	monthEndDate := monthStartDate.AddDate(0, 1, -1)
+
+	params := map[string]interface{}{
+		"month_start_dt": monthStartDate,
+		"month_end_dt":   monthEndDate,
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLStorage) selectEvents(
+	ctx context.Context,
+	query string,
+	params map[string]interface{},
+) ([]storage.Event, error) {
+	var events []storage.Event
+	err := s.read(ctx, func(db *sqlx.DB) error {
+		rows, err := db.NamedQueryContext(ctx, query, params)
+		if err != nil {
+			return readError(err)
+		}
+		defer rows.Close()
+
+		events = []storage.Event{}
+		for rows.Next() {
+			var event StorageEvent
+			err = rows.StructScan(&event)
+			if err != nil {
+				return err
+			}
+
+			events = append(events, buildStorageEvent(event))
+		}
+
+		return readError(rows.Err())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// readError marks the errors of an unreachable or overloaded database with storage.ErrStorageUnavailable.
+func readError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) &&
+		!pgerrcode.IsConnectionException(pgErr.Code) &&
+		!pgerrcode.IsInsufficientResources(pgErr.Code) &&
+		!pgerrcode.IsOperatorIntervention(pgErr.Code) {
+		return err
+	}
+
+	return unavailable(err)
+}
+
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", storage.ErrStorageUnavailable, err)
+}
+
+func appendEventFilter(query string, params map[string]interface{}, filter storage.EventFilter) string {
+	if filter.CalendarIDs == nil {
+		return query
+	}
+
+	calendarIDs := make([]string, 0, len(filter.CalendarIDs))
+	withoutCalendar := false
+	for _, calendarID := range filter.CalendarIDs {
+		if calendarID == "" {
+			withoutCalendar = true
+			continue
+		}
+		calendarIDs = append(calendarIDs, calendarID)
+	}
+
+	withoutCalendarQuery := "calendar_id IS NULL AND :without_calendar"
+	if filter.CreatorID != nil {
+		withoutCalendarQuery += " AND creator_id = :filter_creator_id"
+		params["filter_creator_id"] = *filter.CreatorID
+	}
+
+	query += " AND (calendar_id = ANY(CAST(:calendar_ids AS uuid[])) OR (" + withoutCalendarQuery + "))"
+	params["calendar_ids"] = calendarIDs
+	params["without_calendar"] = withoutCalendar
+
+	return query
+}
+
+func appendTagsFilter(query string, params map[string]interface{}, filter storage.EventFilter) string {
+	if len(filter.Tags) == 0 {
+		return query
+	}
+
+	query += " AND tags && CAST(:filter_tags AS text[])"
+	params["filter_tags"] = filter.Tags
+
+	return query
+}
+
+func tagsParam(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+
+	return tags
+}
+
+func buildStorageEvent(event StorageEvent) storage.Event {
 	return storage.Event{
 		ID:           event.ID,
+		CalendarID:   event.CalendarID.String,
 		CreatorID:    event.CreatorID,
 		Title:        event.Title,
 		Description:  event.Description,
+		Location:     event.Location,
+		URL:          event.URL,
 		StartDate:    event.StartDate,
 		EndDate:      event.EndDate,
 		NotifyBefore: event.NotifyBefore,
-	}, nil
+		Notified:     event.Notified,
+		Tags:         buildEventTags(event.Tags),
+		CreatedAt:    event.CreatedAt,
+		UpdatedAt:    event.UpdatedAt,
+	}
 }
 
-func (s *SQLStorage) GetEventsListByDates(ctx context.Context, from *time.Time, to *time.Time) []storage.Event {
-	if s.db == nil {
-		return []storage.Event{}
+func buildEventTags(tags StorageTags) []string {
+	if len(tags) == 0 {
+		return nil
 	}
 
-	params := map[string]interface{}{}
+	return tags
+}
 
-	query := `SELECT id, creator_id, title, description, start_dt, end_dt, notify_before 
-			  FROM public.events`
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
 
-	if from != nil || to != nil {
-		query += " WHERE 1=1"
-		if from != nil {
-			query += " AND start_dt >= :from"
-			params["from"] = from
+func (s *SQLStorage) GetEventHistory(ctx context.Context, eventID string) (_ []storage.EventHistoryRecord, err error) {
+	ctx, span := startSpan(ctx, "GetEventHistory")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := `SELECT id, event_id, action, user_id, created_at, before_data, after_data, changes
+			  FROM public.events_history
+			  WHERE event_id = :event_id
+			  ORDER BY id`
+
+	var records []storage.EventHistoryRecord
+	err = s.read(ctx, func(db *sqlx.DB) error {
+		rows, err := db.NamedQueryContext(ctx, query, map[string]interface{}{
+			"event_id": eventID,
+		})
+		if err != nil {
+			return readError(err)
 		}
-		if to != nil {
-			query += " AND end_dt <= :to"
-			params["to"] = to
+		defer rows.Close()
+
+		records = []storage.EventHistoryRecord{}
+		for rows.Next() {
+			var row StorageEventHistoryRecord
+			err = rows.StructScan(&row)
+			if err != nil {
+				return err
+			}
+
+			record, err := buildEventHistoryRecord(row)
+			if err != nil {
+				return err
+			}
+
+			records = append(records, record)
 		}
+
+		return readError(rows.Err())
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	rows, err := s.db.NamedQueryContext(ctx, query, params)
+	return records, nil
+}
+
+func (s *SQLStorage) DeleteEventHistory(ctx context.Context, eventID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteEventHistory")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	query := "DELETE FROM public.events_history WHERE event_id = :event_id"
+
+	_, err = s.db.NamedExecContext(ctx, query, map[string]interface{}{
+		"event_id": eventID,
+	})
 	if err != nil {
-		return []storage.Event{}
+		return err
 	}
 
-	events := []storage.Event{}
-	for rows.Next() {
-		var event StorageEvent
-		err = rows.StructScan(&event)
+	return nil
+}
 
-		if err != nil {
-			continue
+func (s *SQLStorage) CreateCalendar(ctx context.Context, calendar storage.Calendar) (err error) {
+	ctx, span := startSpan(ctx, "CreateCalendar")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	query := `INSERT INTO public.calendars (id, owner_id, name) VALUES (:id, :owner_id, :name)`
+
+	_, err = s.db.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":       calendar.ID,
+		"owner_id": calendar.OwnerID,
+		"name":     calendar.Name,
+	})
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.UniqueViolation {
+		return storage.ErrCreateCalendarIDExists
+	}
+
+	return err
+}
+
+func (s *SQLStorage) GetCalendar(ctx context.Context, calendarID string) (_ storage.Calendar, err error) {
+	ctx, span := startSpan(ctx, "GetCalendar")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return storage.Calendar{}, unavailable(ErrDBNotConnected)
+	}
+
+	query := `SELECT id, owner_id, name FROM public.calendars WHERE id = $1`
+
+	var calendar StorageCalendar
+	err = s.db.GetContext(ctx, &calendar, query, calendarID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Calendar{}, storage.ErrCalendarNotExists
+	}
+	if err != nil {
+		return storage.Calendar{}, readError(err)
+	}
+
+	return storage.Calendar{
+		ID:      calendar.ID,
+		OwnerID: calendar.OwnerID,
+		Name:    calendar.Name,
+	}, nil
+}
+
+func (s *SQLStorage) GetUserCalendars(ctx context.Context, userID int) (_ []storage.UserCalendar, err error) {
+	ctx, span := startSpan(ctx, "GetUserCalendars")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := `SELECT c.id, c.owner_id, c.name, g.permission
+			  FROM public.calendars c
+			  LEFT JOIN public.calendar_grants g ON g.calendar_id = c.id AND g.user_id = $1
+			  WHERE c.owner_id = $1 OR g.user_id IS NOT NULL
+			  ORDER BY c.name`
+
+	rows := []StorageCalendar{}
+	err = s.db.SelectContext(ctx, &rows, query, userID)
+	if err != nil {
+		return nil, readError(err)
+	}
+
+	calendars := make([]storage.UserCalendar, 0, len(rows))
+	for i := range rows {
+		permission := storage.CalendarPermission(rows[i].Permission.String)
+		if rows[i].OwnerID == userID {
+			permission = storage.CalendarPermissionOwner
 		}
-		events = append(events, storage.Event{
-			ID:           event.ID,
-			CreatorID:    event.CreatorID,
-			Title:        event.Title,
-			Description:  event.Description,
-			StartDate:    event.StartDate,
-			EndDate:      event.EndDate,
-			NotifyBefore: event.NotifyBefore,
+
+		calendars = append(calendars, storage.UserCalendar{
+			Calendar: storage.Calendar{
+				ID:      rows[i].ID,
+				OwnerID: rows[i].OwnerID,
+				Name:    rows[i].Name,
+			},
+			Permission: permission,
 		})
 	}
-	return events
+
+	return calendars, nil
 }
 
-func (s *SQLStorage) GetEventsForNotify(ctx context.Context, notifyDate string) []storage.Event {
+func (s *SQLStorage) DeleteCalendar(ctx context.Context, calendarID string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteCalendar")
+	defer tracing.End(span, &err)
+
 	if s.db == nil {
-		return []storage.Event{}
+		return unavailable(ErrDBNotConnected)
 	}
 
-	query := `SELECT id, creator_id, title, description, start_dt, end_dt, notify_before 
-			  FROM public.events
-			  WHERE cast((start_dt - cast(CONCAT(notify_before/1000000, ' milliseconds') as interval)) as date) = :notify_date
-			  AND notified IS FALSE`
-
-	rows, err := s.db.NamedQueryContext(ctx, query, map[string]interface{}{
-		"notify_date": notifyDate,
-	})
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return []storage.Event{}
+		return err
 	}
+	defer tx.Rollback()
 
-	events := []storage.Event{}
-	for rows.Next() {
-		var event StorageEvent
-		err = rows.StructScan(&event)
+	eventIDs := []string{}
+	err = tx.SelectContext(ctx, &eventIDs, "SELECT id FROM public.events WHERE calendar_id = $1", calendarID)
+	if err != nil {
+		return err
+	}
 
+	for i := range eventIDs {
+		err = deleteEvent(ctx, tx, eventIDs[i])
 		if err != nil {
-			continue
+			return err
 		}
-		events = append(events, storage.Event{
-			ID:           event.ID,
-			CreatorID:    event.CreatorID,
-			Title:        event.Title,
-			Description:  event.Description,
-			StartDate:    event.StartDate,
-			EndDate:      event.EndDate,
-			NotifyBefore: event.NotifyBefore,
-		})
 	}
-	return events
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM public.calendars WHERE id = $1", calendarID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *SQLStorage) GetEventsOnDate(ctx context.Context, date time.Time) []storage.Event {
+func (s *SQLStorage) SaveCalendarGrant(ctx context.Context, grant storage.CalendarGrant) (err error) {
+	ctx, span := startSpan(ctx, "SaveCalendarGrant")
+	defer tracing.End(span, &err)
+
 	if s.db == nil {
-		return []storage.Event{}
+		return unavailable(ErrDBNotConnected)
 	}
 
-	query := `SELECT id, creator_id, title, description, start_dt, end_dt, notify_before
-			  FROM public.events
-			  where cast(start_dt as date) = :start_dt`
+	query := `INSERT INTO public.calendar_grants (calendar_id, user_id, permission)
+			  VALUES (:calendar_id, :user_id, :permission)
+			  ON CONFLICT (calendar_id, user_id) DO UPDATE SET permission = EXCLUDED.permission`
 
-	rows, err := s.db.NamedQueryContext(ctx, query, map[string]interface{}{
-		"start_dt": date.Format(time.DateOnly),
+	_, err = s.db.NamedExecContext(ctx, query, map[string]interface{}{
+		"calendar_id": grant.CalendarID,
+		"user_id":     grant.UserID,
+		"permission":  string(grant.Permission),
 	})
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.ForeignKeyViolation {
+		return storage.ErrCalendarNotExists
+	}
+
+	return err
+}
+
+func (s *SQLStorage) DeleteCalendarGrant(ctx context.Context, calendarID string, userID int) (err error) {
+	ctx, span := startSpan(ctx, "DeleteCalendarGrant")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM public.calendar_grants WHERE calendar_id = $1 AND user_id = $2",
+		calendarID,
+		userID,
+	)
 	if err != nil {
-		return []storage.Event{}
+		return err
 	}
 
-	events := []storage.Event{}
-	for rows.Next() {
-		var event StorageEvent
-		err = rows.StructScan(&event)
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrCalendarGrantNotExists
+	}
+
+	return nil
+}
+
+func (s *SQLStorage) GetCalendarGrants(ctx context.Context, calendarID string) (_ []storage.CalendarGrant, err error) {
+	ctx, span := startSpan(ctx, "GetCalendarGrants")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
 
+	query := `SELECT calendar_id, user_id, permission
+			  FROM public.calendar_grants
+			  WHERE calendar_id = $1
+			  ORDER BY user_id`
+
+	rows, err := s.db.QueryxContext(ctx, query, calendarID)
+	if err != nil {
+		return nil, readError(err)
+	}
+	defer rows.Close()
+
+	grants := []storage.CalendarGrant{}
+	for rows.Next() {
+		var grant storage.CalendarGrant
+		err = rows.Scan(&grant.CalendarID, &grant.UserID, &grant.Permission)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		events = append(events, storage.Event{
-			ID:           event.ID,
-			CreatorID:    event.CreatorID,
-			Title:        event.Title,
-			Description:  event.Description,
-			StartDate:    event.StartDate,
-			EndDate:      event.EndDate,
-			NotifyBefore: event.NotifyBefore,
-		})
+
+		grants = append(grants, grant)
 	}
-	return events
+
+	return grants, readError(rows.Err())
 }
 
-func (s *SQLStorage) GetEventsOnWeek(ctx context.Context, weekStartDate time.Time) []storage.Event {
+func (s *SQLStorage) SaveTag(ctx context.Context, tag storage.Tag) (err error) {
+	ctx, span := startSpan(ctx, "SaveTag")
+	defer tracing.End(span, &err)
+
 	if s.db == nil {
-		return []storage.Event{}
+		return unavailable(ErrDBNotConnected)
 	}
 
-	query := `SELECT id, creator_id, title, description, start_dt, end_dt, notify_before
-			  FROM public.events
-			  where start_dt >= :week_start_dt and end_dt <= :week_end_dt`
-
-	weekEndDate := weekStartDate.Add(time.Hour * 24 * 6)
+	query := `INSERT INTO public.tags (owner_id, name, color)
+			  VALUES (:owner_id, :name, :color)
+			  ON CONFLICT (owner_id, name) DO UPDATE SET color = EXCLUDED.color`
 
-	rows, err := s.db.NamedQueryContext(ctx, query, map[string]interface{}{
-		"week_start_dt": weekStartDate,
-		"week_end_dt":   weekEndDate,
+	_, err = s.db.NamedExecContext(ctx, query, map[string]interface{}{
+		"owner_id": tag.OwnerID,
+		"name":     tag.Name,
+		"color":    tag.Color,
 	})
+
+	return err
+}
+
+func (s *SQLStorage) DeleteTag(ctx context.Context, ownerID int, name string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteTag")
+	defer tracing.End(span, &err)
+
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM public.tags WHERE owner_id = $1 AND name = $2", ownerID, name)
 	if err != nil {
-		return []storage.Event{}
+		return err
 	}
 
-	events := []storage.Event{}
-	for rows.Next() {
-		var event StorageEvent
-		err = rows.StructScan(&event)
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			continue
-		}
-		events = append(events, storage.Event{
-			ID:           event.ID,
-			CreatorID:    event.CreatorID,
-			Title:        event.Title,
-			Description:  event.Description,
-			StartDate:    event.StartDate,
-			EndDate:      event.EndDate,
-			NotifyBefore: event.NotifyBefore,
-		})
+	if affected == 0 {
+		return storage.ErrTagNotExists
 	}
-	return events
+
+	return nil
 }
 
-func (s *SQLStorage) GetEventsOnMonth(ctx context.Context, monthStartDate time.Time) []storage.Event {
+func (s *SQLStorage) GetUserTags(ctx context.Context, ownerID int) (_ []storage.Tag, err error) {
+	ctx, span := startSpan(ctx, "GetUserTags")
+	defer tracing.End(span, &err)
+
 	if s.db == nil {
-		return []storage.Event{}
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	rows := []StorageTag{}
+	err = s.read(ctx, func(db *sqlx.DB) error {
+		return readError(db.SelectContext(
+			ctx,
+			&rows,
+			"SELECT owner_id, name, color FROM public.tags WHERE owner_id = $1 ORDER BY name",
+			ownerID,
+		))
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	query := `SELECT id, creator_id, title, description, start_dt, end_dt, notify_before
+	tags := make([]storage.Tag, 0, len(rows))
+	for i := range rows {
+		tags = append(tags, storage.Tag{
+			OwnerID: rows[i].OwnerID,
+			Name:    rows[i].Name,
+			Color:   rows[i].Color,
+		})
+	}
+
+	return tags, nil
+}
+
+func getEventForUpdate(ctx context.Context, tx *sqlx.Tx, eventID string) (storage.Event, error) {
+	query := "SELECT " + eventColumns + `
 			  FROM public.events
-			  where start_dt >= :month_start_dt and end_dt <= :month_end_dt`
+			  WHERE id = $1
+			  FOR UPDATE`
 
-	monthEndDate := monthStartDate.AddDate(0, 1, -1)
+	var event StorageEvent
+	err := tx.GetContext(ctx, &event, query, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrReadEventNotExists
+	}
+	if err != nil {
+		return storage.Event{}, err
+	}
 
-	rows, err := s.db.NamedQueryContext(ctx, query, map[string]interface{}{
-		"month_start_dt": monthStartDate,
-		"month_end_dt":   monthEndDate,
+	return buildStorageEvent(event), nil
+}
+
+func addEventHistoryRecord(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	eventID string,
+	action storage.EventAction,
+	before *storage.Event,
+	after *storage.Event,
+) error {
+	record := storage.NewEventHistoryRecord(eventID, action, identity.UserID(ctx), time.Now().UTC(), before, after)
+
+	beforeData, err := marshalHistoryEvent(record.Before)
+	if err != nil {
+		return err
+	}
+
+	afterData, err := marshalHistoryEvent(record.After)
+	if err != nil {
+		return err
+	}
+
+	changes, err := json.Marshal(record.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO public.events_history (event_id, action, user_id, created_at, before_data, after_data, changes)
+			  VALUES (:event_id, :action, :user_id, :created_at, :before_data, :after_data, :changes)`
+
+	_, err = tx.NamedExecContext(ctx, query, map[string]interface{}{
+		"event_id":    record.EventID,
+		"action":      string(record.Action),
+		"user_id":     record.UserID,
+		"created_at":  record.CreatedAt,
+		"before_data": beforeData,
+		"after_data":  afterData,
+		"changes":     string(changes),
 	})
+
+	return err
+}
+
+func marshalHistoryEvent(event *storage.Event) (sql.NullString, error) {
+	if event == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := event.MarshalJSON()
 	if err != nil {
-		return []storage.Event{}
+		return sql.NullString{}, err
 	}
 
-	events := []storage.Event{}
-	for rows.Next() {
-		var event StorageEvent
-		err = rows.StructScan(&event)
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
 
-		if err != nil {
-			continue
-		}
-		events = append(events, storage.Event{
-			ID:           event.ID,
-			CreatorID:    event.CreatorID,
-			Title:        event.Title,
-			Description:  event.Description,
-			StartDate:    event.StartDate,
-			EndDate:      event.EndDate,
-			NotifyBefore: event.NotifyBefore,
-		})
+func unmarshalHistoryEvent(data sql.NullString) (*storage.Event, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+
+	event := &storage.Event{}
+	err := event.UnmarshalJSON([]byte(data.String))
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func buildEventHistoryRecord(row StorageEventHistoryRecord) (storage.EventHistoryRecord, error) {
+	before, err := unmarshalHistoryEvent(row.BeforeData)
+	if err != nil {
+		return storage.EventHistoryRecord{}, err
+	}
+
+	after, err := unmarshalHistoryEvent(row.AfterData)
+	if err != nil {
+		return storage.EventHistoryRecord{}, err
+	}
+
+	changes := []storage.EventChange{}
+	err = json.Unmarshal([]byte(row.Changes), &changes)
+	if err != nil {
+		return storage.EventHistoryRecord{}, err
 	}
-	return events
+
+	return storage.EventHistoryRecord{
+		ID:        row.ID,
+		EventID:   row.EventID,
+		Action:    storage.EventAction(row.Action),
+		UserID:    row.UserID,
+		CreatedAt: row.CreatedAt,
+		Before:    before,
+		After:     after,
+		Changes:   changes,
+	}, nil
 }
 
 func (s *SQLStorage) RemoveEvents(ctx context.Context) error {
 	if s.db == nil {
-		return ErrDBNotConnected
+		return unavailable(ErrDBNotConnected)
 	}
 
 	_, err := s.db.ExecContext(ctx, "DELETE FROM public.events")
@@ -399,5 +1265,20 @@ func (s *SQLStorage) RemoveEvents(ctx context.Context) error {
 		return err
 	}
 
+	_, err = s.db.ExecContext(ctx, "DELETE FROM public.calendars")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM public.events_history")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM public.tags")
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
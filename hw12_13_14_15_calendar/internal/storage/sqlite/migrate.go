@@ -0,0 +1,281 @@
+package sqlitestorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/migrations"
+)
+
+const schemaTable = "schema_migrations"
+
+type StorageMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator applies the SQLite migrations embedded into the binary.
+type Migrator struct {
+	path       string
+	db         *sqlx.DB
+	migrations []migrations.Migration
+}
+
+func NewMigrator(path string) (*Migrator, error) {
+	list, err := migrations.SQLite()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		path:       path,
+		migrations: list,
+	}, nil
+}
+
+func (m *Migrator) Connect(ctx context.Context) error {
+	db, err := open(ctx, m.path)
+	if err != nil {
+		return err
+	}
+
+	m.db = db
+	return nil
+}
+
+func (m *Migrator) Close() error {
+	if m.db == nil {
+		return nil
+	}
+
+	err := m.db.Close()
+	m.db = nil
+
+	return err
+}
+
+// Up applies all pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]migrations.Migration, error) {
+	applied := []migrations.Migration{}
+
+	err := m.withLock(ctx, func(tx *sqlx.Tx) error {
+		versions, err := appliedMigrations(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx, migration.Up)
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration.Name, err)
+			}
+
+			err = recordMigration(ctx, tx, migration)
+			if err != nil {
+				return err
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Baseline records the migrations up to the version as applied without running them and returns
+// the ones recorded, like the Postgres one does for the databases created before the migrations.
+func (m *Migrator) Baseline(ctx context.Context, version int) ([]migrations.Migration, error) {
+	list, err := migrations.UpTo(m.migrations, version)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := []migrations.Migration{}
+
+	err = m.withLock(ctx, func(tx *sqlx.Tx) error {
+		versions, err := appliedMigrations(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range list {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			if err := recordMigration(ctx, tx, migration); err != nil {
+				return err
+			}
+			recorded = append(recorded, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recorded, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]migrations.Migration, error) {
+	rolledBack := []migrations.Migration{}
+
+	err := m.withLock(ctx, func(tx *sqlx.Tx) error {
+		versions, err := appliedMigrations(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %s", migrations.ErrMigrationWithoutDown, migration.Name)
+			}
+
+			_, err = tx.ExecContext(ctx, migration.Down)
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", migration.Name, err)
+			}
+
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+schemaTable+" WHERE version = ?", migration.Version)
+			if err != nil {
+				return err
+			}
+
+			rolledBack = append(rolledBack, migration)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rolledBack, nil
+}
+
+// Status returns every known migration with its applied state.
+func (m *Migrator) Status(ctx context.Context) ([]migrations.Status, error) {
+	var statuses []migrations.Status
+
+	err := m.withLock(ctx, func(tx *sqlx.Tx) error {
+		versions, err := appliedMigrations(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]migrations.Status, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			applied, ok := versions[migration.Version]
+			statuses = append(statuses, migrations.Status{
+				Migration: migration,
+				Applied:   ok,
+				AppliedAt: applied.AppliedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// withLock runs fn in a single immediate transaction, which holds the database write lock,
+// so every migrations run is applied or rolled back as a whole.
+func (m *Migrator) withLock(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	if m.db == nil {
+		return ErrDBNotConnected
+	}
+
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+schemaTable+`(
+		version integer PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at datetime NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func recordMigration(ctx context.Context, tx *sqlx.Tx, migration migrations.Migration) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO "+schemaTable+" (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version,
+		migration.Name,
+		timeParam(time.Now()),
+	)
+	return err
+}
+
+func appliedMigrations(ctx context.Context, tx *sqlx.Tx) (map[int]StorageMigration, error) {
+	rows := []StorageMigration{}
+	err := tx.SelectContext(ctx, &rows, "SELECT version, name, applied_at FROM "+schemaTable)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]StorageMigration, len(rows))
+	for _, migration := range rows {
+		versions[migration.Version] = migration
+	}
+
+	return versions, nil
+}
+
+// checkSchema fails with migrations.ErrSchemaOutdated when any embedded migration is not applied.
+func checkSchema(ctx context.Context, db *sqlx.DB) error {
+	list, err := migrations.SQLite()
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	err = db.GetContext(ctx, &exists,
+		"SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?", schemaTable)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return migrations.ErrSchemaOutdated
+	}
+
+	var versions []int
+	err = db.SelectContext(ctx, &versions, "SELECT version FROM "+schemaTable)
+	if err != nil {
+		return err
+	}
+
+	return migrations.CheckApplied(list, versions)
+}
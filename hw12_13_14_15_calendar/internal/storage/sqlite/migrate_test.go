@@ -0,0 +1,103 @@
+package sqlitestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/migrations"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "calendar.db")
+
+	migrator, err := NewMigrator(path)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Connect(ctx))
+	defer migrator.Close()
+
+	store := New(path)
+	err = store.Connect(ctx)
+	require.ErrorIs(t, err, migrations.ErrSchemaOutdated)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, applied)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		require.True(t, status.Applied, status.Name)
+		require.False(t, status.AppliedAt.IsZero())
+	}
+
+	err = store.Connect(ctx)
+	require.NoError(t, err)
+	err = store.CreateEvent(ctx, storage.Event{ID: "1", Title: "Test", Tags: []string{"work"}})
+	require.NoError(t, err)
+	require.NoError(t, store.Close(ctx))
+
+	rolledBack, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, rolledBack, 1)
+	require.Equal(t, statuses[len(statuses)-1].Name, rolledBack[0].Name)
+
+	err = store.Connect(ctx)
+	require.ErrorIs(t, err, migrations.ErrSchemaOutdated)
+
+	rolledBack, err = migrator.Down(ctx, len(statuses))
+	require.NoError(t, err)
+	require.Len(t, rolledBack, len(statuses)-1)
+
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		require.False(t, status.Applied, status.Name)
+	}
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, len(statuses))
+
+	err = store.Connect(ctx)
+	require.NoError(t, err)
+	defer store.Close(ctx)
+
+	_, err = store.GetEvent(ctx, "1")
+	require.ErrorIs(t, err, storage.ErrReadEventNotExists)
+}
+
+func TestMigratorBaseline(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "calendar.db")
+
+	migrator, err := NewMigrator(path)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Connect(ctx))
+	defer migrator.Close()
+
+	_, err = migrator.Baseline(ctx, 0)
+	require.ErrorIs(t, err, migrations.ErrUnknownVersion)
+
+	recorded, err := migrator.Baseline(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, migrator.migrations[:2], recorded)
+
+	// The recorded migrations are kept.
+	recorded, err = migrator.Baseline(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, migrator.migrations[2:3], recorded)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for i, status := range statuses {
+		require.Equal(t, i < 3, status.Applied, status.Name)
+	}
+}
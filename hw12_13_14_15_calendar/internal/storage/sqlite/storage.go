@@ -0,0 +1,1146 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// timeFormat keeps the stored times in UTC with a fixed width, so they compare as strings.
+const timeFormat = "2006-01-02 15:04:05.000000000"
+
+// eventColumns are the columns StorageEvent is scanned from.
+const eventColumns = `id, calendar_id, creator_id, title, description, location, url, start_dt, end_dt,
+			  notify_before, notified, tags, created_at, updated_at`
+
+var ErrDBNotConnected = errors.New("not connected to database")
+
+type SQLiteStorage struct {
+	path string
+	mu   sync.Mutex
+	db   *sqlx.DB
+}
+
+type StorageEvent struct {
+	ID           string         `db:"id"`
+	CalendarID   sql.NullString `db:"calendar_id"`
+	Title        string         `db:"title"`
+	Description  string         `db:"description"`
+	Location     string         `db:"location"`
+	URL          string         `db:"url"`
+	StartDate    time.Time      `db:"start_dt"`
+	EndDate      time.Time      `db:"end_dt"`
+	CreatorID    int            `db:"creator_id"`
+	NotifyBefore time.Duration  `db:"notify_before"`
+	Notified     bool           `db:"notified"`
+	Tags         StorageTags    `db:"tags"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
+}
+
+// StorageTags scans the events tags stored as a json array.
+type StorageTags []string
+
+func (t *StorageTags) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	case nil:
+		*t = nil
+		return nil
+	default:
+		return errors.New("unsupported tags type")
+	}
+
+	tags := []string{}
+	err := json.Unmarshal(data, &tags)
+	if err != nil {
+		return err
+	}
+
+	*t = tags
+	return nil
+}
+
+type StorageTag struct {
+	OwnerID int    `db:"owner_id"`
+	Name    string `db:"name"`
+	Color   string `db:"color"`
+}
+
+type StorageCalendar struct {
+	ID         string         `db:"id"`
+	OwnerID    int            `db:"owner_id"`
+	Name       string         `db:"name"`
+	Permission sql.NullString `db:"permission"`
+}
+
+type StorageEventHistoryRecord struct {
+	ID         int64          `db:"id"`
+	EventID    string         `db:"event_id"`
+	Action     string         `db:"action"`
+	UserID     int            `db:"user_id"`
+	CreatedAt  time.Time      `db:"created_at"`
+	BeforeData sql.NullString `db:"before_data"`
+	AfterData  sql.NullString `db:"after_data"`
+	Changes    string         `db:"changes"`
+}
+
+func New(path string) *SQLiteStorage {
+	return &SQLiteStorage{
+		path: path,
+	}
+}
+
+func (s *SQLiteStorage) Connect(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db != nil {
+		// already connected
+		return nil
+	}
+
+	db, err := open(ctx, s.path)
+	if err != nil {
+		return err
+	}
+
+	err = checkSchema(ctx, db)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	return nil
+}
+
+// open connects to the database file. SQLite allows a single writer,
+// so the pool is limited to one connection.
+func open(ctx context.Context, path string) (*sqlx.DB, error) {
+	dsn := "file:" + path +
+		"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
+	db, err := sqlx.ConnectContext(ctx, "sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+func (s *SQLiteStorage) Close(_ context.Context) error {
+	err := s.db.Close()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.db = nil
+	return nil
+}
+
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStorage) CreateEvent(ctx context.Context, event storage.Event) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = createEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) UpdateEvent(ctx context.Context, eventID string, event storage.Event) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateEvent(ctx, tx, eventID, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) DeleteEvent(ctx context.Context, eventID string) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = deleteEvent(ctx, tx, eventID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ApplyBatch(
+	ctx context.Context,
+	mode storage.BatchMode,
+	operations []storage.BatchOperation,
+) ([]storage.BatchResult, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	if mode != storage.BatchModeAllOrNothing && mode != storage.BatchModeBestEffort {
+		return nil, storage.ErrBatchUnknownMode
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]storage.BatchResult, 0, len(operations))
+	failed := false
+
+	for i := range operations {
+		eventID := operations[i].EventID
+		if operations[i].Type == storage.BatchOperationCreate {
+			eventID = operations[i].Event.ID
+		}
+
+		if mode == storage.BatchModeAllOrNothing && failed {
+			results = append(results, storage.BatchResult{Index: i, EventID: eventID, Err: storage.ErrBatchAborted})
+			continue
+		}
+
+		// An operation writes the event and its history record,
+		// the savepoint drops both when one of them fails.
+		_, err = tx.ExecContext(ctx, "SAVEPOINT batch_operation")
+		if err != nil {
+			return nil, err
+		}
+
+		opErr := applyBatchOperation(ctx, tx, eventID, operations[i])
+		if opErr != nil {
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_operation")
+			if err == nil {
+				_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation")
+			}
+		} else {
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, storage.BatchResult{Index: i, EventID: eventID, Err: opErr})
+		failed = failed || opErr != nil
+	}
+
+	if mode == storage.BatchModeAllOrNothing && failed {
+		storage.AbortBatchResults(results)
+		return results, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func applyBatchOperation(ctx context.Context, tx *sqlx.Tx, eventID string, operation storage.BatchOperation) error {
+	switch operation.Type {
+	case storage.BatchOperationCreate:
+		return createEvent(ctx, tx, operation.Event)
+	case storage.BatchOperationUpdate:
+		return updateEvent(ctx, tx, eventID, operation.Event)
+	case storage.BatchOperationDelete:
+		return deleteEvent(ctx, tx, eventID)
+	default:
+		return storage.ErrBatchUnknownOperation
+	}
+}
+
+func createEvent(ctx context.Context, tx *sqlx.Tx, event storage.Event) error {
+	tags, err := tagsParam(event.Tags)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO events
+			   (id, calendar_id, creator_id, title, description, location, url, start_dt, end_dt, notify_before,
+			    notified, tags, created_at, updated_at)
+			   VALUES (:id, :calendar_id, :creator_id, :title, :description, :location, :url, :start_dt, :end_dt,
+			    :notify_before, :notified, :tags, :created_at, :updated_at)`
+
+	_, err = tx.NamedExecContext(ctx, query, map[string]interface{}{
+		"id":            event.ID,
+		"calendar_id":   nullString(event.CalendarID),
+		"creator_id":    event.CreatorID,
+		"title":         event.Title,
+		"description":   event.Description,
+		"location":      event.Location,
+		"url":           event.URL,
+		"start_dt":      timeParam(event.StartDate),
+		"end_dt":        timeParam(event.EndDate),
+		"notify_before": event.NotifyBefore,
+		"notified":      event.Notified,
+		"tags":          tags,
+		"created_at":    timeParam(event.CreatedAt),
+		"updated_at":    timeParam(event.UpdatedAt),
+	})
+
+	if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return storage.ErrCreateEventIDExists
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return addEventHistoryRecord(ctx, tx, event.ID, storage.EventActionCreate, nil, &event)
+}
+
+func updateEvent(ctx context.Context, tx *sqlx.Tx, eventID string, event storage.Event) error {
+	before, err := getEvent(ctx, tx, eventID)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		return storage.ErrUpdateEventIDNotExists
+	}
+	if err != nil {
+		return err
+	}
+
+	tags, err := tagsParam(event.Tags)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE events SET
+			   calendar_id = :calendar_id,
+			   creator_id = :creator_id,
+			   title = :title,
+			   description = :description,
+			   location = :location,
+			   url = :url,
+			   start_dt = :start_dt,
+			   end_dt = :end_dt,
+			   notify_before = :notify_before,
+			   notified = :notified,
+			   tags = :tags,
+			   updated_at = :updated_at
+			WHERE id = :event_id`
+
+	_, err = tx.NamedExecContext(ctx, query, map[string]interface{}{
+		"calendar_id":   nullString(event.CalendarID),
+		"creator_id":    event.CreatorID,
+		"title":         event.Title,
+		"description":   event.Description,
+		"location":      event.Location,
+		"url":           event.URL,
+		"start_dt":      timeParam(event.StartDate),
+		"end_dt":        timeParam(event.EndDate),
+		"notify_before": event.NotifyBefore,
+		"notified":      event.Notified,
+		"tags":          tags,
+		"updated_at":    timeParam(event.UpdatedAt),
+		"event_id":      eventID,
+	})
+	if err != nil {
+		return err
+	}
+
+	event.ID = eventID
+	event.CreatedAt = before.CreatedAt
+	return addEventHistoryRecord(ctx, tx, eventID, storage.EventActionUpdate, &before, &event)
+}
+
+func deleteEvent(ctx context.Context, tx *sqlx.Tx, eventID string) error {
+	before, err := getEvent(ctx, tx, eventID)
+	if errors.Is(err, storage.ErrReadEventNotExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM events WHERE id = ?", eventID)
+	if err != nil {
+		return err
+	}
+
+	return addEventHistoryRecord(ctx, tx, eventID, storage.EventActionDelete, &before, nil)
+}
+
+func (s *SQLiteStorage) GetEvent(ctx context.Context, eventID string) (storage.Event, error) {
+	if s.db == nil {
+		return storage.Event{}, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM events WHERE id = ?`
+
+	var event StorageEvent
+	err := s.db.GetContext(ctx, &event, query, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrReadEventNotExists
+	}
+	if err != nil {
+		return storage.Event{}, readError(err)
+	}
+
+	return buildStorageEvent(event), nil
+}
+
+func (s *SQLiteStorage) CountUserEvents(ctx context.Context, creatorID int) (int, error) {
+	if s.db == nil {
+		return 0, unavailable(ErrDBNotConnected)
+	}
+
+	var count int
+	err := s.db.GetContext(ctx, &count, "SELECT count(*) FROM events WHERE creator_id = ?", creatorID)
+	if err != nil {
+		return 0, readError(err)
+	}
+
+	return count, nil
+}
+
+func (s *SQLiteStorage) GetEventsListByDates(
+	ctx context.Context,
+	from *time.Time,
+	to *time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	params := map[string]interface{}{}
+
+	query := "SELECT " + eventColumns + `
+			  FROM events
+			  WHERE 1=1`
+
+	if from != nil {
+		query += " AND start_dt >= :from"
+		params["from"] = timeParam(*from)
+	}
+	if to != nil {
+		query += " AND end_dt <= :to"
+		params["to"] = timeParam(*to)
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLiteStorage) GetEventsForNotify(ctx context.Context, notifyDate string) ([]storage.Event, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM events
+			  WHERE date(start_dt, '-' || (notify_before / 1000000000) || ' seconds') = :notify_date
+			  AND notified IS FALSE`
+
+	return s.selectEvents(ctx, query, map[string]interface{}{
+		"notify_date": notifyDate,
+	})
+}
+
+func (s *SQLiteStorage) GetEventsOnDate(
+	ctx context.Context,
+	date time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM events
+			  WHERE date(start_dt) = :start_dt`
+
+	params := map[string]interface{}{
+		"start_dt": date.Format(time.DateOnly),
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLiteStorage) GetEventsOnWeek(
+	ctx context.Context,
+	weekStartDate time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM events
+			  WHERE start_dt >= :week_start_dt AND end_dt <= :week_end_dt`
+
+	weekEndDate := weekStartDate.Add(time.Hour * 24 * 6)
+
+	params := map[string]interface{}{
+		"week_start_dt": timeParam(weekStartDate),
+		"week_end_dt":   timeParam(weekEndDate),
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLiteStorage) GetEventsOnMonth(
+	ctx context.Context,
+	monthStartDate time.Time,
+	filter storage.EventFilter,
+) ([]storage.Event, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := "SELECT " + eventColumns + `
+			  FROM events
+			  WHERE start_dt >= :month_start_dt AND end_dt <= :month_end_dt`
+
+	monthEndDate := monthStartDate.AddDate(0, 1, -1)
+
+	params := map[string]interface{}{
+		"month_start_dt": timeParam(monthStartDate),
+		"month_end_dt":   timeParam(monthEndDate),
+	}
+
+	query = appendEventFilter(query, params, filter)
+	query = appendTagsFilter(query, params, filter)
+
+	return s.selectEvents(ctx, query, params)
+}
+
+func (s *SQLiteStorage) selectEvents(
+	ctx context.Context,
+	query string,
+	params map[string]interface{},
+) ([]storage.Event, error) {
+	rows, err := s.db.NamedQueryContext(ctx, query, params)
+	if err != nil {
+		return nil, readError(err)
+	}
+	defer rows.Close()
+
+	events := []storage.Event{}
+	for rows.Next() {
+		var event StorageEvent
+		err = rows.StructScan(&event)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, buildStorageEvent(event))
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, readError(err)
+	}
+
+	return events, nil
+}
+
+// readError marks the errors of a locked or unreadable database file with storage.ErrStorageUnavailable.
+func readError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *sqlite.Error
+	if !errors.As(err, &e) {
+		return unavailable(err)
+	}
+
+	switch e.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED, sqlite3.SQLITE_IOERR, sqlite3.SQLITE_CANTOPEN:
+		return unavailable(err)
+	default:
+		return err
+	}
+}
+
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", storage.ErrStorageUnavailable, err)
+}
+
+// appendEventFilter adds the calendar restriction, the ids are passed as a json array for json_each.
+func appendEventFilter(query string, params map[string]interface{}, filter storage.EventFilter) string {
+	if filter.CalendarIDs == nil {
+		return query
+	}
+
+	calendarIDs := make([]string, 0, len(filter.CalendarIDs))
+	withoutCalendar := false
+	for _, calendarID := range filter.CalendarIDs {
+		if calendarID == "" {
+			withoutCalendar = true
+			continue
+		}
+		calendarIDs = append(calendarIDs, calendarID)
+	}
+
+	data, _ := json.Marshal(calendarIDs)
+
+	withoutCalendarQuery := "calendar_id IS NULL AND :without_calendar"
+	if filter.CreatorID != nil {
+		withoutCalendarQuery += " AND creator_id = :filter_creator_id"
+		params["filter_creator_id"] = *filter.CreatorID
+	}
+
+	query += " AND (calendar_id IN (SELECT value FROM json_each(:calendar_ids))" +
+		" OR (" + withoutCalendarQuery + "))"
+	params["calendar_ids"] = string(data)
+	params["without_calendar"] = withoutCalendar
+
+	return query
+}
+
+func appendTagsFilter(query string, params map[string]interface{}, filter storage.EventFilter) string {
+	if len(filter.Tags) == 0 {
+		return query
+	}
+
+	data, _ := json.Marshal(filter.Tags)
+
+	query += " AND EXISTS (SELECT 1 FROM json_each(events.tags) t" +
+		" WHERE t.value IN (SELECT value FROM json_each(:filter_tags)))"
+	params["filter_tags"] = string(data)
+
+	return query
+}
+
+func tagsParam(tags []string) (string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func timeParam(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+func buildStorageEvent(event StorageEvent) storage.Event {
+	return storage.Event{
+		ID:           event.ID,
+		CalendarID:   event.CalendarID.String,
+		CreatorID:    event.CreatorID,
+		Title:        event.Title,
+		Description:  event.Description,
+		Location:     event.Location,
+		URL:          event.URL,
+		StartDate:    event.StartDate,
+		EndDate:      event.EndDate,
+		NotifyBefore: event.NotifyBefore,
+		Notified:     event.Notified,
+		Tags:         buildEventTags(event.Tags),
+		CreatedAt:    event.CreatedAt,
+		UpdatedAt:    event.UpdatedAt,
+	}
+}
+
+func buildEventTags(tags StorageTags) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return tags
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func isConstraintError(err error, code int) bool {
+	var e *sqlite.Error
+	return errors.As(err, &e) && e.Code() == code
+}
+
+func (s *SQLiteStorage) GetEventHistory(ctx context.Context, eventID string) ([]storage.EventHistoryRecord, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := `SELECT id, event_id, action, user_id, created_at, before_data, after_data, changes
+			  FROM events_history
+			  WHERE event_id = ?
+			  ORDER BY id`
+
+	rows := []StorageEventHistoryRecord{}
+	err := s.db.SelectContext(ctx, &rows, query, eventID)
+	if err != nil {
+		return nil, readError(err)
+	}
+
+	records := make([]storage.EventHistoryRecord, 0, len(rows))
+	for i := range rows {
+		record, err := buildEventHistoryRecord(rows[i])
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (s *SQLiteStorage) DeleteEventHistory(ctx context.Context, eventID string) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM events_history WHERE event_id = ?", eventID)
+
+	return err
+}
+
+func (s *SQLiteStorage) CreateCalendar(ctx context.Context, calendar storage.Calendar) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"INSERT INTO calendars (id, owner_id, name) VALUES (?, ?, ?)",
+		calendar.ID,
+		calendar.OwnerID,
+		calendar.Name,
+	)
+
+	if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return storage.ErrCreateCalendarIDExists
+	}
+
+	return err
+}
+
+func (s *SQLiteStorage) GetCalendar(ctx context.Context, calendarID string) (storage.Calendar, error) {
+	if s.db == nil {
+		return storage.Calendar{}, unavailable(ErrDBNotConnected)
+	}
+
+	var calendar StorageCalendar
+	err := s.db.GetContext(ctx, &calendar, "SELECT id, owner_id, name FROM calendars WHERE id = ?", calendarID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Calendar{}, storage.ErrCalendarNotExists
+	}
+	if err != nil {
+		return storage.Calendar{}, readError(err)
+	}
+
+	return storage.Calendar{
+		ID:      calendar.ID,
+		OwnerID: calendar.OwnerID,
+		Name:    calendar.Name,
+	}, nil
+}
+
+func (s *SQLiteStorage) GetUserCalendars(ctx context.Context, userID int) ([]storage.UserCalendar, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := `SELECT c.id, c.owner_id, c.name, g.permission
+			  FROM calendars c
+			  LEFT JOIN calendar_grants g ON g.calendar_id = c.id AND g.user_id = :user_id
+			  WHERE c.owner_id = :user_id OR g.user_id IS NOT NULL
+			  ORDER BY c.name`
+
+	query, args, err := sqlx.Named(query, map[string]interface{}{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []StorageCalendar{}
+	err = s.db.SelectContext(ctx, &rows, query, args...)
+	if err != nil {
+		return nil, readError(err)
+	}
+
+	calendars := make([]storage.UserCalendar, 0, len(rows))
+	for i := range rows {
+		permission := storage.CalendarPermission(rows[i].Permission.String)
+		if rows[i].OwnerID == userID {
+			permission = storage.CalendarPermissionOwner
+		}
+
+		calendars = append(calendars, storage.UserCalendar{
+			Calendar: storage.Calendar{
+				ID:      rows[i].ID,
+				OwnerID: rows[i].OwnerID,
+				Name:    rows[i].Name,
+			},
+			Permission: permission,
+		})
+	}
+
+	return calendars, nil
+}
+
+func (s *SQLiteStorage) DeleteCalendar(ctx context.Context, calendarID string) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	eventIDs := []string{}
+	err = tx.SelectContext(ctx, &eventIDs, "SELECT id FROM events WHERE calendar_id = ?", calendarID)
+	if err != nil {
+		return err
+	}
+
+	for i := range eventIDs {
+		err = deleteEvent(ctx, tx, eventIDs[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM calendars WHERE id = ?", calendarID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) SaveCalendarGrant(ctx context.Context, grant storage.CalendarGrant) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	query := `INSERT INTO calendar_grants (calendar_id, user_id, permission)
+			  VALUES (?, ?, ?)
+			  ON CONFLICT (calendar_id, user_id) DO UPDATE SET permission = excluded.permission`
+
+	_, err := s.db.ExecContext(ctx, query, grant.CalendarID, grant.UserID, string(grant.Permission))
+
+	if isConstraintError(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY) {
+		return storage.ErrCalendarNotExists
+	}
+
+	return err
+}
+
+func (s *SQLiteStorage) DeleteCalendarGrant(ctx context.Context, calendarID string, userID int) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	res, err := s.db.ExecContext(
+		ctx,
+		"DELETE FROM calendar_grants WHERE calendar_id = ? AND user_id = ?",
+		calendarID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrCalendarGrantNotExists
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) GetCalendarGrants(ctx context.Context, calendarID string) ([]storage.CalendarGrant, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	query := `SELECT calendar_id, user_id, permission
+			  FROM calendar_grants
+			  WHERE calendar_id = ?
+			  ORDER BY user_id`
+
+	rows, err := s.db.QueryxContext(ctx, query, calendarID)
+	if err != nil {
+		return nil, readError(err)
+	}
+	defer rows.Close()
+
+	grants := []storage.CalendarGrant{}
+	for rows.Next() {
+		var grant storage.CalendarGrant
+		err = rows.Scan(&grant.CalendarID, &grant.UserID, &grant.Permission)
+		if err != nil {
+			return nil, err
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return grants, readError(rows.Err())
+}
+
+func (s *SQLiteStorage) SaveTag(ctx context.Context, tag storage.Tag) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	query := `INSERT INTO tags (owner_id, name, color)
+			  VALUES (?, ?, ?)
+			  ON CONFLICT (owner_id, name) DO UPDATE SET color = excluded.color`
+
+	_, err := s.db.ExecContext(ctx, query, tag.OwnerID, tag.Name, tag.Color)
+
+	return err
+}
+
+func (s *SQLiteStorage) DeleteTag(ctx context.Context, ownerID int, name string) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM tags WHERE owner_id = ? AND name = ?", ownerID, name)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrTagNotExists
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) GetUserTags(ctx context.Context, ownerID int) ([]storage.Tag, error) {
+	if s.db == nil {
+		return nil, unavailable(ErrDBNotConnected)
+	}
+
+	rows := []StorageTag{}
+	err := s.db.SelectContext(
+		ctx,
+		&rows,
+		"SELECT owner_id, name, color FROM tags WHERE owner_id = ? ORDER BY name",
+		ownerID,
+	)
+	if err != nil {
+		return nil, readError(err)
+	}
+
+	tags := make([]storage.Tag, 0, len(rows))
+	for i := range rows {
+		tags = append(tags, storage.Tag{
+			OwnerID: rows[i].OwnerID,
+			Name:    rows[i].Name,
+			Color:   rows[i].Color,
+		})
+	}
+
+	return tags, nil
+}
+
+// getEvent reads the event inside the write transaction,
+// SQLite has no row locks and the immediate transaction already holds the database lock.
+func getEvent(ctx context.Context, tx *sqlx.Tx, eventID string) (storage.Event, error) {
+	query := "SELECT " + eventColumns + `
+			  FROM events
+			  WHERE id = ?`
+
+	var event StorageEvent
+	err := tx.GetContext(ctx, &event, query, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrReadEventNotExists
+	}
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	return buildStorageEvent(event), nil
+}
+
+func addEventHistoryRecord(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	eventID string,
+	action storage.EventAction,
+	before *storage.Event,
+	after *storage.Event,
+) error {
+	record := storage.NewEventHistoryRecord(eventID, action, identity.UserID(ctx), time.Now().UTC(), before, after)
+
+	beforeData, err := marshalHistoryEvent(record.Before)
+	if err != nil {
+		return err
+	}
+
+	afterData, err := marshalHistoryEvent(record.After)
+	if err != nil {
+		return err
+	}
+
+	changes, err := json.Marshal(record.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO events_history (event_id, action, user_id, created_at, before_data, after_data, changes)
+			  VALUES (:event_id, :action, :user_id, :created_at, :before_data, :after_data, :changes)`
+
+	_, err = tx.NamedExecContext(ctx, query, map[string]interface{}{
+		"event_id":    record.EventID,
+		"action":      string(record.Action),
+		"user_id":     record.UserID,
+		"created_at":  timeParam(record.CreatedAt),
+		"before_data": beforeData,
+		"after_data":  afterData,
+		"changes":     string(changes),
+	})
+
+	return err
+}
+
+func marshalHistoryEvent(event *storage.Event) (sql.NullString, error) {
+	if event == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := event.MarshalJSON()
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalHistoryEvent(data sql.NullString) (*storage.Event, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+
+	event := &storage.Event{}
+	err := event.UnmarshalJSON([]byte(data.String))
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func buildEventHistoryRecord(row StorageEventHistoryRecord) (storage.EventHistoryRecord, error) {
+	before, err := unmarshalHistoryEvent(row.BeforeData)
+	if err != nil {
+		return storage.EventHistoryRecord{}, err
+	}
+
+	after, err := unmarshalHistoryEvent(row.AfterData)
+	if err != nil {
+		return storage.EventHistoryRecord{}, err
+	}
+
+	changes := []storage.EventChange{}
+	err = json.Unmarshal([]byte(row.Changes), &changes)
+	if err != nil {
+		return storage.EventHistoryRecord{}, err
+	}
+
+	return storage.EventHistoryRecord{
+		ID:        row.ID,
+		EventID:   row.EventID,
+		Action:    storage.EventAction(row.Action),
+		UserID:    row.UserID,
+		CreatedAt: row.CreatedAt,
+		Before:    before,
+		After:     after,
+		Changes:   changes,
+	}, nil
+}
+
+func (s *SQLiteStorage) RemoveEvents(ctx context.Context) error {
+	if s.db == nil {
+		return unavailable(ErrDBNotConnected)
+	}
+
+	for _, table := range []string{"events", "calendars", "events_history", "tags"} {
+		_, err := s.db.ExecContext(ctx, "DELETE FROM "+table)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,815 @@
+package sqlitestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/identity"
+	"github.com/wursta/otus_go/hw12_13_14_15_calendar/internal/storage"
+)
+
+// newTestStorage returns a storage over a migrated database in the test temp dir.
+func newTestStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "calendar.db")
+
+	migrator, err := NewMigrator(path)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Connect(context.Background()))
+	defer migrator.Close()
+
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	return New(path)
+}
+
+func TestStoragePing(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	require.ErrorIs(t, store.Ping(ctx), ErrDBNotConnected)
+
+	require.NoError(t, store.Connect(ctx))
+	require.NoError(t, store.Ping(ctx))
+
+	require.NoError(t, store.Close(ctx))
+	require.ErrorIs(t, store.Ping(ctx), ErrDBNotConnected)
+}
+
+func TestStorageUnavailable(t *testing.T) {
+	store := newTestStorage(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Connect(ctx))
+	require.NoError(t, store.db.Close())
+
+	_, err := store.GetUserCalendars(ctx, 1)
+	require.ErrorIs(t, err, storage.ErrStorageUnavailable)
+	_, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{})
+	require.ErrorIs(t, err, storage.ErrStorageUnavailable)
+	_, err = store.GetEvent(ctx, "1")
+	require.ErrorIs(t, err, storage.ErrStorageUnavailable)
+
+	require.NoError(t, store.Close(ctx))
+
+	_, err = store.GetUserCalendars(ctx, 1)
+	require.ErrorIs(t, err, storage.ErrStorageUnavailable)
+	require.ErrorIs(t, err, ErrDBNotConnected)
+}
+
+func TestStorageCreate(t *testing.T) {
+	store := newTestStorage(t)
+
+	newEvent := storage.Event{
+		ID:           uuid.NewString(),
+		CreatorID:    1,
+		Title:        "Test",
+		Description:  "Test description",
+		Location:     "Room 1",
+		URL:          "https://meet.example.com/test",
+		StartDate:    time.Now().UTC(),
+		EndDate:      time.Now().Add(time.Hour * 24 * 5).UTC(),
+		NotifyBefore: time.Hour * 24 * 1,
+		CreatedAt:    time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+		UpdatedAt:    time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC),
+	}
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	err = store.CreateEvent(ctx, newEvent)
+	require.Nil(t, err)
+
+	savedEvent, err := store.GetEvent(ctx, newEvent.ID)
+	require.Nil(t, err)
+	require.Equal(t, newEvent.ID, savedEvent.ID)
+	require.Equal(t, newEvent.CreatorID, savedEvent.CreatorID)
+	require.Equal(t, newEvent.Title, savedEvent.Title)
+	require.Equal(t, newEvent.Description, savedEvent.Description)
+	require.Equal(t, newEvent.StartDate.Format(time.DateOnly), savedEvent.StartDate.Format(time.DateOnly))
+	require.Equal(t, newEvent.EndDate.Format(time.DateOnly), savedEvent.EndDate.Format(time.DateOnly))
+	require.Equal(t, newEvent.NotifyBefore, savedEvent.NotifyBefore)
+	require.Equal(t, newEvent.Location, savedEvent.Location)
+	require.Equal(t, newEvent.URL, savedEvent.URL)
+	require.False(t, savedEvent.Notified)
+	require.True(t, newEvent.CreatedAt.Equal(savedEvent.CreatedAt))
+	require.True(t, newEvent.UpdatedAt.Equal(savedEvent.UpdatedAt))
+
+	err = store.CreateEvent(ctx, newEvent)
+	require.Equal(t, storage.ErrCreateEventIDExists, err)
+}
+
+func TestStorageUpdate(t *testing.T) {
+	store := newTestStorage(t)
+
+	startDate := time.Now()
+	newEvent := storage.Event{
+		ID:           uuid.NewString(),
+		CreatorID:    1,
+		Title:        "Test",
+		Description:  "Test Description",
+		StartDate:    startDate,
+		EndDate:      startDate.Add(time.Duration(time.Duration.Hours(time.Hour * 24 * 6))),
+		NotifyBefore: time.Duration(time.Duration.Hours(time.Hour * 24 * 1)),
+	}
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	err = store.CreateEvent(ctx, newEvent)
+	if err != nil {
+		t.Fail()
+	}
+	newEvent.Title = "Test Test"
+	newEvent.Description = "Test Description Test"
+	newEvent.Location = "Room 2"
+	newEvent.URL = "https://meet.example.com/test"
+	newEvent.CreatedAt = time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	newEvent.UpdatedAt = time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	err = store.UpdateEvent(ctx, newEvent.ID, newEvent)
+	require.Nil(t, err)
+
+	savedEvent, err := store.GetEvent(ctx, newEvent.ID)
+	if err != nil {
+		t.Fail()
+	}
+
+	require.Equal(t, "Test Test", savedEvent.Title)
+	require.Equal(t, "Test Description Test", savedEvent.Description)
+	require.Equal(t, "Room 2", savedEvent.Location)
+	require.Equal(t, "https://meet.example.com/test", savedEvent.URL)
+	require.True(t, savedEvent.CreatedAt.IsZero(), "created_at is kept on update")
+	require.True(t, newEvent.UpdatedAt.Equal(savedEvent.UpdatedAt))
+}
+
+func TestStorageDelete(t *testing.T) {
+	store := newTestStorage(t)
+
+	events := []storage.Event{
+		{
+			ID:    uuid.NewString(),
+			Title: "Test",
+		},
+		{
+			ID:    uuid.NewString(),
+			Title: "Test 2",
+		},
+		{
+			ID:    uuid.NewString(),
+			Title: "Test 3",
+		},
+	}
+
+	ctx := context.Background()
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	for i := range events {
+		err = store.CreateEvent(ctx, events[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = store.GetEvent(ctx, events[1].ID)
+	require.Nil(t, err)
+
+	err = store.DeleteEvent(ctx, events[1].ID)
+	require.Nil(t, err)
+
+	_, err = store.GetEvent(ctx, events[1].ID)
+	require.NotNil(t, err)
+
+	err = store.DeleteEvent(ctx, events[1].ID)
+	require.Nil(t, err)
+}
+
+func TestStorageGet(t *testing.T) {
+	store := newTestStorage(t)
+
+	events := []storage.Event{
+		{
+			ID:    uuid.NewString(),
+			Title: "Test",
+		},
+		{
+			ID:    uuid.NewString(),
+			Title: "Test 2",
+		},
+		{
+			ID:    uuid.NewString(),
+			Title: "Test 3",
+		},
+	}
+
+	ctx := context.Background()
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	for i := range events {
+		err = store.CreateEvent(ctx, events[i])
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	for i := range events {
+		event, err := store.GetEvent(ctx, events[i].ID)
+		require.Nil(t, err)
+		require.Equal(t, event, events[i])
+	}
+
+	_, err = store.GetEvent(ctx, uuid.NewString())
+	require.Equal(t, storage.ErrReadEventNotExists, err)
+}
+
+func TestStorageCountUserEvents(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	for _, creatorID := range []int{1, 1, 2} {
+		err = store.CreateEvent(ctx, storage.Event{ID: uuid.NewString(), CreatorID: creatorID, Title: "Test"})
+		require.NoError(t, err)
+	}
+
+	for creatorID, expected := range map[int]int{1: 2, 2: 1, 3: 0} {
+		count, err := store.CountUserEvents(ctx, creatorID)
+		require.NoError(t, err)
+		require.Equal(t, expected, count)
+	}
+}
+
+func TestStorageGetEventsListByDates(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	uuid1 := uuid.NewString()
+	uuid2 := uuid.NewString()
+	uuid3 := uuid.NewString()
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-05-26")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-26")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid1,
+		Title:        "Test",
+		Description:  "Test Description",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Duration(time.Duration.Hours(time.Hour * 24 * 1)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startDate, _ = time.Parse(time.DateOnly, "2024-04-18")
+	endDate, _ = time.Parse(time.DateOnly, "2024-05-18")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid2,
+		Title:        "Test 2",
+		Description:  "Test Description 2",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Duration(time.Duration.Hours(time.Hour * 24 * 1)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startDate, _ = time.Parse(time.DateOnly, "2024-04-20")
+	endDate, _ = time.Parse(time.DateOnly, "2024-07-22")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid3,
+		Title:        "Test 3",
+		Description:  "Test Description 3",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Duration(time.Duration.Hours(time.Hour * 24 * 1)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromDate, _ := time.Parse(time.DateOnly, "2024-04-17")
+	toDate, _ := time.Parse(time.DateOnly, "2024-06-26")
+	events, err := store.GetEventsListByDates(ctx, &fromDate, &toDate, storage.EventFilter{})
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(events))
+	require.Equal(t, uuid1, events[0].ID)
+	require.Equal(t, uuid2, events[1].ID)
+
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(events))
+}
+
+func TestGetEventsOnDate(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	uuid1 := uuid.NewString()
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid1,
+		Title:        "Test",
+		Description:  "Test Description",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := store.GetEventsOnWeek(ctx, startDate, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, uuid1, events[0].ID)
+}
+
+func TestGetEventsOnWeek(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	uuid1 := uuid.NewString()
+	uuid2 := uuid.NewString()
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid1,
+		Title:        "Test",
+		Description:  "Test Description",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startDate, _ = time.Parse(time.DateOnly, "2024-06-05")
+	endDate, _ = time.Parse(time.DateOnly, "2024-06-12")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid2,
+		Title:        "Test 2",
+		Description:  "Test Description 2",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weekStartDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+
+	events, err := store.GetEventsOnWeek(ctx, weekStartDate, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, uuid1, events[0].ID)
+}
+
+func TestGetEventsOnMonth(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	uuid1 := uuid.NewString()
+	uuid2 := uuid.NewString()
+	uuid3 := uuid.NewString()
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid1,
+		Title:        "Test",
+		Description:  "Test Description",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startDate, _ = time.Parse(time.DateOnly, "2024-06-05")
+	endDate, _ = time.Parse(time.DateOnly, "2024-06-12")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid2,
+		Title:        "Test 2",
+		Description:  "Test Description 2",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startDate, _ = time.Parse(time.DateOnly, "2024-06-20")
+	endDate, _ = time.Parse(time.DateOnly, "2024-07-05")
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:           uuid3,
+		Title:        "Test 3",
+		Description:  "Test Description 3",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weekStartDate, _ := time.Parse(time.DateOnly, "2024-06-01")
+
+	events, err := store.GetEventsOnMonth(ctx, weekStartDate, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(events))
+	require.Equal(t, uuid1, events[0].ID)
+	require.Equal(t, uuid2, events[1].ID)
+}
+
+func TestStorageEventHistory(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := identity.WithUserID(context.Background(), 42)
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+	event := storage.Event{
+		ID:           uuid.NewString(),
+		Title:        "Test",
+		StartDate:    startDate,
+		EndDate:      endDate,
+		NotifyBefore: time.Hour * 24 * 1,
+	}
+
+	err = store.CreateEvent(ctx, event)
+	require.Nil(t, err)
+
+	event.Title = "Test Test"
+	err = store.UpdateEvent(ctx, event.ID, event)
+	require.Nil(t, err)
+
+	err = store.DeleteEvent(ctx, event.ID)
+	require.Nil(t, err)
+
+	history, err := store.GetEventHistory(ctx, event.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(history))
+
+	require.Equal(t, storage.EventActionCreate, history[0].Action)
+	require.Nil(t, history[0].Before)
+
+	require.Equal(t, storage.EventActionUpdate, history[1].Action)
+	require.Equal(t, 42, history[1].UserID)
+	require.Equal(t, []storage.EventChange{
+		{
+			Field:  "title",
+			Before: "Test",
+			After:  "Test Test",
+		},
+	}, history[1].Changes)
+
+	require.Equal(t, storage.EventActionDelete, history[2].Action)
+	require.Nil(t, history[2].After)
+
+	err = store.DeleteEventHistory(ctx, event.ID)
+	require.Nil(t, err)
+
+	history, err = store.GetEventHistory(ctx, event.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(history))
+}
+
+func TestStorageApplyBatch(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+
+	uuid1 := uuid.NewString()
+	uuid2 := uuid.NewString()
+
+	err = store.CreateEvent(ctx, storage.Event{ID: uuid1, Title: "Test", StartDate: startDate, EndDate: endDate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operations := []storage.BatchOperation{
+		{
+			Type:  storage.BatchOperationCreate,
+			Event: storage.Event{ID: uuid2, Title: "Test 2", StartDate: startDate, EndDate: endDate},
+		},
+		{
+			Type:  storage.BatchOperationCreate,
+			Event: storage.Event{ID: uuid1, Title: "Duplicate", StartDate: startDate, EndDate: endDate},
+		},
+	}
+
+	results, err := store.ApplyBatch(ctx, storage.BatchModeAllOrNothing, operations)
+	require.Nil(t, err)
+	require.Equal(t, storage.ErrBatchAborted, results[0].Err)
+	require.Equal(t, storage.ErrCreateEventIDExists, results[1].Err)
+
+	_, err = store.GetEvent(ctx, uuid2)
+	require.Equal(t, storage.ErrReadEventNotExists, err)
+
+	results, err = store.ApplyBatch(ctx, storage.BatchModeBestEffort, operations)
+	require.Nil(t, err)
+	require.Nil(t, results[0].Err)
+	require.Equal(t, storage.ErrCreateEventIDExists, results[1].Err)
+
+	_, err = store.GetEvent(ctx, uuid2)
+	require.Nil(t, err)
+}
+
+func TestStorageCalendars(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+
+	work := storage.Calendar{ID: uuid.NewString(), OwnerID: 1, Name: "Work"}
+	home := storage.Calendar{ID: uuid.NewString(), OwnerID: 2, Name: "Home"}
+
+	err = store.CreateCalendar(ctx, work)
+	require.Nil(t, err)
+
+	err = store.CreateCalendar(ctx, work)
+	require.Equal(t, storage.ErrCreateCalendarIDExists, err)
+
+	err = store.CreateCalendar(ctx, home)
+	require.Nil(t, err)
+
+	err = store.SaveCalendarGrant(ctx, storage.CalendarGrant{
+		CalendarID: home.ID,
+		UserID:     1,
+		Permission: storage.CalendarPermissionFreeBusy,
+	})
+	require.Nil(t, err)
+
+	err = store.SaveCalendarGrant(ctx, storage.CalendarGrant{
+		CalendarID: uuid.NewString(),
+		UserID:     1,
+		Permission: storage.CalendarPermissionRead,
+	})
+	require.Equal(t, storage.ErrCalendarNotExists, err)
+
+	calendars, err := store.GetUserCalendars(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, []storage.UserCalendar{
+		{Calendar: home, Permission: storage.CalendarPermissionFreeBusy},
+		{Calendar: work, Permission: storage.CalendarPermissionOwner},
+	}, calendars)
+
+	workEventID := uuid.NewString()
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:         workEventID,
+		CalendarID: work.ID,
+		StartDate:  startDate,
+		EndDate:    endDate,
+	})
+	require.Nil(t, err)
+
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:         uuid.NewString(),
+		CalendarID: home.ID,
+		StartDate:  startDate,
+		EndDate:    endDate,
+	})
+	require.Nil(t, err)
+
+	noCalendarEventID := uuid.NewString()
+	err = store.CreateEvent(ctx, storage.Event{ID: noCalendarEventID, StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+
+	events, err := store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{CalendarIDs: []string{work.ID}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, workEventID, events[0].ID)
+	require.Equal(t, work.ID, events[0].CalendarID)
+
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{CalendarIDs: []string{""}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, noCalendarEventID, events[0].ID)
+
+	// The events outside of any calendar are limited to the ones of the creator.
+	creatorID := 1
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{
+		CalendarIDs: []string{"", work.ID},
+		CreatorID:   &creatorID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, workEventID, events[0].ID)
+
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(events))
+
+	err = store.DeleteCalendarGrant(ctx, home.ID, 1)
+	require.Nil(t, err)
+
+	err = store.DeleteCalendarGrant(ctx, home.ID, 1)
+	require.Equal(t, storage.ErrCalendarGrantNotExists, err)
+
+	err = store.DeleteCalendar(ctx, work.ID)
+	require.Nil(t, err)
+
+	_, err = store.GetCalendar(ctx, work.ID)
+	require.Equal(t, storage.ErrCalendarNotExists, err)
+
+	_, err = store.GetEvent(ctx, workEventID)
+	require.Equal(t, storage.ErrReadEventNotExists, err)
+}
+
+func TestStorageTags(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx := context.Background()
+
+	err := store.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer store.Close(ctx)
+
+	defer store.RemoveEvents(ctx)
+
+	startDate, _ := time.Parse(time.DateOnly, "2024-06-03")
+	endDate, _ := time.Parse(time.DateOnly, "2024-06-05")
+
+	oncallEventID := uuid.NewString()
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:        oncallEventID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Tags:      []string{"oncall", "work"},
+	})
+	require.Nil(t, err)
+
+	err = store.CreateEvent(ctx, storage.Event{
+		ID:        uuid.NewString(),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Tags:      []string{"home"},
+	})
+	require.Nil(t, err)
+
+	err = store.CreateEvent(ctx, storage.Event{ID: uuid.NewString(), StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+
+	events, err := store.GetEventsOnDate(ctx, startDate, storage.EventFilter{Tags: []string{"oncall"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(events))
+	require.Equal(t, oncallEventID, events[0].ID)
+	require.Equal(t, []string{"oncall", "work"}, events[0].Tags)
+
+	events, err = store.GetEventsListByDates(ctx, nil, nil, storage.EventFilter{Tags: []string{"oncall", "home"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(events))
+
+	err = store.UpdateEvent(ctx, oncallEventID, storage.Event{StartDate: startDate, EndDate: endDate})
+	require.Nil(t, err)
+
+	events, err = store.GetEventsOnWeek(ctx, startDate, storage.EventFilter{Tags: []string{"oncall"}})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(events))
+
+	err = store.SaveTag(ctx, storage.Tag{OwnerID: 1, Name: "oncall", Color: "#ff0000"})
+	require.Nil(t, err)
+	err = store.SaveTag(ctx, storage.Tag{OwnerID: 1, Name: "home", Color: "#00ff00"})
+	require.Nil(t, err)
+	err = store.SaveTag(ctx, storage.Tag{OwnerID: 1, Name: "oncall", Color: "#0000ff"})
+	require.Nil(t, err)
+
+	tags, err := store.GetUserTags(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, []storage.Tag{
+		{OwnerID: 1, Name: "home", Color: "#00ff00"},
+		{OwnerID: 1, Name: "oncall", Color: "#0000ff"},
+	}, tags)
+
+	err = store.DeleteTag(ctx, 1, "home")
+	require.Nil(t, err)
+
+	err = store.DeleteTag(ctx, 1, "home")
+	require.Equal(t, storage.ErrTagNotExists, err)
+}
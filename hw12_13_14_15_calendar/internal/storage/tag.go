@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// MaxTagNameLength limits the length of a tag name in bytes.
+const MaxTagNameLength = 64
+
+var (
+	ErrTagNotExists    = errors.New("tag: passed name not exists")
+	ErrTagNameEmpty    = errors.New("tag: name is empty")
+	ErrTagNameTooLong  = errors.New("tag: name is too long")
+	ErrTagColorInvalid = errors.New("tag: color must be in #rrggbb format")
+)
+
+var tagColorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Tag is a user defined event category with its display color.
+//
+//easyjson:json
+type Tag struct {
+	OwnerID int    `json:"owner_id"`
+	Name    string `json:"name"`
+	Color   string `json:"color"`
+}
+
+//easyjson:json
+type Tags []Tag
+
+func ValidateTagColor(color string) error {
+	if !tagColorRegexp.MatchString(color) {
+		return ErrTagColorInvalid
+	}
+
+	return nil
+}
+
+// NormalizeTags trims the passed tag names and removes the empty and duplicated ones.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		if len(tag) > MaxTagNameLength {
+			return nil, ErrTagNameTooLong
+		}
+
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,248 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	ErrUnknownVersion = errors.New("tls: unknown version, expected 1.2 or 1.3")
+	ErrInvalidCA      = errors.New("tls: no certificates found in the CA file")
+	ErrKeyPairPartial = errors.New("tls: both the certificate and the key must be set")
+)
+
+// Config is the TLS of a server. It is off when CertFile is empty.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mTLS: the clients must present a certificate signed by one of its CAs.
+	ClientCAFile string
+	// MinVersion is 1.2 or 1.3, 1.2 is used when it is empty.
+	MinVersion string
+}
+
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// ClientConfig is the TLS of a client connection. The server certificate is checked against
+// the system roots, or against the CAs of CAFile when it is set. The client certificate is
+// sent only when CertFile and KeyFile are set.
+type ClientConfig struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name the server certificate is checked for.
+	ServerName string
+	MinVersion string
+}
+
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Server serves the certificate and the client CAs of the config, they are read again
+// by Watch when their files change, so the renewed certificates apply to the new connections
+// without a restart.
+type Server struct {
+	config     Config
+	minVersion uint16
+
+	mu      sync.RWMutex
+	current *tls.Config
+}
+
+func NewServer(config Config) (*Server, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, ErrKeyPairPartial
+	}
+
+	minVersion, err := parseVersion(config.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{config: config, minVersion: minVersion}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// TLSConfig returns the config to pass to the server, it picks the current certificate
+// and client CAs for every connection.
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: s.minVersion,
+		// GetCertificate only tells http.Server that the config has a certificate,
+		// the handshakes use the config of GetConfigForClient.
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &s.currentConfig().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.currentConfig(), nil
+		},
+	}
+}
+
+func (s *Server) currentConfig() *tls.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.current
+}
+
+func (s *Server) reload() error {
+	certificate, err := tls.LoadX509KeyPair(s.config.CertFile, s.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	current := &tls.Config{
+		MinVersion:   s.minVersion,
+		Certificates: []tls.Certificate{certificate},
+		// The config replaces the one of the server, which has the protocols set by HTTP/2 and gRPC.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if s.config.ClientCAFile != "" {
+		current.ClientCAs, err = loadCertPool(s.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		current.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	s.mu.Lock()
+	s.current = current
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the files when they change until ctx is done. The directories of the files
+// are watched rather than the files, as the certificates mounted from the Kubernetes
+// secrets are replaced by swapping a symlink. When the new files are invalid the previous
+// certificate is kept.
+func (s *Server) Watch(ctx context.Context, logg Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	dirs := map[string]struct{}{}
+	for _, file := range []string{s.config.CertFile, s.config.KeyFile, s.config.ClientCAFile} {
+		if file != "" {
+			dirs[filepath.Dir(file)] = struct{}{}
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+
+			if err := s.reload(); err != nil {
+				logg.Error("tls certificate reload failed", "file", event.Name, "error", err)
+				continue
+			}
+			logg.Info("tls certificate reloaded", "file", event.Name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logg.Error("tls certificate watch failed", "error", err)
+		}
+	}
+}
+
+// NewClient returns the config of a client connection. The client certificate is read
+// on every handshake, so a reconnect picks the renewed one.
+func NewClient(config ClientConfig) (*tls.Config, error) {
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, ErrKeyPairPartial
+	}
+
+	minVersion, err := parseVersion(config.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: minVersion,
+		ServerName: config.ServerName,
+	}
+
+	if config.CAFile != "" {
+		tlsConfig.RootCAs, err = loadCertPool(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.CertFile != "" {
+		// The pair is checked once here to fail on start rather than on the first connection.
+		if _, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile); err != nil {
+			return nil, fmt.Errorf("load key pair: %w", err)
+		}
+
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("load key pair: %w", err)
+			}
+
+			return &certificate, nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCA, file)
+	}
+
+	return pool, nil
+}
+
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, ErrUnknownVersion
+	}
+}
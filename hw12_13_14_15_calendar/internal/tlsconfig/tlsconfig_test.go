@@ -0,0 +1,219 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(string, ...any)  {}
+func (testLogger) Error(string, ...any) {}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T, dir, name string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	file := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+
+	return testCA{cert: cert, key: key, file: file}
+}
+
+// issue writes the certificate and the key of the common name signed by the CA to the dir.
+func (ca testCA) issue(t *testing.T, dir, name string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+
+	return certFile, keyFile
+}
+
+// handshake returns the serial number of the certificate the server presented to the client.
+func handshake(t *testing.T, server *tls.Config, client *tls.Config) (int64, error) {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), client)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// The TLS 1.3 client learns that the server rejected its certificate on the first read.
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		return 0, err
+	}
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	clientCert, clientKey := ca.issue(t, dir, "client", 3)
+
+	server, err := NewServer(Config{
+		CertFile:     serverCert,
+		KeyFile:      serverKey,
+		ClientCAFile: ca.file,
+		MinVersion:   "1.3",
+	})
+	require.NoError(t, err)
+
+	client, err := NewClient(ClientConfig{
+		CAFile:     ca.file,
+		CertFile:   clientCert,
+		KeyFile:    clientKey,
+		ServerName: "localhost",
+	})
+	require.NoError(t, err)
+
+	serial, err := handshake(t, server.TLSConfig(), client)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), serial)
+
+	withoutCert, err := NewClient(ClientConfig{CAFile: ca.file, ServerName: "localhost"})
+	require.NoError(t, err)
+	_, err = handshake(t, server.TLSConfig(), withoutCert)
+	require.Error(t, err)
+
+	otherCA := newTestCA(t, t.TempDir(), "other")
+	untrusted, err := NewClient(ClientConfig{CAFile: otherCA.file, ServerName: "localhost"})
+	require.NoError(t, err)
+	_, err = handshake(t, server.TLSConfig(), untrusted)
+	require.Error(t, err)
+}
+
+func TestServerWatch(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", 2)
+
+	server, err := NewServer(Config{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watching := make(chan error, 1)
+	go func() {
+		watching <- server.Watch(ctx, testLogger{})
+	}()
+
+	client, err := NewClient(ClientConfig{CAFile: ca.file, ServerName: "localhost"})
+	require.NoError(t, err)
+
+	serial, err := handshake(t, server.TLSConfig(), client)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), serial)
+
+	// The renewed certificate is served to the new connections.
+	require.Eventually(t, func() bool {
+		ca.issue(t, dir, "server", 3)
+		serial, err := handshake(t, server.TLSConfig(), client)
+		return err == nil && serial == 3
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// An invalid file keeps the previous certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	time.Sleep(100 * time.Millisecond)
+	serial, err = handshake(t, server.TLSConfig(), client)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), serial)
+
+	cancel()
+	require.NoError(t, <-watching)
+}
+
+func TestConfigFail(t *testing.T) {
+	_, err := NewServer(Config{CertFile: "server.crt"})
+	require.ErrorIs(t, err, ErrKeyPairPartial)
+
+	_, err = NewServer(Config{CertFile: "server.crt", KeyFile: "server.key", MinVersion: "1.1"})
+	require.ErrorIs(t, err, ErrUnknownVersion)
+
+	_, err = NewClient(ClientConfig{CertFile: "client.crt"})
+	require.ErrorIs(t, err, ErrKeyPairPartial)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+	_, err = NewClient(ClientConfig{CAFile: caFile})
+	require.ErrorIs(t, err, ErrInvalidCA)
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		for _, exporter := range []string{"", ExporterNone} {
+			shutdown, err := Setup(context.Background(), "calendar", Config{Exporter: exporter})
+			require.NoError(t, err)
+			require.NoError(t, shutdown(context.Background()))
+		}
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), "calendar", Config{Exporter: ExporterStdout})
+		require.NoError(t, err)
+		require.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), "calendar", Config{Exporter: "zipkin"})
+		require.ErrorIs(t, err, ErrUnknownExporter)
+	})
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	traced := func(fail bool) (err error) {
+		_, span := tracer.Start(context.Background(), "traced")
+		defer End(span, &err)
+
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	require.NoError(t, traced(false))
+	require.Error(t, traced(true))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, codes.Unset, spans[0].Status().Code)
+	require.Empty(t, spans[0].Events())
+	require.Equal(t, codes.Error, spans[1].Status().Code)
+	require.Equal(t, "failed", spans[1].Status().Description)
+	require.Len(t, spans[1].Events(), 1)
+}
@@ -0,0 +1 @@
+DROP TABLE public.events
\ No newline at end of file
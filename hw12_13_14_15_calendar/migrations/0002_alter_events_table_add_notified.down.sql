@@ -0,0 +1 @@
+ALTER TABLE public.events DROP COLUMN notified
\ No newline at end of file
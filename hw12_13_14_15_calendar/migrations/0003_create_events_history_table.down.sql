@@ -0,0 +1 @@
+DROP TABLE public.events_history;
\ No newline at end of file
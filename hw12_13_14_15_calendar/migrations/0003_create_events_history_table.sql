@@ -0,0 +1,12 @@
+CREATE TABLE public.events_history(
+    id bigserial PRIMARY KEY,
+    event_id uuid NOT NULL,
+    action varchar(16) NOT NULL,
+    user_id int NOT NULL,
+    created_at timestamp NOT NULL,
+    before_data jsonb NULL,
+    after_data jsonb NULL,
+    changes jsonb NOT NULL
+);
+
+CREATE INDEX events_history_event_id_idx ON public.events_history (event_id, id);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE public.events DROP COLUMN calendar_id;
+
+DROP TABLE public.calendar_grants;
+
+DROP TABLE public.calendars;
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE public.calendars(
+    id uuid PRIMARY KEY,
+    owner_id int NOT NULL,
+    name varchar(255) NOT NULL
+);
+
+CREATE INDEX calendars_owner_id_idx ON public.calendars (owner_id);
+
+CREATE TABLE public.calendar_grants(
+    calendar_id uuid NOT NULL REFERENCES public.calendars (id) ON DELETE CASCADE,
+    user_id int NOT NULL,
+    permission varchar(16) NOT NULL,
+    PRIMARY KEY (calendar_id, user_id)
+);
+
+CREATE INDEX calendar_grants_user_id_idx ON public.calendar_grants (user_id);
+
+ALTER TABLE public.events ADD COLUMN calendar_id uuid NULL REFERENCES public.calendars (id);
+
+CREATE INDEX events_calendar_id_idx ON public.events (calendar_id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE public.tags;
+
+ALTER TABLE public.events DROP COLUMN tags;
\ No newline at end of file
@@ -0,0 +1,10 @@
+ALTER TABLE public.events ADD COLUMN tags text[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX events_tags_idx ON public.events USING GIN (tags);
+
+CREATE TABLE public.tags(
+    owner_id int NOT NULL,
+    name varchar(64) NOT NULL,
+    color varchar(7) NOT NULL,
+    PRIMARY KEY (owner_id, name)
+);
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE public.events DROP COLUMN updated_at;
+
+ALTER TABLE public.events DROP COLUMN created_at;
+
+ALTER TABLE public.events DROP COLUMN url;
+
+ALTER TABLE public.events DROP COLUMN location;
\ No newline at end of file
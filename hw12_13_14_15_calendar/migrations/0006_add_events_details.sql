@@ -0,0 +1,7 @@
+ALTER TABLE public.events ADD COLUMN location varchar(255) NOT NULL DEFAULT '';
+
+ALTER TABLE public.events ADD COLUMN url text NOT NULL DEFAULT '';
+
+ALTER TABLE public.events ADD COLUMN created_at timestamp NOT NULL DEFAULT now();
+
+ALTER TABLE public.events ADD COLUMN updated_at timestamp NOT NULL DEFAULT now();
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX public.events_creator_id_idx;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX events_creator_id_idx ON public.events (creator_id);
\ No newline at end of file
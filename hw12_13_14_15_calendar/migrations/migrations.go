@@ -0,0 +1,154 @@
+// Package migrations embeds the SQL schema migrations of the calendar database.
+//
+// Each migration is a NNNN_name.sql file with an optional NNNN_name.down.sql rollback file.
+// Postgres migrations live in the package root, SQLite ones in the sqlite directory
+// and keep the same versions.
+//
+// The databases created before the migrations runner, by the postgres image running the files
+// from docker-entrypoint-initdb.d, have the tables but no record of the migrations. They are
+// upgraded by `calendar migrate baseline <version>`, which records the migrations up to the
+// version as applied without running them, and then `calendar migrate up`, see README.md.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	downSuffix = ".down.sql"
+	upSuffix   = ".sql"
+)
+
+var (
+	ErrSchemaOutdated       = errors.New("database schema is out of date, run `calendar migrate up`")
+	ErrInvalidMigrationName = errors.New("invalid migration file name")
+	ErrDuplicateMigration   = errors.New("duplicate migration version")
+	ErrMigrationWithoutUp   = errors.New("migration has no up file")
+	ErrMigrationWithoutDown = errors.New("migration has no down file")
+	ErrUnknownVersion       = errors.New("unknown migration version")
+	ErrSchemaNotBaselined   = errors.New(
+		"database tables were created without migrations, run `calendar migrate baseline <version>` first",
+	)
+)
+
+//go:embed *.sql
+var postgresFS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func Postgres() ([]Migration, error) {
+	return Load(postgresFS, ".")
+}
+
+func SQLite() ([]Migration, error) {
+	return Load(sqliteFS, "sqlite")
+}
+
+// Load reads NNNN_name.sql and NNNN_name.down.sql files of dir ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Base(file)
+		isDown := strings.HasSuffix(name, downSuffix)
+		name = strings.TrimSuffix(strings.TrimSuffix(name, downSuffix), upSuffix)
+
+		versionPart, title, ok := strings.Cut(name, "_")
+		if !ok || title == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationName, file)
+		}
+
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationName, file)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != name {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateMigration, version)
+		}
+
+		if isDown {
+			migration.Down = string(data)
+		} else {
+			migration.Up = string(data)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationWithoutUp, migration.Name)
+		}
+		list = append(list, *migration)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return list, nil
+}
+
+// CheckApplied fails with ErrSchemaOutdated when any of the migrations is not in the applied versions.
+func CheckApplied(list []Migration, versions []int) error {
+	applied := make(map[int]struct{}, len(versions))
+	for _, version := range versions {
+		applied[version] = struct{}{}
+	}
+
+	for _, migration := range list {
+		if _, ok := applied[migration.Version]; !ok {
+			return fmt.Errorf("%w: %s is not applied", ErrSchemaOutdated, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+// UpTo returns the migrations of the list up to the version, which must be one of them.
+func UpTo(list []Migration, version int) ([]Migration, error) {
+	for i, migration := range list {
+		if migration.Version == version {
+			return list[:i+1], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+}
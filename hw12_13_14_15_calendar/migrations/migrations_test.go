@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("embedded", func(t *testing.T) {
+		postgres, err := Postgres()
+		require.NoError(t, err)
+		require.NotEmpty(t, postgres)
+
+		sqlite, err := SQLite()
+		require.NoError(t, err)
+		require.Len(t, sqlite, len(postgres))
+
+		for i := range postgres {
+			require.Equal(t, i+1, postgres[i].Version)
+			require.NotEmpty(t, postgres[i].Down, postgres[i].Name)
+			require.Equal(t, postgres[i].Name, sqlite[i].Name)
+			require.NotEmpty(t, sqlite[i].Down, sqlite[i].Name)
+		}
+	})
+
+	t.Run("ordered by version", func(t *testing.T) {
+		list, err := Load(fstest.MapFS{
+			"0010_second.sql":     {Data: []byte("SELECT 2")},
+			"0002_first.sql":      {Data: []byte("SELECT 1")},
+			"0002_first.down.sql": {Data: []byte("SELECT -1")},
+			"README.md":           {Data: []byte("ignored")},
+		}, ".")
+		require.NoError(t, err)
+		require.Equal(t, []Migration{
+			{Version: 2, Name: "0002_first", Up: "SELECT 1", Down: "SELECT -1"},
+			{Version: 10, Name: "0010_second", Up: "SELECT 2"},
+		}, list)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Load(fstest.MapFS{"first.sql": {Data: []byte("SELECT 1")}}, ".")
+		require.ErrorIs(t, err, ErrInvalidMigrationName)
+
+		_, err = Load(fstest.MapFS{
+			"0001_first.sql":  {Data: []byte("SELECT 1")},
+			"0001_second.sql": {Data: []byte("SELECT 2")},
+		}, ".")
+		require.ErrorIs(t, err, ErrDuplicateMigration)
+
+		_, err = Load(fstest.MapFS{"0001_first.down.sql": {Data: []byte("SELECT 1")}}, ".")
+		require.ErrorIs(t, err, ErrMigrationWithoutUp)
+	})
+}
+
+func TestUpTo(t *testing.T) {
+	list := []Migration{{Version: 1}, {Version: 2}, {Version: 5}}
+
+	upTo, err := UpTo(list, 2)
+	require.NoError(t, err)
+	require.Equal(t, []Migration{{Version: 1}, {Version: 2}}, upTo)
+
+	_, err = UpTo(list, 3)
+	require.ErrorIs(t, err, ErrUnknownVersion)
+}
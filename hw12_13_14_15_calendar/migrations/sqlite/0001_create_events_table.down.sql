@@ -0,0 +1 @@
+DROP TABLE events
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE events(
+    id text PRIMARY KEY,
+    creator_id integer NOT NULL,
+    title varchar(255) NOT NULL,
+    description text NOT NULL,
+    start_dt datetime NOT NULL,
+    end_dt datetime NOT NULL,
+    notify_before integer NOT NULL
+)
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE events DROP COLUMN notified
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN notified boolean NOT NULL DEFAULT false
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE events_history;
\ No newline at end of file
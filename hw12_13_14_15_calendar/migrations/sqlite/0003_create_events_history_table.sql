@@ -0,0 +1,12 @@
+CREATE TABLE events_history(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    event_id text NOT NULL,
+    action varchar(16) NOT NULL,
+    user_id integer NOT NULL,
+    created_at datetime NOT NULL,
+    before_data text NULL,
+    after_data text NULL,
+    changes text NOT NULL
+);
+
+CREATE INDEX events_history_event_id_idx ON events_history (event_id, id);
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE events_rollback(
+    id text PRIMARY KEY,
+    creator_id integer NOT NULL,
+    title varchar(255) NOT NULL,
+    description text NOT NULL,
+    start_dt datetime NOT NULL,
+    end_dt datetime NOT NULL,
+    notify_before integer NOT NULL,
+    notified boolean NOT NULL DEFAULT false
+);
+
+INSERT INTO events_rollback
+SELECT id, creator_id, title, description, start_dt, end_dt, notify_before, notified FROM events;
+
+DROP TABLE events;
+
+ALTER TABLE events_rollback RENAME TO events;
+
+DROP TABLE calendar_grants;
+
+DROP TABLE calendars;
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE calendars(
+    id text PRIMARY KEY,
+    owner_id integer NOT NULL,
+    name varchar(255) NOT NULL
+);
+
+CREATE INDEX calendars_owner_id_idx ON calendars (owner_id);
+
+CREATE TABLE calendar_grants(
+    calendar_id text NOT NULL REFERENCES calendars (id) ON DELETE CASCADE,
+    user_id integer NOT NULL,
+    permission varchar(16) NOT NULL,
+    PRIMARY KEY (calendar_id, user_id)
+);
+
+CREATE INDEX calendar_grants_user_id_idx ON calendar_grants (user_id);
+
+ALTER TABLE events ADD COLUMN calendar_id text NULL REFERENCES calendars (id);
+
+CREATE INDEX events_calendar_id_idx ON events (calendar_id);
\ No newline at end of file
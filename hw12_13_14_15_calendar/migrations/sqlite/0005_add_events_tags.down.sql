@@ -0,0 +1,3 @@
+DROP TABLE tags;
+
+ALTER TABLE events DROP COLUMN tags;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE events ADD COLUMN tags text NOT NULL DEFAULT '[]';
+
+CREATE TABLE tags(
+    owner_id integer NOT NULL,
+    name varchar(64) NOT NULL,
+    color varchar(7) NOT NULL,
+    PRIMARY KEY (owner_id, name)
+);
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE events DROP COLUMN updated_at;
+
+ALTER TABLE events DROP COLUMN created_at;
+
+ALTER TABLE events DROP COLUMN url;
+
+ALTER TABLE events DROP COLUMN location;
\ No newline at end of file
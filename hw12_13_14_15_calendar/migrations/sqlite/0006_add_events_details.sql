@@ -0,0 +1,12 @@
+ALTER TABLE events ADD COLUMN location varchar(255) NOT NULL DEFAULT '';
+
+ALTER TABLE events ADD COLUMN url text NOT NULL DEFAULT '';
+
+-- SQLite does not allow a non-constant default for an added column
+ALTER TABLE events ADD COLUMN created_at datetime NOT NULL DEFAULT '';
+
+ALTER TABLE events ADD COLUMN updated_at datetime NOT NULL DEFAULT '';
+
+UPDATE events SET
+    created_at = strftime('%Y-%m-%d %H:%M:%f', 'now') || '000000',
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') || '000000';
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX events_creator_id_idx;
\ No newline at end of file